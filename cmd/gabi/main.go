@@ -1,23 +1,49 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
 	"github.com/app-sre/gabi/pkg/cmd"
+	"github.com/app-sre/gabi/pkg/env/logging"
+	"github.com/app-sre/gabi/pkg/logger"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"go.uber.org/zap"
 )
 
 func main() {
-	l, err := zap.NewDevelopment()
+	le := logging.NewLoggingEnv()
+	if err := le.Populate(); err != nil {
+		log.Fatalf("Unable to configure logging: %s", err)
+	}
+
+	level := zap.NewAtomicLevelAt(le.Level)
+
+	l, err := logger.New(le, level)
 	if err != nil {
 		log.Fatalf("Unable to initialize Zap logger: %s", err)
 	}
 	defer func() { _ = l.Sync() }()
 
-	logger := l.Sugar()
-	if err := cmd.Run(logger); err != nil {
-		logger.Fatalf("Unable to start GABI: %s", err)
+	sugar := l.Sugar()
+	if len(os.Args) > 1 && os.Args[1] == "self-test" {
+		if err := cmd.SelfTest(sugar); err != nil {
+			sugar.Fatalf("Unable to complete self-test: %s", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		if err := cmd.VerifyAudit(sugar, os.Args[2:]); err != nil {
+			sugar.Fatalf("Unable to verify audit: %s", err)
+		}
+		return
+	}
+
+	logger.WatchLevel(context.Background(), sugar, level)
+	if err := cmd.Run(sugar); err != nil {
+		sugar.Fatalf("Unable to start GABI: %s", err)
 	}
 }
@@ -2,8 +2,11 @@ package test
 
 import (
 	"io"
+	"net"
 	"os"
 
+	"github.com/app-sre/gabi/pkg/env/proxy"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -20,3 +23,16 @@ func DummyLogger(w io.Writer) *zap.Logger {
 
 	return l
 }
+
+// ProxyEnv returns the proxy environment trusting the peers of requests made
+// using httptest, whether recorded or sent to a test server, without which no
+// forwarding headers are honored.
+func ProxyEnv() *proxy.Env {
+	pe := proxy.NewProxyEnv()
+	pe.TrustedProxies = []*net.IPNet{
+		{IP: net.IP{192, 0, 2, 0}, Mask: net.CIDRMask(24, 32)},
+		{IP: net.IP{127, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+		{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	}
+	return pe
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	channelHeader = "X-Splunk-Request-Channel"
+
+	// How often the acknowledgement endpoint is polled for indexed events.
+	ackPollInterval = 1 * time.Second
+)
+
+func (d *SplunkAudit) setChannel(req *http.Request) {
+	if d.SplunkEnv.Channel != "" {
+		req.Header.Set(channelHeader, d.SplunkEnv.Channel)
+	}
+}
+
+// Acknowledgements are polled for until the event has been indexed, or the
+// timeout has passed, in which case the event might still be indexed later
+// on, and as such could be delivered more than once when retried.
+func (d *SplunkAudit) acknowledge(ctx context.Context, endpoint string, id int64) error {
+	url, err := collectorURL(endpoint, "/services/collector/ack")
+	if err != nil {
+		return fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+
+	content, err := json.Marshal(map[string][]int64{"acks": {id}})
+	if err != nil {
+		return fmt.Errorf("unable to marshal Splunk acknowledgement request: %w", err)
+	}
+
+	timeout := d.SplunkEnv.AckTimeout
+	if timeout <= 0 {
+		timeout = requestTimeout
+	}
+
+	clock := d.currentClock()
+	deadline := clock.Now().Add(timeout)
+
+	for {
+		acked, err := d.pollAck(ctx, d.httpClient(endpoint), url, content, id)
+		if err != nil {
+			return err
+		}
+		if acked {
+			return nil
+		}
+		if !clock.Now().Before(deadline) {
+			return failure(FailureConnectivity, fmt.Errorf("unable to confirm Splunk acknowledgement: event not indexed within %s", timeout))
+		}
+		select {
+		case <-ctx.Done():
+			return failure(FailureConnectivity, fmt.Errorf("unable to confirm Splunk acknowledgement: %w", ctx.Err()))
+		case <-clock.After(ackPollInterval):
+		}
+	}
+}
+
+func (d *SplunkAudit) pollAck(ctx context.Context, client *http.Client, url string, content []byte, id int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(content))
+	if err != nil {
+		return false, fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+	if err := d.authorize(req); err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", d.UserAgent())
+	d.setChannel(req)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, failure(FailureConnectivity, fmt.Errorf("unable to send request to Splunk: %w", err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, failure(FailureConnectivity, fmt.Errorf("unable to read Splunk response body: %w", err))
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err := fmt.Errorf("unable to confirm Splunk acknowledgement: %s: %s", resp.Status, responseSnippet(body))
+		return false, failure(statusReason(resp.StatusCode), err)
+	}
+
+	splunk := struct {
+		Acks map[string]bool `json:"acks"`
+	}{}
+
+	if err := json.Unmarshal(body, &splunk); err != nil {
+		return false, failure(FailureRejection, fmt.Errorf("unable to unmarshal Splunk response: %w", err))
+	}
+
+	return splunk.Acks[strconv.FormatInt(id, 10)], nil
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/app-sre/gabi/pkg/env/splunk"
+)
+
+const testChannel = "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d"
+
+func ackServer(t *testing.T, indexedAfter int32) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var polls int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, testChannel, r.Header.Get("X-Splunk-Request-Channel"))
+
+		switch r.URL.Path {
+		case "/services/collector/event":
+			fmt.Fprintln(w, `{"text":"Success","code":0,"ackId":7}`)
+		case "/services/collector/ack":
+			acks := struct {
+				Acks []int64 `json:"acks"`
+			}{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&acks))
+			assert.Equal(t, []int64{7}, acks.Acks)
+
+			n := atomic.AddInt32(&polls, 1)
+			fmt.Fprintf(w, `{"acks":{"7":%t}}`, indexedAfter > 0 && n >= indexedAfter)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &polls
+}
+
+func writeWithClock(s *SplunkAudit, clock *FakeClock) error {
+	done := make(chan error, 1)
+	go func() { done <- s.Write(&QueryData{Query: "select 1;", User: "test"}) }()
+
+	for {
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+		if clock.Waiters() > 0 {
+			clock.Advance(ackPollInterval)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSplunkAuditWriteAck(t *testing.T) {
+	t.Parallel()
+
+	server, polls := ackServer(t, 3)
+	clock := NewFakeClock(time.Now())
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Channel: testChannel, Ack: true, AckTimeout: time.Minute},
+		WithHTTPClient(http.DefaultClient), WithClock(clock))
+	require.NoError(t, err)
+
+	require.NoError(t, writeWithClock(s, clock))
+	assert.Equal(t, int32(3), atomic.LoadInt32(polls))
+}
+
+func TestSplunkAuditWriteAckTimeout(t *testing.T) {
+	t.Parallel()
+
+	server, polls := ackServer(t, 0)
+	clock := NewFakeClock(time.Now())
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Channel: testChannel, Ack: true, AckTimeout: 5 * time.Second},
+		WithHTTPClient(http.DefaultClient), WithClock(clock))
+	require.NoError(t, err)
+
+	err = writeWithClock(s, clock)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to confirm Splunk acknowledgement: event not indexed within 5s")
+	assert.Equal(t, FailureConnectivity, Reason(err))
+	assert.Equal(t, int32(6), atomic.LoadInt32(polls))
+}
+
+func TestSplunkAuditWriteAckMissingID(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"text":"Success","code":0}`)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Channel: testChannel, Ack: true}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	err = s.Write(&QueryData{Query: "select 1;", User: "test"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no acknowledgement ID returned")
+	assert.Equal(t, FailureRejection, Reason(err))
+}
@@ -1,13 +1,389 @@
 package audit
 
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/multierr"
+)
+
+const queryHashLength = 16
+
+// How much of a database error is included in the audit.
+const maxQueryErrorLength = 256
+
+const RedactedArg = "REDACTED"
+
+// Limits of the tags clients can attach to their queries.
+const (
+	maxTags           = 10
+	maxTagValueLength = 256
+)
+
+var tagNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,63}$`)
+
+// The types of lifecycle events, audited next to queries.
+const (
+	EventStartup    = "startup"
+	EventShutdown   = "shutdown"
+	EventReload     = "config_reload"
+	EventExpiration = "expiration_reached"
+)
+
+// The decisions taken about requests, telling queries allowed to run apart from
+// requests denied by policy, such as the query policy or limits, from those
+// denied for lack of authentication or authorization, and from errors.
+const (
+	DecisionAllowed      = "allowed"
+	DecisionDeniedPolicy = "denied_policy"
+	DecisionDeniedAuth   = "denied_auth"
+	DecisionError        = "error"
+)
+
+// The phases of a query audited in two phases, with the event preceding the
+// query telling that it was started, and the outcome whether it completed.
+const (
+	PhaseStarted   = "started"
+	PhaseCompleted = "completed"
+	PhaseFailed    = "failed"
+)
+
+// The class of errors of queries canceled as the client disconnected, rather
+// than failed in the database.
+const ErrorClassClientCanceled = "client_canceled"
+
+// QueryData describes an audited query, with the Timestamp given as seconds
+// since the Unix epoch. RequestID correlates the events audited for the same
+// request. Executed is set once the query has run, in which case Success,
+// Error and Partial describe its outcome, with ErrorClass naming the class of
+// errors reported by the database, when known, or telling that the client
+// canceled the query. Decision tells whether the query was allowed, or
+// denied, and why, and is unset on the event preceding the query, which might
+// still be refused. Override holds the reason the query would have been
+// refused for, when the client forced it through regardless. Phase is only
+// set when queries are audited in two phases. SampleRate is only set when the
+// query was subject to sampling, with Sampled holding the decision.
+// PostExpiry is set for queries served during the grace period following the
+// expiration date, and ReadOnly for queries run in a read-only transaction.
+// Columns holds the columns of the results, when these are audited, Masked
+// the columns whose values were masked in the results, and StatusCode and
+// ResponseBytes the status code and size of the response, once written.
+// AuthProvider names the authentication provider having identified the user.
+// Fields are static fields added to every audit event, other than reserved
+// ones, together with the tags supplied by the client, if any. Signature and
+// PreviousSignature are set when events are signed. EventType is only set for
+// lifecycle events of the service, rather than queries, with Detail
+// describing the event.
 type QueryData struct {
-	Query     string
-	User      string
-	Namespace string
-	Pod       string
-	Timestamp int64
+	Query         string
+	User          string
+	AuthProvider  string
+	Decision      string
+	Database      string
+	DatabaseHost  string
+	ClientIP      string
+	Namespace     string
+	Pod           string
+	Timestamp     int64
+	RequestID     string
+	Rejection     string
+	Override      string
+	Phase         string
+	Args          []string
+	CacheHit      bool
+	Executed      bool
+	Success       bool
+	Error         string
+	ErrorClass    string
+	Partial       bool
+	Timeout       time.Duration
+	SampleRate    float64
+	Sampled       bool
+	PostExpiry    bool
+	ReadOnly      bool
+	Columns       []string
+	Masked        []string
+	StatusCode    int
+	ResponseBytes int64
+	Fields        map[string]string
+
+	EventType string
+	Detail    string
+
+	Signature         string
+	PreviousSignature string
+}
+
+// The names of the fields making up audit events, which static fields can never
+// replace.
+var reservedFields = map[string]struct{}{
+	"query":          {},
+	"query_hash":     {},
+	"user":           {},
+	"auth_provider":  {},
+	"decision":       {},
+	"database":       {},
+	"database_host":  {},
+	"client_ip":      {},
+	"namespace":      {},
+	"pod":            {},
+	"timestamp":      {},
+	"request_id":     {},
+	"rejection":      {},
+	"override":       {},
+	"phase":          {},
+	"args":           {},
+	"cache_hit":      {},
+	"success":        {},
+	"error":          {},
+	"error_class":    {},
+	"partial":        {},
+	"timeout_ms":     {},
+	"sample_rate":    {},
+	"sampled":        {},
+	"post_expiry":    {},
+	"read_only":      {},
+	"columns":        {},
+	"masked_columns": {},
+	"status_code":    {},
+	"response_bytes": {},
+	"event_type":     {},
+	"detail":         {},
+
+	"signature":          {},
+	"previous_signature": {},
+}
+
+// IsReservedField reports whether the name belongs to one of the fields making
+// up audit events.
+func IsReservedField(name string) bool {
+	_, ok := reservedFields[strings.ToLower(name)]
+	return ok
+}
+
+// ValidateFieldNames verifies that the names given to audit fields, mapped
+// from their canonical names, only rename known fields, and that no two fields
+// end up sharing the same name.
+func ValidateFieldNames(names map[string]string) error {
+	fields := make([]string, 0, len(reservedFields))
+	for field := range reservedFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for field, name := range names {
+		if _, ok := reservedFields[field]; !ok {
+			return fmt.Errorf("unknown audit field: %s", field)
+		}
+		if name == "" {
+			return fmt.Errorf("empty name for audit field: %s", field)
+		}
+	}
+
+	seen := make(map[string]string, len(fields))
+	for _, field := range fields {
+		name := field
+		if n, ok := names[field]; ok {
+			name = n
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("audit fields %s and %s cannot share the same name: %s", other, field, name)
+		}
+		seen[name] = field
+	}
+
+	return nil
+}
+
+// ValidateTags verifies that the tags supplied by a client with its query are
+// within limits, and that none of them replaces an audit field, whether under
+// its canonical name or the name it is renamed to, or a static field.
+func ValidateTags(tags, fields, names map[string]string) error {
+	if len(tags) > maxTags {
+		return fmt.Errorf("too many tags: %d (maximum: %d)", len(tags), maxTags)
+	}
+
+	renamed := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		renamed[strings.ToLower(name)] = struct{}{}
+	}
+
+	for name, value := range tags {
+		if !tagNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid tag name: %q", name)
+		}
+		if _, ok := renamed[strings.ToLower(name)]; ok || IsReservedField(name) {
+			return fmt.Errorf("tag cannot replace audit field: %s", name)
+		}
+		if _, ok := fields[name]; ok {
+			return fmt.Errorf("tag cannot replace static field: %s", name)
+		}
+		if len(value) > maxTagValueLength || !utf8.ValidString(value) {
+			return fmt.Errorf("invalid value for tag: %s", name)
+		}
+	}
+
+	return nil
+}
+
+// StaticFields returns the names of the static fields that can be added to the
+// audit event, in sorted order, leaving out reserved ones.
+func (q *QueryData) StaticFields() []string {
+	names := make([]string, 0, len(q.Fields))
+	for name := range q.Fields {
+		if !IsReservedField(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
 }
 
 type Audit interface {
 	Write(*QueryData) error
 }
+
+// ContextWriter is implemented by audit backends whose writes can be bounded,
+// or canceled, using a context.
+type ContextWriter interface {
+	WriteContext(context.Context, *QueryData) error
+}
+
+// WriteContext writes the audit data to the backend, bounded by the context
+// when the backend supports it, or as usual otherwise.
+func WriteContext(ctx context.Context, a Audit, q *QueryData) error {
+	if w, ok := a.(ContextWriter); ok {
+		return w.WriteContext(ctx, q)
+	}
+	return a.Write(q)
+}
+
+// Previewer is implemented by audit backends that can encode audit data as
+// these would send it, without sending it.
+type Previewer interface {
+	Preview(*QueryData) ([]byte, error)
+}
+
+// Preview returns the audit data encoded as the backend would send it, or as
+// the attributes sent to Splunk for backends that do not implement Previewer.
+func Preview(a Audit, q *QueryData) ([]byte, error) {
+	if p, ok := a.(Previewer); ok {
+		return p.Preview(q)
+	}
+	return json.Marshal(newEventData(q, q.Namespace, q.Pod, nil))
+}
+
+// Checker is implemented by audit backends that can verify whether they are
+// reachable, without writing any audit data.
+type Checker interface {
+	Check(context.Context) error
+}
+
+// Flusher is implemented by audit backends that buffer audit data, allowing
+// it to be flushed before the service exits.
+type Flusher interface {
+	Flush(context.Context) error
+}
+
+// Shutdown flushes any audit data still buffered by the backend, and then
+// releases its resources, for backends implementing Flusher or io.Closer
+// respectively. Other backends are left as they are.
+func Shutdown(ctx context.Context, a Audit) error {
+	var err error
+	if f, ok := a.(Flusher); ok {
+		err = multierr.Append(err, f.Flush(ctx))
+	}
+	if c, ok := a.(io.Closer); ok {
+		err = multierr.Append(err, c.Close())
+	}
+	return err
+}
+
+// QueryHash returns a short, stable fingerprint of the query that can be
+// logged in place of the query itself.
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])[:queryHashLength]
+}
+
+// Sampled reports whether the query is audited at the given sampling rate.
+// The decision is derived from the query itself, thus repeated identical
+// queries are consistently either sampled or skipped.
+func Sampled(query string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	sum := sha256.Sum256([]byte(query))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < rate
+}
+
+// QueryArgs returns the query arguments as they should be audited, where each
+// value is redacted unless explicitly included.
+func QueryArgs(args []interface{}, include bool) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	s := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !include {
+			s = append(s, RedactedArg)
+			continue
+		}
+		if arg == nil {
+			s = append(s, "NULL")
+			continue
+		}
+		s = append(s, fmt.Sprint(arg))
+	}
+	return s
+}
+
+// PartialError reports that reading the results of a query failed at the given
+// row, after the rows before it were read.
+type PartialError struct {
+	Row int
+	Err error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("unable to read row %d: %s", e.Row, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// QueryError returns the database error as it should be audited, where only
+// the first line of the message is kept, as any details that follow can
+// contain data from the database, and the message is truncated.
+func QueryError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	s := strings.TrimSpace(err.Error())
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	if len(s) <= maxQueryErrorLength {
+		return s
+	}
+
+	s = s[:maxQueryErrorLength]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
@@ -0,0 +1,262 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHash(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "354b7196c9ba5fb4", QueryHash("select 1;"))
+	assert.Equal(t, "e3b0c44298fc1c14", QueryHash(""))
+}
+
+func TestQueryArgs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []interface{}
+		include     bool
+		expected    []string
+	}{
+		{
+			"no arguments",
+			nil,
+			true,
+			nil,
+		},
+		{
+			"arguments redacted",
+			[]interface{}{float64(1), "test", nil},
+			false,
+			[]string{"REDACTED", "REDACTED", "REDACTED"},
+		},
+		{
+			"arguments included",
+			[]interface{}{float64(1), "test", true, nil},
+			true,
+			[]string{"1", "test", "true", "NULL"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := QueryArgs(tc.given, tc.include)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		expected    string
+	}{
+		{
+			"no error",
+			nil,
+			"",
+		},
+		{
+			"single line error",
+			errors.New(`ERROR: relation "test" does not exist (SQLSTATE 42P01)`),
+			`ERROR: relation "test" does not exist (SQLSTATE 42P01)`,
+		},
+		{
+			"error with details",
+			errors.New("ERROR: duplicate key value violates unique constraint\nDETAIL: Key (id)=(1) already exists."),
+			"ERROR: duplicate key value violates unique constraint",
+		},
+		{
+			"error too long",
+			errors.New(strings.Repeat("a", 300)),
+			strings.Repeat("a", 256) + "...",
+		},
+		{
+			"error too long with multi-byte characters",
+			errors.New(strings.Repeat("a", 255) + "ü"),
+			strings.Repeat("a", 255) + "...",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := QueryError(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestSampled(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, Sampled("select 1;", 1))
+	assert.False(t, Sampled("select 1;", 0.000001))
+
+	// The decision is the same for the same query.
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, Sampled("select 1;", 0.5), Sampled("select 1;", 0.5))
+	}
+
+	// Roughly the given fraction of distinct queries is sampled.
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if Sampled(fmt.Sprintf("select %d;", i), 0.1) {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+}
+
+type fakeLifecycle struct {
+	fakeAudit
+	flushErr error
+	closeErr error
+	calls    []string
+}
+
+func (f *fakeLifecycle) Flush(ctx context.Context) error {
+	f.calls = append(f.calls, "flush")
+	return f.flushErr
+}
+
+func (f *fakeLifecycle) Close() error {
+	f.calls = append(f.calls, "close")
+	return f.closeErr
+}
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	t.Run("backend without flush or close", func(t *testing.T) {
+		t.Parallel()
+
+		require.NoError(t, Shutdown(context.Background(), &fakeAudit{}))
+	})
+
+	t.Run("backend flushed before closing", func(t *testing.T) {
+		t.Parallel()
+
+		a := &fakeLifecycle{}
+
+		require.NoError(t, Shutdown(context.Background(), a))
+		assert.Equal(t, []string{"flush", "close"}, a.calls)
+	})
+
+	t.Run("backend closed despite failed flush", func(t *testing.T) {
+		t.Parallel()
+
+		a := &fakeLifecycle{flushErr: errors.New("flush"), closeErr: errors.New("close")}
+
+		err := Shutdown(context.Background(), a)
+		require.Error(t, err)
+		assert.Equal(t, "flush; close", err.Error())
+		assert.Equal(t, []string{"flush", "close"}, a.calls)
+	})
+
+	t.Run("wrapped backend flushed and closed", func(t *testing.T) {
+		t.Parallel()
+
+		a := &fakeLifecycle{}
+
+		require.NoError(t, Shutdown(context.Background(), NewCircuitBreaker(a, 3, time.Second)))
+		assert.Equal(t, []string{"flush", "close"}, a.calls)
+	})
+}
+
+func TestPreview(t *testing.T) {
+	t.Parallel()
+
+	q := &QueryData{Query: "select 1;", User: "test", Timestamp: 1672531200}
+
+	t.Run("backend without preview", func(t *testing.T) {
+		t.Parallel()
+
+		content, err := Preview(&fakeAudit{}, q)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"query":"select 1;","user":"test","namespace":"","pod":""}`, string(content))
+	})
+
+	t.Run("wrapped backend previewed unsigned", func(t *testing.T) {
+		t.Parallel()
+
+		var b strings.Builder
+		a := NewSigner(NewCircuitBreaker(NewStreamAudit(&b, "", "", nil), 3, time.Second), []byte("key"), "test", "gabi-1")
+
+		content, err := Preview(a, q)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"time":1672531200,"event":{"query":"select 1;","user":"test","namespace":"test","pod":"gabi-1"}}`, string(content))
+		assert.Empty(t, b.String())
+		assert.Empty(t, q.Namespace)
+	})
+}
+
+func TestIsReservedField(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, IsReservedField("user"))
+	assert.True(t, IsReservedField("Query"))
+	assert.True(t, IsReservedField("query_hash"))
+	assert.False(t, IsReservedField("team"))
+}
+
+func TestStaticFields(t *testing.T) {
+	t.Parallel()
+
+	q := &QueryData{Fields: map[string]string{"team": "sre", "environment": "prod", "user": "admin"}}
+
+	assert.Equal(t, []string{"environment", "team"}, q.StaticFields())
+	assert.Empty(t, (&QueryData{}).StaticFields())
+}
+
+func TestValidateFieldNames(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       map[string]string
+		want        string
+	}{
+		{"no field names", nil, ``},
+		{"renamed fields", map[string]string{"query": "sql", "user": "actor"}, ``},
+		{"swapped fields", map[string]string{"query": "user", "user": "query"}, ``},
+		{"unknown field", map[string]string{"team": "squad"}, `unknown audit field: team`},
+		{"empty name", map[string]string{"query": ""}, `empty name for audit field: query`},
+		{"two fields with the same name", map[string]string{"query": "sql", "user": "sql"}, `audit fields query and user cannot share the same name: sql`},
+		{"field renamed to another field", map[string]string{"query": "user"}, `audit fields query and user cannot share the same name: user`},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateFieldNames(tc.given)
+			if tc.want == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.want)
+		})
+	}
+}
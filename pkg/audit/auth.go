@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are refreshed this long before these expire, so that a request is
+// never sent with a token that expires while in flight.
+const tokenExpiryDelta = 30 * time.Second
+
+// TokenSource provides bearer tokens attached to requests sent to an audit
+// backend, in place of a static token.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// ClientCredentials is a TokenSource using the OAuth2 client credentials
+// grant, which caches the token obtained until shortly before it expires.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	client *http.Client
+	clock  Clock
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+var _ TokenSource = (*ClientCredentials)(nil)
+
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes []string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		client:       &http.Client{Timeout: requestTimeout},
+		clock:        SystemClock,
+	}
+}
+
+func (c *ClientCredentials) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && (c.expiry.IsZero() || c.clock.Now().Before(c.expiry.Add(-tokenExpiryDelta))) {
+		return c.token, nil
+	}
+
+	form := url.Values{"grant_type": []string{"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("unable to create token request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to send token request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to obtain token: %s: %s", resp.Status, responseSnippet(body))
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return "", fmt.Errorf("unable to unmarshal token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("unable to obtain token: no access token in response")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", fmt.Errorf("unable to obtain token: unsupported token type: %s", token.TokenType)
+	}
+
+	c.token = token.AccessToken
+	c.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.expiry = c.clock.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return c.token, nil
+}
@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCredentialsToken(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		handler     func(w http.ResponseWriter, r *http.Request)
+		error       bool
+		want        string
+	}{
+		{
+			"valid token response",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"access_token":"test123","token_type":"Bearer","expires_in":3600}`)
+			},
+			false,
+			`test123`,
+		},
+		{
+			"error status in token response",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprintln(w, `{"error":"invalid_client"}`)
+			},
+			true,
+			`unable to obtain token: 401 Unauthorized: {"error":"invalid_client"}`,
+		},
+		{
+			"missing access token in response",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"token_type":"Bearer"}`)
+			},
+			true,
+			`unable to obtain token: no access token in response`,
+		},
+		{
+			"unsupported token type in response",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"access_token":"test123","token_type":"mac"}`)
+			},
+			true,
+			`unable to obtain token: unsupported token type: mac`,
+		},
+		{
+			"malformed JSON in token response",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"access_token:"test123"}`)
+			},
+			true,
+			`unable to unmarshal token response`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(tc.handler))
+			defer server.Close()
+
+			c := NewClientCredentials(server.URL, "test", "secret", nil)
+			token, err := c.Token(context.Background())
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, token)
+			}
+		})
+	}
+}
+
+func TestClientCredentialsRefresh(t *testing.T) {
+	t.Parallel()
+
+	var (
+		requests int
+		form     string
+		username string
+		password string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_ = r.ParseForm()
+		form = r.PostForm.Encode()
+		username, password, _ = r.BasicAuth()
+		fmt.Fprintf(w, `{"access_token":"test%d","token_type":"bearer","expires_in":60}`, requests)
+	}))
+	defer server.Close()
+
+	clock := NewFakeClock(time.Now())
+
+	c := NewClientCredentials(server.URL, "test", "secret", []string{"audit.write", "audit.read"})
+	c.clock = clock
+
+	token, err := c.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test1", token)
+	assert.Equal(t, "grant_type=client_credentials&scope=audit.write+audit.read", form)
+	assert.Equal(t, "test", username)
+	assert.Equal(t, "secret", password)
+
+	// The cached token is used until shortly before it expires.
+	clock.Advance(20 * time.Second)
+	token, err = c.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test1", token)
+
+	clock.Advance(10 * time.Second)
+	token, err = c.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "test2", token)
+	assert.Equal(t, 2, requests)
+}
+
+type staticTokens string
+
+func (s staticTokens) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+func TestWithTokenSource(t *testing.T) {
+	t.Parallel()
+
+	var header string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("Authorization")
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Token: "test123"},
+		WithHTTPClient(http.DefaultClient),
+		WithTokenSource(staticTokens("test456")),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	assert.Equal(t, "Bearer test456", header)
+
+	require.NoError(t, s.Check(context.Background()))
+	assert.Equal(t, "Bearer test456", header)
+}
@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/datadog"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+)
+
+// NewBackend returns the audit backend selected using the AUDIT_BACKEND
+// environment variable. The Splunk and Datadog settings only apply to their
+// respective backend, which cannot be used without them, while the options
+// given only apply to the Splunk backend.
+func NewBackend(logger *zap.SugaredLogger, ae *auditing.Env, se *splunk.Env, dd *datadog.Env, options ...Option) (Audit, error) {
+	switch ae.Backend {
+	case auditing.BackendSplunk:
+		if se == nil {
+			return nil, errors.New("unable to configure Splunk: missing Splunk configuration")
+		}
+		return NewSplunkBackend(logger, se, ae, options...)
+	case auditing.BackendDatadog:
+		if dd == nil {
+			return nil, errors.New("unable to configure Datadog: missing Datadog configuration")
+		}
+		d, err := NewDatadogAudit(dd, WithDatadogFieldNames(ae.FieldNames))
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure Datadog: %w", err)
+		}
+		return d, nil
+	case auditing.BackendFile:
+		return NewFileAudit(ae.FilePath, ae.Namespace, ae.Pod, ae.FieldNames, WithStreamEncoder(formatEncoder(ae.Format)))
+	case auditing.BackendConsole:
+		return NewStreamAudit(os.Stdout, ae.Namespace, ae.Pod, ae.FieldNames, WithStreamEncoder(formatEncoder(ae.Format))), nil
+	case auditing.BackendNone:
+		return NopAudit{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit backend: %q (supported: %s, %s, %s, %s, %s)", ae.Backend,
+			auditing.BackendSplunk, auditing.BackendDatadog, auditing.BackendFile, auditing.BackendConsole, auditing.BackendNone)
+	}
+}
+
+// NewSplunkBackend returns the Splunk backend configured by the environment,
+// with the options given applied last.
+func NewSplunkBackend(logger *zap.SugaredLogger, se *splunk.Env, ae *auditing.Env, options ...Option) (*SplunkAudit, error) {
+	defaults := []Option{WithUserAgent(se.UserAgent), WithFieldNames(ae.FieldNames)}
+	if se.OAuthTokenURL != "" {
+		logger.Infof("Using OAuth2 client credentials for Splunk (token endpoint: %s)", se.OAuthTokenURL)
+		defaults = append(defaults, WithTokenSource(
+			NewClientCredentials(se.OAuthTokenURL, se.OAuthClientID, se.OAuthClientSecret, se.OAuthScopes),
+		))
+	}
+
+	if se.Collector == splunk.CollectorRaw {
+		logger.Infof("Using Splunk raw event endpoint (channel: %s)", se.Channel)
+	}
+
+	if se.CAFile != "" {
+		config, err := caTLSConfig(se.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to configure Splunk: %w", err)
+		}
+		logger.Infof("Verifying Splunk certificates against CA file: %s", se.CAFile)
+		defaults = append(defaults, WithTLSConfig(config))
+	}
+
+	if se.InsecureEndpoint != "" {
+		logger.Warnf("TLS verification disabled for Splunk endpoint: %s, which must never be used in production", se.InsecureEndpoint)
+		defaults = append(defaults, WithInsecureSkipVerify(se.InsecureEndpoint))
+	}
+
+	s, err := NewSplunkAudit(se, append(defaults, options...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure Splunk: %w", err)
+	}
+	return s, nil
+}
+
+// Certificates are verified against the CA bundle only, in place of the
+// system roots, as is the case for a Splunk using a private CA.
+func caTLSConfig(caFile string) (*tls.Config, error) {
+	bundle, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("unable to parse CA file: %s", caFile)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// Events are encoded as sent to Splunk unless another format is configured.
+func formatEncoder(format string) Encoder {
+	switch format {
+	case auditing.FormatCEF:
+		return NewCEFEncoder()
+	case auditing.FormatLEEF:
+		return NewLEEFEncoder()
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/datadog"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBackend(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		expected    Audit
+	}{
+		{
+			"Datadog backend",
+			&auditing.Env{Backend: auditing.BackendDatadog},
+			&DatadogAudit{},
+		},
+		{
+			"file backend",
+			&auditing.Env{Backend: auditing.BackendFile, FilePath: filepath.Join(t.TempDir(), "audit.log")},
+			&StreamAudit{},
+		},
+		{
+			"console backend",
+			&auditing.Env{Backend: auditing.BackendConsole},
+			&StreamAudit{},
+		},
+		{
+			"none backend",
+			&auditing.Env{Backend: auditing.BackendNone},
+			NopAudit{},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+			actual, err := NewBackend(logger, tc.given, nil, &datadog.Env{})
+
+			require.NoError(t, err)
+			assert.IsType(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestNewBackendError(t *testing.T) {
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	_, err := NewBackend(logger, &auditing.Env{Backend: "syslog"}, nil, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown audit backend: "syslog" (supported: splunk, datadog, file, console, none)`)
+
+	_, err = NewBackend(logger, &auditing.Env{Backend: auditing.BackendSplunk}, nil, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to configure Splunk: missing Splunk configuration")
+
+	_, err = NewBackend(logger, &auditing.Env{Backend: auditing.BackendDatadog}, nil, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to configure Datadog: missing Datadog configuration")
+}
+
+func TestNewSplunkBackendCAFile(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "ca.crt")
+	bundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(path, bundle, 0o600))
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	s, err := NewSplunkBackend(logger, &splunk.Env{Endpoint: server.URL, CAFile: path}, &auditing.Env{})
+	require.NoError(t, err)
+	require.NoError(t, s.check(context.Background(), server.URL))
+
+	_, err = NewSplunkBackend(logger, &splunk.Env{Endpoint: server.URL, CAFile: filepath.Join(t.TempDir(), "missing")}, &auditing.Env{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to configure Splunk: unable to read CA file")
+}
@@ -0,0 +1,191 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerHalfOpen
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitOpenError is returned when a write is short-circuited, without
+// reaching the audit backend.
+type CircuitOpenError struct {
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("audit backend circuit breaker is open until: %s", e.Until.Format(time.RFC3339))
+}
+
+// CircuitBreaker wraps an audit backend, and stops writing to it after a number
+// of consecutive failures. Once the cooldown has passed, a single write is let
+// through to probe whether the backend has recovered.
+type CircuitBreaker struct {
+	audit     Audit
+	threshold int
+	cooldown  time.Duration
+
+	onStateChange func(from, to BreakerState)
+	clock         Clock
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+var (
+	_ Audit   = (*CircuitBreaker)(nil)
+	_ Checker = (*CircuitBreaker)(nil)
+	_ Flusher = (*CircuitBreaker)(nil)
+
+	_ ContextWriter = (*CircuitBreaker)(nil)
+	_ Previewer     = (*CircuitBreaker)(nil)
+)
+
+type BreakerOption func(*CircuitBreaker)
+
+func WithStateChange(callback func(from, to BreakerState)) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.onStateChange = callback
+	}
+}
+
+func WithBreakerClock(clock Clock) BreakerOption {
+	return func(b *CircuitBreaker) {
+		b.clock = clock
+	}
+}
+
+func NewCircuitBreaker(audit Audit, threshold int, cooldown time.Duration, options ...BreakerOption) *CircuitBreaker {
+	b := &CircuitBreaker{
+		audit:     audit,
+		threshold: threshold,
+		cooldown:  cooldown,
+		clock:     SystemClock,
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
+
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state
+}
+
+func (b *CircuitBreaker) Write(q *QueryData) error {
+	return b.WriteContext(context.Background(), q)
+}
+
+func (b *CircuitBreaker) WriteContext(ctx context.Context, q *QueryData) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+
+	err := WriteContext(ctx, b.audit, q)
+	b.record(err == nil)
+
+	return err
+}
+
+func (b *CircuitBreaker) Check(ctx context.Context) error {
+	if c, ok := b.audit.(Checker); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) Flush(ctx context.Context) error {
+	if f, ok := b.audit.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) Preview(q *QueryData) ([]byte, error) {
+	return Preview(b.audit, q)
+}
+
+func (b *CircuitBreaker) Close() error {
+	if c, ok := b.audit.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		until := b.openedAt.Add(b.cooldown)
+		if b.clock.Now().Before(until) {
+			return &CircuitOpenError{Until: until}
+		}
+		b.transition(BreakerHalfOpen)
+		return nil
+	case BreakerHalfOpen:
+		// Only a single probe is allowed while half-open.
+		return &CircuitOpenError{Until: b.clock.Now()}
+	default:
+		return nil
+	}
+}
+
+func (b *CircuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.clock.Now()
+		if b.state != BreakerOpen {
+			b.transition(BreakerOpen)
+		}
+	}
+}
+
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	b.state = to
+	if b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAudit struct {
+	err    error
+	writes int
+}
+
+func (f *fakeAudit) Write(q *QueryData) error {
+	f.writes++
+	return f.err
+}
+
+func TestNewCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	actual := NewCircuitBreaker(&fakeAudit{}, 3, time.Second)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &CircuitBreaker{}, actual)
+	assert.Equal(t, BreakerClosed, actual.State())
+}
+
+func TestCircuitBreakerWrite(t *testing.T) {
+	t.Parallel()
+
+	var transitions []string
+
+	clock := NewFakeClock(time.Now())
+	inner := &fakeAudit{err: errors.New("test")}
+
+	b := NewCircuitBreaker(inner, 2, time.Minute, WithStateChange(func(from, to BreakerState) {
+		transitions = append(transitions, from.String()+" -> "+to.String())
+	}), WithBreakerClock(clock))
+
+	// Failures below the threshold keep the circuit closed.
+	require.Error(t, b.Write(&QueryData{}))
+	assert.Equal(t, BreakerClosed, b.State())
+
+	require.Error(t, b.Write(&QueryData{}))
+	assert.Equal(t, BreakerOpen, b.State())
+
+	// Writes are short-circuited while open.
+	var openError *CircuitOpenError
+	err := b.Write(&QueryData{})
+	require.ErrorAs(t, err, &openError)
+	assert.Equal(t, clock.Now().Add(time.Minute), openError.Until)
+	assert.Equal(t, 2, inner.writes)
+
+	// A failed probe after the cooldown opens the circuit again.
+	clock.Advance(time.Minute)
+	require.EqualError(t, b.Write(&QueryData{}), "test")
+	assert.Equal(t, BreakerOpen, b.State())
+	assert.Equal(t, 3, inner.writes)
+
+	// A successful probe closes the circuit.
+	clock.Advance(time.Minute)
+	inner.err = nil
+	require.NoError(t, b.Write(&QueryData{}))
+	assert.Equal(t, BreakerClosed, b.State())
+	assert.Equal(t, 4, inner.writes)
+
+	assert.Equal(t, []string{
+		"closed -> open",
+		"open -> half-open",
+		"half-open -> open",
+		"open -> half-open",
+		"half-open -> closed",
+	}, transitions)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	b := NewCircuitBreaker(&fakeAudit{}, 1, time.Minute)
+	b.state = BreakerHalfOpen
+
+	// Only a single probe can be in flight while half-open.
+	var openError *CircuitOpenError
+	err := b.Write(&QueryData{})
+	require.ErrorAs(t, err, &openError)
+}
+
+func TestBreakerStateString(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       BreakerState
+		expected    string
+	}{
+		{"closed", BreakerClosed, "closed"},
+		{"half-open", BreakerHalfOpen, "half-open"},
+		{"open", BreakerOpen, "open"},
+		{"unknown", BreakerState(-1), "unknown"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.given.String())
+		})
+	}
+}
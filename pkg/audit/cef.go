@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// CEFEncoder encodes audit data in the Common Event Format (CEF), where the
+// fields are mapped to the standard extensions where possible, and to custom
+// string extensions otherwise.
+type CEFEncoder struct{}
+
+var _ Encoder = (*CEFEncoder)(nil)
+
+func NewCEFEncoder() *CEFEncoder {
+	return &CEFEncoder{}
+}
+
+func (e *CEFEncoder) Encode(q *QueryData) ([]byte, error) {
+	kind := kindOf(q)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(eventVendor),
+		cefHeaderEscaper.Replace(eventProduct),
+		cefHeaderEscaper.Replace(eventVersion()),
+		kind.id,
+		kind.name,
+		kind.severity,
+	)
+
+	// Lifecycle events are described in place of the query.
+	msg := q.Query
+	if q.EventType != "" {
+		msg = q.Detail
+	}
+	extensions := []string{
+		"rt", fmt.Sprint(time.Unix(q.Timestamp, 0).UnixMilli()),
+		"suser", q.User,
+		"msg", msg,
+	}
+	// Lifecycle events carry no decision, thus both share the action.
+	if q.EventType != "" {
+		extensions = append(extensions, "act", q.EventType)
+	} else if q.Decision != "" {
+		extensions = append(extensions, "act", q.Decision)
+	}
+	if q.RequestID != "" {
+		extensions = append(extensions, "externalId", q.RequestID)
+	}
+	// Overrides are told apart from rejections by the action, as both carry
+	// the reason the query was, or would have been, refused for.
+	if q.Rejection != "" {
+		extensions = append(extensions, "reason", q.Rejection)
+	} else if q.Override != "" {
+		extensions = append(extensions, "reason", q.Override)
+	}
+	if q.Executed {
+		outcome := "failure"
+		if q.Success {
+			outcome = "success"
+		}
+		extensions = append(extensions, "outcome", outcome)
+	}
+	if q.DatabaseHost != "" {
+		extensions = append(extensions, "dhost", q.DatabaseHost)
+	}
+	if q.ClientIP != "" {
+		extensions = append(extensions, "src", q.ClientIP)
+	}
+	if q.Timeout > 0 {
+		extensions = append(extensions, "cn1Label", "timeout_ms", "cn1", fmt.Sprint(q.Timeout.Milliseconds()))
+	}
+	// All six custom strings can be taken, thus the flag is a custom number.
+	if q.PostExpiry {
+		extensions = append(extensions, "cn2Label", "post_expiry", "cn2", "1")
+	}
+	if q.ReadOnly {
+		extensions = append(extensions, "cn4Label", "read_only", "cn4", "1")
+	}
+	if q.StatusCode > 0 {
+		extensions = append(extensions,
+			"cn3Label", "status_code", "cn3", fmt.Sprint(q.StatusCode),
+			"out", fmt.Sprint(q.ResponseBytes),
+		)
+	}
+	// The columns of the results are left out, as no extension remains to
+	// carry these, unlike the masked ones.
+	if len(q.Masked) > 0 {
+		extensions = append(extensions, "flexString1Label", "masked_columns", "flexString1", strings.Join(q.Masked, ","))
+	}
+	// The class of the error categorizes the event, as failures go.
+	if q.ErrorClass != "" {
+		extensions = append(extensions, "cat", q.ErrorClass)
+	}
+	if q.AuthProvider != "" {
+		extensions = append(extensions, "flexString2Label", "auth_provider", "flexString2", q.AuthProvider)
+	}
+
+	custom := []string{
+		"namespace", q.Namespace,
+		"pod", q.Pod,
+		"database", q.Database,
+		"args", strings.Join(q.Args, ","),
+		"error", q.Error,
+		"cache_hit", flag(q.CacheHit),
+		"phase", q.Phase,
+	}
+	n := 0
+	for i := 0; i < len(custom); i += 2 {
+		if custom[i+1] == "" {
+			continue
+		}
+		n++
+		extensions = append(extensions,
+			fmt.Sprintf("cs%dLabel", n), custom[i],
+			fmt.Sprintf("cs%d", n), custom[i+1],
+		)
+	}
+
+	for i := 0; i < len(extensions); i += 2 {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s=%s", extensions[i], cefExtensionEscaper.Replace(extensions[i+1]))
+	}
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCEFEncoderEncode(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	header := func(kind string) string {
+		return fmt.Sprintf("CEF:0|app-sre|GABI|%s|%s|", eventVersion(), kind)
+	}
+
+	cases := []struct {
+		description string
+		given       QueryData
+		want        string
+	}{
+		{
+			"query data with required fields set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1;",
+		},
+		{
+			"query data with rejection reason set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Rejection: "test"},
+			header("rejection") + "Request refused|7|rt=1672531200000 suser=test msg=select 1; reason=test",
+		},
+		{
+			"query data with successful outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true},
+			header("success") + "Query succeeded|3|rt=1672531200000 suser=test msg=select 1; outcome=success",
+		},
+		{
+			"query data with failed outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Error: "test", Timeout: time.Second},
+			header("failure") + "Query failed|5|rt=1672531200000 suser=test msg=select 1; outcome=failure cn1Label=timeout_ms cn1=1000 cs1Label=error cs1=test",
+		},
+		{
+			"query data with database and deployment set",
+			QueryData{Query: "select $1;", User: "test", Timestamp: timestamp, Database: "test", DatabaseHost: "db.example.com", ClientIP: "203.0.113.1", Namespace: "test", Pod: "gabi-1", Args: []string{"REDACTED"}, CacheHit: true},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select $1; dhost=db.example.com src=203.0.113.1 " +
+				"cs1Label=namespace cs1=test cs2Label=pod cs2=gabi-1 cs3Label=database cs3=test cs4Label=args cs4=REDACTED cs5Label=cache_hit cs5=true",
+		},
+		{
+			"query data with classified error set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Error: "test", ErrorClass: "syntax_error"},
+			header("failure") + "Query failed|5|rt=1672531200000 suser=test msg=select 1; outcome=failure cat=syntax_error cs1Label=error cs1=test",
+		},
+		{
+			"query data with decision set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Decision: "denied_policy", Rejection: "test"},
+			header("rejection") + "Request refused|7|rt=1672531200000 suser=test msg=select 1; act=denied_policy reason=test",
+		},
+		{
+			"query data with override set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Decision: "allowed", Override: "test"},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1; act=allowed reason=test",
+		},
+		{
+			"query data with post-expiry flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, PostExpiry: true},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1; cn2Label=post_expiry cn2=1",
+		},
+		{
+			"query data with authentication provider set",
+			QueryData{Query: "select 1;", User: "test", AuthProvider: "client_cert", Timestamp: timestamp},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1; flexString2Label=auth_provider flexString2=client_cert",
+		},
+		{
+			"query data with read-only flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, ReadOnly: true},
+			header("success") + "Query succeeded|3|rt=1672531200000 suser=test msg=select 1; outcome=success cn4Label=read_only cn4=1",
+		},
+		{
+			"query data with masked columns set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, Masked: []string{"ssn", "token"}},
+			header("success") + "Query succeeded|3|rt=1672531200000 suser=test msg=select 1; outcome=success flexString1Label=masked_columns flexString1=ssn,token",
+		},
+		{
+			"query data with response set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, StatusCode: 200, ResponseBytes: 42},
+			header("success") + "Query succeeded|3|rt=1672531200000 suser=test msg=select 1; outcome=success cn3Label=status_code cn3=200 out=42",
+		},
+		{
+			"query data with request ID set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, RequestID: "test"},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1; externalId=test",
+		},
+		{
+			"query data with phase set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Phase: PhaseStarted},
+			header("query") + "Query audited|3|rt=1672531200000 suser=test msg=select 1; cs1Label=phase cs1=started",
+		},
+		{
+			"lifecycle event set",
+			QueryData{User: "test", Timestamp: timestamp, EventType: EventReload, Detail: "users: 2"},
+			header("lifecycle") + "Lifecycle event|3|rt=1672531200000 suser=test msg=users: 2 act=config_reload",
+		},
+		{
+			"query data with characters requiring escaping",
+			QueryData{Query: "select 'a=b|c\\d'\nfrom test;", User: "test", Timestamp: timestamp},
+			header("query") + `Query audited|3|rt=1672531200000 suser=test msg=select 'a\=b|c\\d'\nfrom test;`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := NewCEFEncoder().Encode(&tc.given)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(actual))
+		})
+	}
+}
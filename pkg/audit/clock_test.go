@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemClock(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now()
+	actual := SystemClock.Now()
+
+	assert.False(t, actual.Before(before))
+	assert.NotNil(t, SystemClock.After(time.Millisecond))
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	assert.Equal(t, start, clock.Now())
+
+	first := clock.After(time.Minute)
+	second := clock.After(2 * time.Minute)
+	assert.Equal(t, 2, clock.Waiters())
+
+	// Channels only fire once the clock has been advanced past their deadline.
+	clock.Advance(59 * time.Second)
+	assert.Len(t, first, 0)
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-first)
+	assert.Len(t, second, 0)
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, start.Add(time.Hour+time.Minute), <-second)
+	assert.Equal(t, 0, clock.Waiters())
+	assert.Equal(t, start.Add(time.Hour+time.Minute), clock.Now())
+
+	// Channels without a duration fire straight away.
+	assert.Equal(t, clock.Now(), <-clock.After(0))
+}
@@ -1,6 +1,8 @@
 package audit
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 )
 
@@ -8,17 +10,108 @@ type ConsoleAudit struct {
 	Logger *zap.SugaredLogger
 }
 
-var _ Audit = (*ConsoleAudit)(nil)
+var (
+	_ Audit   = (*ConsoleAudit)(nil)
+	_ Flusher = (*ConsoleAudit)(nil)
+)
 
 func NewLoggerAudit(logger *zap.SugaredLogger) *ConsoleAudit {
 	return &ConsoleAudit{Logger: logger}
 }
 
 func (d *ConsoleAudit) Write(q *QueryData) error {
-	d.Logger.Infow("AUDIT",
-		"Query", q.Query,
-		"User", q.User,
-		"Timestamp", q.Timestamp,
+	hash := QueryHash(q.Query)
+
+	fields := []interface{}{
+		"user", q.User,
+		"query_hash", hash,
+		"timestamp", q.Timestamp,
+	}
+	if q.RequestID != "" {
+		fields = append(fields, "request_id", q.RequestID)
+	}
+	if q.Database != "" {
+		fields = append(fields, "database", q.Database)
+	}
+	if q.DatabaseHost != "" {
+		fields = append(fields, "database_host", q.DatabaseHost)
+	}
+	if q.ClientIP != "" {
+		fields = append(fields, "client_ip", q.ClientIP)
+	}
+	if q.AuthProvider != "" {
+		fields = append(fields, "auth_provider", q.AuthProvider)
+	}
+	if q.Decision != "" {
+		fields = append(fields, "decision", q.Decision)
+	}
+	if q.Rejection != "" {
+		fields = append(fields, "rejection", q.Rejection)
+	}
+	if q.Override != "" {
+		fields = append(fields, "override", q.Override)
+	}
+	if q.Phase != "" {
+		fields = append(fields, "phase", q.Phase)
+	}
+	if len(q.Args) > 0 {
+		fields = append(fields, "args", q.Args)
+	}
+	if q.CacheHit {
+		fields = append(fields, "cache_hit", true)
+	}
+	if q.Timeout > 0 {
+		fields = append(fields, "timeout_ms", q.Timeout.Milliseconds())
+	}
+	if q.SampleRate > 0 {
+		fields = append(fields, "sample_rate", q.SampleRate, "sampled", q.Sampled)
+	}
+	if q.PostExpiry {
+		fields = append(fields, "post_expiry", true)
+	}
+	if q.ReadOnly {
+		fields = append(fields, "read_only", true)
+	}
+	if q.Executed {
+		fields = append(fields, "success", q.Success)
+		if q.Error != "" {
+			fields = append(fields, "error", q.Error)
+		}
+		if q.ErrorClass != "" {
+			fields = append(fields, "error_class", q.ErrorClass)
+		}
+		if q.Partial {
+			fields = append(fields, "partial", true)
+		}
+		if len(q.Columns) > 0 {
+			fields = append(fields, "columns", q.Columns)
+		}
+		if len(q.Masked) > 0 {
+			fields = append(fields, "masked_columns", q.Masked)
+		}
+	}
+	if q.StatusCode > 0 {
+		fields = append(fields, "status_code", q.StatusCode, "response_bytes", q.ResponseBytes)
+	}
+	if q.EventType != "" {
+		fields = append(fields, "event_type", q.EventType, "detail", q.Detail)
+	}
+	for _, name := range q.StaticFields() {
+		fields = append(fields, name, q.Fields[name])
+	}
+	d.Logger.Infow("AUDIT", fields...)
+
+	// Queries can contain sensitive data, thus never log these above the debug level.
+	d.Logger.Debugw("AUDIT query",
+		"query_hash", hash,
+		"query", q.Query,
 	)
 	return nil
 }
+
+func (d *ConsoleAudit) Flush(ctx context.Context) error {
+	// Syncing standard error or output is not supported on every platform,
+	// thus errors are not reported.
+	_ = d.Logger.Sync()
+	return nil
+}
@@ -33,17 +33,92 @@ func TestLoggingAuditWrite(t *testing.T) {
 		{
 			"query data with all fields set",
 			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
-			regexp.MustCompile(`AUDIT\s{"Query": "select 1;", "User": "test", "Timestamp": 1672531200}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 		},
 		{
 			"query data with no SQL statements provided",
 			QueryData{Query: "", User: "test", Timestamp: time.Now().Unix()},
-			regexp.MustCompile(`AUDIT\s{"Query": "", "User": "test", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "e3b0c44298fc1c14", "query": ""}`),
+		},
+		{
+			"query data with rejection reason set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Rejection: "test"},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "rejection": "test"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with arguments set",
+			QueryData{Query: "select $1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Args: []string{"REDACTED"}},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "[0-9a-f]{16}", "timestamp": 1672531200, "args": \["REDACTED"\]}.*AUDIT query\s{"query_hash": "[0-9a-f]{16}", "query": "select \$1;"}`),
+		},
+		{
+			"query data with successful outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "success": true}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with failed outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Error: "test"},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "success": false, "error": "test"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with database set",
+			QueryData{Query: "select 1;", User: "test", Database: "test", DatabaseHost: "localhost", ClientIP: "203.0.113.1", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "database": "test", "database_host": "localhost", "client_ip": "203.0.113.1"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with authentication provider set",
+			QueryData{Query: "select 1;", User: "test", AuthProvider: "bearer", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "auth_provider": "bearer"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with sampling decision set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), SampleRate: 0.5, Sampled: true},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "sample_rate": 0.5, "sampled": true}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with post-expiry flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), PostExpiry: true},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "post_expiry": true}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with read-only flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true, ReadOnly: true},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "read_only": true, "success": true}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with masked columns set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true, Masked: []string{"ssn", "token"}},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "success": true, "masked_columns": \["ssn", "token"\]}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with response set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true, StatusCode: 200, ResponseBytes: 42},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "success": true, "status_code": 200, "response_bytes": 42}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with request ID set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), RequestID: "test"},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "request_id": "test"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query data with phase set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), RequestID: "test", Phase: PhaseStarted},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "request_id": "test", "phase": "started"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"lifecycle event set",
+			QueryData{User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), EventType: EventReload, Detail: "users: 2"},
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "[0-9a-f]+", "timestamp": 1672531200, "event_type": "config_reload", "detail": "users: 2"}`),
+		},
+		{
+			"query data with static fields set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Fields: map[string]string{"team": "sre", "environment": "prod", "user": "admin"}},
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "environment": "prod", "team": "sre"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 		},
 		{
 			"invalid query data with nothing set",
 			QueryData{},
-			regexp.MustCompile(`AUDIT\s{"Query": "", "User": "", "Timestamp": 0}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "", "query_hash": "e3b0c44298fc1c14", "timestamp": 0}.*AUDIT query\s{"query_hash": "e3b0c44298fc1c14", "query": ""}`),
 		},
 	}
 
@@ -0,0 +1,207 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env/datadog"
+	"github.com/app-sre/gabi/pkg/version"
+)
+
+// DatadogAudit sends audit events to the Datadog logs intake, with the same
+// attributes as sent to Splunk, next to the reserved attributes of Datadog.
+type DatadogAudit struct {
+	DatadogEnv *datadog.Env
+
+	client    *http.Client
+	userAgent string
+	names     map[string]string
+
+	customClient bool
+}
+
+var (
+	_ Audit         = (*DatadogAudit)(nil)
+	_ ContextWriter = (*DatadogAudit)(nil)
+	_ Previewer     = (*DatadogAudit)(nil)
+)
+
+type DatadogOption func(*DatadogAudit)
+
+// WithDatadogHTTPClient sets the HTTP client used to send requests to Datadog,
+// in place of the default one.
+func WithDatadogHTTPClient(client *http.Client) DatadogOption {
+	return func(d *DatadogAudit) {
+		d.client = client
+		d.customClient = true
+	}
+}
+
+func WithDatadogUserAgent(userAgent string) DatadogOption {
+	return func(d *DatadogAudit) {
+		d.userAgent = userAgent
+	}
+}
+
+// WithDatadogFieldNames renames the attributes of audit events sent to
+// Datadog, mapping their canonical names to the names expected downstream.
+func WithDatadogFieldNames(names map[string]string) DatadogOption {
+	return func(d *DatadogAudit) {
+		d.names = names
+	}
+}
+
+func NewDatadogAudit(dd *datadog.Env, options ...DatadogOption) (*DatadogAudit, error) {
+	d := &DatadogAudit{DatadogEnv: dd}
+
+	for _, option := range options {
+		option(d)
+	}
+
+	if d.customClient && d.client == nil {
+		return nil, errors.New("invalid Datadog audit options: HTTP client cannot be nil")
+	}
+	if err := ValidateFieldNames(d.names); err != nil {
+		return nil, fmt.Errorf("invalid Datadog audit options: %w", err)
+	}
+
+	// Unlike with Splunk, the certificate of Datadog is always verified.
+	if !d.customClient {
+		d.client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout:   connectTimeout,
+					KeepAlive: keepAlive,
+				}).DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        maxIdleConns,
+				MaxIdleConnsPerHost: maxIdleConns,
+				IdleConnTimeout:     idleConnTimeout,
+			},
+		}
+	}
+
+	return d, nil
+}
+
+// UserAgent returns the User-Agent sent with requests to Datadog, which
+// defaults to "GABI/<version>" unless set using the WithDatadogUserAgent option.
+func (d *DatadogAudit) UserAgent() string {
+	if d.userAgent != "" {
+		return d.userAgent
+	}
+	return fmt.Sprintf("GABI/%s", version.Version())
+}
+
+func (d *DatadogAudit) Write(q *QueryData) error {
+	return d.WriteContext(context.Background(), q)
+}
+
+func (d *DatadogAudit) WriteContext(ctx context.Context, q *QueryData) error {
+	content, err := d.marshal(q)
+	if err != nil {
+		return fmt.Errorf("unable to marshal Datadog audit: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.DatadogEnv.Endpoint, bytes.NewBuffer(content))
+	if err != nil {
+		return fmt.Errorf("unable to create request to Datadog: %w", err)
+	}
+	req.Header.Set("DD-API-KEY", d.DatadogEnv.APIKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", d.UserAgent())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return failure(FailureConnectivity, fmt.Errorf("unable to send request to Datadog: %w", err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return failure(FailureConnectivity, fmt.Errorf("unable to read Datadog response body: %w", err))
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err := fmt.Errorf("unable to write to Datadog: %s: %s", resp.Status, responseSnippet(body))
+		return failure(statusReason(resp.StatusCode), err)
+	}
+
+	return nil
+}
+
+// Preview returns the event as it would be sent to Datadog.
+func (d *DatadogAudit) Preview(q *QueryData) ([]byte, error) {
+	return d.marshal(q)
+}
+
+// The event is sent as a single log, with its attributes at the top level, so
+// that these can be used as facets, and with the reserved attributes of
+// Datadog taking precedence over static fields of the same name.
+func (d *DatadogAudit) marshal(q *QueryData) ([]byte, error) {
+	namespace := q.Namespace
+	if namespace == "" {
+		namespace = d.DatadogEnv.Namespace
+	}
+
+	pod := q.Pod
+	if pod == "" {
+		pod = d.DatadogEnv.Pod
+	}
+
+	content, err := json.Marshal(newEventData(q, namespace, pod, d.names))
+	if err != nil {
+		return nil, err
+	}
+
+	log := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &log); err != nil {
+		return nil, err
+	}
+
+	kind := kindOf(q)
+	reserved := map[string]interface{}{
+		"ddsource":  d.DatadogEnv.Source,
+		"service":   d.DatadogEnv.Service,
+		"message":   kind.name,
+		"status":    datadogStatus(kind),
+		"timestamp": q.Timestamp * 1000,
+	}
+	if d.DatadogEnv.Host != "" {
+		reserved["hostname"] = d.DatadogEnv.Host
+	}
+	if len(d.DatadogEnv.Tags) > 0 {
+		reserved["ddtags"] = strings.Join(d.DatadogEnv.Tags, ",")
+	}
+	for name, value := range reserved {
+		content, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		log[name] = content
+	}
+
+	return json.Marshal([]map[string]json.RawMessage{log})
+}
+
+func datadogStatus(kind eventKind) string {
+	switch kind {
+	case eventFailure:
+		return "error"
+	case eventRejection:
+		return "warn"
+	default:
+		return "info"
+	}
+}
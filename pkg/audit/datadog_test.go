@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env/datadog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDatadogAudit(t *testing.T) {
+	t.Parallel()
+
+	actual, err := NewDatadogAudit(&datadog.Env{})
+
+	require.NoError(t, err)
+	assert.NotNil(t, actual.client)
+	assert.Equal(t, "GABI/", actual.UserAgent()[:5])
+
+	_, err = NewDatadogAudit(&datadog.Env{}, WithDatadogHTTPClient(nil))
+
+	require.Error(t, err)
+	assert.EqualError(t, err, `invalid Datadog audit options: HTTP client cannot be nil`)
+
+	_, err = NewDatadogAudit(&datadog.Env{}, WithDatadogFieldNames(map[string]string{"team": "group"}))
+
+	require.Error(t, err)
+	assert.EqualError(t, err, `invalid Datadog audit options: unknown audit field: team`)
+}
+
+func TestDatadogAuditWrite(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       QueryData
+		options     []DatadogOption
+		expected    map[string]interface{}
+	}{
+		{
+			"query audited before it runs",
+			QueryData{Query: "select 1;", User: "test", Timestamp: 1672531200, RequestID: "test", Timeout: time.Second},
+			nil,
+			map[string]interface{}{
+				"ddsource":   "gabi",
+				"service":    "gabi",
+				"hostname":   "test",
+				"ddtags":     "env:test,team:sre",
+				"message":    "Query audited",
+				"status":     "info",
+				"timestamp":  float64(1672531200000),
+				"query":      "select 1;",
+				"user":       "test",
+				"namespace":  "test",
+				"pod":        "test",
+				"request_id": "test",
+				"timeout_ms": float64(1000),
+			},
+		},
+		{
+			"failed query with namespace and static fields",
+			QueryData{Query: "select 1;", User: "test", Namespace: "other", Executed: true, Error: "test", Fields: map[string]string{"team": "sre", "service": "other"}},
+			nil,
+			map[string]interface{}{
+				"ddsource":  "gabi",
+				"service":   "gabi",
+				"hostname":  "test",
+				"ddtags":    "env:test,team:sre",
+				"message":   "Query failed",
+				"status":    "error",
+				"timestamp": float64(0),
+				"query":     "select 1;",
+				"user":      "test",
+				"namespace": "other",
+				"pod":       "test",
+				"success":   false,
+				"error":     "test",
+				"team":      "sre",
+			},
+		},
+		{
+			"rejected request with renamed fields",
+			QueryData{User: "test", Rejection: "test"},
+			[]DatadogOption{WithDatadogFieldNames(map[string]string{"user": "usr.id"})},
+			map[string]interface{}{
+				"ddsource":  "gabi",
+				"service":   "gabi",
+				"hostname":  "test",
+				"ddtags":    "env:test,team:sre",
+				"message":   "Request refused",
+				"status":    "warn",
+				"timestamp": float64(0),
+				"query":     "",
+				"usr.id":    "test",
+				"namespace": "test",
+				"pod":       "test",
+				"rejection": "test",
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				body    []byte
+				headers http.Header
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				headers = r.Header
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte(`{}`))
+			}))
+			defer server.Close()
+
+			dd := &datadog.Env{
+				Endpoint:  server.URL + "/api/v2/logs",
+				APIKey:    "test123",
+				Source:    "gabi",
+				Service:   "gabi",
+				Tags:      []string{"env:test", "team:sre"},
+				Host:      "test",
+				Namespace: "test",
+				Pod:       "test",
+			}
+			options := append([]DatadogOption{WithDatadogHTTPClient(http.DefaultClient), WithDatadogUserAgent("test")}, tc.options...)
+			d, err := NewDatadogAudit(dd, options...)
+			require.NoError(t, err)
+
+			require.NoError(t, d.Write(&tc.given))
+
+			assert.Equal(t, "test123", headers.Get("DD-API-KEY"))
+			assert.Equal(t, "application/json; charset=utf-8", headers.Get("Content-Type"))
+			assert.Equal(t, "test", headers.Get("User-Agent"))
+
+			var actual []map[string]interface{}
+			require.NoError(t, json.Unmarshal(body, &actual))
+			require.Len(t, actual, 1)
+			assert.Equal(t, tc.expected, actual[0])
+		})
+	}
+}
+
+func TestDatadogAuditWriteStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		status      int
+		body        string
+		want        string
+		reason      FailureReason
+	}{
+		{
+			"forbidden",
+			http.StatusForbidden,
+			`{"errors":[{"status":"403","title":"Forbidden","detail":"Invalid API key"}]}`,
+			`unable to write to Datadog: 403 Forbidden: {"errors":[{"status":"403","title":"Forbidden","detail":"Invalid API key"}]}`,
+			FailureAuth,
+		},
+		{
+			"payload too large",
+			http.StatusRequestEntityTooLarge,
+			`{"errors":[]}`,
+			`unable to write to Datadog: 413 Request Entity Too Large: {"errors":[]}`,
+			FailureRejection,
+		},
+		{
+			"service unavailable with long body",
+			http.StatusServiceUnavailable,
+			strings.Repeat("a", 300),
+			`unable to write to Datadog: 503 Service Unavailable: ` + strings.Repeat("a", 256) + `...`,
+			FailureConnectivity,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			d, err := NewDatadogAudit(&datadog.Env{Endpoint: server.URL}, WithDatadogHTTPClient(http.DefaultClient))
+			require.NoError(t, err)
+
+			err = d.Write(&QueryData{Query: "select 1;", User: "test"})
+
+			require.Error(t, err)
+			assert.EqualError(t, err, tc.want)
+			assert.Equal(t, tc.reason, Reason(err))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"github.com/app-sre/gabi/pkg/version"
+)
+
+const (
+	eventVendor  = "app-sre"
+	eventProduct = "GABI"
+)
+
+// Encoder is implemented by encodings of audit data for delivery to backends
+// that do not accept the JSON used by Splunk, such as legacy SIEMs.
+type Encoder interface {
+	Encode(*QueryData) ([]byte, error)
+}
+
+// The kind of audit event, with its identifier, name and severity on the scale
+// from 0 to 10 shared by CEF and LEEF.
+type eventKind struct {
+	id       string
+	name     string
+	severity int
+}
+
+var (
+	eventQuery     = eventKind{"query", "Query audited", 3}
+	eventSuccess   = eventKind{"success", "Query succeeded", 3}
+	eventFailure   = eventKind{"failure", "Query failed", 5}
+	eventRejection = eventKind{"rejection", "Request refused", 7}
+	eventLifecycle = eventKind{"lifecycle", "Lifecycle event", 3}
+)
+
+func kindOf(q *QueryData) eventKind {
+	switch {
+	case q.EventType != "":
+		return eventLifecycle
+	case q.Rejection != "":
+		return eventRejection
+	case q.Executed && q.Success:
+		return eventSuccess
+	case q.Executed:
+		return eventFailure
+	default:
+		return eventQuery
+	}
+}
+
+func eventVersion() string {
+	return version.Version()
+}
+
+func flag(b bool) string {
+	if b {
+		return "true"
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package audit
+
+import "errors"
+
+// FailureReason describes why an audit backend did not accept an event.
+type FailureReason string
+
+const (
+	FailureUnknown      FailureReason = "unknown"
+	FailureAuth         FailureReason = "auth"
+	FailureConnectivity FailureReason = "connectivity"
+	FailureRejection    FailureReason = "rejection"
+)
+
+// DeliveryError annotates an error returned by an audit backend with the
+// reason for the failure, leaving its message unchanged.
+type DeliveryError struct {
+	Reason FailureReason
+	Err    error
+}
+
+func (e *DeliveryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Reason returns the reason for the given audit backend error, if known.
+func Reason(err error) FailureReason {
+	var deliveryError *DeliveryError
+	if errors.As(err, &deliveryError) {
+		return deliveryError.Reason
+	}
+	return FailureUnknown
+}
+
+func failure(reason FailureReason, err error) error {
+	return &DeliveryError{Reason: reason, Err: err}
+}
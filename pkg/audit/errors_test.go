@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+)
+
+func TestReason(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		expected    FailureReason
+	}{
+		{
+			"error without reason",
+			errors.New("test"),
+			FailureUnknown,
+		},
+		{
+			"error with reason",
+			failure(FailureAuth, errors.New("test")),
+			FailureAuth,
+		},
+		{
+			"wrapped error with reason",
+			fmt.Errorf("test: %w", failure(FailureRejection, errors.New("test"))),
+			FailureRejection,
+		},
+		{
+			"combined errors with reason",
+			multierr.Append(failure(FailureConnectivity, errors.New("test")), errors.New("test")),
+			FailureConnectivity,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Reason(tc.given))
+		})
+	}
+}
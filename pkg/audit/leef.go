@@ -0,0 +1,99 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	leefTimeFormat = "MMM dd yyyy HH:mm:ss.SSS z"
+	leefTimeLayout = "Jan 02 2006 15:04:05.000 MST"
+)
+
+var (
+	leefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	leefAttributeEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+)
+
+// LEEFEncoder encodes audit data in the Log Event Extended Format (LEEF),
+// version 1.0, with tab-separated attributes.
+type LEEFEncoder struct{}
+
+var _ Encoder = (*LEEFEncoder)(nil)
+
+func NewLEEFEncoder() *LEEFEncoder {
+	return &LEEFEncoder{}
+}
+
+func (e *LEEFEncoder) Encode(q *QueryData) ([]byte, error) {
+	kind := kindOf(q)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:1.0|%s|%s|%s|%s|",
+		leefHeaderEscaper.Replace(eventVendor),
+		leefHeaderEscaper.Replace(eventProduct),
+		leefHeaderEscaper.Replace(eventVersion()),
+		kind.id,
+	)
+
+	attributes := []string{
+		"devTime", time.Unix(q.Timestamp, 0).UTC().Format(leefTimeLayout),
+		"devTimeFormat", leefTimeFormat,
+		"cat", kind.name,
+		"sev", fmt.Sprint(kind.severity),
+		"usrName", q.User,
+		"query", q.Query,
+	}
+	if q.Executed {
+		outcome := "failure"
+		if q.Success {
+			outcome = "success"
+		}
+		attributes = append(attributes, "outcome", outcome)
+	}
+	if q.Timeout > 0 {
+		attributes = append(attributes, "timeout_ms", fmt.Sprint(q.Timeout.Milliseconds()))
+	}
+	if q.StatusCode > 0 {
+		attributes = append(attributes, "status_code", fmt.Sprint(q.StatusCode), "response_bytes", fmt.Sprint(q.ResponseBytes))
+	}
+
+	optional := []string{
+		"request_id", q.RequestID,
+		"reason", q.Rejection,
+		"override", q.Override,
+		"phase", q.Phase,
+		"dstHost", q.DatabaseHost,
+		"src", q.ClientIP,
+		"auth_provider", q.AuthProvider,
+		"decision", q.Decision,
+		"namespace", q.Namespace,
+		"pod", q.Pod,
+		"database", q.Database,
+		"args", strings.Join(q.Args, ","),
+		"error", q.Error,
+		"error_class", q.ErrorClass,
+		"cache_hit", flag(q.CacheHit),
+		"post_expiry", flag(q.PostExpiry),
+		"read_only", flag(q.ReadOnly),
+		"columns", strings.Join(q.Columns, ","),
+		"masked_columns", strings.Join(q.Masked, ","),
+		"event_type", q.EventType,
+		"detail", q.Detail,
+	}
+	for i := 0; i < len(optional); i += 2 {
+		if optional[i+1] != "" {
+			attributes = append(attributes, optional[i], optional[i+1])
+		}
+	}
+
+	for i := 0; i < len(attributes); i += 2 {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		fmt.Fprintf(&b, "%s=%s", attributes[i], leefAttributeEscaper.Replace(attributes[i+1]))
+	}
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLEEFEncoderEncode(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	header := func(kind string) string {
+		return fmt.Sprintf("LEEF:1.0|app-sre|GABI|%s|%s|devTime=Jan 01 2023 00:00:00.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\t", eventVersion(), kind)
+	}
+
+	cases := []struct {
+		description string
+		given       QueryData
+		want        string
+	}{
+		{
+			"query data with required fields set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;",
+		},
+		{
+			"query data with rejection reason set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Rejection: "test"},
+			header("rejection") + "cat=Request refused\tsev=7\tusrName=test\tquery=select 1;\treason=test",
+		},
+		{
+			"query data with successful outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true},
+			header("success") + "cat=Query succeeded\tsev=3\tusrName=test\tquery=select 1;\toutcome=success",
+		},
+		{
+			"query data with failed outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Error: "test", Timeout: time.Second},
+			header("failure") + "cat=Query failed\tsev=5\tusrName=test\tquery=select 1;\toutcome=failure\ttimeout_ms=1000\terror=test",
+		},
+		{
+			"query data with classified error set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Error: "test", ErrorClass: "syntax_error"},
+			header("failure") + "cat=Query failed\tsev=5\tusrName=test\tquery=select 1;\toutcome=failure\terror=test\terror_class=syntax_error",
+		},
+		{
+			"query data with columns set",
+			QueryData{Query: "select * from test;", User: "test", Timestamp: timestamp, Executed: true, Success: true, Columns: []string{"id", "name"}},
+			header("success") + "cat=Query succeeded\tsev=3\tusrName=test\tquery=select * from test;\toutcome=success\tcolumns=id,name",
+		},
+		{
+			"query data with decision set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Decision: "denied_auth", Rejection: "test"},
+			header("rejection") + "cat=Request refused\tsev=7\tusrName=test\tquery=select 1;\treason=test\tdecision=denied_auth",
+		},
+		{
+			"query data with override set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Decision: "allowed", Override: "test"},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;\toverride=test\tdecision=allowed",
+		},
+		{
+			"query data with post-expiry flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, PostExpiry: true},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;\tpost_expiry=true",
+		},
+		{
+			"query data with authentication provider set",
+			QueryData{Query: "select 1;", User: "test", AuthProvider: "client_cert", Timestamp: timestamp},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;\tauth_provider=client_cert",
+		},
+		{
+			"query data with read-only flag set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, ReadOnly: true},
+			header("success") + "cat=Query succeeded\tsev=3\tusrName=test\tquery=select 1;\toutcome=success\tread_only=true",
+		},
+		{
+			"query data with masked columns set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Masked: []string{"ssn", "token"}},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;\tmasked_columns=ssn,token",
+		},
+		{
+			"query data with response set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, StatusCode: 200, ResponseBytes: 42},
+			header("success") + "cat=Query succeeded\tsev=3\tusrName=test\tquery=select 1;\toutcome=success\tstatus_code=200\tresponse_bytes=42",
+		},
+		{
+			"query data with request ID set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, RequestID: "test"},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select 1;\trequest_id=test",
+		},
+		{
+			"query data with phase set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: timestamp, Executed: true, Success: true, Phase: PhaseCompleted},
+			header("success") + "cat=Query succeeded\tsev=3\tusrName=test\tquery=select 1;\toutcome=success\tphase=completed",
+		},
+		{
+			"lifecycle event set",
+			QueryData{User: "test", Timestamp: timestamp, EventType: EventReload, Detail: "users: 2"},
+			header("lifecycle") + "cat=Lifecycle event\tsev=3\tusrName=test\tquery=\tevent_type=config_reload\tdetail=users: 2",
+		},
+		{
+			"query data with database and deployment set",
+			QueryData{Query: "select $1;", User: "test", Timestamp: timestamp, Database: "test", DatabaseHost: "db.example.com", ClientIP: "203.0.113.1", Namespace: "test", Pod: "gabi-1", Args: []string{"REDACTED"}, CacheHit: true},
+			header("query") + "cat=Query audited\tsev=3\tusrName=test\tquery=select $1;\tdstHost=db.example.com\tsrc=203.0.113.1\tnamespace=test\tpod=gabi-1\tdatabase=test\targs=REDACTED\tcache_hit=true",
+		},
+		{
+			"query data with characters requiring escaping",
+			QueryData{Query: "select 'a\tb'\nfrom test;", User: "test", Timestamp: timestamp},
+			header("query") + `cat=Query audited` + "\tsev=3\tusrName=test\t" + `query=select 'a\tb'\nfrom test;`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := NewLEEFEncoder().Encode(&tc.given)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(actual))
+		})
+	}
+}
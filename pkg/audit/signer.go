@@ -0,0 +1,309 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Lines of the audit file can be as long as the queries these hold.
+const maxVerifyLineBytes = 16 << 20
+
+// Signer wraps an audit backend, and signs every event written to it using an
+// HMAC over the attributes of the event, which include the signature of the
+// event signed before it, thus chaining events together. An event altered,
+// or removed from the middle of the chain, is detected by the Verifier.
+type Signer struct {
+	audit     Audit
+	key       []byte
+	namespace string
+	pod       string
+
+	mu       sync.Mutex
+	previous string
+}
+
+var (
+	_ Audit   = (*Signer)(nil)
+	_ Checker = (*Signer)(nil)
+	_ Flusher = (*Signer)(nil)
+
+	_ ContextWriter = (*Signer)(nil)
+	_ Previewer     = (*Signer)(nil)
+)
+
+// NewSigner returns a signer for the given backend, where the namespace and
+// pod identify the instance in the signed events, as these would otherwise be
+// set by the backend, once already signed.
+func NewSigner(audit Audit, key []byte, namespace, pod string) *Signer {
+	return &Signer{audit: audit, key: key, namespace: namespace, pod: pod}
+}
+
+func (s *Signer) Write(q *QueryData) error {
+	return s.WriteContext(context.Background(), q)
+}
+
+func (s *Signer) WriteContext(ctx context.Context, q *QueryData) error {
+	if q.Namespace == "" {
+		q.Namespace = s.namespace
+	}
+	if q.Pod == "" {
+		q.Pod = s.pod
+	}
+
+	// Events are signed and written one at a time, for every event to refer
+	// to the previous one written, as the chain only advances once an event
+	// was written, leaving events failing to be written out of it.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.sign(q); err != nil {
+		return fmt.Errorf("unable to sign audit: %w", err)
+	}
+	if err := WriteContext(ctx, s.audit, q); err != nil {
+		return err
+	}
+	s.previous = q.Signature
+	return nil
+}
+
+func (s *Signer) sign(q *QueryData) error {
+	q.PreviousSignature = s.previous
+	q.Signature = ""
+
+	content, err := json.Marshal(newEventData(q, q.Namespace, q.Pod, nil))
+	if err != nil {
+		return err
+	}
+	event := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &event); err != nil {
+		return err
+	}
+
+	signature, err := signEvent(s.key, event, q.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	q.Signature = signature
+	return nil
+}
+
+// Preview returns the event as the backend would send it, though unsigned, as
+// signing it would chain the events to one that is never sent.
+func (s *Signer) Preview(q *QueryData) ([]byte, error) {
+	p := *q
+	if p.Namespace == "" {
+		p.Namespace = s.namespace
+	}
+	if p.Pod == "" {
+		p.Pod = s.pod
+	}
+	p.Signature, p.PreviousSignature = "", ""
+	return Preview(s.audit, &p)
+}
+
+func (s *Signer) Check(ctx context.Context) error {
+	if c, ok := s.audit.(Checker); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
+
+func (s *Signer) Flush(ctx context.Context) error {
+	if f, ok := s.audit.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+func (s *Signer) Close() error {
+	if c, ok := s.audit.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// The signature covers the attributes of the event under their canonical
+// names, except for the signature itself, together with the time of the
+// event, encoded with sorted keys.
+func signEvent(key []byte, event map[string]json.RawMessage, timestamp int64) (string, error) {
+	attributes := make(map[string]json.RawMessage, len(event)+1)
+	for name, value := range event {
+		if name != "signature" {
+			attributes[name] = value
+		}
+	}
+	attributes["timestamp"] = json.RawMessage(strconv.FormatInt(timestamp, 10))
+
+	content, err := json.Marshal(attributes)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyError reports the event at the given line that failed verification.
+type VerifyError struct {
+	Line   int
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("audit event at line %d failed verification: %s", e.Line, e.Reason)
+}
+
+// Verifier verifies the signatures of audit events, and that every signed
+// event the events refer to is present. Events removed from the end of a
+// chain cannot be detected, as no event refers to these.
+type Verifier struct {
+	key   []byte
+	names map[string]string
+
+	signatures map[string]struct{}
+	references map[string]int
+	order      []string
+}
+
+// NewVerifier returns a verifier for events signed using the given key, and
+// sent with their attributes renamed according to the names given, if any.
+func NewVerifier(key []byte, names map[string]string) *Verifier {
+	canonical := make(map[string]string, len(names))
+	for field, name := range names {
+		canonical[name] = field
+	}
+	return &Verifier{
+		key:        key,
+		names:      canonical,
+		signatures: make(map[string]struct{}),
+		references: make(map[string]int),
+	}
+}
+
+// Verify reads audit events, one per line, in the format written by the file
+// audit backend, in any order, and returns the first event failing
+// verification, or an event referring to a previous event that is missing.
+func (v *Verifier) Verify(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxVerifyLineBytes)
+
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := v.verify(scanner.Bytes(), line); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, fmt.Errorf("unable to read audit events: %w", err)
+	}
+
+	for _, signature := range v.order {
+		if _, ok := v.signatures[signature]; !ok {
+			return n, &VerifyError{Line: v.references[signature], Reason: "previous event is missing"}
+		}
+	}
+
+	return n, nil
+}
+
+func (v *Verifier) verify(content []byte, line int) error {
+	var e struct {
+		Time  json.RawMessage            `json:"time"`
+		Event map[string]json.RawMessage `json:"event"`
+	}
+	if err := json.Unmarshal(content, &e); err != nil {
+		return &VerifyError{Line: line, Reason: fmt.Sprintf("unable to unmarshal event: %s", err)}
+	}
+	timestamp, err := unmarshalTime(e.Time)
+	if err != nil {
+		return &VerifyError{Line: line, Reason: "invalid event time"}
+	}
+
+	event := make(map[string]json.RawMessage, len(e.Event))
+	for name, value := range e.Event {
+		if n, ok := v.names[name]; ok {
+			name = n
+		}
+		event[name] = value
+	}
+
+	var signature, previous string
+	if err := unmarshalString(event["signature"], &signature); err != nil || signature == "" {
+		return &VerifyError{Line: line, Reason: "event is not signed"}
+	}
+	if err := unmarshalString(event["previous_signature"], &previous); err != nil {
+		return &VerifyError{Line: line, Reason: "invalid previous signature"}
+	}
+
+	expected, err := signEvent(v.key, event, timestamp)
+	if err != nil {
+		return &VerifyError{Line: line, Reason: fmt.Sprintf("unable to marshal event: %s", err)}
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return &VerifyError{Line: line, Reason: "invalid signature"}
+	}
+
+	// Chains never fork, thus events cannot be repeated.
+	if _, ok := v.signatures[signature]; ok {
+		return &VerifyError{Line: line, Reason: "event is repeated"}
+	}
+	v.signatures[signature] = struct{}{}
+	if previous == "" {
+		return nil
+	}
+	if _, ok := v.references[previous]; ok {
+		return &VerifyError{Line: line, Reason: "previous event is referred to more than once"}
+	}
+	v.references[previous] = line
+	v.order = append(v.order, previous)
+	return nil
+}
+
+// Events sent to Splunk carry their time in any of the supported formats.
+func unmarshalTime(content json.RawMessage) (int64, error) {
+	var s string
+	if err := json.Unmarshal(content, &s); err == nil {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return 0, err
+		}
+		return t.Unix(), nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(content, &n); err != nil {
+		return 0, err
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, err
+	}
+	// Times in milliseconds are told apart by their magnitude.
+	if f >= 1e11 {
+		f /= 1000
+	}
+	return int64(f), nil
+}
+
+func unmarshalString(content json.RawMessage, s *string) error {
+	if content == nil {
+		return nil
+	}
+	return json.Unmarshal(content, s)
+}
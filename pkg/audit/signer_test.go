@@ -0,0 +1,236 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSigningKey = []byte(strings.Repeat("k", 32))
+
+func TestSignerWrite(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	s := NewSigner(NewStreamAudit(&output, "", "", nil), testSigningKey, "test", "gabi-1")
+
+	first := &QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	second := &QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true}
+
+	require.NoError(t, s.Write(first))
+	require.NoError(t, s.Write(second))
+
+	assert.Equal(t, "test", first.Namespace)
+	assert.Equal(t, "gabi-1", first.Pod)
+	assert.Len(t, first.Signature, 64)
+	assert.Empty(t, first.PreviousSignature)
+	assert.Equal(t, first.Signature, second.PreviousSignature)
+	assert.NotEqual(t, first.Signature, second.Signature)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"namespace":"test","pod":"gabi-1","signature":"`+first.Signature+`"`)
+	assert.Contains(t, lines[1], `"signature":"`+second.Signature+`","previous_signature":"`+first.Signature+`"`)
+}
+
+func TestSignerWriteFailure(t *testing.T) {
+	t.Parallel()
+
+	backend := &fakeAudit{}
+	s := NewSigner(backend, testSigningKey, "test", "gabi-1")
+
+	first := &QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	failed := &QueryData{Query: "select 2;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	second := &QueryData{Query: "select 3;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+
+	require.NoError(t, s.Write(first))
+	backend.err = errors.New("test")
+	require.Error(t, s.Write(failed))
+	backend.err = nil
+	require.NoError(t, s.Write(second))
+
+	// The event failing to be written is left out of the chain.
+	assert.Equal(t, first.Signature, failed.PreviousSignature)
+	assert.Equal(t, first.Signature, second.PreviousSignature)
+	assert.Equal(t, 3, backend.writes)
+}
+
+func TestVerifierVerify(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       func(lines []string) []string
+		key         []byte
+		names       map[string]string
+		error       bool
+		want        string
+	}{
+		{
+			"events verified",
+			func(lines []string) []string {
+				return lines
+			},
+			testSigningKey,
+			nil,
+			false,
+			``,
+		},
+		{
+			"events verified out of order",
+			func(lines []string) []string {
+				return []string{lines[2], lines[0], lines[1]}
+			},
+			testSigningKey,
+			nil,
+			false,
+			``,
+		},
+		{
+			"events verified with field names",
+			func(lines []string) []string {
+				for i := range lines {
+					lines[i] = strings.Replace(lines[i], `"query":`, `"sql":`, 1)
+				}
+				return lines
+			},
+			testSigningKey,
+			map[string]string{"query": "sql"},
+			false,
+			``,
+		},
+		{
+			"event altered",
+			func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"user":"test"`, `"user":"admin"`, 1)
+				return lines
+			},
+			testSigningKey,
+			nil,
+			true,
+			`audit event at line 2 failed verification: invalid signature`,
+		},
+		{
+			"event time altered",
+			func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], `"time":1672531200`, `"time":1672531201`, 1)
+				return lines
+			},
+			testSigningKey,
+			nil,
+			true,
+			`audit event at line 1 failed verification: invalid signature`,
+		},
+		{
+			"event removed",
+			func(lines []string) []string {
+				return []string{lines[0], lines[2]}
+			},
+			testSigningKey,
+			nil,
+			true,
+			`audit event at line 2 failed verification: previous event is missing`,
+		},
+		{
+			"event repeated",
+			func(lines []string) []string {
+				return append(lines, lines[1])
+			},
+			testSigningKey,
+			nil,
+			true,
+			`audit event at line 4 failed verification: event is repeated`,
+		},
+		{
+			"event not signed",
+			func(lines []string) []string {
+				return append(lines, `{"time":1672531200,"event":{"query":"select 1;","user":"test"}}`)
+			},
+			testSigningKey,
+			nil,
+			true,
+			`audit event at line 4 failed verification: event is not signed`,
+		},
+		{
+			"events signed using another key",
+			func(lines []string) []string {
+				return lines
+			},
+			[]byte(strings.Repeat("x", 32)),
+			nil,
+			true,
+			`audit event at line 1 failed verification: invalid signature`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			s := NewSigner(NewStreamAudit(&output, "test", "test", tc.names), testSigningKey, "test", "test")
+			for i := 0; i < 3; i++ {
+				require.NoError(t, s.Write(&QueryData{
+					Query:     "select 1;",
+					User:      "test",
+					Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(),
+					Fields:    map[string]string{"team": "<sre>"},
+				}))
+			}
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+
+			v := NewVerifier(tc.key, tc.names)
+			n, err := v.Verify(strings.NewReader(strings.Join(tc.given(lines), "\n") + "\n"))
+
+			if tc.error {
+				require.Error(t, err)
+				assert.IsType(t, &VerifyError{}, err)
+				assert.EqualError(t, err, tc.want)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, 3, n)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expected    int64
+		error       bool
+	}{
+		{"time in seconds", `1672531200`, 1672531200, false},
+		{"time in milliseconds", `1672531200000`, 1672531200, false},
+		{"time in RFC 3339 format", `"2023-01-01T00:00:00Z"`, 1672531200, false},
+		{"invalid time", `"test"`, 0, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := unmarshalTime(json.RawMessage(tc.given))
+
+			if tc.error {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
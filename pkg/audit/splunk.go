@@ -5,12 +5,19 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"go.uber.org/multierr"
+
 	"github.com/app-sre/gabi/pkg/env/splunk"
 	"github.com/app-sre/gabi/pkg/version"
 )
@@ -21,21 +28,170 @@ const (
 
 	connectTimeout = 5 * time.Second
 	requestTimeout = 30 * time.Second
+
+	// Connections to Splunk are kept open in between writes, rather than being
+	// established for every single one.
+	keepAlive       = 30 * time.Second
+	maxIdleConns    = 100
+	idleConnTimeout = 90 * time.Second
+
+	// How much of an error response body is included in the error message.
+	maxResponseSnippet = 256
+
+	// How long an endpoint is skipped for after a failed request.
+	unhealthyPeriod = 30 * time.Second
 )
 
 type SplunkAudit struct {
 	SplunkEnv *splunk.Env
 
-	client *http.Client
+	client    *http.Client
+	userAgent string
+	tokens    TokenSource
+	clock     Clock
+	names     map[string]string
+
+	customClient    bool
+	tlsConfig       *tls.Config
+	insecure        string
+	insecureClient  *http.Client
+	connectTimeout  time.Duration
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	http2           bool
+	transportSet    bool
+
+	mu        sync.Mutex
+	next      int
+	unhealthy map[string]time.Time
 }
 
-var _ Audit = (*SplunkAudit)(nil)
+var (
+	_ Audit         = (*SplunkAudit)(nil)
+	_ Checker       = (*SplunkAudit)(nil)
+	_ ContextWriter = (*SplunkAudit)(nil)
+	_ Previewer     = (*SplunkAudit)(nil)
+)
 
 type SplunkEventData struct {
-	Query     string `json:"query"`
-	User      string `json:"user"`
-	Namespace string `json:"namespace"`
-	Pod       string `json:"pod"`
+	Query         string   `json:"query"`
+	User          string   `json:"user"`
+	AuthProvider  string   `json:"auth_provider,omitempty"`
+	Decision      string   `json:"decision,omitempty"`
+	Database      string   `json:"database,omitempty"`
+	DatabaseHost  string   `json:"database_host,omitempty"`
+	ClientIP      string   `json:"client_ip,omitempty"`
+	Namespace     string   `json:"namespace"`
+	Pod           string   `json:"pod"`
+	RequestID     string   `json:"request_id,omitempty"`
+	Rejection     string   `json:"rejection,omitempty"`
+	Override      string   `json:"override,omitempty"`
+	Phase         string   `json:"phase,omitempty"`
+	Args          []string `json:"args,omitempty"`
+	CacheHit      bool     `json:"cache_hit,omitempty"`
+	Success       *bool    `json:"success,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	ErrorClass    string   `json:"error_class,omitempty"`
+	Partial       bool     `json:"partial,omitempty"`
+	TimeoutMs     int64    `json:"timeout_ms,omitempty"`
+	SampleRate    float64  `json:"sample_rate,omitempty"`
+	PostExpiry    bool     `json:"post_expiry,omitempty"`
+	ReadOnly      bool     `json:"read_only,omitempty"`
+	Columns       []string `json:"columns,omitempty"`
+	Masked        []string `json:"masked_columns,omitempty"`
+	StatusCode    int      `json:"status_code,omitempty"`
+	ResponseBytes *int64   `json:"response_bytes,omitempty"`
+	EventType     string   `json:"event_type,omitempty"`
+	Detail        string   `json:"detail,omitempty"`
+
+	Signature         string `json:"signature,omitempty"`
+	PreviousSignature string `json:"previous_signature,omitempty"`
+
+	// Static fields are merged into the event, next to the fields above,
+	// which are renamed according to Names.
+	Fields map[string]string `json:"-"`
+	Names  map[string]string `json:"-"`
+}
+
+func newEventData(q *QueryData, namespace, pod string, names map[string]string) *SplunkEventData {
+	e := &SplunkEventData{
+		Query:        q.Query,
+		User:         q.User,
+		AuthProvider: q.AuthProvider,
+		Decision:     q.Decision,
+		Database:     q.Database,
+		DatabaseHost: q.DatabaseHost,
+		ClientIP:     q.ClientIP,
+		Namespace:    namespace,
+		Pod:          pod,
+		RequestID:    q.RequestID,
+		Rejection:    q.Rejection,
+		Override:     q.Override,
+		Phase:        q.Phase,
+		Args:         q.Args,
+		CacheHit:     q.CacheHit,
+		TimeoutMs:    q.Timeout.Milliseconds(),
+		SampleRate:   q.SampleRate,
+		PostExpiry:   q.PostExpiry,
+		ReadOnly:     q.ReadOnly,
+		Columns:      q.Columns,
+		Masked:       q.Masked,
+		EventType:    q.EventType,
+		Detail:       q.Detail,
+		Fields:       q.Fields,
+		Names:        names,
+
+		Signature:         q.Signature,
+		PreviousSignature: q.PreviousSignature,
+	}
+	if q.Executed {
+		success := q.Success
+		e.Success = &success
+		e.Error = q.Error
+		e.ErrorClass = q.ErrorClass
+		e.Partial = q.Partial
+	}
+	// Empty responses are audited as such, when the response was written.
+	if q.StatusCode > 0 {
+		size := q.ResponseBytes
+		e.StatusCode = q.StatusCode
+		e.ResponseBytes = &size
+	}
+	return e
+}
+
+func (e *SplunkEventData) MarshalJSON() ([]byte, error) {
+	type event SplunkEventData
+
+	content, err := json.Marshal((*event)(e))
+	if err != nil || len(e.Fields) == 0 && len(e.Names) == 0 {
+		return content, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]json.RawMessage, len(fields)+len(e.Fields))
+	for name, value := range fields {
+		if n, ok := e.Names[name]; ok {
+			name = n
+		}
+		merged[name] = value
+	}
+	for name, value := range e.Fields {
+		if _, ok := merged[name]; ok || IsReservedField(name) {
+			continue
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		merged[name] = v
+	}
+
+	return json.Marshal(merged)
 }
 
 type SplunkQueryData struct {
@@ -44,99 +200,566 @@ type SplunkQueryData struct {
 	Host       string           `json:"host"`
 	Source     string           `json:"source"`
 	SourceType string           `json:"sourcetype"`
-	Time       int64            `json:"time"`
+	Time       interface{}      `json:"time"`
 }
 
 type Option func(*SplunkAudit)
 
+// WithHTTPClient sets the HTTP client used to send requests to Splunk, in
+// place of the default one, thus it cannot be combined with the options that
+// configure the default client.
 func WithHTTPClient(client *http.Client) Option {
 	return func(s *SplunkAudit) {
-		s.SetHTTPClient(client)
+		s.client = client
+		s.customClient = true
 	}
 }
 
-func NewSplunkAudit(splunk *splunk.Env, options ...Option) *SplunkAudit {
-	s := &SplunkAudit{SplunkEnv: splunk}
+// WithTLSConfig sets the TLS configuration of the default HTTP client, which
+// otherwise verifies the certificate of Splunk against the system roots.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(s *SplunkAudit) {
+		s.tlsConfig = config
+	}
+}
 
-	s.client = &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout: connectTimeout,
-			}).DialContext,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
-		},
+// WithInsecureSkipVerify skips verifying the certificate of the given Splunk
+// endpoint, and of no other, for development instances of Splunk using
+// self-signed certificates. It must never be used in production.
+func WithInsecureSkipVerify(endpoint string) Option {
+	return func(s *SplunkAudit) {
+		s.insecure = endpoint
+	}
+}
+
+// WithConnectTimeout sets how long the default HTTP client waits for a
+// connection to Splunk to be established.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(s *SplunkAudit) {
+		s.connectTimeout = timeout
+	}
+}
+
+// WithMaxIdleConns sets how many idle connections to Splunk the default HTTP
+// client keeps open, for all endpoints together as well as for each of them.
+// Setting it to zero disables keep-alives altogether.
+func WithMaxIdleConns(n int) Option {
+	return func(s *SplunkAudit) {
+		s.maxIdleConns = n
+		s.transportSet = true
+	}
+}
+
+// WithIdleConnTimeout sets how long the default HTTP client keeps an idle
+// connection to Splunk open, before closing it.
+func WithIdleConnTimeout(timeout time.Duration) Option {
+	return func(s *SplunkAudit) {
+		s.idleConnTimeout = timeout
+		s.transportSet = true
+	}
+}
+
+// WithHTTP2 sets whether the default HTTP client attempts to use HTTP/2 with
+// Splunk, which it does unless disabled.
+func WithHTTP2(enabled bool) Option {
+	return func(s *SplunkAudit) {
+		s.http2 = enabled
+		s.transportSet = true
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(s *SplunkAudit) {
+		s.userAgent = userAgent
+	}
+}
+
+// WithTokenSource sets the source of bearer tokens sent with requests to
+// Splunk, which are used in place of the static token.
+func WithTokenSource(tokens TokenSource) Option {
+	return func(s *SplunkAudit) {
+		s.tokens = tokens
+	}
+}
+
+// WithClock sets the clock used to track how long failed endpoints are
+// skipped for.
+func WithClock(clock Clock) Option {
+	return func(s *SplunkAudit) {
+		s.clock = clock
+	}
+}
+
+// WithFieldNames renames the fields of audit events sent to Splunk, mapping
+// their canonical names to the names expected downstream.
+func WithFieldNames(names map[string]string) Option {
+	return func(s *SplunkAudit) {
+		s.names = names
+	}
+}
+
+// NewSplunkAudit returns an error when the options given conflict with each
+// other, or are invalid, rather than silently ignoring some of them.
+func NewSplunkAudit(splunk *splunk.Env, options ...Option) (*SplunkAudit, error) {
+	s := &SplunkAudit{
+		SplunkEnv:       splunk,
+		clock:           SystemClock,
+		maxIdleConns:    maxIdleConns,
+		idleConnTimeout: idleConnTimeout,
+		http2:           true,
 	}
 
 	for _, option := range options {
 		option(s)
 	}
 
-	return s
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("invalid Splunk audit options: %w", err)
+	}
+
+	if !s.customClient {
+		s.client = s.defaultHTTPClient(false)
+		if s.insecure != "" {
+			s.insecureClient = s.defaultHTTPClient(true)
+		}
+	}
+
+	return s, nil
+}
+
+func (d *SplunkAudit) validate() error {
+	switch {
+	case d.customClient && d.client == nil:
+		return errors.New("HTTP client cannot be nil")
+	case d.customClient && d.tlsConfig != nil:
+		return errors.New("WithHTTPClient cannot be combined with WithTLSConfig, configure TLS on the HTTP client instead")
+	case d.customClient && d.connectTimeout != 0:
+		return errors.New("WithHTTPClient cannot be combined with WithConnectTimeout, configure the timeout on the HTTP client instead")
+	case d.customClient && d.transportSet:
+		return errors.New("WithHTTPClient cannot be combined with the connection pool options, configure the transport of the HTTP client instead")
+	case d.customClient && d.insecure != "":
+		return errors.New("WithHTTPClient cannot be combined with WithInsecureSkipVerify, configure TLS on the HTTP client instead")
+	case d.insecure != "" && !d.hasEndpoint(d.insecure):
+		return fmt.Errorf("endpoint skipping TLS verification is not a Splunk endpoint: %s", d.insecure)
+	case d.connectTimeout < 0:
+		return errors.New("connect timeout cannot be negative")
+	case d.maxIdleConns < 0:
+		return errors.New("maximum idle connections cannot be negative")
+	case d.idleConnTimeout < 0:
+		return errors.New("idle connection timeout cannot be negative")
+	case d.clock == nil:
+		return errors.New("clock cannot be nil")
+	case d.SplunkEnv.Collector == splunk.CollectorRaw && d.SplunkEnv.Channel == "":
+		return errors.New("raw collector endpoint requires a channel")
+	}
+	return ValidateFieldNames(d.names)
+}
+
+// The default HTTP client pools connections to Splunk, since establishing one,
+// including the TLS handshake, would otherwise add to the latency of every
+// single write. HTTP/2 is attempted explicitly, as setting a TLS configuration
+// would otherwise disable it.
+func (d *SplunkAudit) defaultHTTPClient(insecure bool) *http.Client {
+	config, timeout := d.tlsConfig, d.connectTimeout
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if insecure {
+		config = config.Clone()
+		config.InsecureSkipVerify = true
+	}
+	if timeout == 0 {
+		timeout = connectTimeout
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   timeout,
+				KeepAlive: keepAlive,
+			}).DialContext,
+			TLSClientConfig:     config,
+			ForceAttemptHTTP2:   d.http2,
+			DisableKeepAlives:   d.maxIdleConns == 0,
+			MaxIdleConns:        d.maxIdleConns,
+			MaxIdleConnsPerHost: d.maxIdleConns,
+			IdleConnTimeout:     d.idleConnTimeout,
+		},
+	}
 }
 
+// SetHTTPClient sets the HTTP client used for every endpoint, including the
+// one skipping TLS verification, if any.
 func (d *SplunkAudit) SetHTTPClient(client *http.Client) {
 	d.client = client
+	d.insecureClient = nil
+}
+
+// InsecureEndpoint returns the endpoint whose certificate is not verified, if
+// any, as set using the WithInsecureSkipVerify option.
+func (d *SplunkAudit) InsecureEndpoint() string {
+	if d.insecureClient == nil {
+		return ""
+	}
+	return d.insecure
+}
+
+func (d *SplunkAudit) httpClient(endpoint string) *http.Client {
+	if d.insecureClient != nil && endpoint == d.insecure {
+		return d.insecureClient
+	}
+	return d.client
+}
+
+func (d *SplunkAudit) hasEndpoint(endpoint string) bool {
+	for _, e := range d.SplunkEnv.AllEndpoints() {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// UserAgent returns the User-Agent sent with requests to Splunk, which
+// defaults to "GABI/<version>" unless set using the WithUserAgent option.
+func (d *SplunkAudit) UserAgent() string {
+	if d.userAgent != "" {
+		return d.userAgent
+	}
+	return fmt.Sprintf("GABI/%s", version.Version())
 }
 
 func (d *SplunkAudit) Write(q *QueryData) error {
-	query := &SplunkQueryData{
-		Index:      d.SplunkEnv.Index,
+	return d.WriteContext(context.Background(), q)
+}
+
+// WriteContext sends the event to Splunk, failing over to the next endpoint
+// until the context is done, with each request bounded by its own timeout.
+func (d *SplunkAudit) WriteContext(ctx context.Context, q *QueryData) error {
+	content, err := d.Preview(q)
+	if err != nil {
+		return fmt.Errorf("unable to marshal Splunk audit: %w", err)
+	}
+	path, params := d.collector(q)
+
+	var errs error
+	for _, endpoint := range d.endpoints() {
+		failover, err := d.send(ctx, endpoint, path, params, content)
+		if err == nil {
+			d.setHealthy(endpoint, true)
+			return nil
+		}
+		if !failover {
+			return err
+		}
+		d.setHealthy(endpoint, false)
+		errs = multierr.Append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return errs
+}
+
+// Preview returns the event as it would be sent to Splunk. The raw collector
+// endpoint takes the bare event, with its metadata given as query parameters.
+func (d *SplunkAudit) Preview(q *QueryData) ([]byte, error) {
+	namespace := d.namespace(q)
+
+	pod := q.Pod
+	if pod == "" {
+		pod = d.SplunkEnv.Pod
+	}
+	event := newEventData(q, namespace, pod, d.names)
+
+	if d.SplunkEnv.Collector == splunk.CollectorRaw {
+		return json.Marshal(event)
+	}
+
+	return json.Marshal(&SplunkQueryData{
+		Event:      event,
+		Index:      d.index(namespace),
 		Host:       d.SplunkEnv.Host,
 		Source:     splunkSource,
 		SourceType: splunkSourceType,
-		Time:       q.Timestamp,
+		Time:       eventTime(q.Timestamp, d.SplunkEnv.TimeFormat),
+	})
+}
+
+func (d *SplunkAudit) namespace(q *QueryData) string {
+	if q.Namespace != "" {
+		return q.Namespace
 	}
+	return d.SplunkEnv.Namespace
+}
 
-	query.Event = &SplunkEventData{
-		Query:     q.Query,
-		User:      q.User,
-		Namespace: d.SplunkEnv.Namespace,
-		Pod:       d.SplunkEnv.Pod,
+// The path of the collector endpoint events are sent to, along with the query
+// parameters carrying the metadata of raw events, which the raw endpoint only
+// accepts as seconds since the Unix epoch. The source and source type are left
+// to the HEC input, as raw events are parsed as configured there.
+func (d *SplunkAudit) collector(q *QueryData) (string, url.Values) {
+	if d.SplunkEnv.Collector != splunk.CollectorRaw {
+		return "/services/collector/event", nil
 	}
 
-	content, err := json.Marshal(query)
-	if err != nil {
-		return fmt.Errorf("unable to marshal Splunk audit: %w", err)
+	params := url.Values{}
+	params.Set("channel", d.SplunkEnv.Channel)
+	if index := d.index(d.namespace(q)); index != "" {
+		params.Set("index", index)
+	}
+	if d.SplunkEnv.Host != "" {
+		params.Set("host", d.SplunkEnv.Host)
 	}
+	params.Set("time", strconv.FormatInt(q.Timestamp, 10))
 
-	url := fmt.Sprintf("%s/services/collector/event", d.SplunkEnv.Endpoint)
+	return "/services/collector/raw", params
+}
+
+// Events are sent to the index mapped to their namespace, if any, and to the
+// default index otherwise.
+func (d *SplunkAudit) index(namespace string) string {
+	if index, ok := d.SplunkEnv.IndexMap[namespace]; ok {
+		return index
+	}
+	return d.SplunkEnv.Index
+}
+
+// The given event is sent to a single endpoint. Connection errors and server
+// errors are reported as failover, after which the next endpoint is tried.
+func (d *SplunkAudit) send(ctx context.Context, endpoint, path string, params url.Values, content []byte) (bool, error) {
+	url, err := collectorURL(endpoint, path)
+	if err != nil {
+		return false, fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+	if len(params) > 0 {
+		url += "?" + params.Encode()
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(content))
 	if err != nil {
-		return fmt.Errorf("unable to create request to Splunk: %w", err)
+		return false, fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+	if err := d.authorize(req); err != nil {
+		return false, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Splunk %s", d.SplunkEnv.Token))
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-	req.Header.Set("User-Agent", fmt.Sprintf("GABI/%s", version.Version()))
+	req.Header.Set("User-Agent", d.UserAgent())
+	d.setChannel(req)
 
-	resp, err := d.client.Do(req)
+	resp, err := d.httpClient(endpoint).Do(req)
 	if err != nil {
-		return fmt.Errorf("unable to send request to Splunk: %w", err)
+		return true, failure(FailureConnectivity, fmt.Errorf("unable to send request to Splunk: %w", err))
 	}
 	defer func() { _ = resp.Body.Close() }()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("unable to read Splunk response body: %w", err)
+		return true, failure(FailureConnectivity, fmt.Errorf("unable to read Splunk response body: %w", err))
+	}
+
+	// A valid-looking body can come with an error status, thus the status is
+	// always checked first.
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err := fmt.Errorf("unable to write to Splunk: %s: %s", resp.Status, responseSnippet(body))
+		return resp.StatusCode >= http.StatusInternalServerError, failure(statusReason(resp.StatusCode), err)
 	}
 
 	splunk := struct {
-		Code int    `json:"code"`
-		Text string `json:"text"`
+		Code  int    `json:"code"`
+		Text  string `json:"text"`
+		AckID *int64 `json:"ackId"`
 	}{}
 
 	err = json.Unmarshal(body, &splunk)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal Splunk response: %w", err)
+		return false, failure(FailureRejection, fmt.Errorf("unable to unmarshal Splunk response: %w", err))
 	}
 	if splunk.Code > 0 {
-		return fmt.Errorf("unable to write to Splunk: %s (%d)", splunk.Text, splunk.Code)
+		return false, failure(FailureRejection, fmt.Errorf("unable to write to Splunk: %s (%d)", splunk.Text, splunk.Code))
+	}
+
+	if !d.SplunkEnv.Ack {
+		return false, nil
+	}
+	if splunk.AckID == nil {
+		return false, failure(FailureRejection, errors.New("unable to confirm Splunk acknowledgement: no acknowledgement ID returned"))
+	}
+
+	// The event has been accepted, thus it is not sent to another endpoint,
+	// even when it cannot be confirmed as indexed.
+	return false, d.acknowledge(ctx, endpoint, *splunk.AckID)
+}
+
+// Endpoints are given as URLs, or as addresses using HTTPS. IPv6 literals are
+// expected in brackets, such as "[::1]:8088", with unbracketed ones, which are
+// ambiguous as soon as a port is set, only accepted without a port.
+func collectorURL(endpoint, path string) (string, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(u.Host, "[") && strings.Count(u.Host, ":") > 1 {
+		if net.ParseIP(u.Host) == nil {
+			return "", fmt.Errorf("invalid host in endpoint: %s", u.Redacted())
+		}
+		u.Host = "[" + u.Host + "]"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+
+	return u.String(), nil
+}
+
+// The event time defaults to seconds since the Unix epoch, as expected by
+// Splunk, unless configured otherwise.
+func eventTime(timestamp int64, format string) interface{} {
+	switch format {
+	case splunk.TimeFormatMilliseconds:
+		return time.Unix(timestamp, 0).UnixMilli()
+	case splunk.TimeFormatRFC3339:
+		return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+	default:
+		return timestamp
+	}
+}
+
+func statusReason(status int) FailureReason {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return FailureAuth
+	case status >= http.StatusInternalServerError:
+		return FailureConnectivity
+	default:
+		return FailureRejection
+	}
+}
+
+func responseSnippet(body []byte) string {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) > maxResponseSnippet {
+		snippet = snippet[:maxResponseSnippet] + "..."
+	}
+	return snippet
+}
+
+func (d *SplunkAudit) authorize(req *http.Request) error {
+	if d.tokens == nil {
+		req.Header.Set("Authorization", fmt.Sprintf("Splunk %s", d.SplunkEnv.Token))
+		return nil
+	}
+
+	token, err := d.tokens.Token(req.Context())
+	if err != nil {
+		return failure(FailureAuth, fmt.Errorf("unable to authorize request to Splunk: %w", err))
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	return nil
+}
+
+// Endpoints are returned in round-robin order to distribute load, with those
+// recently marked as unhealthy moved to the end, so that these are only tried
+// as a last resort rather than dropping the audit altogether.
+func (d *SplunkAudit) endpoints() []string {
+	all := d.SplunkEnv.AllEndpoints()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	start := d.next % len(all)
+	d.next++
+
+	now := d.now()
+
+	healthy := make([]string, 0, len(all))
+	var unhealthy []string
+	for i := range all {
+		endpoint := all[(start+i)%len(all)]
+		if until, ok := d.unhealthy[endpoint]; ok && now.Before(until) {
+			unhealthy = append(unhealthy, endpoint)
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+func (d *SplunkAudit) now() time.Time {
+	return d.currentClock().Now()
+}
+
+// The clock is not set when created without NewSplunkAudit.
+func (d *SplunkAudit) currentClock() Clock {
+	if d.clock == nil {
+		return SystemClock
+	}
+	return d.clock
+}
+
+func (d *SplunkAudit) setHealthy(endpoint string, healthy bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if healthy {
+		delete(d.unhealthy, endpoint)
+		return
+	}
+	if d.unhealthy == nil {
+		d.unhealthy = make(map[string]time.Time)
+	}
+	d.unhealthy[endpoint] = d.now().Add(unhealthyPeriod)
+}
+
+// Check reports Splunk as reachable when at least one of the configured
+// endpoints is healthy.
+func (d *SplunkAudit) Check(ctx context.Context) error {
+	var errs error
+	for _, endpoint := range d.SplunkEnv.AllEndpoints() {
+		err := d.check(ctx, endpoint)
+		if err == nil {
+			return nil
+		}
+		errs = multierr.Append(errs, err)
+	}
+
+	return errs
+}
+
+func (d *SplunkAudit) check(ctx context.Context, endpoint string) error {
+	url, err := collectorURL(endpoint, "/services/collector/health")
+	if err != nil {
+		return fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("unable to create request to Splunk: %w", err)
+	}
+	if err := d.authorize(req); err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", d.UserAgent())
+	d.setChannel(req)
+
+	resp, err := d.httpClient(endpoint).Do(req)
+	if err != nil {
+		return failure(FailureConnectivity, fmt.Errorf("unable to send request to Splunk: %w", err))
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return failure(statusReason(resp.StatusCode), fmt.Errorf("unable to reach Splunk: %s", resp.Status))
 	}
 
 	return nil
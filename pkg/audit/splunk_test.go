@@ -2,11 +2,17 @@ package audit
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,33 +27,126 @@ func TestNewSplunkAudit(t *testing.T) {
 
 	cases := []struct {
 		description string
-		given       Option
+		given       []Option
 		want        *splunk.Env
-		option      bool
+		error       bool
+		message     string
 	}{
 		{
 			"using option that updates internal state",
-			func(s *SplunkAudit) {
+			[]Option{func(s *SplunkAudit) {
 				s.SplunkEnv.Index = "test"
-			},
+			}},
 			&splunk.Env{Index: "test"},
-			true,
+			false,
+			``,
 		},
 		{
 			"using option that does nothing",
-			func(s *SplunkAudit) {
+			[]Option{func(s *SplunkAudit) {
 				// No-op.
-			},
+			}},
 			&splunk.Env{},
-			true,
+			false,
+			``,
 		},
 		{
 			"without using any options",
-			func(s *SplunkAudit) {
-				// No-op.
-			},
+			[]Option{},
+			&splunk.Env{},
+			false,
+			``,
+		},
+		{
+			"using TLS configuration and connect timeout for the default HTTP client",
+			[]Option{WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}), WithConnectTimeout(time.Second)},
+			&splunk.Env{},
+			false,
+			``,
+		},
+		{
+			"using custom HTTP client with TLS configuration",
+			[]Option{WithHTTPClient(http.DefaultClient), WithTLSConfig(&tls.Config{})},
+			nil,
+			true,
+			`invalid Splunk audit options: WithHTTPClient cannot be combined with WithTLSConfig, configure TLS on the HTTP client instead`,
+		},
+		{
+			"using custom HTTP client with connect timeout",
+			[]Option{WithConnectTimeout(time.Second), WithHTTPClient(http.DefaultClient)},
+			nil,
+			true,
+			`invalid Splunk audit options: WithHTTPClient cannot be combined with WithConnectTimeout, configure the timeout on the HTTP client instead`,
+		},
+		{
+			"using connection pool options for the default HTTP client",
+			[]Option{WithMaxIdleConns(10), WithIdleConnTimeout(time.Minute), WithHTTP2(false)},
 			&splunk.Env{},
 			false,
+			``,
+		},
+		{
+			"using custom HTTP client with connection pool options",
+			[]Option{WithHTTPClient(http.DefaultClient), WithMaxIdleConns(10)},
+			nil,
+			true,
+			`invalid Splunk audit options: WithHTTPClient cannot be combined with the connection pool options, configure the transport of the HTTP client instead`,
+		},
+		{
+			"using custom HTTP client skipping TLS verification",
+			[]Option{WithHTTPClient(http.DefaultClient), WithInsecureSkipVerify("https://splunk.example.com")},
+			nil,
+			true,
+			`invalid Splunk audit options: WithHTTPClient cannot be combined with WithInsecureSkipVerify, configure TLS on the HTTP client instead`,
+		},
+		{
+			"skipping TLS verification for unknown endpoint",
+			[]Option{WithInsecureSkipVerify("https://splunk.example.com")},
+			nil,
+			true,
+			`invalid Splunk audit options: endpoint skipping TLS verification is not a Splunk endpoint: https://splunk.example.com`,
+		},
+		{
+			"using negative maximum idle connections",
+			[]Option{WithMaxIdleConns(-1)},
+			nil,
+			true,
+			`invalid Splunk audit options: maximum idle connections cannot be negative`,
+		},
+		{
+			"using negative idle connection timeout",
+			[]Option{WithIdleConnTimeout(-time.Second)},
+			nil,
+			true,
+			`invalid Splunk audit options: idle connection timeout cannot be negative`,
+		},
+		{
+			"using nil HTTP client",
+			[]Option{WithHTTPClient(nil)},
+			nil,
+			true,
+			`invalid Splunk audit options: HTTP client cannot be nil`,
+		},
+		{
+			"using negative connect timeout",
+			[]Option{WithConnectTimeout(-time.Second)},
+			nil,
+			true,
+			`invalid Splunk audit options: connect timeout cannot be negative`,
+		},
+		{
+			"using nil clock",
+			[]Option{WithClock(nil)},
+			nil,
+			true,
+			`invalid Splunk audit options: clock cannot be nil`,
+		},
+		{
+			"using conflicting field names",
+			[]Option{WithFieldNames(map[string]string{"query": "user"})},
+			nil,
+			true,
+			`invalid Splunk audit options: audit fields query and user cannot share the same name: user`,
 		},
 	}
 
@@ -56,14 +155,16 @@ func TestNewSplunkAudit(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			t.Parallel()
 
-			var actual *SplunkAudit
+			actual, err := NewSplunkAudit(&splunk.Env{}, tc.given...)
 
-			if tc.option {
-				actual = NewSplunkAudit(&splunk.Env{}, tc.given)
-			} else {
-				actual = NewSplunkAudit(&splunk.Env{})
+			if tc.error {
+				require.Error(t, err)
+				assert.Nil(t, actual)
+				assert.EqualError(t, err, tc.message)
+				return
 			}
 
+			require.NoError(t, err)
 			require.NotNil(t, actual)
 			assert.IsType(t, &SplunkAudit{}, actual)
 			assert.Equal(t, tc.want, actual.SplunkEnv)
@@ -96,7 +197,8 @@ func TestWithHTTPClient(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			t.Parallel()
 
-			actual := NewSplunkAudit(&splunk.Env{}, tc.given...)
+			actual, err := NewSplunkAudit(&splunk.Env{}, tc.given...)
+			require.NoError(t, err)
 
 			require.NotNil(t, actual)
 
@@ -141,7 +243,8 @@ func TestSetHTTPClient(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			t.Parallel()
 
-			actual := NewSplunkAudit(&splunk.Env{})
+			actual, err := NewSplunkAudit(&splunk.Env{})
+			require.NoError(t, err)
 			tc.given(actual)
 
 			require.NotNil(t, actual)
@@ -158,6 +261,57 @@ func TestSetHTTPClient(t *testing.T) {
 	}
 }
 
+func TestWithUserAgent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []Option
+		want        string
+	}{
+		{
+			"using default User-Agent",
+			[]Option{},
+			fmt.Sprintf("GABI/%s", version.Version()),
+		},
+		{
+			"using custom User-Agent",
+			[]Option{WithUserAgent("test/1.0")},
+			"test/1.0",
+		},
+		{
+			"using empty custom User-Agent",
+			[]Option{WithUserAgent("")},
+			fmt.Sprintf("GABI/%s", version.Version()),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var header string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header.Get("User-Agent")
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer server.Close()
+
+			options := append([]Option{WithHTTPClient(http.DefaultClient)}, tc.given...)
+			actual, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL}, options...)
+			require.NoError(t, err)
+
+			err = actual.Write(&QueryData{Query: "select 1;", User: "test"})
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, actual.UserAgent())
+			assert.Equal(t, tc.want, header)
+		})
+	}
+}
+
 func TestSplunkAduitWrite(t *testing.T) {
 	t.Parallel()
 
@@ -205,8 +359,8 @@ func TestSplunkAduitWrite(t *testing.T) {
 			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test"},(.*),"time":1672531200`),
 		},
 		{
-			"valid query with no SQL statements provided",
-			QueryData{Query: "", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with failed outcome",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Error: "test"},
 			func() *http.Header {
 				return &http.Header{
 					"Accept":          []string{"application/json"},
@@ -235,23 +389,27 @@ func TestSplunkAduitWrite(t *testing.T) {
 			},
 			false,
 			``,
-			regexp.MustCompile(`{"query":"","user":"test","namespace":"test","pod":"test"},(.*),"time":\d{10}`),
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test","success":false,"error":"test"},(.*),"time":1672531200`),
 		},
 		{
-			"valid query with invalid Splunk environment set",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with static fields",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Fields: map[string]string{"team": "sre", "environment": "prod", "user": "admin"}},
 			func() *http.Header {
 				return &http.Header{
 					"Accept":          []string{"application/json"},
 					"Accept-Encoding": []string{"gzip"},
-					"Authorization":   []string{"Splunk"},
+					"Authorization":   []string{"Splunk test123"},
 					"Content-Type":    []string{"application/json; charset=utf-8"},
 					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
 				}
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint: s.URL,
+					Endpoint:  s.URL,
+					Token:     "test123",
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
@@ -264,69 +422,113 @@ func TestSplunkAduitWrite(t *testing.T) {
 			},
 			false,
 			``,
-			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"","pod":""},(.*),"time":\d{10}`),
+			regexp.MustCompile(`{"environment":"prod","namespace":"test","pod":"test","query":"select 1;","team":"sre","user":"test"},(.*),"time":1672531200`),
 		},
 		{
-			"valid query with no Splunk endpoint configured",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with seconds time format",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
 			func() *http.Header {
-				return &http.Header{}
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
 			},
 			func(s *httptest.Server) *splunk.Env {
-				return &splunk.Env{}
+				return &splunk.Env{
+					Endpoint:   s.URL,
+					Token:      "test123",
+					Host:       "test",
+					Namespace:  "test",
+					Pod:        "test",
+					TimeFormat: splunk.TimeFormatSeconds,
+				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
 				return func(w http.ResponseWriter, r *http.Request) {
-					// No-op.
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to send request to Splunk`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test"},(.*),"time":1672531200}`),
 		},
 		{
-			"valid query with invalid Splunk endpoint configured",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with milliseconds time format",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
 			func() *http.Header {
-				return &http.Header{}
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint: "http://test/%",
+					Endpoint:   s.URL,
+					Token:      "test123",
+					Host:       "test",
+					Namespace:  "test",
+					Pod:        "test",
+					TimeFormat: splunk.TimeFormatMilliseconds,
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
 				return func(w http.ResponseWriter, r *http.Request) {
-					// No-op.
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to create request to Splunk`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test"},(.*),"time":1672531200000}`),
 		},
 		{
-			"valid query with unreachable Splunk endpoint configured",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with RFC3339 time format",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
 			func() *http.Header {
-				return &http.Header{}
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint: "http://test",
+					Endpoint:   s.URL,
+					Token:      "test123",
+					Host:       "test",
+					Namespace:  "test",
+					Pod:        "test",
+					TimeFormat: splunk.TimeFormatRFC3339,
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
 				return func(w http.ResponseWriter, r *http.Request) {
-					// No-op.
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to send request to Splunk`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test"},(.*),"time":"2023-01-01T00:00:00Z"}`),
 		},
 		{
-			"valid query with an error in Splunk response",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"valid query with rejection reason set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Rejection: "test"},
 			func() *http.Header {
 				return &http.Header{
 					"Accept":          []string{"application/json"},
@@ -338,8 +540,11 @@ func TestSplunkAduitWrite(t *testing.T) {
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint: s.URL,
-					Token:    "test123",
+					Endpoint:  s.URL,
+					Token:     "test123",
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
@@ -347,16 +552,16 @@ func TestSplunkAduitWrite(t *testing.T) {
 					_, _ = io.Copy(b, r.Body)
 					*h = r.Header
 					h.Del("Content-Length")
-					fmt.Fprintln(w, `{"Code":123,"Text":"test"}`)
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to write to Splunk`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"test","pod":"test","rejection":"test"},(.*),"time":1672531200`),
 		},
 		{
-			"valid query with malformed JSON in Splunk response",
-			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			"lifecycle event",
+			QueryData{User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), EventType: EventReload, Detail: "users: 2"},
 			func() *http.Header {
 				return &http.Header{
 					"Accept":          []string{"application/json"},
@@ -368,8 +573,11 @@ func TestSplunkAduitWrite(t *testing.T) {
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint: s.URL,
-					Token:    "test123",
+					Endpoint:  s.URL,
+					Token:     "test123",
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
@@ -377,50 +585,61 @@ func TestSplunkAduitWrite(t *testing.T) {
 					_, _ = io.Copy(b, r.Body)
 					*h = r.Header
 					h.Del("Content-Length")
-					fmt.Fprintln(w, `{"Code:0,"Text":""}`)
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to unmarshal Splunk response`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"","user":"test","namespace":"test","pod":"test","event_type":"config_reload","detail":"users: 2"},(.*),"time":1672531200`),
 		},
 		{
-			"invalid query and invalid Splunk environment set",
-			QueryData{},
+			"valid query with no SQL statements provided",
+			QueryData{Query: "", User: "test", Timestamp: time.Now().Unix()},
 			func() *http.Header {
-				return &http.Header{}
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
 			},
 			func(s *httptest.Server) *splunk.Env {
-				return &splunk.Env{}
+				return &splunk.Env{
+					Endpoint:  s.URL,
+					Token:     "test123",
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
+				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
 				return func(w http.ResponseWriter, r *http.Request) {
-					// No-op.
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
 				}
 			},
-			true,
-			`unable to send request to Splunk`,
-			regexp.MustCompile(``),
+			false,
+			``,
+			regexp.MustCompile(`{"query":"","user":"test","namespace":"test","pod":"test"},(.*),"time":\d{10}`),
 		},
 		{
-			"invalid query data with nothing set",
-			QueryData{},
+			"valid query with invalid Splunk environment set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
 			func() *http.Header {
 				return &http.Header{
 					"Accept":          []string{"application/json"},
 					"Accept-Encoding": []string{"gzip"},
-					"Authorization":   []string{"Splunk test123"},
+					"Authorization":   []string{"Splunk"},
 					"Content-Type":    []string{"application/json; charset=utf-8"},
 					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
 				}
 			},
 			func(s *httptest.Server) *splunk.Env {
 				return &splunk.Env{
-					Endpoint:  s.URL,
-					Token:     "test123",
-					Host:      "test",
-					Namespace: "test",
-					Pod:       "test",
+					Endpoint: s.URL,
 				}
 			},
 			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
@@ -433,13 +652,182 @@ func TestSplunkAduitWrite(t *testing.T) {
 			},
 			false,
 			``,
-			regexp.MustCompile(`{"query":"","user":"","namespace":"test","pod":"test"},(.*),"time":0`),
+			regexp.MustCompile(`{"query":"select 1;","user":"test","namespace":"","pod":""},(.*),"time":\d{10}`),
 		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.description, func(t *testing.T) {
+		{
+			"valid query with no Splunk endpoint configured",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			func() *http.Header {
+				return &http.Header{}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					// No-op.
+				}
+			},
+			true,
+			`unable to send request to Splunk`,
+			regexp.MustCompile(``),
+		},
+		{
+			"valid query with invalid Splunk endpoint configured",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			func() *http.Header {
+				return &http.Header{}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint: "http://test/%",
+				}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					// No-op.
+				}
+			},
+			true,
+			`unable to create request to Splunk`,
+			regexp.MustCompile(``),
+		},
+		{
+			"valid query with unreachable Splunk endpoint configured",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			func() *http.Header {
+				return &http.Header{}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint: "http://test",
+				}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					// No-op.
+				}
+			},
+			true,
+			`unable to send request to Splunk`,
+			regexp.MustCompile(``),
+		},
+		{
+			"valid query with an error in Splunk response",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			func() *http.Header {
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint: s.URL,
+					Token:    "test123",
+				}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":123,"Text":"test"}`)
+				}
+			},
+			true,
+			`unable to write to Splunk`,
+			regexp.MustCompile(``),
+		},
+		{
+			"valid query with malformed JSON in Splunk response",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Now().Unix()},
+			func() *http.Header {
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint: s.URL,
+					Token:    "test123",
+				}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code:0,"Text":""}`)
+				}
+			},
+			true,
+			`unable to unmarshal Splunk response`,
+			regexp.MustCompile(``),
+		},
+		{
+			"invalid query and invalid Splunk environment set",
+			QueryData{},
+			func() *http.Header {
+				return &http.Header{}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					// No-op.
+				}
+			},
+			true,
+			`unable to send request to Splunk`,
+			regexp.MustCompile(``),
+		},
+		{
+			"invalid query data with nothing set",
+			QueryData{},
+			func() *http.Header {
+				return &http.Header{
+					"Accept":          []string{"application/json"},
+					"Accept-Encoding": []string{"gzip"},
+					"Authorization":   []string{"Splunk test123"},
+					"Content-Type":    []string{"application/json; charset=utf-8"},
+					"User-Agent":      []string{fmt.Sprintf("GABI/%s", version.Version())},
+				}
+			},
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint:  s.URL,
+					Token:     "test123",
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
+				}
+			},
+			func(b *bytes.Buffer, h *http.Header) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(b, r.Body)
+					*h = r.Header
+					h.Del("Content-Length")
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+				}
+			},
+			false,
+			``,
+			regexp.MustCompile(`{"query":"","user":"","namespace":"test","pod":"test"},(.*),"time":0`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
 			t.Parallel()
 
 			var server bytes.Buffer
@@ -465,3 +853,540 @@ func TestSplunkAduitWrite(t *testing.T) {
 		})
 	}
 }
+
+func TestSplunkAuditWriteStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		status      int
+		body        string
+		want        string
+		reason      FailureReason
+	}{
+		{
+			"unauthorized with valid-looking body",
+			http.StatusUnauthorized,
+			`{"Code":0,"Text":""}`,
+			`unable to write to Splunk: 401 Unauthorized: {"Code":0,"Text":""}`,
+			FailureAuth,
+		},
+		{
+			"forbidden",
+			http.StatusForbidden,
+			`{"text":"Invalid token","code":4}`,
+			`unable to write to Splunk: 403 Forbidden: {"text":"Invalid token","code":4}`,
+			FailureAuth,
+		},
+		{
+			"service unavailable with long body",
+			http.StatusServiceUnavailable,
+			strings.Repeat("a", 300),
+			`unable to write to Splunk: 503 Service Unavailable: ` + strings.Repeat("a", 256) + `...`,
+			FailureConnectivity,
+		},
+		{
+			"bad request",
+			http.StatusBadRequest,
+			`{"text":"Invalid data format","code":6}`,
+			`unable to write to Splunk: 400 Bad Request: {"text":"Invalid data format","code":6}`,
+			FailureRejection,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprintln(w, tc.body)
+			}))
+			defer server.Close()
+
+			s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL}, WithHTTPClient(http.DefaultClient))
+			require.NoError(t, err)
+			err = s.Write(&QueryData{Query: "select 1;", User: "test"})
+
+			require.EqualError(t, err, tc.want)
+			assert.Equal(t, tc.reason, Reason(err))
+		})
+	}
+}
+
+func TestSplunkAuditConnectionReuse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []Option
+		expected    int32
+	}{
+		{
+			"default HTTP client",
+			[]Option{},
+			1,
+		},
+		{
+			"keep-alives disabled",
+			[]Option{WithMaxIdleConns(0)},
+			3,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var connections atomic.Int32
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					connections.Add(1)
+				}
+			}
+			server.Start()
+			defer server.Close()
+
+			s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL}, tc.given...)
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+			}
+
+			assert.Equal(t, tc.expected, connections.Load())
+		})
+	}
+}
+
+func TestSplunkAuditWriteRaw(t *testing.T) {
+	t.Parallel()
+
+	var (
+		path  string
+		query url.Values
+		body  string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		path, query, body = r.URL.Path, r.URL.Query(), string(b)
+		fmt.Fprintln(w, `{"text":"Success","code":0}`)
+	}))
+	defer server.Close()
+
+	se := &splunk.Env{
+		Endpoint:  server.URL,
+		Index:     "test",
+		Host:      "test",
+		Namespace: "test",
+		Pod:       "test",
+		Channel:   "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d",
+		Collector: splunk.CollectorRaw,
+	}
+	s, err := NewSplunkAudit(se, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test", Timestamp: 1136214245}))
+
+	assert.Equal(t, "/services/collector/raw", path)
+	assert.Equal(t, url.Values{
+		"channel": {"0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d"},
+		"index":   {"test"},
+		"host":    {"test"},
+		"time":    {"1136214245"},
+	}, query)
+	// The bare event is sent, without the envelope of the event endpoint.
+	assert.Equal(t, `{"query":"select 1;","user":"test","namespace":"test","pod":"test"}`, body)
+}
+
+func TestSplunkAuditRawWithoutChannel(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewSplunkAudit(&splunk.Env{Endpoint: "test", Collector: splunk.CollectorRaw})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "raw collector endpoint requires a channel")
+}
+
+func TestSplunkAuditWriteFailover(t *testing.T) {
+	t.Parallel()
+
+	var healthy, unhealthy int
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		unhealthy++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthy++
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: failing.URL, Endpoints: []string{server.URL}}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	}
+
+	// The failing endpoint is skipped once marked as unhealthy.
+	assert.Equal(t, 1, unhealthy)
+	assert.Equal(t, 4, healthy)
+}
+
+func TestSplunkAuditWriteRoundRobin(t *testing.T) {
+	t.Parallel()
+
+	var first, second int
+
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first++
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server1.Close()
+
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		second++
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server2.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server1.URL, Endpoints: []string{server2.URL}}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	}
+
+	assert.Equal(t, 2, first)
+	assert.Equal(t, 2, second)
+}
+
+func TestSplunkAuditWriteAllEndpointsFailing(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Endpoints: []string{"http://test"}}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+	err = s.Write(&QueryData{Query: "select 1;", User: "test"})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unable to write to Splunk: 500 Internal Server Error`)
+	assert.Contains(t, err.Error(), `unable to send request to Splunk`)
+}
+
+func TestSplunkAuditWriteContext(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Second):
+		}
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}
+
+	server1 := httptest.NewServer(http.HandlerFunc(slow))
+	defer server1.Close()
+
+	server2 := httptest.NewServer(http.HandlerFunc(slow))
+	defer server2.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server1.URL, Endpoints: []string{server2.URL}}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = WriteContext(ctx, s, &QueryData{Query: "select 1;", User: "test"})
+
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	// No other endpoint is tried once the deadline has passed.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestCollectorURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		want        string
+		error       bool
+	}{
+		{"endpoint with scheme", "https://splunk.example.com:8088", "https://splunk.example.com:8088/services/collector/event", false},
+		{"endpoint with trailing slash", "https://splunk.example.com:8088/", "https://splunk.example.com:8088/services/collector/event", false},
+		{"endpoint with path", "https://example.com/splunk", "https://example.com/splunk/services/collector/event", false},
+		{"endpoint without scheme", "splunk.example.com:8088", "https://splunk.example.com:8088/services/collector/event", false},
+		{"IPv6 endpoint with scheme and port", "http://[::1]:8088", "http://[::1]:8088/services/collector/event", false},
+		{"IPv6 endpoint without scheme", "[::1]:8088", "https://[::1]:8088/services/collector/event", false},
+		{"IPv6 endpoint with zone", "https://[fe80::1%25eth0]:8088", "https://[fe80::1%25eth0]:8088/services/collector/event", false},
+		{"unbracketed IPv6 endpoint without port", "https://2001:db8::1", "https://[2001:db8::1]/services/collector/event", false},
+		{"unbracketed invalid IPv6 endpoint", "https://2001:db8::1::8088", ``, true},
+		{"invalid endpoint", "http://test/%", ``, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := collectorURL(tc.given, "/services/collector/event")
+
+			if tc.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, actual)
+		})
+	}
+}
+
+func TestSplunkAuditWriteIPv6(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+
+	// The endpoint is given as a bracketed address with a port, without a scheme.
+	endpoint := strings.TrimPrefix(server.URL, "https://")
+	require.True(t, strings.HasPrefix(endpoint, "[::1]:"))
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: endpoint, Token: "test123"}, WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	require.NoError(t, s.Check(context.Background()))
+}
+
+func TestSplunkAuditInsecureSkipVerify(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+	})
+
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+	insecure := httptest.NewTLSServer(handler)
+	defer insecure.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: secure.URL, Endpoints: []string{insecure.URL}}, WithInsecureSkipVerify(insecure.URL))
+	require.NoError(t, err)
+	assert.Equal(t, insecure.URL, s.InsecureEndpoint())
+
+	// The certificate of every other endpoint is still verified.
+	err = s.check(context.Background(), secure.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "certificate")
+
+	require.NoError(t, s.check(context.Background(), insecure.URL))
+
+	s.SetHTTPClient(http.DefaultClient)
+	assert.Empty(t, s.InsecureEndpoint())
+}
+
+func TestSplunkAuditCheck(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		handler     func(w http.ResponseWriter, r *http.Request)
+		error       bool
+		want        string
+	}{
+		{
+			"Splunk is reachable",
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+			},
+			false,
+			``,
+		},
+		{
+			"Splunk is not healthy",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			true,
+			`unable to reach Splunk: 503 Service Unavailable`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var path string
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				tc.handler(w, r)
+			}))
+			defer server.Close()
+
+			s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Token: "test123"})
+			require.NoError(t, err)
+			err = s.Check(context.Background())
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, "/services/collector/health", path)
+		})
+	}
+}
+
+func TestSplunkAuditUnhealthyPeriod(t *testing.T) {
+	t.Parallel()
+
+	clock := NewFakeClock(time.Now())
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: "https://test1", Endpoints: []string{"https://test2"}}, WithClock(clock))
+	require.NoError(t, err)
+	s.setHealthy("https://test1", false)
+
+	// Unhealthy endpoints are only tried as a last resort, until the period has passed.
+	assert.Equal(t, []string{"https://test2", "https://test1"}, s.endpoints())
+	assert.Equal(t, []string{"https://test2", "https://test1"}, s.endpoints())
+
+	clock.Advance(unhealthyPeriod - time.Second)
+	assert.Equal(t, []string{"https://test2", "https://test1"}, s.endpoints())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, []string{"https://test2", "https://test1"}, s.endpoints())
+	assert.Equal(t, []string{"https://test1", "https://test2"}, s.endpoints())
+}
+
+func TestSplunkAuditWriteFieldNames(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&body, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Namespace: "test", Pod: "test"},
+		WithHTTPClient(http.DefaultClient), WithFieldNames(map[string]string{"query": "sql", "user": "actor"}))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test", Fields: map[string]string{"team": "sre"}}))
+	assert.Contains(t, body.String(), `"event":{"actor":"test","namespace":"test","pod":"test","sql":"select 1;","team":"sre"}`)
+}
+
+func TestSplunkAuditWriteIndexMap(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		namespace   string
+		want        string
+	}{
+		{
+			"namespace mapped to an index",
+			"team-a",
+			`"event":{"query":"select 1;","user":"test","namespace":"team-a","pod":"test"},"index":"audit-a"`,
+		},
+		{
+			"another namespace mapped to an index",
+			"team-b",
+			`"event":{"query":"select 1;","user":"test","namespace":"team-b","pod":"test"},"index":"audit-b"`,
+		},
+		{
+			"namespace not mapped to any index",
+			"team-c",
+			`"event":{"query":"select 1;","user":"test","namespace":"team-c","pod":"test"},"index":"default"`,
+		},
+		{
+			"namespace of the instance used",
+			"",
+			`"event":{"query":"select 1;","user":"test","namespace":"team-a","pod":"test"},"index":"audit-a"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body bytes.Buffer
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(&body, r.Body)
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer server.Close()
+
+			se := &splunk.Env{
+				Endpoint:  server.URL,
+				Index:     "default",
+				IndexMap:  map[string]string{"team-a": "audit-a", "team-b": "audit-b"},
+				Namespace: "team-a",
+				Pod:       "test",
+			}
+			s, err := NewSplunkAudit(se, WithHTTPClient(http.DefaultClient))
+			require.NoError(t, err)
+
+			require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test", Namespace: tc.namespace}))
+			assert.Contains(t, body.String(), tc.want)
+		})
+	}
+}
+
+func TestSplunkAuditWriteResponse(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&body, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer server.Close()
+
+	s, err := NewSplunkAudit(&splunk.Env{Endpoint: server.URL, Namespace: "test", Pod: "test"}, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+
+	// Empty responses still report their size.
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test", Executed: true, StatusCode: 504}))
+	assert.Contains(t, body.String(), `"success":false,"status_code":504,"response_bytes":0}`)
+}
@@ -0,0 +1,366 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+const (
+	spoolFileExtension = ".json"
+
+	defaultSpoolRetryInterval = 30 * time.Second
+)
+
+var ErrSpoolFull = errors.New("audit spool is full")
+
+// Spool wraps an audit backend, and persists events that could not be written
+// to a directory on disk, from which delivery is retried in the background
+// until it succeeds, thus giving at-least-once delivery. Once full, events are
+// either rejected, or dropped when so configured.
+type Spool struct {
+	audit     Audit
+	dir       string
+	maxEvents int
+	maxBytes  int64
+	drop      bool
+	interval  time.Duration
+	clock     Clock
+
+	onDepthChange func(depth int)
+	onBytesChange func(bytes int64)
+	onOverflow    func(dropped bool)
+
+	mu    sync.Mutex
+	depth int
+	bytes int64
+	seq   uint64
+
+	// Serializes replays, so that no event is delivered twice.
+	replayMu sync.Mutex
+}
+
+var (
+	_ Audit   = (*Spool)(nil)
+	_ Checker = (*Spool)(nil)
+	_ Flusher = (*Spool)(nil)
+
+	_ ContextWriter = (*Spool)(nil)
+	_ Previewer     = (*Spool)(nil)
+)
+
+type SpoolOption func(*Spool)
+
+func WithRetryInterval(interval time.Duration) SpoolOption {
+	return func(s *Spool) {
+		s.interval = interval
+	}
+}
+
+func WithSpoolClock(clock Clock) SpoolOption {
+	return func(s *Spool) {
+		s.clock = clock
+	}
+}
+
+func WithDepthChange(callback func(depth int)) SpoolOption {
+	return func(s *Spool) {
+		s.onDepthChange = callback
+	}
+}
+
+// WithSpoolMaxBytes limits the total size of the events held by the spool,
+// in addition to their number.
+func WithSpoolMaxBytes(maxBytes int64) SpoolOption {
+	return func(s *Spool) {
+		s.maxBytes = maxBytes
+	}
+}
+
+// WithOverflowDrop drops events that do not fit into the spool, rather than
+// rejecting them, in which case these are lost.
+func WithOverflowDrop(drop bool) SpoolOption {
+	return func(s *Spool) {
+		s.drop = drop
+	}
+}
+
+func WithBytesChange(callback func(bytes int64)) SpoolOption {
+	return func(s *Spool) {
+		s.onBytesChange = callback
+	}
+}
+
+// WithOverflow sets a callback that is called for every event that did not
+// fit into the spool, and whether it was dropped.
+func WithOverflow(callback func(dropped bool)) SpoolOption {
+	return func(s *Spool) {
+		s.onOverflow = callback
+	}
+}
+
+// NewSpool creates the spool directory, if needed, and accounts for any events
+// left over from a previous run, which are replayed once Run is called.
+func NewSpool(audit Audit, dir string, maxEvents int, options ...SpoolOption) (*Spool, error) {
+	s := &Spool{
+		audit:     audit,
+		dir:       dir,
+		maxEvents: maxEvents,
+		interval:  defaultSpoolRetryInterval,
+		clock:     SystemClock,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	switch {
+	case s.interval <= 0:
+		return nil, errors.New("invalid audit spool options: retry interval must be positive")
+	case s.clock == nil:
+		return nil, errors.New("invalid audit spool options: clock cannot be nil")
+	case maxEvents <= 0:
+		return nil, errors.New("invalid audit spool options: maximum number of events must be positive")
+	case s.maxBytes < 0:
+		return nil, errors.New("invalid audit spool options: maximum size cannot be negative")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("unable to create audit spool directory: %w", err)
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var size int64
+	for _, name := range files {
+		if info, err := os.Stat(name); err == nil {
+			size += info.Size()
+		}
+	}
+	s.setDepth(len(files), size)
+
+	return s, nil
+}
+
+// Bytes returns the total size of the events held by the spool.
+func (s *Spool) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bytes
+}
+
+func (s *Spool) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.depth
+}
+
+func (s *Spool) Write(q *QueryData) error {
+	return s.WriteContext(context.Background(), q)
+}
+
+// WriteContext spools the event when the backend fails to write it, including
+// when the context is done first.
+func (s *Spool) WriteContext(ctx context.Context, q *QueryData) error {
+	err := WriteContext(ctx, s.audit, q)
+	if err == nil {
+		return nil
+	}
+
+	spoolErr := s.store(q)
+	if errors.Is(spoolErr, ErrSpoolFull) {
+		if s.onOverflow != nil {
+			s.onOverflow(s.drop)
+		}
+		if s.drop {
+			return nil
+		}
+	}
+	if spoolErr != nil {
+		return multierr.Append(err, spoolErr)
+	}
+
+	return nil
+}
+
+// Run replays spooled events straight away, and then periodically, until the
+// given context is canceled.
+func (s *Spool) Run(ctx context.Context) {
+	for {
+		_ = s.replay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+func (s *Spool) Check(ctx context.Context) error {
+	if c, ok := s.audit.(Checker); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
+
+func (s *Spool) Flush(ctx context.Context) error {
+	err := s.replay(ctx)
+	if f, ok := s.audit.(Flusher); ok {
+		err = multierr.Append(err, f.Flush(ctx))
+	}
+	return err
+}
+
+func (s *Spool) Preview(q *QueryData) ([]byte, error) {
+	return Preview(s.audit, q)
+}
+
+func (s *Spool) Close() error {
+	if c, ok := s.audit.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *Spool) store(q *QueryData) error {
+	content, err := json.Marshal(q)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit spool event: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(len(content))
+	if s.depth >= s.maxEvents || s.maxBytes > 0 && s.bytes+size > s.maxBytes {
+		return ErrSpoolFull
+	}
+
+	s.seq++
+	name := filepath.Join(s.dir, fmt.Sprintf("%020d-%06d%s", s.clock.Now().UnixNano(), s.seq, spoolFileExtension))
+
+	// Write to a temporary file first, so that a partially written event is
+	// never replayed.
+	temp := name + ".tmp"
+	if err := os.WriteFile(temp, content, 0o600); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("unable to write audit spool event: %w", err)
+	}
+	if err := os.Rename(temp, name); err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("unable to write audit spool event: %w", err)
+	}
+
+	s.depth++
+	s.bytes += size
+	s.notify()
+
+	return nil
+}
+
+// Events are replayed oldest first, stopping at the first one that cannot be
+// delivered, as the backend is most likely still unavailable.
+func (s *Spool) replay(ctx context.Context) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("unable to read audit spool event: %w", err)
+		}
+
+		var q QueryData
+		if err := json.Unmarshal(content, &q); err != nil {
+			// A corrupted event can never be delivered, and would block the
+			// spool forever otherwise.
+			s.remove(name, int64(len(content)))
+			continue
+		}
+
+		if err := s.audit.Write(&q); err != nil {
+			return fmt.Errorf("unable to replay audit spool event: %w", err)
+		}
+		s.remove(name, int64(len(content)))
+	}
+
+	return nil
+}
+
+func (s *Spool) remove(name string, size int64) {
+	// An event that cannot be removed is simply delivered again later.
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.depth > 0 {
+		s.depth--
+	}
+	s.bytes -= size
+	if s.bytes < 0 {
+		s.bytes = 0
+	}
+	s.notify()
+}
+
+func (s *Spool) files() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read audit spool directory: %w", err)
+	}
+
+	// Entries are sorted by name, thus by the time these were spooled.
+	var files []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), spoolFileExtension) {
+			files = append(files, filepath.Join(s.dir, e.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+func (s *Spool) setDepth(depth int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.depth = depth
+	s.bytes = bytes
+	s.notify()
+}
+
+func (s *Spool) notify() {
+	if s.onDepthChange != nil {
+		s.onDepthChange(s.depth)
+	}
+	if s.onBytesChange != nil {
+		s.onBytesChange(s.bytes)
+	}
+}
@@ -0,0 +1,249 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSpool(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "spool")
+
+	actual, err := NewSpool(&fakeAudit{}, dir, 10)
+
+	require.NoError(t, err)
+	require.NotNil(t, actual)
+	assert.Equal(t, 0, actual.Depth())
+	assert.DirExists(t, dir)
+}
+
+func TestNewSpoolInvalidOptions(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		maxEvents   int
+		given       []SpoolOption
+		want        string
+	}{
+		{"zero retry interval", 10, []SpoolOption{WithRetryInterval(0)}, `invalid audit spool options: retry interval must be positive`},
+		{"nil clock", 10, []SpoolOption{WithSpoolClock(nil)}, `invalid audit spool options: clock cannot be nil`},
+		{"zero maximum number of events", 0, nil, `invalid audit spool options: maximum number of events must be positive`},
+		{"negative maximum size", 10, []SpoolOption{WithSpoolMaxBytes(-1)}, `invalid audit spool options: maximum size cannot be negative`},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := NewSpool(&fakeAudit{}, t.TempDir(), tc.maxEvents, tc.given...)
+
+			assert.Nil(t, actual)
+			assert.EqualError(t, err, tc.want)
+		})
+	}
+}
+
+func TestSpoolWrite(t *testing.T) {
+	t.Parallel()
+
+	var depths []int
+
+	inner := &fakeAudit{err: errors.New("test")}
+
+	s, err := NewSpool(inner, t.TempDir(), 2, WithDepthChange(func(depth int) {
+		depths = append(depths, depth)
+	}))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	require.NoError(t, s.Write(&QueryData{Query: "select 2;", User: "test"}))
+	assert.Equal(t, 2, s.Depth())
+
+	// Events are no longer accepted once the spool is full.
+	err = s.Write(&QueryData{Query: "select 3;", User: "test"})
+	require.ErrorIs(t, err, ErrSpoolFull)
+	assert.Contains(t, err.Error(), "test")
+	assert.Equal(t, 2, s.Depth())
+
+	// Replaying fails while the backend is still unavailable.
+	require.Error(t, s.Flush(context.Background()))
+	assert.Equal(t, 2, s.Depth())
+
+	inner.err = nil
+	require.NoError(t, s.Flush(context.Background()))
+	assert.Equal(t, 0, s.Depth())
+
+	assert.Equal(t, []int{0, 1, 2, 1, 0}, depths)
+	assert.Equal(t, 6, inner.writes)
+}
+
+func TestSpoolMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		drop        bool
+		error       bool
+	}{
+		{"events rejected once full", false, true},
+		{"events dropped once full", true, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				sizes     []int64
+				overflows []bool
+			)
+
+			inner := &fakeAudit{err: errors.New("test")}
+			q := &QueryData{Query: "select 1;", User: "test"}
+
+			content, err := json.Marshal(q)
+			require.NoError(t, err)
+			size := int64(len(content))
+
+			dir := t.TempDir()
+			s, err := NewSpool(inner, dir, 10,
+				WithSpoolMaxBytes(2*size+1),
+				WithOverflowDrop(tc.drop),
+				WithBytesChange(func(bytes int64) { sizes = append(sizes, bytes) }),
+				WithOverflow(func(dropped bool) { overflows = append(overflows, dropped) }),
+			)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Write(q))
+			require.NoError(t, s.Write(q))
+			assert.Equal(t, 2*size, s.Bytes())
+
+			// The event no longer fits, even though the spool holds fewer
+			// events than allowed.
+			err = s.Write(q)
+			if tc.error {
+				require.ErrorIs(t, err, ErrSpoolFull)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 2, s.Depth())
+			assert.Equal(t, []bool{tc.drop}, overflows)
+
+			// The size of events left over from a previous run is accounted for.
+			restarted, err := NewSpool(inner, dir, 10)
+			require.NoError(t, err)
+			assert.Equal(t, 2*size, restarted.Bytes())
+
+			inner.err = nil
+			require.NoError(t, s.Flush(context.Background()))
+			assert.Equal(t, int64(0), s.Bytes())
+			assert.Equal(t, []int64{0, size, 2 * size, size, 0}, sizes)
+		})
+	}
+}
+
+func TestSpoolReplayOnStartup(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	s, err := NewSpool(&fakeAudit{err: errors.New("test")}, dir, 10)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+
+	// Corrupted and partially written events are never replayed.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0-0.json"), []byte("{"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1-1.json.tmp"), []byte("{}"), 0o600))
+
+	var written []string
+
+	inner := &recordingAudit{written: &written}
+
+	s, err = NewSpool(inner, dir, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, s.Depth())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	assert.Eventually(t, func() bool { return s.Depth() == 0 }, time.Second, 10*time.Millisecond)
+	cancel()
+
+	inner.mu.Lock()
+	defer inner.mu.Unlock()
+	assert.Equal(t, []string{"select 1;"}, written)
+}
+
+type recordingAudit struct {
+	mu      sync.Mutex
+	written *[]string
+}
+
+func (r *recordingAudit) Write(q *QueryData) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.written = append(*r.written, q.Query)
+	return nil
+}
+
+func TestSpoolRunRetryInterval(t *testing.T) {
+	t.Parallel()
+
+	clock := NewFakeClock(time.Now())
+	inner := &switchableAudit{}
+	inner.setFailing(true)
+
+	s, err := NewSpool(inner, t.TempDir(), 10, WithRetryInterval(time.Minute), WithSpoolClock(clock))
+	require.NoError(t, err)
+	require.NoError(t, s.Write(&QueryData{Query: "select 1;", User: "test"}))
+	assert.Equal(t, 1, s.Depth())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// The first replay fails, after which the spool waits for the interval.
+	require.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	inner.setFailing(false)
+
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, 1, clock.Waiters())
+	assert.Equal(t, 1, s.Depth())
+
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool { return s.Depth() == 0 }, time.Second, time.Millisecond)
+}
+
+type switchableAudit struct {
+	mu      sync.Mutex
+	failing bool
+}
+
+func (a *switchableAudit) setFailing(failing bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.failing = failing
+}
+
+func (a *switchableAudit) Write(q *QueryData) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.failing {
+		return errors.New("test")
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// StreamAudit writes audit events as JSON, one per line, to a file or to the
+// standard output, using the same attributes as sent to Splunk, together with
+// the time of the event given as seconds since the Unix epoch, unless encoded
+// otherwise, such as in CEF.
+type StreamAudit struct {
+	w         io.Writer
+	namespace string
+	pod       string
+	names     map[string]string
+	encoder   Encoder
+
+	mu sync.Mutex
+}
+
+var (
+	_ Audit     = (*StreamAudit)(nil)
+	_ Flusher   = (*StreamAudit)(nil)
+	_ Previewer = (*StreamAudit)(nil)
+	_ io.Closer = (*StreamAudit)(nil)
+)
+
+type streamEvent struct {
+	Time  int64            `json:"time"`
+	Event *SplunkEventData `json:"event"`
+}
+
+type StreamOption func(*StreamAudit)
+
+// WithStreamEncoder sets the encoding of the events written, in place of the
+// JSON sent to Splunk. Attributes are not renamed then.
+func WithStreamEncoder(encoder Encoder) StreamOption {
+	return func(d *StreamAudit) {
+		d.encoder = encoder
+	}
+}
+
+func NewStreamAudit(w io.Writer, namespace, pod string, names map[string]string, options ...StreamOption) *StreamAudit {
+	d := &StreamAudit{w: w, namespace: namespace, pod: pod, names: names}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// NewFileAudit appends audit events to the file at the given path, which is
+// created when it does not exist.
+func NewFileAudit(path, namespace, pod string, names map[string]string, options ...StreamOption) (*StreamAudit, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit file: %w", err)
+	}
+	return NewStreamAudit(f, namespace, pod, names, options...), nil
+}
+
+func (d *StreamAudit) Write(q *QueryData) error {
+	content, err := d.Preview(q)
+	if err != nil {
+		return fmt.Errorf("unable to marshal audit: %w", err)
+	}
+	content = append(content, '\n')
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := d.w.Write(content); err != nil {
+		return fmt.Errorf("unable to write audit: %w", err)
+	}
+	return nil
+}
+
+// Preview returns the event as it would be written, without the trailing
+// newline.
+func (d *StreamAudit) Preview(q *QueryData) ([]byte, error) {
+	namespace, pod := q.Namespace, q.Pod
+	if namespace == "" {
+		namespace = d.namespace
+	}
+	if pod == "" {
+		pod = d.pod
+	}
+
+	// The event is copied, as the pod is only ever filled in by the signer.
+	if d.encoder != nil {
+		e := *q
+		e.Namespace, e.Pod = namespace, pod
+		return d.encoder.Encode(&e)
+	}
+
+	return json.Marshal(&streamEvent{
+		Time:  q.Timestamp,
+		Event: newEventData(q, namespace, pod, d.names),
+	})
+}
+
+func (d *StreamAudit) Flush(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The standard output cannot be synced on every platform.
+	if f, ok := d.w.(*os.File); ok && f != os.Stdout {
+		return f.Sync()
+	}
+	return nil
+}
+
+func (d *StreamAudit) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if f, ok := d.w.(*os.File); ok && f != os.Stdout {
+		return f.Close()
+	}
+	return nil
+}
+
+// NopAudit discards all audit events.
+type NopAudit struct{}
+
+var _ Audit = NopAudit{}
+
+func (NopAudit) Write(*QueryData) error {
+	return nil
+}
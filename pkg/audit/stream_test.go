@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStreamAudit(t *testing.T) {
+	t.Parallel()
+
+	actual := NewStreamAudit(&bytes.Buffer{}, "test", "test", nil)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &StreamAudit{}, actual)
+}
+
+func TestStreamAuditWrite(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       QueryData
+		names       map[string]string
+		encoder     Encoder
+		want        string
+	}{
+		{
+			"query data with user and query set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			nil,
+			nil,
+			`{"time":1672531200,"event":{"query":"select 1;","user":"test","namespace":"test","pod":"test"}}` + "\n",
+		},
+		{
+			"query data with successful outcome set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Executed: true, Success: true},
+			nil,
+			nil,
+			`{"time":1672531200,"event":{"query":"select 1;","user":"test","namespace":"test","pod":"test","success":true}}` + "\n",
+		},
+		{
+			"query data with field names set",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			map[string]string{"query": "sql"},
+			nil,
+			`{"time":1672531200,"event":{"namespace":"test","pod":"test","sql":"select 1;","user":"test"}}` + "\n",
+		},
+		{
+			"query data encoded in CEF",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()},
+			nil,
+			NewCEFEncoder(),
+			fmt.Sprintf("CEF:0|app-sre|GABI|%s|query|Query audited|3|rt=1672531200000 suser=test msg=select 1; cs1Label=namespace cs1=test cs2Label=pod cs2=test\n", eventVersion()),
+		},
+		{
+			"query data encoded in LEEF",
+			QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Namespace: "other"},
+			nil,
+			NewLEEFEncoder(),
+			fmt.Sprintf("LEEF:1.0|app-sre|GABI|%s|query|devTime=Jan 01 2023 00:00:00.000 UTC\tdevTimeFormat=MMM dd yyyy HH:mm:ss.SSS z\tcat=Query audited\tsev=3\tusrName=test\tquery=select 1;\tnamespace=other\tpod=test\n", eventVersion()),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			s := NewStreamAudit(&output, "test", "test", tc.names, WithStreamEncoder(tc.encoder))
+			err := s.Write(&tc.given)
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestFileAudit(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o600))
+
+	s, err := NewFileAudit(path, "test", "test", nil)
+	require.NoError(t, err)
+
+	given := &QueryData{Query: "select 1;", User: "test", Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	require.NoError(t, s.Write(given))
+	require.NoError(t, s.Flush(context.Background()))
+	require.NoError(t, s.Close())
+
+	actual, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "existing\n"+`{"time":1672531200,"event":{"query":"select 1;","user":"test","namespace":"test","pod":"test"}}`+"\n", string(actual))
+}
+
+func TestFileAuditError(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "missing", "audit.log")
+
+	_, err := NewFileAudit(path, "test", "test", nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to open audit file")
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	actual := New(10, time.Minute)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Cache{}, actual)
+	assert.Equal(t, 0, actual.Len())
+}
+
+func TestCacheEviction(t *testing.T) {
+	t.Parallel()
+
+	c := New(2, time.Minute)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Accessing an entry makes it the most recently used one.
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	c.Set("c", 3)
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Set("a", 4)
+	v, _ = c.Get("a")
+	assert.Equal(t, 4, v)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestCacheExpiration(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	c := New(2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+
+	now = now.Add(59 * time.Second)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expected    string
+	}{
+		{"already normalized", "select 1", "select 1"},
+		{"surrounding whitespace and semicolon", "  select 1;  ", "select 1"},
+		{"repeated whitespace", "select\n\t*   from  test;;", "select * from test"},
+		{"whitespace in quoted strings", "select  'a  b',   \"c  d\"", `select 'a  b', "c  d"`},
+		{"semicolon in quoted string", "select ';'", "select ';'"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, Normalize(tc.given))
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	key := Key("test", "select 1;", nil, "")
+
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, Key("test", "  select   1", nil, ""))
+	assert.NotEqual(t, key, Key("test2", "select 1;", nil, ""))
+	assert.NotEqual(t, key, Key("test", "select 1;", nil, "base64_results=true"))
+	assert.NotEqual(t, Key("test", "select $1;", []interface{}{1}, ""), Key("test", "select $1;", []interface{}{2}, ""))
+}
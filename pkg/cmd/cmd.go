@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
 	"encoding/base64"
 	"fmt"
@@ -8,7 +11,10 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 
 	gorillahandlers "github.com/gorilla/handlers"
@@ -18,11 +24,15 @@ import (
 
 	gabi "github.com/app-sre/gabi/pkg"
 	"github.com/app-sre/gabi/pkg/audit"
-	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/cache"
+	"github.com/app-sre/gabi/pkg/config"
 	"github.com/app-sre/gabi/pkg/env/splunk"
 	"github.com/app-sre/gabi/pkg/env/user"
 	"github.com/app-sre/gabi/pkg/handlers"
+	"github.com/app-sre/gabi/pkg/health"
+	"github.com/app-sre/gabi/pkg/metrics"
 	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/app-sre/gabi/pkg/telemetry"
 	"github.com/app-sre/gabi/pkg/version"
 )
 
@@ -30,91 +40,608 @@ const (
 	readTimeout       = 1 * time.Minute
 	readHeaderTimeout = 20 * time.Second
 	writeTimeout      = 2 * time.Minute
+
+	tracingShutdownTimeout = 5 * time.Second
+	auditShutdownTimeout   = 10 * time.Second
+
+	expirationCheckInterval = 1 * time.Minute
+
+	sessionProbeTimeout = 10 * time.Second
 )
 
 func Run(logger *zap.SugaredLogger) error {
-	logger.Infof("Starting GABI version: %s", version.Version())
+	logger.Infof("Starting GABI version: %s (commit: %s, build date: %s)", version.Version(), version.Commit(), version.BuildDate())
 
-	usere := user.NewUserEnv()
-	err := usere.Populate()
+	c, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("unable to configure users: %w", err)
+		return err
 	}
+	usere, authe, dbe := c.User, c.Auth, c.DB
+	pe, le, srve, pxe := c.Policy, c.Limits, c.Server, c.Proxy
+	ae, ce, cachee, me, te := c.Auditing, c.CORS, c.Cache, c.Maintenance, c.Tracing
 
 	expiry := usere.IsExpired()
 	date := usere.Expiration.Format(user.ExpiryDateLayout)
-	logger.Infof("Production: %t, expired: %t (expiration date: %s)", gabi.Production(), expiry, date)
+	logger.Infof("Production: %t, expired: %t (expiration date: %s)", c.Production, expiry, date)
+	if usere.GraceDays > 0 {
+		logger.Infof("Serving read-only queries for %d day(s) after the expiration date", usere.GraceDays)
+	}
 	logger.Debugf("Authorized users: %v", usere.Users)
 
-	dbe := db.NewDBEnv()
-	err = dbe.Populate()
-	if err != nil {
-		return fmt.Errorf("unable to configure database: %w", err)
+	if pxe.Enabled() {
+		logger.Infof("Trusting forwarding headers from proxies: %v (user header: %s, request ID header: %s, client IP header: %s)",
+			pxe.TrustedProxies, pxe.UserHeader, pxe.RequestIDHeader, pxe.ClientIPHeader)
+	} else {
+		logger.Warn("Not trusting forwarding headers from any peer, as no trusted proxies are set")
 	}
+	if authe.Enabled() {
+		logger.Infof("Trusting user header: %s (secret header: %s, exclusive: %t)", authe.UserHeader, authe.SecretHeader, authe.Exclusive)
+	}
+	if c.Production {
+		logger.Infof("Administrators: %v", authe.Admins)
+	}
+	if len(authe.Providers) > 0 {
+		logger.Infof("Authenticating users using providers: %v", authe.Providers)
+	}
+
 	logger.Infof("Using database driver: %s (write access: %t)", dbe.Driver, dbe.AllowWrite)
+	if dbe.QueryTimeout > 0 || dbe.MaxQueryTimeout > 0 {
+		logger.Infof("Using query timeout of %s (maximum: %s)", dbe.QueryTimeout, dbe.MaxQueryTimeout)
+	}
+	if dbe.MaxConcurrentQueries > 0 {
+		logger.Infof("Limiting concurrent queries to %d (policy: %s, queue timeout: %s)", dbe.MaxConcurrentQueries, dbe.ConcurrencyPolicy, dbe.QueueTimeout)
+	}
 
-	db, err := sql.Open(dbe.Driver.String(), dbe.ConnectionDSN())
+	db, err := dbe.Open()
 	if err != nil {
 		return fmt.Errorf("unable to open database connection: %w", err)
 	}
 	defer db.Close()
 	logger.Debugf("Connected to database host: %s (port: %d)", dbe.Host, dbe.Port)
 
+	// The session is set up on every new connection, and validated using a
+	// connection opened upfront, unless the connector waits for the database,
+	// whose first connection then does.
+	if len(dbe.InitSQL) > 0 {
+		logger.Infof("Setting up database sessions using %d statement(s)", len(dbe.InitSQL))
+		if dbe.ConnectTimeout == 0 {
+			if err := probeSession(db); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Every database that can be selected has a pool of its own, as the
+	// database of a connection is given when connecting.
+	databases := make(map[string]*sql.DB, len(dbe.Databases))
+	for _, name := range dbe.Databases {
+		database, err := dbe.OpenDatabase(name)
+		if err != nil {
+			return fmt.Errorf("unable to open database connection: %w", err)
+		}
+		defer database.Close()
+		if len(dbe.InitSQL) > 0 && dbe.ConnectTimeout == 0 {
+			if err := probeSession(database); err != nil {
+				return err
+			}
+		}
+		databases[name] = database
+	}
+	if len(databases) > 0 {
+		logger.Infof("Allowing clients to select databases: %v (default: %s)", dbe.Databases, dbe.Name)
+	}
+
+	var connector *health.Connector
+	if dbe.ConnectTimeout > 0 {
+		logger.Infof("Waiting up to %s for the database to become available", dbe.ConnectTimeout)
+		connector = health.NewConnector(db, dbe.ConnectTimeout,
+			health.WithAttempt(func(attempt int, err error, delay time.Duration) {
+				logger.Warnf("Unable to connect to the database (attempt: %d), retrying in %s: %s", attempt, delay, err)
+			}),
+		)
+	}
+
+	var pinger *health.Pinger
+	if dbe.PingInterval > 0 {
+		logger.Infof("Pinging database every %s", dbe.PingInterval)
+		pinger = health.NewPinger(db, dbe.PingInterval,
+			health.WithStateChange(func(healthy bool, err error) {
+				if !healthy {
+					logger.Errorf("Database became unhealthy: %s", err)
+					return
+				}
+				logger.Info("Database became healthy")
+			}),
+		)
+	}
+
+	logger.Infof("Using query policy with %d allow and %d deny patterns", len(pe.Allow), len(pe.Deny))
+	if len(pe.Statements) > 0 {
+		logger.Infof("Allowing statement types: %v", pe.Statements)
+	}
+	if len(pe.LargeTables) > 0 {
+		logger.Infof("Refusing unbounded queries on large tables unless forced: %v", pe.LargeTables)
+	}
+
+	logger.Infof("Using rate limit of %d requests per minute (burst: %d)", le.RatePerMinute, le.RateBurst)
+	logger.Infof("Using maximum request size of %d bytes", le.MaxRequestBytes)
+	if le.MaxQueryBytes > 0 {
+		logger.Infof("Using maximum query size of %d bytes", le.MaxQueryBytes)
+	}
+	if le.MaxRows > 0 {
+		logger.Infof("Returning at most %d rows per query", le.MaxRows)
+	}
+	if le.RequestTimeout > 0 {
+		logger.Infof("Using request timeout of %s", le.RequestTimeout)
+	}
+
+	if srve.TLSEnabled() {
+		logger.Infof("Serving HTTPS using certificate: %s (client CA: %s)", srve.TLSCertFile, authe.ClientCAFile)
+	}
+
+	// Requests legitimately running for longer than the window, with no other
+	// request completing meanwhile, would fail the liveness check.
+	var watchdog *health.Watchdog
+	if srve.StallTimeout > 0 {
+		logger.Infof("Failing liveness once no request completes for %s while requests are in flight", srve.StallTimeout)
+		longest := dbe.MaxQueryTimeout
+		for _, timeout := range []time.Duration{dbe.QueryTimeout, le.RequestTimeout} {
+			if timeout > longest {
+				longest = timeout
+			}
+		}
+		switch {
+		case longest == 0:
+			logger.Warnf("Queries are not bounded by any timeout, thus those running for longer than %s fail liveness", srve.StallTimeout)
+		case srve.StallTimeout <= longest:
+			logger.Warnf("Liveness stall timeout of %s does not exceed the longest query timeout of %s", srve.StallTimeout, longest)
+		}
+		watchdog = health.NewWatchdog(srve.StallTimeout)
+	}
+	if authe.BearerRequired() {
+		logger.Info("Requiring bearer token for query, schema and config endpoints")
+	}
+
+	logger.Infof("Auditing query arguments: %t, database name: %t, database host: %t, client IP: %t, columns: %t", ae.IncludeArgs, ae.IncludeDatabaseName, ae.IncludeDatabaseHost, ae.IncludeClientIP, ae.IncludeColumns)
+	if ae.RedactLiterals {
+		logger.Info("Redacting literals from audited queries")
+	}
+	if ae.TwoPhase {
+		logger.Info("Auditing queries in two phases, telling the phase of every event")
+	}
+	if len(ae.Fields) > 0 {
+		logger.Infof("Adding static fields to audit: %v", ae.Fields)
+	}
+	if len(ae.FieldNames) > 0 {
+		logger.Infof("Renaming audit fields sent to Splunk: %v", ae.FieldNames)
+	}
+	if ae.SampleRate < 1 {
+		if dbe.AllowWrite {
+			logger.Warn("Audit sampling disabled, as database write access is enabled")
+		} else {
+			logger.Infof("Sending %g of successful queries to Splunk", ae.SampleRate)
+		}
+	}
+	if ae.Timeout > 0 {
+		logger.Infof("Bounding audit writes to: %s (on timeout: %s)", ae.Timeout, ae.TimeoutPolicy)
+	}
+
+	logger.Infof("CORS enabled: %t (allowed origins: %v)", ce.Enabled(), ce.AllowedOrigins)
+
+	var qc *cache.Cache
+	switch {
+	case cachee.Enabled() && dbe.AllowWrite:
+		logger.Warn("Query cache disabled, as database write access is enabled")
+	case cachee.Enabled():
+		logger.Infof("Using query cache of %d entries (TTL: %s, per user: %t)", cachee.Size, cachee.TTL, cachee.PerUser)
+		qc = cache.New(cachee.Size, cachee.TTL)
+	}
+
+	if me.Enabled() {
+		logger.Infof("Rejecting queries while maintenance flag file exists: %s (retry after: %s)", me.File, me.RetryAfter)
+	}
+
 	la := audit.NewLoggerAudit(logger)
 
-	se := splunk.NewSplunkEnv()
-	err = se.Populate()
+	logger = logger.With("namespace", ae.Namespace)
+
+	m := metrics.New(ae.Namespace, metrics.WithExemplars(te.Exemplars))
+	if te.Exemplars && !te.Enabled() {
+		logger.Warn("Metrics exemplars enabled, but tracing is not, thus no exemplars will be recorded")
+	}
+	m.ObserveDB(dbe.Name, db)
+	for name, database := range databases {
+		m.ObserveDB(name, database)
+	}
+
+	sa, err := audit.NewBackend(logger, ae, c.Splunk, c.Datadog)
 	if err != nil {
-		return fmt.Errorf("unable to configure Splunk: %w", err)
+		return fmt.Errorf("unable to configure auditing: %w", err)
 	}
-	logger.Infof("Sending audit to Splunk endpoint: %s", se.Endpoint)
 
-	sa := audit.NewSplunkAudit(se)
+	var (
+		se    *splunk.Env
+		spool *audit.Spool
+	)
+	switch backend := sa.(type) {
+	case *audit.SplunkAudit:
+		se = backend.SplunkEnv
+		logger.Infof("Sending audit to Splunk endpoint: %s (health check: %t)", se.Endpoint, se.HealthCheck)
+		m.SetAuditTLSInsecure(backend.InsecureEndpoint() != "")
+
+		sa, spool, err = splunkReliability(logger, m, se, sa)
+		if err != nil {
+			return fmt.Errorf("unable to configure Splunk: %w", err)
+		}
+	case *audit.DatadogAudit:
+		logger.Infof("Sending audit to Datadog endpoint: %s (service: %s, tags: %v)", backend.DatadogEnv.Endpoint, backend.DatadogEnv.Service, backend.DatadogEnv.Tags)
+	case audit.NopAudit:
+		logger.Warn("Audit backend disabled, queries are only audited in the log")
+	case *audit.StreamAudit:
+		if ae.FilePath != "" {
+			logger.Infof("Writing audit to file: %s (format: %s)", ae.FilePath, ae.Format)
+		} else {
+			logger.Infof("Writing audit to standard output (format: %s)", ae.Format)
+		}
+	}
+
+	// Events are signed once, ahead of any retries by the spool.
+	if ae.SigningKey != "" {
+		logger.Info("Signing audit events sent to the audit backend")
+		sa = audit.NewSigner(sa, []byte(ae.SigningKey), ae.Namespace, ae.Pod)
+	}
 
 	cfg := &gabi.Config{
-		DB:          db,
-		DBEnv:       dbe,
-		UserEnv:     usere,
-		LoggerAudit: la,
-		SplunkAudit: sa,
-		Logger:      logger,
-		Encoder:     base64.StdEncoding,
+		DB:             db,
+		Databases:      databases,
+		DBEnv:          dbe,
+		UserEnv:        usere,
+		AuthEnv:        authe,
+		PolicyEnv:      pe,
+		LimitsEnv:      le,
+		SplunkEnv:      se,
+		AuditingEnv:    ae,
+		CORSEnv:        ce,
+		ProxyEnv:       pxe,
+		ServerEnv:      srve,
+		CacheEnv:       cachee,
+		MaintenanceEnv: me,
+		LoggerAudit:    la,
+		SplunkAudit:    sa,
+		Cache:          qc,
+		Pinger:         pinger,
+		Connector:      connector,
+		Watchdog:       watchdog,
+		Metrics:        m,
+		Logger:         logger,
+		Encoder:        base64.StdEncoding,
+		Production:     c.Production,
 	}
 
 	// Temp workaround for easy to access io.Writer.
 	defaultLogOutput := log.Default().Writer()
 
 	healthLogOutput := io.Discard
-	if !gabi.Production() {
+	if !c.Production {
 		healthLogOutput = defaultLogOutput
 	}
 	logHandler := gorillahandlers.LoggingHandler
 
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	shutdown, err := telemetry.Setup(ctx, te, ae.Namespace)
+	if err != nil {
+		return fmt.Errorf("unable to configure tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		_ = shutdown(ctx)
+	}()
+	logger.Infof("Tracing enabled: %t (collector endpoint: %s, metrics exemplars: %t)", te.Enabled(), te.Endpoint, te.Exemplars)
+
+	if spool != nil {
+		go spool.Run(ctx)
+	}
+	if pinger != nil {
+		go pinger.Run(ctx)
+	}
+
+	err = user.Watch(ctx, func(u *user.Env, err error) {
+		if err != nil {
+			logger.Errorf("Unable to reload users configuration: %s", err)
+			return
+		}
+		cfg.SetUserEnv(u)
+
+		expiry := u.IsExpired()
+		date := u.Expiration.Format(user.ExpiryDateLayout)
+		logger.Infof("Reloaded users configuration, expired: %t (expiration date: %s)", expiry, date)
+		logger.Debugf("Authorized users: %v", u.Users)
+
+		middleware.AuditLifecycle(cfg, audit.EventReload,
+			fmt.Sprintf("users: %d, expired: %t (expiration date: %s)", len(u.Users), expiry, date))
+	})
+	if err != nil {
+		return fmt.Errorf("unable to watch users configuration: %w", err)
+	}
+	go watchExpiration(ctx, cfg, expirationCheckInterval)
+
 	queryChain := alice.New(
+		alice.Constructor(middleware.CORS(cfg)),
+		alice.Constructor(middleware.Draining(cfg)),
+		alice.Constructor(middleware.Progress(cfg)),
+		alice.Constructor(middleware.RequestID(cfg)),
+		alice.Constructor(middleware.Tracing(cfg)),
+		alice.Constructor(middleware.Metrics(cfg)),
 		alice.Constructor(middleware.Recovery(cfg)),
+		alice.Constructor(middleware.RequestTimeout(cfg)),
+		alice.Constructor(middleware.ClientAuth(cfg)),
 		alice.Constructor(middleware.Authorization(cfg)),
+		alice.Constructor(middleware.RateLimit(cfg)),
 		alice.Constructor(middleware.Expiration(cfg)),
+		alice.Constructor(middleware.Maintenance(cfg)),
+		alice.Constructor(middleware.BodyLimit(cfg)),
+		alice.Constructor(middleware.Decompress(cfg)),
 		alice.Constructor(middleware.Audit(cfg)),
+		alice.Constructor(middleware.Concurrency(cfg)),
+		alice.Constructor(middleware.Compress(cfg)),
 	)
 	queryHandler := queryChain.Then(handlers.Query(cfg))
 
-	r := mux.NewRouter()
+	schemaChain := alice.New(
+		alice.Constructor(middleware.Draining(cfg)),
+		alice.Constructor(middleware.Progress(cfg)),
+		alice.Constructor(middleware.RequestID(cfg)),
+		alice.Constructor(middleware.Recovery(cfg)),
+		alice.Constructor(middleware.RequestTimeout(cfg)),
+		alice.Constructor(middleware.ClientAuth(cfg)),
+		alice.Constructor(middleware.Authorization(cfg)),
+		alice.Constructor(middleware.RateLimit(cfg)),
+		alice.Constructor(middleware.Expiration(cfg)),
+		alice.Constructor(middleware.Maintenance(cfg)),
+	)
+	schemaHandler := schemaChain.Then(handlers.Schema(cfg))
+
+	configHandler := configHandler(cfg)
+
+	auditPreviewChain := alice.New(
+		alice.Constructor(middleware.RequestID(cfg)),
+		alice.Constructor(middleware.Recovery(cfg)),
+		alice.Constructor(middleware.ClientAuth(cfg)),
+		alice.Constructor(middleware.Authorization(cfg)),
+		alice.Constructor(middleware.Admin(cfg)),
+		alice.Constructor(middleware.BodyLimit(cfg)),
+		alice.Constructor(middleware.Decompress(cfg)),
+	)
+	auditPreviewHandler := auditPreviewChain.Then(handlers.AuditPreview(cfg))
+
+	versionHandler := middleware.CORS(cfg)(handlers.Version(cfg))
+
+	// Preflight requests are only answered when CORS is enabled.
+	queryMethods, versionMethods := []string{"POST"}, []string{"GET"}
+	if ce.Enabled() {
+		queryMethods = append(queryMethods, "OPTIONS")
+		versionMethods = append(versionMethods, "OPTIONS")
+	}
+
+	// Routes are registered under the base path, when set, for GABI to be served
+	// behind a shared ingress without rewriting the paths at the proxy.
+	router := mux.NewRouter()
+	r := router
+	if srve.CompressionEnabled() {
+		logger.Infof("Compressing query responses for clients accepting gzip (level: %d)", srve.CompressionLevel)
+	}
+	if srve.BasePath != "" {
+		logger.Infof("Serving under base path: %s", srve.BasePath)
+		r = router.PathPrefix(srve.BasePath).Subrouter()
+	}
 	r.Handle("/healthcheck", logHandler(healthLogOutput, handlers.Healthcheck(cfg))).Methods("GET")
-	r.Handle("/query", logHandler(defaultLogOutput, queryHandler)).Methods("POST")
+	r.Handle("/healthz", logHandler(healthLogOutput, handlers.Liveness(cfg))).Methods("GET")
+	r.Handle("/readyz", logHandler(healthLogOutput, handlers.Readiness(cfg))).Methods("GET")
+	r.Handle("/version", logHandler(healthLogOutput, versionHandler)).Methods(versionMethods...)
+	r.Handle("/query", logHandler(defaultLogOutput, queryHandler)).Methods(queryMethods...)
+	r.Handle("/schema", logHandler(defaultLogOutput, schemaHandler)).Methods("GET")
+	r.Handle("/config", logHandler(defaultLogOutput, configHandler)).Methods("GET")
+	r.Handle("/audit/preview", logHandler(defaultLogOutput, auditPreviewHandler)).Methods("POST")
+	r.Handle("/metrics", logHandler(healthLogOutput, cfg.Metrics.Handler())).Methods("GET")
 
 	port := 8080
 	logger.Infof("HTTP server starting on port: %d", port)
 
 	server := &http.Server{
 		Addr:              net.JoinHostPort("", strconv.Itoa(port)),
-		Handler:           r,
+		Handler:           router,
 		ReadTimeout:       readTimeout,
 		ReadHeaderTimeout: readHeaderTimeout,
 		WriteTimeout:      writeTimeout,
 	}
-	if err := server.ListenAndServe(); err != nil {
+
+	if srve.TLSEnabled() {
+		server.TLSConfig, err = serverTLSConfig(authe.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("unable to configure server: %w", err)
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if srve.TLSEnabled() {
+			errs <- server.ListenAndServeTLS(srve.TLSCertFile, srve.TLSKeyFile)
+			return
+		}
+		errs <- server.ListenAndServe()
+	}()
+
+	// The server is started first, so that liveness probes succeed while
+	// readiness is held back until the database is reached.
+	connectErrs := make(chan error, 1)
+	if connector != nil {
+		go func() {
+			if err := connector.Run(ctx); err != nil {
+				connectErrs <- err
+				return
+			}
+			logger.Info("Connected to the database")
+		}()
+	}
+
+	middleware.AuditLifecycle(cfg, audit.EventStartup,
+		fmt.Sprintf("version: %s, expired: %t (expiration date: %s)", version.Version(), expiry, date))
+
+	select {
+	case err := <-errs:
 		return fmt.Errorf("unable to start HTTP server: %w", err)
+	case err := <-connectErrs:
+		if ctx.Err() == nil {
+			shutdownServer(cfg, server, srve.ShutdownGracePeriod)
+			return err
+		}
+	case <-ctx.Done():
 	}
 
+	shutdownServer(cfg, server, srve.ShutdownGracePeriod)
+
 	return nil
 }
+
+// The effective configuration names the users, the policies and the database
+// of the instance, thus only administrators can read it in production.
+func configHandler(cfg *gabi.Config) http.Handler {
+	return alice.New(
+		alice.Constructor(middleware.Recovery(cfg)),
+		alice.Constructor(middleware.ClientAuth(cfg)),
+		alice.Constructor(middleware.Authorization(cfg)),
+		alice.Constructor(middleware.Admin(cfg)),
+	).Then(handlers.Config(cfg))
+}
+
+// Client certificates are verified against the given CA bundle when presented,
+// but not required during the handshake, so that health probes keep working.
+// Requests without one are refused later on, by the client auth middleware.
+func serverTLSConfig(clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	bundle, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("unable to parse client CA file: %s", clientCAFile)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return config, nil
+}
+
+// The circuit breaker and spool wrap the Splunk backend when enabled, with the
+// spool returned separately, as it needs to be run.
+func splunkReliability(logger *zap.SugaredLogger, m *metrics.Metrics, se *splunk.Env, sa audit.Audit) (audit.Audit, *audit.Spool, error) {
+	if se.BreakerThreshold > 0 {
+		logger.Infof("Using Splunk circuit breaker after %d failures (cooldown: %s)", se.BreakerThreshold, se.BreakerCooldown)
+		sa = audit.NewCircuitBreaker(sa, se.BreakerThreshold, se.BreakerCooldown,
+			audit.WithStateChange(func(from, to audit.BreakerState) {
+				logger.Warnf("Splunk circuit breaker state changed: %s -> %s", from, to)
+				m.SetAuditBreakerState(int(to))
+			}),
+		)
+	}
+
+	if se.SpoolDir == "" {
+		return sa, nil, nil
+	}
+
+	spool, err := audit.NewSpool(sa, se.SpoolDir, se.SpoolMaxEvents,
+		audit.WithDepthChange(m.SetAuditSpoolDepth),
+		audit.WithSpoolMaxBytes(se.SpoolMaxBytes),
+		audit.WithOverflowDrop(se.SpoolOverflow == splunk.SpoolOverflowDrop),
+		audit.WithBytesChange(m.SetAuditSpoolBytes),
+		audit.WithOverflow(func(dropped bool) {
+			m.ObserveAuditSpoolOverflow(dropped)
+			if dropped {
+				logger.Warn("Audit spool is full, dropping undelivered audit event")
+			} else {
+				logger.Warn("Audit spool is full, rejecting undelivered audit event")
+			}
+		}),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Infof("Spooling undelivered audit to: %s (pending: %d, maximum: %d, size: %d bytes, maximum size: %d bytes, overflow: %s)",
+		se.SpoolDir, spool.Depth(), se.SpoolMaxEvents, spool.Bytes(), se.SpoolMaxBytes, se.SpoolOverflow)
+
+	return spool, spool, nil
+}
+
+// The expiration date is audited once reached, including when a reload moved
+// it into the past, checking periodically, as it can be changed at any time.
+func watchExpiration(ctx context.Context, cfg *gabi.Config, interval time.Duration) {
+	expired := cfg.CurrentUserEnv().IsExpired()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		u := cfg.CurrentUserEnv()
+		if u.IsExpired() && !expired {
+			middleware.AuditLifecycle(cfg, audit.EventExpiration,
+				fmt.Sprintf("expiration date: %s", u.Expiration.Format(user.ExpiryDateLayout)))
+		}
+		expired = u.IsExpired()
+	}
+}
+
+// Stop accepting new requests and wait for the in-flight ones to finish,
+// then flush any audit data that might still be buffered.
+func shutdownServer(cfg *gabi.Config, server *http.Server, period time.Duration) {
+	cfg.Logger.Infof("Shutting down HTTP server (grace period: %s)", period)
+	cfg.SetDraining(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), period)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if err != nil {
+		cfg.Logger.Errorf("Unable to gracefully shut down HTTP server: %s", err)
+		_ = server.Close()
+	}
+
+	middleware.AuditLifecycle(cfg, audit.EventShutdown, fmt.Sprintf("grace period: %s", period))
+
+	// The audit gets a deadline of its own, as the grace period might have been
+	// used up entirely by requests still in flight.
+	ctx, cancel = context.WithTimeout(context.Background(), auditShutdownTimeout)
+	defer cancel()
+
+	for _, a := range []audit.Audit{cfg.SplunkAudit, cfg.LoggerAudit} {
+		if err := audit.Shutdown(ctx, a); err != nil {
+			cfg.Logger.Errorf("Unable to flush audit: %s", err)
+		}
+	}
+	cfg.Logger.Info("HTTP server stopped")
+}
+
+func probeSession(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sessionProbeTimeout)
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to connect to the database: %w", err)
+	}
+	return conn.Close()
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigHandler(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		production  bool
+		user        string
+		code        int
+	}{
+		{
+			"administrator in production",
+			true,
+			"admin",
+			200,
+		},
+		{
+			"user other than administrators in production",
+			true,
+			"test",
+			403,
+		},
+		{
+			"user other than administrators outside production",
+			false,
+			"test",
+			200,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{
+				UserEnv:     &user.Env{Users: []string{"test", "admin"}},
+				AuthEnv:     &auth.Env{Admins: []string{"admin"}},
+				ProxyEnv:    test.ProxyEnv(),
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Production:  tc.production,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/config", nil)
+			r.Header.Set("X-Forwarded-User", tc.user)
+
+			configHandler(cfg).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			if tc.code == http.StatusForbidden {
+				assert.Contains(t, output.String(), `"rejection": "User is not an administrator"`)
+			}
+		})
+	}
+}
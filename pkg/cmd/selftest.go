@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/config"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+)
+
+const (
+	selfTestUser  = "gabi-self-test"
+	selfTestQuery = "-- GABI audit self-test"
+)
+
+// SelfTest verifies that the configuration is valid, and that every Splunk
+// endpoint accepts a synthetic audit event. Events are sent to each endpoint
+// directly, bypassing the failover, circuit breaker and spool, which would
+// otherwise mask a failure. Other audit backends are sent the event as usual.
+func SelfTest(logger *zap.SugaredLogger) error {
+	c, err := config.Load()
+	if err != nil {
+		return err
+	}
+	ae := c.Auditing
+	logger.Info("Configuration is valid")
+
+	selfTest := &audit.QueryData{
+		Query:     selfTestQuery,
+		User:      selfTestUser,
+		Timestamp: time.Now().Unix(),
+	}
+
+	if ae.Backend != auditing.BackendSplunk {
+		backend, err := audit.NewBackend(logger, ae, nil, c.Datadog)
+		if err != nil {
+			return fmt.Errorf("unable to configure auditing: %w", err)
+		}
+		defer func() { _ = audit.Shutdown(context.Background(), backend) }()
+
+		if err := backend.Write(selfTest); err != nil {
+			return fmt.Errorf("audit self-test failed: %w", err)
+		}
+		logger.Infof("Audit self-test succeeded for audit backend: %s", ae.Backend)
+		return nil
+	}
+
+	se := c.Splunk
+
+	var errs error
+	for _, endpoint := range se.AllEndpoints() {
+		e := *se
+		e.Endpoint, e.Endpoints = endpoint, nil
+
+		sa, err := audit.NewSplunkBackend(logger, &e, ae)
+		if err != nil {
+			return err
+		}
+
+		err = sa.Write(selfTest)
+		if err != nil {
+			logger.Errorf("Audit self-test failed for Splunk endpoint: %s (reason: %s): %s", endpoint, audit.Reason(err), err)
+			errs = multierr.Append(errs, fmt.Errorf("%s: %s", audit.Reason(err), endpoint))
+			continue
+		}
+		logger.Infof("Audit self-test succeeded for Splunk endpoint: %s", endpoint)
+	}
+	if errs != nil {
+		return fmt.Errorf("audit self-test failed: %w", errs)
+	}
+
+	return nil
+}
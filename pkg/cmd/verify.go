@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+)
+
+// VerifyAudit verifies the signatures of the audit events read from the files
+// given, or from the standard input otherwise, using the signing key and field
+// names configured for auditing.
+func VerifyAudit(logger *zap.SugaredLogger, paths []string) error {
+	ae := auditing.NewAuditingEnv()
+	if err := ae.Populate(); err != nil {
+		return fmt.Errorf("unable to configure auditing: %w", err)
+	}
+	if ae.SigningKey == "" {
+		return errors.New("unable to configure auditing: missing signing key")
+	}
+
+	var readers []io.Reader
+	for _, path := range paths {
+		f, err := os.Open(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("unable to open audit file: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+		readers = append(readers, f)
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+
+	// Chains can span files, such as when these are rotated.
+	n, err := audit.NewVerifier([]byte(ae.SigningKey), ae.FieldNames).Verify(io.MultiReader(readers...))
+	if err != nil {
+		return err
+	}
+	logger.Infof("Verified %d audit event(s)", n)
+	return nil
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.uber.org/multierr"
+
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/app-sre/gabi/pkg/env/cache"
+	"github.com/app-sre/gabi/pkg/env/cors"
+	"github.com/app-sre/gabi/pkg/env/datadog"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/maintenance"
+	"github.com/app-sre/gabi/pkg/env/policy"
+	"github.com/app-sre/gabi/pkg/env/proxy"
+	"github.com/app-sre/gabi/pkg/env/server"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/app-sre/gabi/pkg/env/tracing"
+	"github.com/app-sre/gabi/pkg/env/user"
+)
+
+// Config holds the settings of every part of the service, as read from the
+// environment and the files it points to. The Splunk and Datadog settings are
+// only read when either is the audit backend, and are nil otherwise.
+type Config struct {
+	Production  bool
+	User        *user.Env
+	Auth        *auth.Env
+	DB          *db.Env
+	Policy      *policy.Env
+	Limits      *limits.Env
+	Server      *server.Env
+	Proxy       *proxy.Env
+	Auditing    *auditing.Env
+	Splunk      *splunk.Env
+	Datadog     *datadog.Env
+	CORS        *cors.Env
+	Cache       *cache.Env
+	Maintenance *maintenance.Env
+	Tracing     *tracing.Env
+}
+
+type populator interface {
+	Populate() error
+}
+
+// New returns the configuration with defaults applied, as it would be loaded
+// from an empty environment, but without any validation.
+func New() *Config {
+	return &Config{
+		User:        user.NewUserEnv(),
+		Auth:        auth.NewAuthEnv(),
+		DB:          db.NewDBEnv(),
+		Policy:      policy.NewPolicyEnv(),
+		Limits:      limits.NewLimitsEnv(),
+		Server:      server.NewServerEnv(),
+		Proxy:       proxy.NewProxyEnv(),
+		Auditing:    auditing.NewAuditingEnv(),
+		CORS:        cors.NewCORSEnv(),
+		Cache:       cache.NewCacheEnv(),
+		Maintenance: maintenance.NewMaintenanceEnv(),
+		Tracing:     tracing.NewTracingEnv(),
+	}
+}
+
+// Load reads the whole configuration and validates it, returning every error
+// found at once, rather than only the first one.
+func Load() (*Config, error) {
+	c := New()
+	c.Production = os.Getenv("ENVIRONMENT") == "production"
+
+	var errs error
+	for _, p := range []struct {
+		name string
+		env  populator
+	}{
+		{"users", c.User},
+		{"authentication", c.Auth},
+		{"database", c.DB},
+		{"query policy", c.Policy},
+		{"limits", c.Limits},
+		{"server", c.Server},
+		{"trusted proxies", c.Proxy},
+		{"auditing", c.Auditing},
+		{"CORS", c.CORS},
+		{"query cache", c.Cache},
+		{"maintenance mode", c.Maintenance},
+		{"tracing", c.Tracing},
+	} {
+		if err := p.env.Populate(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to configure %s: %w", p.name, err))
+		}
+	}
+
+	if c.Auditing.Backend == auditing.BackendSplunk {
+		se := splunk.NewSplunkEnv()
+		if err := se.Populate(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to configure Splunk: %w", err))
+		}
+		c.Splunk = se
+	}
+
+	if c.Auditing.Backend == auditing.BackendDatadog {
+		dd := datadog.NewDatadogEnv()
+		if err := dd.Populate(); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("unable to configure Datadog: %w", err))
+		}
+		c.Datadog = dd
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate verifies the settings that depend on one another, which are not
+// checked when each part of the configuration is read on its own.
+func (c *Config) Validate() error {
+	var errs error
+
+	if c.Auth.ClientCAFile != "" && !c.Server.TLSEnabled() {
+		errs = multierr.Append(errs, errors.New("unable to configure authentication: client certificates require TLS to be enabled"))
+	}
+
+	if err := audit.ValidateFieldNames(c.Auditing.FieldNames); err != nil {
+		errs = multierr.Append(errs, fmt.Errorf("unable to configure auditing: %w", err))
+	}
+
+	// Statements are still run in read-only transactions without write access.
+	if write := c.Policy.WriteStatements(); len(write) > 0 && !c.DB.AllowWrite {
+		errs = multierr.Append(errs, fmt.Errorf("unable to configure query policy: statement types require database write access: %s", strings.Join(write, ", ")))
+	}
+
+	names := make([]string, 0, len(c.Auditing.Fields))
+	for name := range c.Auditing.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if audit.IsReservedField(name) || isRenamedTo(c.Auditing.FieldNames, name) {
+			errs = multierr.Append(errs, fmt.Errorf("unable to configure auditing: static field cannot replace audit field: %s", name))
+		}
+	}
+
+	if c.Auditing.Backend == auditing.BackendNone && c.Production {
+		errs = multierr.Append(errs, errors.New("unable to configure auditing: audit backend cannot be disabled in production"))
+	}
+
+	if c.Auditing.Backend == auditing.BackendSplunk && c.Splunk == nil {
+		errs = multierr.Append(errs, errors.New("unable to configure Splunk: missing Splunk configuration"))
+	}
+
+	if c.Auditing.Backend == auditing.BackendDatadog && c.Datadog == nil {
+		errs = multierr.Append(errs, errors.New("unable to configure Datadog: missing Datadog configuration"))
+	}
+
+	return errs
+}
+
+func isRenamedTo(names map[string]string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	actual := New()
+
+	require.NotNil(t, actual)
+	assert.False(t, actual.Production)
+	assert.NotNil(t, actual.User)
+	assert.NotNil(t, actual.DB)
+	assert.NotNil(t, actual.Tracing)
+	assert.Nil(t, actual.Splunk)
+	assert.Equal(t, auditing.BackendSplunk, actual.Auditing.Backend)
+}
+
+func TestLoad(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		error       bool
+		want        []string
+	}{
+		{
+			"required environment variables set",
+			func() {
+				setRequired(t)
+			},
+			false,
+			nil,
+		},
+		{
+			"Splunk backend set",
+			func() {
+				setRequired(t)
+				t.Setenv("AUDIT_BACKEND", "splunk")
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "https://splunk.example.com")
+				t.Setenv("SPLUNK_TOKEN", "test")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			false,
+			nil,
+		},
+		{
+			"Datadog backend set without API key",
+			func() {
+				setRequired(t)
+				t.Setenv("AUDIT_BACKEND", "datadog")
+			},
+			true,
+			[]string{`unable to configure Datadog: unable to access environment variable: DD_API_KEY`},
+		},
+		{
+			"no environment variables set",
+			func() {
+			},
+			true,
+			[]string{
+				`unable to configure users: unable to access environment variable: EXPIRATION_DATE`,
+				`unable to configure database: unable to access environment variable: DB_DRIVER`,
+				`unable to configure Splunk: unable to access environment variable: SPLUNK_INDEX`,
+			},
+		},
+		{
+			"invalid settings in several parts",
+			func() {
+				setRequired(t)
+				t.Setenv("DB_PORT", "test")
+				t.Setenv("SHUTDOWN_GRACE_PERIOD", "test")
+			},
+			true,
+			[]string{
+				`unable to configure database: unable to convert environment variable: DB_PORT`,
+				`unable to configure server: unable to convert environment variable: SHUTDOWN_GRACE_PERIOD`,
+			},
+		},
+		{
+			"audit backend disabled in production",
+			func() {
+				setRequired(t)
+				t.Setenv("ENVIRONMENT", "production")
+			},
+			true,
+			[]string{`unable to configure auditing: audit backend cannot be disabled in production`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual, err := Load()
+
+			if tc.error {
+				require.Error(t, err)
+				for _, want := range tc.want {
+					assert.Contains(t, err.Error(), want)
+				}
+				assert.Nil(t, actual)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, actual)
+				assert.Equal(t, "test", actual.DB.Name)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       func(*Config)
+		error       bool
+		want        []string
+	}{
+		{
+			"defaults with Splunk settings",
+			func(c *Config) {
+			},
+			false,
+			nil,
+		},
+		{
+			"client CA set without TLS",
+			func(c *Config) {
+				c.Auth.ClientCAFile = "/etc/gabi/ca.crt"
+			},
+			true,
+			[]string{`unable to configure authentication: client certificates require TLS to be enabled`},
+		},
+		{
+			"static fields replacing audit fields",
+			func(c *Config) {
+				c.Auditing.Fields = map[string]string{"user": "test", "team": "sre", "account": "test"}
+				c.Auditing.FieldNames = map[string]string{"user": "account"}
+			},
+			true,
+			[]string{
+				`static field cannot replace audit field: account`,
+				`static field cannot replace audit field: user`,
+			},
+		},
+		{
+			"write statement types allowed without write access",
+			func(c *Config) {
+				c.Policy.Statements = []string{"SELECT", "INSERT", "UPDATE"}
+			},
+			true,
+			[]string{`unable to configure query policy: statement types require database write access: INSERT, UPDATE`},
+		},
+		{
+			"write statement types allowed with write access",
+			func(c *Config) {
+				c.Policy.Statements = []string{"SELECT", "INSERT"}
+				c.DB.AllowWrite = true
+			},
+			false,
+			nil,
+		},
+		{
+			"unknown audit field renamed",
+			func(c *Config) {
+				c.Auditing.FieldNames = map[string]string{"team": "group"}
+			},
+			true,
+			[]string{`unable to configure auditing: unknown audit field: team`},
+		},
+		{
+			"audit backend disabled in production",
+			func(c *Config) {
+				c.Production = true
+				c.Auditing.Backend = auditing.BackendNone
+			},
+			true,
+			[]string{`unable to configure auditing: audit backend cannot be disabled in production`},
+		},
+		{
+			"Splunk backend without Splunk settings",
+			func(c *Config) {
+				c.Splunk = nil
+			},
+			true,
+			[]string{`unable to configure Splunk: missing Splunk configuration`},
+		},
+		{
+			"Datadog backend without Datadog settings",
+			func(c *Config) {
+				c.Auditing.Backend = auditing.BackendDatadog
+			},
+			true,
+			[]string{`unable to configure Datadog: missing Datadog configuration`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			c := New()
+			c.Splunk = splunk.NewSplunkEnv()
+			tc.given(c)
+
+			err := c.Validate()
+
+			if tc.error {
+				require.Error(t, err)
+				for _, want := range tc.want {
+					assert.Contains(t, err.Error(), want)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func setRequired(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("EXPIRATION_DATE", "2023-01-01")
+	t.Setenv("DB_DRIVER", "pgx")
+	t.Setenv("DB_HOST", "127.0.0.1")
+	t.Setenv("DB_USER", "test")
+	t.Setenv("DB_PASSWORD", "test")
+	t.Setenv("DB_NAME", "test")
+	t.Setenv("AUDIT_BACKEND", "none")
+}
@@ -0,0 +1,218 @@
+package auditing
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const defaultSampleRate = 1.0
+
+// Keys shorter than the output of SHA-256 weaken the signatures.
+const minSigningKeyLength = 32
+
+const (
+	BackendSplunk  = "splunk"
+	BackendDatadog = "datadog"
+	BackendFile    = "file"
+	BackendConsole = "console"
+	BackendNone    = "none"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+)
+
+const (
+	TimeoutPolicyReject = "reject"
+	TimeoutPolicyAllow  = "allow"
+)
+
+type Env struct {
+	Backend             string
+	FilePath            string
+	Format              string
+	Namespace           string
+	Pod                 string
+	IncludeArgs         bool
+	IncludeDatabaseName bool
+	IncludeDatabaseHost bool
+	IncludeClientIP     bool
+	IncludeColumns      bool
+	RedactLiterals      bool
+	TwoPhase            bool
+	SampleRate          float64
+	Fields              map[string]string
+	FieldNames          map[string]string
+	SigningKey          string
+	Timeout             time.Duration
+	TimeoutPolicy       string
+}
+
+func NewAuditingEnv() *Env {
+	return &Env{Backend: BackendSplunk, Format: FormatJSON, SampleRate: defaultSampleRate, TimeoutPolicy: TimeoutPolicyReject}
+}
+
+func (a *Env) Populate() error {
+	if s := os.Getenv("AUDIT_BACKEND"); s != "" {
+		switch s = strings.ToLower(s); s {
+		case BackendSplunk, BackendDatadog, BackendFile, BackendConsole, BackendNone:
+			a.Backend = s
+		default:
+			return &env.TypeError{Name: "AUDIT_BACKEND"}
+		}
+	}
+
+	if a.Backend == BackendFile {
+		path := os.Getenv("AUDIT_FILE_PATH")
+		if path == "" {
+			return &env.Error{Name: "AUDIT_FILE_PATH"}
+		}
+		a.FilePath = path
+	}
+
+	// Only the file and console backends write events themselves, thus can
+	// encode these otherwise.
+	if s := os.Getenv("AUDIT_FORMAT"); s != "" {
+		switch s = strings.ToLower(s); s {
+		case FormatJSON:
+		case FormatCEF, FormatLEEF:
+			if a.Backend != BackendFile && a.Backend != BackendConsole {
+				return &env.TypeError{Name: "AUDIT_FORMAT"}
+			}
+		default:
+			return &env.TypeError{Name: "AUDIT_FORMAT"}
+		}
+		a.Format = s
+	}
+
+	// Identify the pod in events written by the file and console backends.
+	a.Namespace = os.Getenv("NAMESPACE")
+	a.Pod = os.Getenv("POD_NAME")
+
+	if s := os.Getenv("AUDIT_QUERY_ARGS"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_QUERY_ARGS"}
+		}
+		a.IncludeArgs = include
+	}
+
+	if s := os.Getenv("AUDIT_DATABASE_NAME"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_DATABASE_NAME"}
+		}
+		a.IncludeDatabaseName = include
+	}
+
+	if s := os.Getenv("AUDIT_DATABASE_HOST"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_DATABASE_HOST"}
+		}
+		a.IncludeDatabaseHost = include
+	}
+
+	if s := os.Getenv("AUDIT_CLIENT_IP"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_CLIENT_IP"}
+		}
+		a.IncludeClientIP = include
+	}
+
+	if s := os.Getenv("AUDIT_COLUMNS"); s != "" {
+		include, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_COLUMNS"}
+		}
+		a.IncludeColumns = include
+	}
+
+	if s := os.Getenv("AUDIT_REDACT_LITERALS"); s != "" {
+		redact, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_REDACT_LITERALS"}
+		}
+		a.RedactLiterals = redact
+	}
+
+	// Events of queries audited in two phases carry the phase they belong to.
+	if s := os.Getenv("AUDIT_TWO_PHASE"); s != "" {
+		twoPhase, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_TWO_PHASE"}
+		}
+		a.TwoPhase = twoPhase
+	}
+
+	if s := os.Getenv("AUDIT_SAMPLE_RATE"); s != "" {
+		rate, err := strconv.ParseFloat(s, 64)
+		if err != nil || rate <= 0 || rate > 1 {
+			return &env.TypeError{Name: "AUDIT_SAMPLE_RATE"}
+		}
+		a.SampleRate = rate
+	}
+
+	if s := os.Getenv("AUDIT_STATIC_FIELDS"); s != "" {
+		fields, err := env.KeyValues(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_STATIC_FIELDS"}
+		}
+		if len(fields) > 0 {
+			a.Fields = fields
+		}
+	}
+
+	// Field names map the name of an audit field to the name it is sent as.
+	if s := os.Getenv("AUDIT_FIELD_NAMES"); s != "" {
+		names, err := env.KeyValues(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUDIT_FIELD_NAMES"}
+		}
+		for _, name := range names {
+			if name == "" {
+				return &env.TypeError{Name: "AUDIT_FIELD_NAMES"}
+			}
+		}
+		if len(names) > 0 {
+			a.FieldNames = names
+		}
+	}
+
+	key, err := env.Secret("AUDIT_SIGNING_KEY")
+	if err != nil {
+		return err
+	}
+	if key != "" && len(key) < minSigningKeyLength {
+		return &env.TypeError{Name: "AUDIT_SIGNING_KEY"}
+	}
+	a.SigningKey = key
+
+	// The timeout bounds every audit write, independently of the query
+	// timeout, with the default leaving it to the backend.
+	if s := os.Getenv("AUDIT_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil || timeout < 0 {
+			return &env.TypeError{Name: "AUDIT_TIMEOUT"}
+		}
+		a.Timeout = timeout
+	}
+
+	if s := os.Getenv("AUDIT_TIMEOUT_POLICY"); s != "" {
+		switch s = strings.ToLower(s); s {
+		case TimeoutPolicyReject, TimeoutPolicyAllow:
+			a.TimeoutPolicy = s
+		default:
+			return &env.TypeError{Name: "AUDIT_TIMEOUT_POLICY"}
+		}
+	}
+
+	return nil
+}
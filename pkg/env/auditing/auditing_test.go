@@ -0,0 +1,390 @@
+package auditing
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuditingEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewAuditingEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+	assert.Equal(t, 1.0, actual.SampleRate)
+	assert.False(t, actual.TwoPhase)
+	assert.Equal(t, BackendSplunk, actual.Backend)
+	assert.Equal(t, FormatJSON, actual.Format)
+	assert.Equal(t, TimeoutPolicyReject, actual.TimeoutPolicy)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{},
+			false,
+			``,
+		},
+		{
+			"query arguments included",
+			func() {
+				t.Setenv("AUDIT_QUERY_ARGS", "true")
+			},
+			&Env{IncludeArgs: true},
+			false,
+			``,
+		},
+		{
+			"database identity included",
+			func() {
+				t.Setenv("AUDIT_DATABASE_NAME", "true")
+				t.Setenv("AUDIT_DATABASE_HOST", "true")
+			},
+			&Env{IncludeDatabaseName: true, IncludeDatabaseHost: true},
+			false,
+			``,
+		},
+		{
+			"sample rate set",
+			func() {
+				t.Setenv("AUDIT_SAMPLE_RATE", "0.25")
+			},
+			&Env{SampleRate: 0.25},
+			false,
+			``,
+		},
+		{
+			"client IP included",
+			func() {
+				t.Setenv("AUDIT_CLIENT_IP", "true")
+			},
+			&Env{IncludeClientIP: true},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_CLIENT_IP environment variable",
+			func() {
+				t.Setenv("AUDIT_CLIENT_IP", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_CLIENT_IP`,
+		},
+		{
+			"columns included",
+			func() {
+				t.Setenv("AUDIT_COLUMNS", "true")
+			},
+			&Env{IncludeColumns: true},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_COLUMNS environment variable",
+			func() {
+				t.Setenv("AUDIT_COLUMNS", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_COLUMNS`,
+		},
+		{
+			"invalid AUDIT_QUERY_ARGS environment variable",
+			func() {
+				t.Setenv("AUDIT_QUERY_ARGS", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_QUERY_ARGS`,
+		},
+		{
+			"invalid AUDIT_DATABASE_NAME environment variable",
+			func() {
+				t.Setenv("AUDIT_DATABASE_NAME", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_DATABASE_NAME`,
+		},
+		{
+			"invalid AUDIT_DATABASE_HOST environment variable",
+			func() {
+				t.Setenv("AUDIT_DATABASE_HOST", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_DATABASE_HOST`,
+		},
+		{
+			"literal redaction enabled",
+			func() {
+				t.Setenv("AUDIT_REDACT_LITERALS", "true")
+			},
+			&Env{RedactLiterals: true},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_REDACT_LITERALS environment variable",
+			func() {
+				t.Setenv("AUDIT_REDACT_LITERALS", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_REDACT_LITERALS`,
+		},
+		{
+			"two-phase auditing enabled",
+			func() {
+				t.Setenv("AUDIT_TWO_PHASE", "true")
+			},
+			&Env{TwoPhase: true},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_TWO_PHASE environment variable",
+			func() {
+				t.Setenv("AUDIT_TWO_PHASE", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_TWO_PHASE`,
+		},
+		{
+			"invalid AUDIT_SAMPLE_RATE environment variable",
+			func() {
+				t.Setenv("AUDIT_SAMPLE_RATE", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_SAMPLE_RATE`,
+		},
+		{
+			"AUDIT_SAMPLE_RATE environment variable out of range",
+			func() {
+				t.Setenv("AUDIT_SAMPLE_RATE", "1.5")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_SAMPLE_RATE`,
+		},
+		{
+			"static fields set",
+			func() {
+				t.Setenv("AUDIT_STATIC_FIELDS", "environment=prod, team = sre,,note=a=b")
+			},
+			&Env{Fields: map[string]string{"environment": "prod", "team": "sre", "note": "a=b"}},
+			false,
+			``,
+		},
+		{
+			"static fields set with empty list",
+			func() {
+				t.Setenv("AUDIT_STATIC_FIELDS", " , ")
+			},
+			&Env{},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_STATIC_FIELDS environment variable",
+			func() {
+				t.Setenv("AUDIT_STATIC_FIELDS", "environment")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_STATIC_FIELDS`,
+		},
+		{
+			"AUDIT_STATIC_FIELDS environment variable with empty key",
+			func() {
+				t.Setenv("AUDIT_STATIC_FIELDS", "=prod")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_STATIC_FIELDS`,
+		},
+		{
+			"field names set",
+			func() {
+				t.Setenv("AUDIT_FIELD_NAMES", "query=sql, user = actor")
+			},
+			&Env{FieldNames: map[string]string{"query": "sql", "user": "actor"}},
+			false,
+			``,
+		},
+		{
+			"AUDIT_FIELD_NAMES environment variable with empty name",
+			func() {
+				t.Setenv("AUDIT_FIELD_NAMES", "query=")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_FIELD_NAMES`,
+		},
+		{
+			"signing key set",
+			func() {
+				t.Setenv("AUDIT_SIGNING_KEY", strings.Repeat("k", 32))
+			},
+			&Env{SigningKey: strings.Repeat("k", 32)},
+			false,
+			``,
+		},
+		{
+			"AUDIT_SIGNING_KEY environment variable too short",
+			func() {
+				t.Setenv("AUDIT_SIGNING_KEY", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_SIGNING_KEY`,
+		},
+		{
+			"timeout and timeout policy set",
+			func() {
+				t.Setenv("AUDIT_TIMEOUT", "2s")
+				t.Setenv("AUDIT_TIMEOUT_POLICY", "Allow")
+			},
+			&Env{Timeout: 2 * time.Second, TimeoutPolicy: TimeoutPolicyAllow},
+			false,
+			``,
+		},
+		{
+			"invalid AUDIT_TIMEOUT environment variable",
+			func() {
+				t.Setenv("AUDIT_TIMEOUT", "-1s")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_TIMEOUT`,
+		},
+		{
+			"invalid AUDIT_TIMEOUT_POLICY environment variable",
+			func() {
+				t.Setenv("AUDIT_TIMEOUT_POLICY", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_TIMEOUT_POLICY`,
+		},
+		{
+			"file backend set",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "File")
+				t.Setenv("AUDIT_FILE_PATH", "/var/log/gabi/audit.log")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{Backend: BackendFile, FilePath: "/var/log/gabi/audit.log", Namespace: "test", Pod: "test"},
+			false,
+			``,
+		},
+		{
+			"console backend set",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "console")
+			},
+			&Env{Backend: BackendConsole},
+			false,
+			``,
+		},
+		{
+			"Datadog backend set",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "datadog")
+			},
+			&Env{Backend: BackendDatadog},
+			false,
+			``,
+		},
+		{
+			"file backend set without AUDIT_FILE_PATH environment variable",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "file")
+			},
+			&Env{Backend: BackendFile},
+			true,
+			`unable to access environment variable: AUDIT_FILE_PATH`,
+		},
+		{
+			"console backend set with CEF format",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "console")
+				t.Setenv("AUDIT_FORMAT", "CEF")
+			},
+			&Env{Backend: BackendConsole, Format: FormatCEF},
+			false,
+			``,
+		},
+		{
+			"Splunk backend set with LEEF format",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "splunk")
+				t.Setenv("AUDIT_FORMAT", "leef")
+			},
+			&Env{Backend: BackendSplunk},
+			true,
+			`unable to convert environment variable: AUDIT_FORMAT`,
+		},
+		{
+			"invalid AUDIT_FORMAT environment variable",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "file")
+				t.Setenv("AUDIT_FILE_PATH", "/var/log/gabi/audit.log")
+				t.Setenv("AUDIT_FORMAT", "xml")
+			},
+			&Env{Backend: BackendFile, FilePath: "/var/log/gabi/audit.log"},
+			true,
+			`unable to convert environment variable: AUDIT_FORMAT`,
+		},
+		{
+			"invalid AUDIT_BACKEND environment variable",
+			func() {
+				t.Setenv("AUDIT_BACKEND", "syslog")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: AUDIT_BACKEND`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const defaultSecretHeader = "X-Gabi-Auth-Secret"
+
+// The authentication providers that can be chained, each identifying the user
+// from the credentials of the request in its own way.
+const (
+	ProviderTrustedHeader   = "trusted_header"
+	ProviderForwardedHeader = "forwarded_header"
+	ProviderBearer          = "bearer"
+	ProviderClientCert      = "client_cert"
+)
+
+type Env struct {
+	UserHeader   string
+	SecretHeader string
+	Secret       string
+	Exclusive    bool
+
+	BearerToken  string
+	BearerUser   string
+	ClientCAFile string
+
+	Providers []string
+	Admins    []string
+}
+
+func NewAuthEnv() *Env {
+	return &Env{}
+}
+
+// Populate leaves trusted header authentication disabled unless a user header
+// is set, in which case a shared secret is also required. The providers, when
+// set, must each have their credentials configured.
+func (a *Env) Populate() error {
+	a.BearerToken = os.Getenv("AUTH_BEARER_TOKEN")
+	a.BearerUser = strings.TrimSpace(os.Getenv("AUTH_BEARER_USER"))
+	a.ClientCAFile = strings.TrimSpace(os.Getenv("AUTH_CLIENT_CA_FILE"))
+
+	for _, entry := range strings.Split(os.Getenv("AUTH_ADMIN_USERS"), ",") {
+		if s := strings.TrimSpace(entry); s != "" {
+			a.Admins = append(a.Admins, s)
+		}
+	}
+
+	if err := a.populateTrustedHeader(); err != nil {
+		return err
+	}
+
+	for _, entry := range strings.Split(os.Getenv("AUTH_PROVIDERS"), ",") {
+		s := strings.ToLower(strings.TrimSpace(entry))
+		if s == "" {
+			continue
+		}
+		switch s {
+		case ProviderTrustedHeader, ProviderForwardedHeader, ProviderBearer, ProviderClientCert:
+		default:
+			return &env.TypeError{Name: "AUTH_PROVIDERS"}
+		}
+		if a.HasProvider(s) {
+			return &env.TypeError{Name: "AUTH_PROVIDERS"}
+		}
+		a.Providers = append(a.Providers, s)
+	}
+
+	switch {
+	case a.HasProvider(ProviderTrustedHeader) && !a.Enabled():
+		return &env.Error{Name: "AUTH_TRUSTED_USER_HEADER"}
+	case a.HasProvider(ProviderBearer) && a.BearerToken == "":
+		return &env.Error{Name: "AUTH_BEARER_TOKEN"}
+	case a.HasProvider(ProviderBearer) && a.BearerUser == "":
+		return &env.Error{Name: "AUTH_BEARER_USER"}
+	case a.HasProvider(ProviderClientCert) && a.ClientCAFile == "":
+		return &env.Error{Name: "AUTH_CLIENT_CA_FILE"}
+	}
+
+	return nil
+}
+
+func (a *Env) populateTrustedHeader() error {
+	a.UserHeader = strings.TrimSpace(os.Getenv("AUTH_TRUSTED_USER_HEADER"))
+	if a.UserHeader == "" {
+		return nil
+	}
+
+	a.SecretHeader = defaultSecretHeader
+	if s := strings.TrimSpace(os.Getenv("AUTH_TRUSTED_SECRET_HEADER")); s != "" {
+		a.SecretHeader = s
+	}
+
+	a.Secret = os.Getenv("AUTH_TRUSTED_SECRET")
+	if a.Secret == "" {
+		return &env.Error{Name: "AUTH_TRUSTED_SECRET"}
+	}
+
+	if s := os.Getenv("AUTH_TRUSTED_HEADER_ONLY"); s != "" {
+		ok, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "AUTH_TRUSTED_HEADER_ONLY"}
+		}
+		a.Exclusive = ok
+	}
+
+	return nil
+}
+
+func (a *Env) Enabled() bool {
+	return a.UserHeader != ""
+}
+
+// IsSecretValid reports whether the given secret matches the shared secret,
+// using a constant-time comparison.
+func (a *Env) IsSecretValid(secret string) bool {
+	if a.Secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a.Secret), []byte(secret)) == 1
+}
+
+// ClientAuthEnabled reports whether requests must carry a bearer token or a
+// verified client certificate before reaching any other handling, which is not
+// the case for those identifying the user as providers instead.
+func (a *Env) ClientAuthEnabled() bool {
+	return a.BearerRequired() || a.ClientCertRequired()
+}
+
+func (a *Env) BearerRequired() bool {
+	return a.BearerToken != "" && !a.HasProvider(ProviderBearer)
+}
+
+func (a *Env) ClientCertRequired() bool {
+	return a.ClientCAFile != "" && !a.HasProvider(ProviderClientCert)
+}
+
+// IsTokenValid reports whether the given token matches the bearer token,
+// using a constant-time comparison.
+func (a *Env) IsTokenValid(token string) bool {
+	if a.BearerToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(a.BearerToken), []byte(token)) == 1
+}
+
+// HasProvider reports whether the provider is part of the configured chain.
+func (a *Env) HasProvider(provider string) bool {
+	for _, p := range a.Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the user is one of the administrators, permitted to
+// use the endpoints meant for operating GABI itself.
+func (a *Env) IsAdmin(user string) bool {
+	for _, admin := range a.Admins {
+		if admin == user {
+			return true
+		}
+	}
+	return false
+}
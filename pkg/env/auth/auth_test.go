@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuthEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewAuthEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{},
+			false,
+			``,
+		},
+		{
+			"secret set without user header",
+			func() {
+				t.Setenv("AUTH_TRUSTED_SECRET", "test")
+			},
+			&Env{},
+			false,
+			``,
+		},
+		{
+			"bearer token and client CA file set",
+			func() {
+				t.Setenv("AUTH_BEARER_TOKEN", "test")
+				t.Setenv("AUTH_CLIENT_CA_FILE", "/etc/gabi/ca.crt")
+			},
+			&Env{BearerToken: "test", ClientCAFile: "/etc/gabi/ca.crt"},
+			false,
+			``,
+		},
+		{
+			"user header and secret set",
+			func() {
+				t.Setenv("AUTH_TRUSTED_USER_HEADER", "X-Auth-Request-User")
+				t.Setenv("AUTH_TRUSTED_SECRET", "test")
+			},
+			&Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "test"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set",
+			func() {
+				t.Setenv("AUTH_TRUSTED_USER_HEADER", "X-Auth-Request-User")
+				t.Setenv("AUTH_TRUSTED_SECRET_HEADER", "X-Auth-Secret")
+				t.Setenv("AUTH_TRUSTED_SECRET", "test")
+				t.Setenv("AUTH_TRUSTED_HEADER_ONLY", "true")
+			},
+			&Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Auth-Secret", Secret: "test", Exclusive: true},
+			false,
+			``,
+		},
+		{
+			"user header set without secret",
+			func() {
+				t.Setenv("AUTH_TRUSTED_USER_HEADER", "X-Auth-Request-User")
+			},
+			&Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret"},
+			true,
+			`unable to access environment variable: AUTH_TRUSTED_SECRET`,
+		},
+		{
+			"providers set with their credentials",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "client_cert, Bearer,forwarded_header")
+				t.Setenv("AUTH_BEARER_TOKEN", "test")
+				t.Setenv("AUTH_BEARER_USER", "service")
+				t.Setenv("AUTH_CLIENT_CA_FILE", "/etc/gabi/ca.crt")
+			},
+			&Env{
+				BearerToken:  "test",
+				BearerUser:   "service",
+				ClientCAFile: "/etc/gabi/ca.crt",
+				Providers:    []string{"client_cert", "bearer", "forwarded_header"},
+			},
+			false,
+			``,
+		},
+		{
+			"administrators set",
+			func() {
+				t.Setenv("AUTH_ADMIN_USERS", "test, admin,")
+			},
+			&Env{Admins: []string{"test", "admin"}},
+			false,
+			``,
+		},
+		{
+			"unknown provider set",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "forwarded_header,basic")
+			},
+			&Env{Providers: []string{"forwarded_header"}},
+			true,
+			`unable to convert environment variable: AUTH_PROVIDERS`,
+		},
+		{
+			"provider set more than once",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "forwarded_header,forwarded_header")
+			},
+			&Env{Providers: []string{"forwarded_header"}},
+			true,
+			`unable to convert environment variable: AUTH_PROVIDERS`,
+		},
+		{
+			"trusted header provider set without user header",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "trusted_header")
+			},
+			&Env{Providers: []string{"trusted_header"}},
+			true,
+			`unable to access environment variable: AUTH_TRUSTED_USER_HEADER`,
+		},
+		{
+			"bearer provider set without user",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "bearer")
+				t.Setenv("AUTH_BEARER_TOKEN", "test")
+			},
+			&Env{BearerToken: "test", Providers: []string{"bearer"}},
+			true,
+			`unable to access environment variable: AUTH_BEARER_USER`,
+		},
+		{
+			"client certificate provider set without client CA file",
+			func() {
+				t.Setenv("AUTH_PROVIDERS", "client_cert")
+			},
+			&Env{Providers: []string{"client_cert"}},
+			true,
+			`unable to access environment variable: AUTH_CLIENT_CA_FILE`,
+		},
+		{
+			"invalid AUTH_TRUSTED_HEADER_ONLY environment variable",
+			func() {
+				t.Setenv("AUTH_TRUSTED_USER_HEADER", "X-Auth-Request-User")
+				t.Setenv("AUTH_TRUSTED_SECRET", "test")
+				t.Setenv("AUTH_TRUSTED_HEADER_ONLY", "test")
+			},
+			&Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "test"},
+			true,
+			`unable to convert environment variable: AUTH_TRUSTED_HEADER_ONLY`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			tc.given()
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIsSecretValid(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		secret      string
+		expected    bool
+	}{
+		{"no secret set", "", "", false},
+		{"secret matching", "test", "test", true},
+		{"secret not matching", "test", "test2", false},
+		{"secret missing", "test", "", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := &Env{Secret: tc.given}
+			assert.Equal(t, tc.expected, e.IsSecretValid(tc.secret))
+		})
+	}
+}
+
+func TestClientAuthEnabled(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *Env
+		expected    bool
+	}{
+		{"nothing set", &Env{}, false},
+		{"trusted header set", &Env{UserHeader: "X-Auth-Request-User", Secret: "test"}, false},
+		{"bearer token set", &Env{BearerToken: "test"}, true},
+		{"client CA file set", &Env{ClientCAFile: "/etc/gabi/ca.crt"}, true},
+		{"bearer token set as provider", &Env{BearerToken: "test", Providers: []string{"bearer"}}, false},
+		{"client CA file set as provider", &Env{ClientCAFile: "/etc/gabi/ca.crt", Providers: []string{"client_cert"}}, false},
+		{"client CA file set with bearer provider", &Env{BearerToken: "test", ClientCAFile: "/etc/gabi/ca.crt", Providers: []string{"bearer"}}, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.given.ClientAuthEnabled())
+		})
+	}
+}
+
+func TestIsTokenValid(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		token       string
+		expected    bool
+	}{
+		{"no token set", "", "", false},
+		{"token matching", "test", "test", true},
+		{"token not matching", "test", "test2", false},
+		{"token missing", "test", "", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := &Env{BearerToken: tc.given}
+			assert.Equal(t, tc.expected, e.IsTokenValid(tc.token))
+		})
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		user        string
+		expected    bool
+	}{
+		{"no administrators set", nil, "test", false},
+		{"user set as administrator", []string{"admin", "test"}, "test", true},
+		{"user not set as administrator", []string{"admin"}, "test", false},
+		{"empty user", []string{"admin"}, "", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := &Env{Admins: tc.given}
+			assert.Equal(t, tc.expected, e.IsAdmin(tc.user))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const defaultTTL = 1 * time.Minute
+
+type Env struct {
+	Size    int
+	TTL     time.Duration
+	PerUser bool
+}
+
+func NewCacheEnv() *Env {
+	return &Env{}
+}
+
+// Populate leaves the cache disabled unless its size is set. Entries are kept
+// per user by default, as results can differ between users, such as when
+// row-level security is in use.
+func (c *Env) Populate() error {
+	if s := os.Getenv("CACHE_SIZE"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "CACHE_SIZE"}
+		}
+		c.Size = int(n)
+	}
+
+	c.TTL = defaultTTL
+	if s := os.Getenv("CACHE_TTL"); s != "" {
+		ttl, err := time.ParseDuration(s)
+		if err != nil || ttl <= 0 {
+			return &env.TypeError{Name: "CACHE_TTL"}
+		}
+		c.TTL = ttl
+	}
+
+	c.PerUser = true
+	if s := os.Getenv("CACHE_PER_USER"); s != "" {
+		perUser, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "CACHE_PER_USER"}
+		}
+		c.PerUser = perUser
+	}
+
+	return nil
+}
+
+func (c *Env) Enabled() bool {
+	return c.Size > 0
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCacheEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewCacheEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{TTL: defaultTTL, PerUser: true},
+			false,
+			``,
+		},
+		{
+			"all environment variables set",
+			func() {
+				t.Setenv("CACHE_SIZE", "100")
+				t.Setenv("CACHE_TTL", "5m")
+				t.Setenv("CACHE_PER_USER", "false")
+			},
+			&Env{Size: 100, TTL: 5 * time.Minute, PerUser: false},
+			false,
+			``,
+		},
+		{
+			"invalid CACHE_SIZE environment variable",
+			func() {
+				t.Setenv("CACHE_SIZE", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: CACHE_SIZE`,
+		},
+		{
+			"invalid CACHE_TTL environment variable",
+			func() {
+				t.Setenv("CACHE_TTL", "-1s")
+			},
+			&Env{TTL: defaultTTL},
+			true,
+			`unable to convert environment variable: CACHE_TTL`,
+		},
+		{
+			"invalid CACHE_PER_USER environment variable",
+			func() {
+				t.Setenv("CACHE_PER_USER", "test")
+			},
+			&Env{TTL: defaultTTL, PerUser: true},
+			true,
+			`unable to convert environment variable: CACHE_PER_USER`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			tc.given()
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expected.Size > 0, actual.Enabled())
+		})
+	}
+}
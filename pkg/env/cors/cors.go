@@ -0,0 +1,89 @@
+package cors
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const wildcardOrigin = "*"
+
+var (
+	defaultAllowedMethods = []string{http.MethodGet, http.MethodPost}
+	defaultAllowedHeaders = []string{"Content-Type"}
+)
+
+type Env struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+}
+
+func NewCORSEnv() *Env {
+	return &Env{}
+}
+
+// Populate leaves CORS disabled unless any allowed origins are set.
+func (c *Env) Populate() error {
+	c.AllowedOrigins = split(os.Getenv("CORS_ALLOWED_ORIGINS"))
+
+	c.AllowedMethods = defaultAllowedMethods
+	if methods := split(os.Getenv("CORS_ALLOWED_METHODS")); len(methods) > 0 {
+		for i := range methods {
+			methods[i] = strings.ToUpper(methods[i])
+		}
+		c.AllowedMethods = methods
+	}
+
+	c.AllowedHeaders = defaultAllowedHeaders
+	if headers := split(os.Getenv("CORS_ALLOWED_HEADERS")); len(headers) > 0 {
+		c.AllowedHeaders = headers
+	}
+
+	if s := os.Getenv("CORS_ALLOW_CREDENTIALS"); s != "" {
+		ok, err := strconv.ParseBool(s)
+		// Credentials must never be allowed for any origin.
+		if err != nil || (ok && c.IsOriginAllowed(wildcardOrigin)) {
+			return &env.TypeError{Name: "CORS_ALLOW_CREDENTIALS"}
+		}
+		c.AllowCredentials = ok
+	}
+
+	return nil
+}
+
+func (c *Env) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+func (c *Env) IsOriginAllowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == wildcardOrigin || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Env) IsMethodAllowed(method string) bool {
+	for _, m := range c.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func split(s string) []string {
+	var values []string
+	for _, entry := range strings.Split(s, ",") {
+		if v := strings.TrimSpace(entry); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,124 @@
+package cors
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCORSEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewCORSEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{AllowedMethods: []string{"GET", "POST"}, AllowedHeaders: []string{"Content-Type"}},
+			false,
+			``,
+		},
+		{
+			"all environment variables set",
+			func() {
+				t.Setenv("CORS_ALLOWED_ORIGINS", "https://example.com, https://example.org")
+				t.Setenv("CORS_ALLOWED_METHODS", "post")
+				t.Setenv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization")
+				t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+			},
+			&Env{
+				AllowedOrigins:   []string{"https://example.com", "https://example.org"},
+				AllowedMethods:   []string{"POST"},
+				AllowedHeaders:   []string{"Content-Type", "Authorization"},
+				AllowCredentials: true,
+			},
+			false,
+			``,
+		},
+		{
+			"invalid CORS_ALLOW_CREDENTIALS environment variable",
+			func() {
+				t.Setenv("CORS_ALLOWED_ORIGINS", "https://example.com")
+				t.Setenv("CORS_ALLOW_CREDENTIALS", "test")
+			},
+			&Env{AllowedOrigins: []string{"https://example.com"}, AllowedMethods: []string{"GET", "POST"}, AllowedHeaders: []string{"Content-Type"}},
+			true,
+			`unable to convert environment variable: CORS_ALLOW_CREDENTIALS`,
+		},
+		{
+			"credentials allowed for any origin",
+			func() {
+				t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+				t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+			},
+			&Env{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "POST"}, AllowedHeaders: []string{"Content-Type"}},
+			true,
+			`unable to convert environment variable: CORS_ALLOW_CREDENTIALS`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			tc.given()
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIsOriginAllowed(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		origin      string
+		expected    bool
+	}{
+		{"no origins allowed", nil, "https://example.com", false},
+		{"origin allowed", []string{"https://example.com"}, "https://EXAMPLE.com", true},
+		{"origin not allowed", []string{"https://example.com"}, "https://example.org", false},
+		{"any origin allowed", []string{"*"}, "https://example.org", true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := &Env{AllowedOrigins: tc.given}
+			assert.Equal(t, tc.expected, e.IsOriginAllowed(tc.origin))
+			assert.Equal(t, len(tc.given) > 0, e.Enabled())
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package datadog
+
+import (
+	"os"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const (
+	defaultSite    = "datadoghq.com"
+	defaultSource  = "gabi"
+	defaultService = "gabi"
+)
+
+type Env struct {
+	Endpoint  string
+	APIKey    string
+	Source    string
+	Service   string
+	Tags      []string
+	Host      string
+	Namespace string
+	Pod       string
+}
+
+func NewDatadogEnv() *Env {
+	return &Env{Source: defaultSource, Service: defaultService}
+}
+
+// Populate sends logs to the intake endpoint of the Datadog site, unless the
+// endpoint is set explicitly, such as when going through a proxy.
+func (d *Env) Populate() error {
+	key, err := env.Secret("DD_API_KEY")
+	if err != nil {
+		return err
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return &env.Error{Name: "DD_API_KEY"}
+	}
+	d.APIKey = key
+
+	site := strings.TrimSpace(os.Getenv("DD_SITE"))
+	if site == "" {
+		site = defaultSite
+	}
+	d.Endpoint = "https://http-intake.logs." + site + "/api/v2/logs"
+	if endpoint := strings.TrimSpace(os.Getenv("DD_LOGS_ENDPOINT")); endpoint != "" {
+		d.Endpoint = endpoint
+	}
+
+	if source := strings.TrimSpace(os.Getenv("DD_SOURCE")); source != "" {
+		d.Source = source
+	}
+	if service := strings.TrimSpace(os.Getenv("DD_SERVICE")); service != "" {
+		d.Service = service
+	}
+
+	// Tags are accepted separated by commas or spaces, as with the agent.
+	if tags := strings.FieldsFunc(os.Getenv("DD_TAGS"), func(r rune) bool {
+		return r == ',' || r == ' '
+	}); len(tags) > 0 {
+		d.Tags = tags
+	}
+
+	d.Host = os.Getenv("HOST")
+	d.Namespace = os.Getenv("NAMESPACE")
+	d.Pod = os.Getenv("POD_NAME")
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package datadog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDatadogEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewDatadogEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+	assert.Equal(t, defaultSource, actual.Source)
+	assert.Equal(t, defaultService, actual.Service)
+}
+
+func TestPopulate(t *testing.T) {
+	dir := t.TempDir()
+
+	keyFile := filepath.Join(dir, "api-key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file123\n"), 0o600))
+
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"only API key set",
+			func() {
+				t.Setenv("DD_API_KEY", "test123")
+			},
+			&Env{Endpoint: "https://http-intake.logs.datadoghq.com/api/v2/logs", APIKey: "test123", Source: defaultSource, Service: defaultService},
+			false,
+			``,
+		},
+		{
+			"all environment variables set",
+			func() {
+				t.Setenv("DD_API_KEY", "test123")
+				t.Setenv("DD_SITE", "datadoghq.eu")
+				t.Setenv("DD_SOURCE", "postgres")
+				t.Setenv("DD_SERVICE", "gabi-test")
+				t.Setenv("DD_TAGS", "env:test, team:sre  tier:db")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{
+				Endpoint:  "https://http-intake.logs.datadoghq.eu/api/v2/logs",
+				APIKey:    "test123",
+				Source:    "postgres",
+				Service:   "gabi-test",
+				Tags:      []string{"env:test", "team:sre", "tier:db"},
+				Host:      "test",
+				Namespace: "test",
+				Pod:       "test",
+			},
+			false,
+			``,
+		},
+		{
+			"logs endpoint set",
+			func() {
+				t.Setenv("DD_API_KEY", "test123")
+				t.Setenv("DD_SITE", "datadoghq.eu")
+				t.Setenv("DD_LOGS_ENDPOINT", "https://proxy.example.com/api/v2/logs")
+			},
+			&Env{Endpoint: "https://proxy.example.com/api/v2/logs", APIKey: "test123", Source: defaultSource, Service: defaultService},
+			false,
+			``,
+		},
+		{
+			"API key read from file",
+			func() {
+				t.Setenv("DD_API_KEY", "test123")
+				t.Setenv("DD_API_KEY_FILE", keyFile)
+			},
+			&Env{Endpoint: "https://http-intake.logs.datadoghq.com/api/v2/logs", APIKey: "file123", Source: defaultSource, Service: defaultService},
+			false,
+			``,
+		},
+		{
+			"API key file missing",
+			func() {
+				t.Setenv("DD_API_KEY_FILE", filepath.Join(dir, "missing"))
+			},
+			&Env{Source: defaultSource, Service: defaultService},
+			true,
+			`unable to read secret file for DD_API_KEY`,
+		},
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{Source: defaultSource, Service: defaultService},
+			true,
+			`unable to access environment variable: DD_API_KEY`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := NewDatadogEnv()
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package db
+
+import "strings"
+
+// Longer values are truncated, as these only serve to attribute the query.
+const maxAnnotationLength = 128
+
+// Annotate returns the query prefixed with a comment naming the user and the
+// request it is run for, such that it can be attributed in the activity views
+// of the database, such as pg_stat_activity. Values are reduced to a safe set
+// of characters, thus can neither end the comment nor turn it into one that
+// MySQL executes.
+func Annotate(query, user, requestID string) string {
+	var b strings.Builder
+
+	b.WriteString("/* gabi user=")
+	b.WriteString(annotationValue(user))
+	if requestID != "" {
+		b.WriteString(" request_id=")
+		b.WriteString(annotationValue(requestID))
+	}
+	b.WriteString(" */ ")
+	b.WriteString(query)
+
+	return b.String()
+}
+
+func annotationValue(s string) string {
+	if len(s) > maxAnnotationLength {
+		s = s[:maxAnnotationLength]
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("@._+-", r):
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
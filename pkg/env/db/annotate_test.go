@@ -0,0 +1,63 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		user        string
+		requestID   string
+		expected    string
+	}{
+		{
+			"user and request ID set",
+			"test@example.com",
+			"0123456789abcdef",
+			`/* gabi user=test@example.com request_id=0123456789abcdef */ select 1;`,
+		},
+		{
+			"request ID not set",
+			"test",
+			"",
+			`/* gabi user=test */ select 1;`,
+		},
+		{
+			"user ending the comment",
+			"test */; drop table users; /*",
+			"test",
+			`/* gabi user=test_____drop_table_users____ request_id=test */ select 1;`,
+		},
+		{
+			"request ID with control characters and quotes",
+			"test",
+			"a\n'b\"c*/",
+			`/* gabi user=test request_id=a__b_c__ */ select 1;`,
+		},
+		{
+			"user exceeding the maximum length",
+			strings.Repeat("a", 200),
+			"",
+			`/* gabi user=` + strings.Repeat("a", 128) + ` */ select 1;`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := Annotate("select 1;", tc.user, tc.requestID)
+
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, 0, DriverType("pgx").Placeholders(actual))
+			assert.Equal(t, 0, DriverType("mysql").Placeholders(actual))
+		})
+	}
+}
@@ -2,13 +2,25 @@ package db
 
 import (
 	"fmt"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/app-sre/gabi/pkg/env"
 )
 
+const (
+	ConcurrencyPolicyQueue  = "queue"
+	ConcurrencyPolicyReject = "reject"
+)
+
+const defaultQueueTimeout = 30 * time.Second
+
 type Env struct {
 	Driver     DriverType
 	Host       string
@@ -17,10 +29,30 @@ type Env struct {
 	Password   string
 	Name       string
 	AllowWrite bool
+
+	// Databases on the same server that clients can select instead of the
+	// configured one, which remains the default.
+	Databases []string
+	Annotate  bool
+
+	MaxConcurrentQueries int
+	ConcurrencyPolicy    string
+	QueueTimeout         time.Duration
+
+	PingInterval   time.Duration
+	ConnectTimeout time.Duration
+
+	QueryTimeout    time.Duration
+	MaxQueryTimeout time.Duration
+
+	InitSQL []string
 }
 
 func NewDBEnv() *Env {
-	return &Env{}
+	return &Env{
+		ConcurrencyPolicy: ConcurrencyPolicyQueue,
+		QueueTimeout:      defaultQueueTimeout,
+	}
 }
 
 func (d *Env) Populate() error {
@@ -34,10 +66,14 @@ func (d *Env) Populate() error {
 		return fmt.Errorf("unable to use driver type: %s", driver)
 	}
 
-	host := os.Getenv("DB_HOST")
+	host := strings.TrimSpace(os.Getenv("DB_HOST"))
 	if host == "" {
 		return &env.Error{Name: "DB_HOST"}
 	}
+	// IPv6 literals are bracketed when assembling the connection string only.
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
 	d.Host = host
 
 	d.Port = d.Driver.Port()
@@ -50,19 +86,26 @@ func (d *Env) Populate() error {
 		d.Port = int(port)
 	}
 
-	username := os.Getenv("DB_USER")
+	username := strings.TrimSpace(os.Getenv("DB_USER"))
 	if username == "" {
 		return &env.Error{Name: "DB_USER"}
 	}
 	d.Username = username
 
-	password := os.Getenv("DB_PASS")
+	// The shorter name is still accepted for compatibility.
+	password, err := env.Secret("DB_PASSWORD")
+	if err != nil {
+		return err
+	}
 	if password == "" {
-		return &env.Error{Name: "DB_PASS"}
+		password = os.Getenv("DB_PASS")
+	}
+	if password == "" {
+		return &env.Error{Name: "DB_PASSWORD"}
 	}
 	d.Password = password
 
-	name := os.Getenv("DB_NAME")
+	name := strings.TrimSpace(os.Getenv("DB_NAME"))
 	if name == "" {
 		return &env.Error{Name: "DB_NAME"}
 	}
@@ -78,14 +121,145 @@ func (d *Env) Populate() error {
 		d.AllowWrite = write
 	}
 
-	// Only do this for PostgreSQL driver as the MySQL driver will handle encoding.
-	if d.Driver == driverPostgreSQL {
-		d.Password = url.PathEscape(d.Password)
+	if s := os.Getenv("DB_ANNOTATE_QUERIES"); s != "" {
+		annotate, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "DB_ANNOTATE_QUERIES"}
+		}
+		d.Annotate = annotate
+	}
+
+	if s := os.Getenv("DB_MAX_CONCURRENT_QUERIES"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "DB_MAX_CONCURRENT_QUERIES"}
+		}
+		d.MaxConcurrentQueries = int(n)
+	}
+
+	if s := os.Getenv("DB_CONCURRENCY_POLICY"); s != "" {
+		switch s {
+		case ConcurrencyPolicyQueue, ConcurrencyPolicyReject:
+			d.ConcurrencyPolicy = s
+		default:
+			return &env.TypeError{Name: "DB_CONCURRENCY_POLICY"}
+		}
+	}
+
+	if s := os.Getenv("DB_QUEUE_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil || timeout <= 0 {
+			return &env.TypeError{Name: "DB_QUEUE_TIMEOUT"}
+		}
+		d.QueueTimeout = timeout
+	}
+
+	if s := os.Getenv("DB_PING_INTERVAL"); s != "" {
+		interval, err := time.ParseDuration(s)
+		if err != nil || interval < 0 {
+			return &env.TypeError{Name: "DB_PING_INTERVAL"}
+		}
+		d.PingInterval = interval
+	}
+
+	if s := os.Getenv("DB_CONNECT_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil || timeout < 0 {
+			return &env.TypeError{Name: "DB_CONNECT_TIMEOUT"}
+		}
+		d.ConnectTimeout = timeout
+	}
+
+	if s := os.Getenv("DB_QUERY_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil || timeout < 0 {
+			return &env.TypeError{Name: "DB_QUERY_TIMEOUT"}
+		}
+		d.QueryTimeout = timeout
+	}
+
+	if s := os.Getenv("DB_MAX_QUERY_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil || timeout < 0 || (timeout > 0 && timeout < d.QueryTimeout) {
+			return &env.TypeError{Name: "DB_MAX_QUERY_TIMEOUT"}
+		}
+		d.MaxQueryTimeout = timeout
+	}
+
+	// Statements are separated by semicolons, other than those within strings,
+	// using the syntax of the driver.
+	if s := os.Getenv("DB_INIT_SQL"); s != "" {
+		d.InitSQL = d.Driver.SplitStatements(s)
+	}
+
+	if s := os.Getenv("DB_DATABASES"); s != "" {
+		var databases []string
+		for _, database := range strings.Split(s, ",") {
+			database = strings.TrimSpace(database)
+			if database == "" || database == d.Name || containsName(databases, database) {
+				continue
+			}
+			databases = append(databases, database)
+		}
+		d.Databases = databases
 	}
 
 	return nil
 }
 
+// IsAllowedDatabase reports whether clients can select the database, being
+// either the configured one, or one of those allowed besides it.
+func (d *Env) IsAllowedDatabase(name string) bool {
+	return name == d.Name || containsName(d.Databases, name)
+}
+
+// EffectiveTimeout returns the timeout applied to a query, given the timeout
+// requested by the client, if any, which is capped at the maximum timeout, or
+// the default timeout when no maximum is set. The returned flag reports
+// whether the requested timeout was capped.
+func (d *Env) EffectiveTimeout(requested time.Duration) (time.Duration, bool) {
+	if requested <= 0 {
+		return d.QueryTimeout, false
+	}
+
+	limit := d.MaxQueryTimeout
+	if limit == 0 {
+		limit = d.QueryTimeout
+	}
+	if limit > 0 && requested > limit {
+		return limit, true
+	}
+
+	return requested, false
+}
+
+// ConnectionDSN assembles the connection string from its components, each
+// encoded as expected by the driver, thus these can contain any character.
 func (d *Env) ConnectionDSN() string {
-	return fmt.Sprintf(d.Driver.Format(), d.Username, d.Password, d.Host, d.Port, d.Name)
+	return d.databaseDSN(d.Name)
+}
+
+func (d *Env) databaseDSN(name string) string {
+	address := net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+
+	switch d.Driver.driver() {
+	case driverMySQL:
+		c := mysql.NewConfig()
+		c.User = d.Username
+		c.Passwd = d.Password
+		c.Net = "tcp"
+		c.Addr = address
+		c.DBName = name
+		return c.FormatDSN()
+	case driverPostgreSQL:
+		u := &url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(d.Username, d.Password),
+			Host:   address,
+			Path:   "/" + name,
+		}
+		return u.String()
+	default:
+		return ""
+	}
 }
@@ -1,14 +1,16 @@
 package db
 
+import (
+	"fmt"
+	"time"
+)
+
 const (
 	driverMySQL      = "mysql"
 	driverPostgreSQL = "pgx"
 
 	driverMySQLPort      = 3306
 	driverPostgreSQLPort = 5432
-
-	driverMySQLFormat      = `%s:%s@tcp(%s:%d)/%s`
-	driverPostgreSQLFormat = `postgres://%s:%s@%s:%d/%s`
 )
 
 type DriverType string
@@ -28,15 +30,9 @@ func (t DriverType) Port() int {
 	}
 }
 
-func (t DriverType) Format() string {
-	switch t.driver() {
-	case driverMySQL:
-		return driverMySQLFormat
-	case driverPostgreSQL:
-		return driverPostgreSQLFormat
-	default:
-		return ""
-	}
+// HasArrays reports whether the database supports array types.
+func (t DriverType) HasArrays() bool {
+	return t.driver() == driverPostgreSQL
 }
 
 func (t DriverType) IsValid() bool {
@@ -63,3 +59,12 @@ func (t DriverType) driver() DriverType {
 
 	return t
 }
+
+// StatementTimeout returns the statement setting the timeout of queries run
+// in the current transaction, where supported by the database.
+func (t DriverType) StatementTimeout(timeout time.Duration) string {
+	if t.driver() != driverPostgreSQL || timeout <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("set local statement_timeout = %d;", timeout.Milliseconds())
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +16,6 @@ func TestDriveType(t *testing.T) {
 		given       string
 		want        string
 		port        int
-		format      string
 		valid       bool
 	}{
 		{
@@ -23,7 +23,6 @@ func TestDriveType(t *testing.T) {
 			"mysql",
 			"mysql",
 			3306,
-			`%s:%s@tcp(%s:%d)/%s`,
 			true,
 		},
 		{
@@ -31,7 +30,6 @@ func TestDriveType(t *testing.T) {
 			"pgx",
 			"pgx",
 			5432,
-			`postgres://%s:%s@%s:%d/%s`,
 			true,
 		},
 		{
@@ -39,7 +37,6 @@ func TestDriveType(t *testing.T) {
 			"postgres",
 			"pgx",
 			5432,
-			`postgres://%s:%s@%s:%d/%s`,
 			true,
 		},
 		{
@@ -47,7 +44,6 @@ func TestDriveType(t *testing.T) {
 			"postgresql",
 			"pgx",
 			5432,
-			`postgres://%s:%s@%s:%d/%s`,
 			true,
 		},
 		{
@@ -55,7 +51,6 @@ func TestDriveType(t *testing.T) {
 			"",
 			"",
 			0,
-			``,
 			false,
 		},
 		{
@@ -63,7 +58,6 @@ func TestDriveType(t *testing.T) {
 			"test",
 			"",
 			0,
-			``,
 			false,
 		},
 	}
@@ -78,8 +72,32 @@ func TestDriveType(t *testing.T) {
 			require.Equal(t, tc.want, actual.String())
 
 			assert.Equal(t, tc.port, actual.Port())
-			assert.Equal(t, tc.format, actual.Format())
 			assert.Equal(t, tc.valid, actual.IsValid())
+			assert.Equal(t, tc.want == "pgx", actual.HasArrays())
+		})
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		timeout     time.Duration
+		expected    string
+	}{
+		{"PostgreSQL driver", "pgx", 90 * time.Second, "set local statement_timeout = 90000;"},
+		{"PostgreSQL driver without timeout", "pgx", 0, ""},
+		{"MySQL driver", "mysql", 90 * time.Second, ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, DriverType(tc.given).StatementTimeout(tc.timeout))
 		})
 	}
 }
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+)
+
+// ErrorClass is the kind of a database error, telling whether the query itself
+// is at fault, or the database, regardless of the driver reporting it.
+type ErrorClass string
+
+const (
+	ErrorSyntax     ErrorClass = "syntax_error"
+	ErrorUndefined  ErrorClass = "undefined_object"
+	ErrorData       ErrorClass = "invalid_data"
+	ErrorPermission ErrorClass = "permission_denied"
+	ErrorReadOnly   ErrorClass = "read_only"
+	ErrorConstraint ErrorClass = "constraint_violation"
+	ErrorConflict   ErrorClass = "conflict"
+	ErrorTimeout    ErrorClass = "timeout"
+	ErrorConnection ErrorClass = "connection"
+	ErrorResources  ErrorClass = "insufficient_resources"
+)
+
+// The classes of PostgreSQL errors are given by their SQLSTATE, either by the
+// exact code, or by the two characters making up the class of the code.
+var postgresErrorClasses = map[string]ErrorClass{
+	"42601": ErrorSyntax,
+	"42P01": ErrorUndefined,
+	"42703": ErrorUndefined,
+	"42883": ErrorUndefined,
+	"3F000": ErrorUndefined,
+	"42501": ErrorPermission,
+	"25006": ErrorReadOnly,
+	"40001": ErrorConflict,
+	"40P01": ErrorConflict,
+	"55P03": ErrorConflict,
+	"57014": ErrorTimeout,
+	"22":    ErrorData,
+	"23":    ErrorConstraint,
+	"08":    ErrorConnection,
+	"28":    ErrorConnection,
+	"53":    ErrorResources,
+}
+
+// The SQLSTATE reported by MySQL is too coarse to tell most errors apart, thus
+// these are classified by their error number instead.
+var mysqlErrorClasses = map[uint16]ErrorClass{
+	1064: ErrorSyntax,     // ER_PARSE_ERROR
+	1146: ErrorUndefined,  // ER_NO_SUCH_TABLE
+	1054: ErrorUndefined,  // ER_BAD_FIELD_ERROR
+	1049: ErrorUndefined,  // ER_BAD_DB_ERROR
+	1305: ErrorUndefined,  // ER_SP_DOES_NOT_EXIST
+	1366: ErrorData,       // ER_TRUNCATED_WRONG_VALUE_FOR_FIELD
+	1406: ErrorData,       // ER_DATA_TOO_LONG
+	1264: ErrorData,       // ER_WARN_DATA_OUT_OF_RANGE
+	1044: ErrorPermission, // ER_DBACCESS_DENIED_ERROR
+	1142: ErrorPermission, // ER_TABLEACCESS_DENIED_ERROR
+	1143: ErrorPermission, // ER_COLUMNACCESS_DENIED_ERROR
+	1227: ErrorPermission, // ER_SPECIFIC_ACCESS_DENIED_ERROR
+	1792: ErrorReadOnly,   // ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+	1290: ErrorReadOnly,   // ER_OPTION_PREVENTS_STATEMENT
+	1048: ErrorConstraint, // ER_BAD_NULL_ERROR
+	1062: ErrorConstraint, // ER_DUP_ENTRY
+	1451: ErrorConstraint, // ER_ROW_IS_REFERENCED_2
+	1452: ErrorConstraint, // ER_NO_REFERENCED_ROW_2
+	3819: ErrorConstraint, // ER_CHECK_CONSTRAINT_VIOLATED
+	1205: ErrorConflict,   // ER_LOCK_WAIT_TIMEOUT
+	1213: ErrorConflict,   // ER_LOCK_DEADLOCK
+	1317: ErrorTimeout,    // ER_QUERY_INTERRUPTED
+	3024: ErrorTimeout,    // ER_QUERY_TIMEOUT
+	1045: ErrorConnection, // ER_ACCESS_DENIED_ERROR
+	1040: ErrorResources,  // ER_CON_COUNT_ERROR
+	1041: ErrorResources,  // ER_OUT_OF_RESOURCES
+}
+
+// DriverError is a database error reported by one of the supported drivers,
+// classified along with the message of the database, without any details
+// such as the values involved, or the query as seen by the database. Code is
+// the SQLSTATE for PostgreSQL, and the error number for MySQL.
+type DriverError struct {
+	Class   ErrorClass
+	Code    string
+	Message string
+}
+
+// ClassifyError returns the classified error reported by the driver, or nil
+// when the error is not one reported by the database, or is not known.
+func ClassifyError(err error) *DriverError {
+	var (
+		pgErr    *pgconn.PgError
+		mysqlErr *mysql.MySQLError
+	)
+
+	switch {
+	case errors.As(err, &pgErr):
+		class, ok := postgresErrorClasses[pgErr.Code]
+		if !ok && len(pgErr.Code) == 5 {
+			class, ok = postgresErrorClasses[pgErr.Code[:2]]
+		}
+		if !ok {
+			return nil
+		}
+		return &DriverError{Class: class, Code: pgErr.Code, Message: strings.TrimSpace(pgErr.Message)}
+	case errors.As(err, &mysqlErr):
+		class, ok := mysqlErrorClasses[mysqlErr.Number]
+		if !ok {
+			return nil
+		}
+		return &DriverError{Class: class, Code: strconv.Itoa(int(mysqlErr.Number)), Message: strings.TrimSpace(mysqlErr.Message)}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		expected    *DriverError
+	}{
+		{
+			"PostgreSQL syntax error",
+			&pgconn.PgError{Severity: "ERROR", Code: "42601", Message: `syntax error at or near "selec"`},
+			&DriverError{Class: ErrorSyntax, Code: "42601", Message: `syntax error at or near "selec"`},
+		},
+		{
+			"PostgreSQL permission error",
+			&pgconn.PgError{Code: "42501", Message: "permission denied for table test"},
+			&DriverError{Class: ErrorPermission, Code: "42501", Message: "permission denied for table test"},
+		},
+		{
+			"PostgreSQL error classified by class of code",
+			&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint", Detail: "Key (id)=(1) already exists."},
+			&DriverError{Class: ErrorConstraint, Code: "23505", Message: "duplicate key value violates unique constraint"},
+		},
+		{
+			"wrapped PostgreSQL error",
+			fmt.Errorf("test: %w", &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}),
+			&DriverError{Class: ErrorTimeout, Code: "57014", Message: "canceling statement due to statement timeout"},
+		},
+		{
+			"unknown PostgreSQL error",
+			&pgconn.PgError{Code: "XX000", Message: "test"},
+			nil,
+		},
+		{
+			"MySQL syntax error",
+			&mysql.MySQLError{Number: 1064, SQLState: [5]byte{'4', '2', '0', '0', '0'}, Message: "You have an error in your SQL syntax"},
+			&DriverError{Class: ErrorSyntax, Code: "1064", Message: "You have an error in your SQL syntax"},
+		},
+		{
+			"MySQL read-only error",
+			&mysql.MySQLError{Number: 1792, Message: "Cannot execute statement in a READ ONLY transaction."},
+			&DriverError{Class: ErrorReadOnly, Code: "1792", Message: "Cannot execute statement in a READ ONLY transaction."},
+		},
+		{
+			"unknown MySQL error",
+			&mysql.MySQLError{Number: 1, Message: "test"},
+			nil,
+		},
+		{
+			"error not reported by database",
+			errors.New("test"),
+			nil,
+		},
+		{
+			"no error",
+			nil,
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, ClassifyError(tc.given))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package db
+
+import "strings"
+
+// Kinds of tokens a query is split into, leaving out whitespace and comments.
+type tokenKind int
+
+const (
+	// Keywords and unquoted identifiers.
+	tokenWord tokenKind = iota
+	// Identifiers enclosed in double quotes, or in backticks for MySQL.
+	tokenIdentifier
+	// Strings, including prefixed and dollar-quoted ones.
+	tokenString
+	tokenNumber
+	// Numbered placeholders, such as $1, while question marks are symbols, as
+	// these are operators of PostgreSQL as well.
+	tokenPlaceholder
+	// Any other character, one at a time.
+	tokenSymbol
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	start int
+}
+
+// lex splits the query into tokens using the syntax of the driver, where
+// double quotes enclose strings for MySQL, which escapes quotes using
+// backslashes as well, and identifiers for PostgreSQL, which in turn supports
+// dollar-quoted strings. Unterminated strings, identifiers and comments run
+// until the end of the query. MySQL also takes # to start a comment, while --
+// only does when followed by whitespace, and runs the body of comments
+// starting with /*! or /*+, which are thus split into tokens as well.
+func lex(query string, mysql bool) []token {
+	var (
+		tokens     []token
+		executable bool
+	)
+
+	for i := 0; i < len(query); {
+		kind, end := tokenSymbol, i+1
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case c == '-' && i+1 < len(query) && query[i+1] == '-' && (!mysql || i+2 == len(query) || query[i+2] <= ' '),
+			c == '#' && mysql:
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			i += j
+			continue
+		case c == '*' && executable && i+1 < len(query) && query[i+1] == '/':
+			executable = false
+			i += 2
+			continue
+		case c == '/' && mysql && !executable && strings.HasPrefix(query[i:], "/*!"),
+			c == '/' && mysql && !executable && strings.HasPrefix(query[i:], "/*+"):
+			// The version the body is run from, if any, is no part of it.
+			j := i + 3
+			for query[i+2] == '!' && j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			executable = true
+			i = j
+			continue
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			j := strings.Index(query[i+2:], "*/")
+			if j < 0 {
+				i = len(query)
+				continue
+			}
+			i += 2 + j + 2
+			continue
+		case isIdentifierStart(c):
+			j := i
+			for j < len(query) && isIdentifierPart(query[j]) {
+				j++
+			}
+			kind, end = tokenWord, j
+			// Prefixed strings, such as E'...' or N'...', are strings as a whole.
+			if j < len(query) && query[j] == '\'' && isStringPrefix(query[i:j], mysql) {
+				kind, end = tokenString, skipString(query, j, mysql || strings.EqualFold(query[i:j], "e"))+1
+			}
+		case isDigit(c) || c == '.' && i+1 < len(query) && isDigit(query[i+1]):
+			kind, end = tokenNumber, skipNumber(query, i)+1
+		case c == '\'' || c == '"' && mysql:
+			kind, end = tokenString, skipString(query, i, mysql)+1
+		case c == '"' || c == '`':
+			kind, end = tokenIdentifier, skipQuoted(query, i, c)+1
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			kind, end = tokenPlaceholder, j
+		case c == '$' && !mysql:
+			if j, ok := skipDollarQuoted(query, i); ok {
+				kind, end = tokenString, j+1
+			}
+		}
+		if end > len(query) {
+			end = len(query)
+		}
+
+		tokens = append(tokens, token{kind: kind, text: query[i:end], start: i})
+		i = end
+	}
+
+	return tokens
+}
+
+// Returns the keyword in upper case, or an empty string for other tokens.
+func (t token) word() string {
+	if t.kind != tokenWord {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// Returns the identifier in lower case, unquoted, for words and quoted
+// identifiers alike.
+func (t token) name() string {
+	if t.kind != tokenIdentifier {
+		return strings.ToLower(t.text)
+	}
+	quote := t.text[:1]
+	s := strings.TrimSuffix(t.text[1:], quote)
+	return strings.ToLower(strings.ReplaceAll(s, quote+quote, quote))
+}
+
+func (t token) is(symbol byte) bool {
+	return t.kind == tokenSymbol && t.text[0] == symbol
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLex(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		mysql       bool
+		given       string
+		expected    []string
+	}{
+		{
+			"words, symbols and numbers",
+			false,
+			`SELECT a.b, 1.5e3 FROM t;`,
+			[]string{"SELECT", "a", ".", "b", ",", "1.5e3", "FROM", "t", ";"},
+		},
+		{
+			"comments left out",
+			false,
+			"SELECT 1 -- one\n/* two */ FROM t /* three",
+			[]string{"SELECT", "1", "FROM", "t"},
+		},
+		{
+			"strings and quoted identifiers",
+			false,
+			`SELECT 'it''s', E'\'', "a""b" FROM t`,
+			[]string{"SELECT", `'it''s'`, ",", `E'\''`, ",", `"a""b"`, "FROM", "t"},
+		},
+		{
+			"dollar-quoted strings and placeholders",
+			false,
+			`SELECT $$ ? $$, $tag$ $1 $tag$, $2`,
+			[]string{"SELECT", "$$ ? $$", ",", "$tag$ $1 $tag$", ",", "$2"},
+		},
+		{
+			"strings with backslashes and backticks of mysql",
+			true,
+			"SELECT 'a\\'b', \"c\", `d` FROM t",
+			[]string{"SELECT", `'a\'b'`, ",", `"c"`, ",", "`d`", "FROM", "t"},
+		},
+		{
+			"hash and double dash comments of mysql",
+			true,
+			"SELECT 1 # 'a\n, 2 -- 'b\n--3",
+			[]string{"SELECT", "1", ",", "2", "-", "-", "3"},
+		},
+		{
+			"executable comments of mysql",
+			true,
+			"SELECT 1 /*!50001 , 2 */ /*+ a */ /* 3 */",
+			[]string{"SELECT", "1", ",", "2", "a"},
+		},
+		{
+			"executable comments of postgresql left out",
+			false,
+			"SELECT 1 /*! , 2 */ # 3",
+			[]string{"SELECT", "1", "#", "3"},
+		},
+		{
+			"unterminated string",
+			false,
+			`SELECT 'a`,
+			[]string{"SELECT", `'a`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var actual []string
+			for _, tok := range lex(tc.given, tc.mysql) {
+				actual = append(actual, tok.text)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
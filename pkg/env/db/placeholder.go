@@ -0,0 +1,163 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Placeholders returns the number of bind parameters used in the query, using
+// the placeholder syntax of the driver: "?" for MySQL, and "$1", "$2", etc.,
+// for PostgreSQL. Placeholders within quoted strings, identifiers, and
+// comments are not counted.
+func (t DriverType) Placeholders(query string) int {
+	var count int
+
+	for _, p := range scanPlaceholders(query, t.driver() == driverMySQL) {
+		switch t.driver() {
+		case driverMySQL:
+			if p.index == 0 {
+				count++
+			}
+		case driverPostgreSQL:
+			if p.index > count {
+				count = p.index
+			}
+		}
+	}
+
+	return count
+}
+
+// Returns the position of the closing quote, where doubling the quote escapes it.
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query)
+}
+
+// PlaceholderError reports a query whose placeholders do not match the
+// arguments bound to these.
+type PlaceholderError struct {
+	Placeholders int
+	Args         int
+}
+
+func (e *PlaceholderError) Error() string {
+	return fmt.Sprintf("query placeholders count does not match arguments count (%d != %d)", e.Placeholders, e.Args)
+}
+
+var errMixedPlaceholders = errors.New("query mixes placeholder styles")
+
+// A placeholder found in a query, spanning from start to end, where index is
+// the argument of a numbered placeholder, counting from one, or zero for a
+// question mark.
+type placeholder struct {
+	start int
+	end   int
+	index int
+}
+
+// TranslatePlaceholders returns the query with its placeholders rewritten to
+// the syntax of the driver, such that clients can use either syntax against
+// any database, along with the arguments to bind, which are reordered, or
+// repeated, when numbered placeholders are rewritten to question marks. The
+// query is only rewritten when it uses the syntax of the other driver, thus
+// question marks in PostgreSQL queries using numbered placeholders, such as
+// the JSON operator, are kept. An error is returned when the query mixes both
+// syntaxes, or its placeholders do not match the arguments.
+func (t DriverType) TranslatePlaceholders(query string, args []interface{}) (string, []interface{}, error) {
+	var numbered, questions []placeholder
+	for _, p := range scanPlaceholders(query, t.driver() == driverMySQL) {
+		if p.index > 0 {
+			numbered = append(numbered, p)
+		} else {
+			questions = append(questions, p)
+		}
+	}
+
+	switch t.driver() {
+	case driverMySQL:
+		if len(numbered) == 0 {
+			return query, args, checkPlaceholders(len(questions), args)
+		}
+		if len(questions) > 0 {
+			return "", nil, errMixedPlaceholders
+		}
+		// The same argument is bound to every question mark it replaces.
+		count := 0
+		for _, p := range numbered {
+			if p.index > count {
+				count = p.index
+			}
+		}
+		if err := checkPlaceholders(count, args); err != nil {
+			return "", nil, err
+		}
+		bound := make([]interface{}, 0, len(numbered))
+		for _, p := range numbered {
+			bound = append(bound, args[p.index-1])
+		}
+		return rewritePlaceholders(query, numbered, func(int) string { return "?" }), bound, nil
+	case driverPostgreSQL:
+		if len(numbered) > 0 || len(questions) == 0 {
+			return query, args, checkPlaceholders(t.Placeholders(query), args)
+		}
+		if err := checkPlaceholders(len(questions), args); err != nil {
+			return "", nil, err
+		}
+		return rewritePlaceholders(query, questions, func(i int) string { return fmt.Sprintf("$%d", i+1) }), args, nil
+	default:
+		return "", nil, checkPlaceholders(0, args)
+	}
+}
+
+func checkPlaceholders(count int, args []interface{}) error {
+	if count != len(args) {
+		return &PlaceholderError{Placeholders: count, Args: len(args)}
+	}
+	return nil
+}
+
+// Placeholders of either syntax are found outside of strings, quoted
+// identifiers, and comments, using the syntax of the driver, where numbered
+// ones must not be part of an identifier, as the dollar sign can be.
+func scanPlaceholders(query string, mysql bool) []placeholder {
+	var found []placeholder
+
+	for _, tok := range lex(query, mysql) {
+		switch {
+		case tok.is('?'):
+			found = append(found, placeholder{start: tok.start, end: tok.start + 1})
+		case tok.kind == tokenPlaceholder:
+			// Placeholders are numbered from one, thus $0 is left as is.
+			if n, err := strconv.Atoi(tok.text[1:]); err == nil && n > 0 {
+				found = append(found, placeholder{start: tok.start, end: tok.start + len(tok.text), index: n})
+			}
+		}
+	}
+
+	return found
+}
+
+func rewritePlaceholders(query string, found []placeholder, replace func(int) string) string {
+	var b strings.Builder
+
+	last := 0
+	for i, p := range found {
+		b.WriteString(query[last:p.start])
+		b.WriteString(replace(i))
+		last = p.end
+	}
+	b.WriteString(query[last:])
+
+	return b.String()
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceholders(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    int
+	}{
+		{
+			"MySQL query without placeholders",
+			"mysql",
+			`select 1;`,
+			0,
+		},
+		{
+			"MySQL query with placeholders",
+			"mysql",
+			`select * from test where id = ? and name = ?;`,
+			2,
+		},
+		{
+			"MySQL query with placeholders in strings and comments",
+			"mysql",
+			"select '?', \"?\", `?` from test where id = ? -- ?\n and name = 'it''s ?' /* ? */;",
+			1,
+		},
+		{
+			"PostgreSQL query with placeholders",
+			"pgx",
+			`select * from test where id = $1 and name = $2 or parent = $1;`,
+			2,
+		},
+		{
+			"PostgreSQL query with placeholders in strings and comments",
+			"postgres",
+			"select '$3', \"$4\" from test where id = $1 -- $5\n /* $6 */;",
+			1,
+		},
+		{
+			"PostgreSQL query with placeholders in dollar-quoted strings",
+			"pgx",
+			`select $$ $3 $$, $tag$ ' $4 $tag$ from test where id = $1;`,
+			1,
+		},
+		{
+			"MySQL query with placeholders in strings with escaped quotes",
+			"mysql",
+			`select 'it\'s ?', "\" ?" from test where id = ?;`,
+			1,
+		},
+		{
+			"PostgreSQL query with question mark operator",
+			"pgx",
+			`select * from test where data ? 'key';`,
+			0,
+		},
+		{
+			"invalid driver",
+			"test",
+			`select * from test where id = ? and name = $1;`,
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := DriverType(tc.driver).Placeholders(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestTranslatePlaceholders(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		args        []interface{}
+		expected    string
+		bound       []interface{}
+		error       bool
+		want        string
+	}{
+		{
+			"PostgreSQL query with numbered placeholders kept",
+			"pgx",
+			`select * from test where id = $1 and data ? 'key' and name = $2;`,
+			[]interface{}{1, "test"},
+			`select * from test where id = $1 and data ? 'key' and name = $2;`,
+			[]interface{}{1, "test"},
+			false,
+			``,
+		},
+		{
+			"PostgreSQL query with question marks translated",
+			"postgres",
+			"select '?', \"?\" from test where id = ? -- ?\n and name = ? /* ? */;",
+			[]interface{}{1, "test"},
+			"select '?', \"?\" from test where id = $1 -- ?\n and name = $2 /* ? */;",
+			[]interface{}{1, "test"},
+			false,
+			``,
+		},
+		{
+			"PostgreSQL query with question marks not matching arguments",
+			"pgx",
+			`select * from test where id = ? and name = ?;`,
+			[]interface{}{1},
+			``,
+			nil,
+			true,
+			`query placeholders count does not match arguments count (2 != 1)`,
+		},
+		{
+			"PostgreSQL query with numbered placeholders not matching arguments",
+			"pgx",
+			`select * from test where id = $1;`,
+			[]interface{}{1, 2},
+			``,
+			nil,
+			true,
+			`query placeholders count does not match arguments count (1 != 2)`,
+		},
+		{
+			"PostgreSQL query with question marks in dollar-quoted strings",
+			"pgx",
+			`select $$ ? $$, ?;`,
+			[]interface{}{1},
+			`select $$ ? $$, $1;`,
+			[]interface{}{1},
+			false,
+			``,
+		},
+		{
+			"MySQL query with numbered placeholders in strings with escaped quotes",
+			"mysql",
+			`select 'it\'s $2', $1;`,
+			[]interface{}{1},
+			`select 'it\'s $2', ?;`,
+			[]interface{}{1},
+			false,
+			``,
+		},
+		{
+			"MySQL query with question marks kept",
+			"mysql",
+			`select * from test where id = ? and name = ?;`,
+			[]interface{}{1, "test"},
+			`select * from test where id = ? and name = ?;`,
+			[]interface{}{1, "test"},
+			false,
+			``,
+		},
+		{
+			"MySQL query with numbered placeholders translated",
+			"mysql",
+			"select '$3', `a$1`, a$1 from test where name = $2 and (id = $1 or parent = $1) -- $4\n;",
+			[]interface{}{1, "test"},
+			"select '$3', `a$1`, a$1 from test where name = ? and (id = ? or parent = ?) -- $4\n;",
+			[]interface{}{"test", 1, 1},
+			false,
+			``,
+		},
+		{
+			"MySQL query with numbered placeholders not matching arguments",
+			"mysql",
+			`select * from test where id = $1 and name = $3;`,
+			[]interface{}{1, "test"},
+			``,
+			nil,
+			true,
+			`query placeholders count does not match arguments count (3 != 2)`,
+		},
+		{
+			"MySQL query mixing placeholder styles",
+			"mysql",
+			`select * from test where id = $1 and name = ?;`,
+			[]interface{}{1, "test"},
+			``,
+			nil,
+			true,
+			`query mixes placeholder styles`,
+		},
+		{
+			"invalid driver",
+			"test",
+			`select * from test where id = ?;`,
+			[]interface{}{1},
+			``,
+			nil,
+			true,
+			`query placeholders count does not match arguments count (0 != 1)`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, bound, err := DriverType(tc.driver).TranslatePlaceholders(tc.given, tc.args)
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Equal(t, tc.want, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.bound, bound)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import "strings"
+
+// Literals are replaced with the same marker regardless of their type.
+const redactedLiteral = "?"
+
+// RedactLiterals returns the query with its string and numeric literals
+// replaced, such that it can be audited without the values it contains, while
+// keeping keywords, identifiers, placeholders and comments intact. Literals
+// are told apart using the syntax of the driver, where double quotes enclose
+// strings for MySQL, and identifiers for PostgreSQL, which in turn supports
+// dollar-quoted strings.
+func (t DriverType) RedactLiterals(query string) string {
+	var (
+		b     strings.Builder
+		mysql = t.driver() == driverMySQL
+	)
+
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case isIdentifierStart(c):
+			j := i
+			for j < len(query) && isIdentifierPart(query[j]) {
+				j++
+			}
+			// Prefixed strings, such as E'...' or N'...', are redacted as a whole.
+			if j < len(query) && query[j] == '\'' && isStringPrefix(query[i:j], mysql) {
+				i = skipString(query, j, mysql || strings.EqualFold(query[i:j], "e"))
+				b.WriteString(redactedLiteral)
+				continue
+			}
+			b.WriteString(query[i:j])
+			i = j - 1
+		case isDigit(c) || c == '.' && i+1 < len(query) && isDigit(query[i+1]):
+			i = skipNumber(query, i)
+			b.WriteString(redactedLiteral)
+		case c == '\'' || c == '"' && mysql:
+			i = skipString(query, i, mysql)
+			b.WriteString(redactedLiteral)
+		case c == '"' || c == '`':
+			j := skipQuoted(query, i, c)
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			b.WriteString(query[i : j+1])
+			i = j
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i - 1
+			}
+			b.WriteString(query[i : i+j+1])
+			i += j
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			j := strings.Index(query[i+2:], "*/")
+			end := len(query)
+			if j >= 0 {
+				end = i + 2 + j + 2
+			}
+			b.WriteString(query[i:end])
+			i = end - 1
+		case c == '$' && !mysql:
+			if i+1 < len(query) && isDigit(query[i+1]) {
+				j := i + 1
+				for j < len(query) && isDigit(query[j]) {
+					j++
+				}
+				b.WriteString(query[i:j])
+				i = j - 1
+				continue
+			}
+			if end, ok := skipDollarQuoted(query, i); ok {
+				b.WriteString(redactedLiteral)
+				i = end
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Bytes outside of ASCII are taken to be part of identifiers.
+func isIdentifierStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c) || c == '$'
+}
+
+// Prefixes select escape strings (E), national character sets (N), hexadecimal
+// (X) and binary (B) strings, or for MySQL, the character set (_utf8mb4).
+func isStringPrefix(prefix string, mysql bool) bool {
+	switch strings.ToLower(prefix) {
+	case "e", "n", "x", "b":
+		return true
+	}
+	return mysql && strings.HasPrefix(prefix, "_")
+}
+
+// Returns the position of the closing quote of a string, where doubling the
+// quote escapes it, as does a backslash when enabled.
+func skipString(query string, start int, backslash bool) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query)
+}
+
+// Returns the position of the last character of a numeric literal, including
+// decimal, exponent and hexadecimal notation.
+func skipNumber(query string, start int) int {
+	hex := strings.HasPrefix(strings.ToLower(query[start:]), "0x")
+	i := start
+	for i+1 < len(query) {
+		c := query[i+1]
+		sign := (c == '+' || c == '-') && !hex && (query[i] == 'e' || query[i] == 'E')
+		if !isIdentifierPart(c) && c != '.' && !sign {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// Returns the position of the closing delimiter of a dollar-quoted string,
+// such as $$...$$ or $tag$...$tag$, and whether the query has one at start.
+func skipDollarQuoted(query string, start int) (int, bool) {
+	j := start + 1
+	for j < len(query) && query[j] != '$' {
+		if !isIdentifierStart(query[j]) && !(j > start+1 && isDigit(query[j])) {
+			return 0, false
+		}
+		j++
+	}
+	if j >= len(query) {
+		return 0, false
+	}
+	tag := query[start : j+1]
+	end := strings.Index(query[j+1:], tag)
+	if end < 0 {
+		return len(query), true
+	}
+	return j + 1 + end + len(tag) - 1, true
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactLiterals(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    string
+	}{
+		{
+			"query without literals",
+			"pgx",
+			`select id, name from test;`,
+			`select id, name from test;`,
+		},
+		{
+			"PostgreSQL query with string and numeric literals",
+			"pgx",
+			`select * from test where token = 'secret' and id = 42 and score > -1.5e+3;`,
+			`select * from test where token = ? and id = ? and score > -?;`,
+		},
+		{
+			"PostgreSQL query with quoted identifiers and placeholders",
+			"postgres",
+			`select "Name", t1.id from test t1 where id = $1 and name = 'it''s';`,
+			`select "Name", t1.id from test t1 where id = $1 and name = ?;`,
+		},
+		{
+			"PostgreSQL query with escape and dollar-quoted strings",
+			"pgx",
+			`select E'a\'b', $$secret$$, $tag$it's $$ secret$tag$, 'x'::text;`,
+			`select ?, ?, ?, ?::text;`,
+		},
+		{
+			"PostgreSQL query with literals in comments",
+			"pgx",
+			"select 1 -- 'test' 2\n/* 'test' */ from test;",
+			"select ? -- 'test' 2\n/* 'test' */ from test;",
+		},
+		{
+			"MySQL query with string and numeric literals",
+			"mysql",
+			"select * from `test` where token = \"secret\" and name = 'it\\'s' and id in (1, 0x1F);",
+			"select * from `test` where token = ? and name = ? and id in (?, ?);",
+		},
+		{
+			"MySQL query with prefixed strings and placeholders",
+			"mysql",
+			`select _utf8mb4'secret', N'test', X'0F' from test where id = ?;`,
+			`select ?, ?, ? from test where id = ?;`,
+		},
+		{
+			"unterminated string",
+			"pgx",
+			`select 'secret`,
+			`select ?`,
+		},
+		{
+			"unterminated quoted identifier",
+			"pgx",
+			`select "test`,
+			`select "test`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := DriverType(tc.driver).RedactLiterals(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package db
+
+import "strings"
+
+// Keywords ending the list of tables following FROM, or a query as a whole,
+// such as the first of a UNION.
+var (
+	clauseKeywords = map[string]struct{}{
+		"WHERE": {}, "GROUP": {}, "HAVING": {}, "WINDOW": {}, "ORDER": {}, "LIMIT": {}, "FETCH": {}, "OFFSET": {},
+		"ON": {}, "USING": {}, "SET": {}, "RETURNING": {}, "FOR": {},
+	}
+	setKeywords = map[string]struct{}{
+		"UNION": {}, "INTERSECT": {}, "EXCEPT": {},
+	}
+	boundKeywords = map[string]struct{}{
+		"WHERE": {}, "LIMIT": {}, "FETCH": {},
+	}
+)
+
+// Every query in parentheses, such as a subquery, is bounded on its own.
+type scanScope struct {
+	tables  []string
+	bounded bool
+	from    bool
+}
+
+// UnboundedTables returns the tables read by queries having neither a WHERE
+// clause nor a LIMIT, thus scanning these in full, in lower case along with
+// their qualifier, if any. Only a LIMIT, or FETCH, given a value bounds the
+// query, thus LIMIT ALL and LIMIT NULL do not. Subqueries are bounded on their
+// own, and keywords within strings, quoted identifiers and comments are
+// ignored, using the syntax of the driver. Tables are those following FROM
+// and JOIN, as well as those updated, and the TABLE statement.
+func (t DriverType) UnboundedTables(query string) []string {
+	var (
+		found  []string
+		tokens = lex(query, t.driver() == driverMySQL)
+		scopes = []*scanScope{{}}
+		expect bool
+		first  = true
+		prev   string
+	)
+
+	flush := func(s *scanScope) {
+		if !s.bounded {
+			for _, name := range s.tables {
+				if !containsName(found, name) {
+					found = append(found, name)
+				}
+			}
+		}
+		*s = scanScope{}
+	}
+	current := func() *scanScope {
+		return scopes[len(scopes)-1]
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch tok := tokens[i]; {
+		case tok.kind == tokenWord || tok.kind == tokenIdentifier:
+			word := tok.word()
+			if expect && word != "ONLY" && word != "LATERAL" {
+				name, end := qualifiedName(tokens, i)
+				// Functions returning rows are no tables.
+				if end+1 >= len(tokens) || !tokens[end+1].is('(') {
+					current().tables = append(current().tables, name)
+				}
+				expect, first = false, false
+				i = end
+				continue
+			}
+			if tok.kind == tokenIdentifier {
+				continue
+			}
+
+			s := current()
+			if _, ok := setKeywords[word]; ok {
+				flush(s)
+			}
+			if _, ok := clauseKeywords[word]; ok {
+				s.from = false
+			}
+			if _, ok := boundKeywords[word]; ok && !first && bounds(word, tokens[i+1:]) {
+				s.bounded = true
+			}
+			switch {
+			case word == "FROM":
+				expect, s.from = true, true
+			case word == "JOIN":
+				expect = true
+			case word == "UPDATE" && prev != "FOR" && prev != "ON" && prev != "KEY":
+				expect = true
+			case word == "TABLE" && first:
+				expect = true
+			}
+			first, prev = false, word
+		case tok.is('('):
+			scopes = append(scopes, &scanScope{})
+			expect, first = false, true
+		case tok.is(')'):
+			if len(scopes) > 1 {
+				flush(current())
+				scopes = scopes[:len(scopes)-1]
+			}
+			expect = false
+		case tok.is(','):
+			expect = current().from
+		case tok.is(';'):
+			for len(scopes) > 1 {
+				flush(current())
+				scopes = scopes[:len(scopes)-1]
+			}
+			flush(current())
+			expect, first, prev = false, true, ""
+		default:
+			expect = false
+		}
+	}
+	for _, s := range scopes {
+		flush(s)
+	}
+
+	return found
+}
+
+// A WHERE clause always bounds the query, while a LIMIT, or FETCH, only does
+// when given a value, being a number, a placeholder or an expression, as is
+// FETCH FIRST ROW ONLY, which fetches a single row.
+func bounds(word string, next []token) bool {
+	if word == "WHERE" {
+		return true
+	}
+	if word == "FETCH" && len(next) > 0 && (next[0].word() == "FIRST" || next[0].word() == "NEXT") {
+		next = next[1:]
+		if len(next) > 0 && (next[0].word() == "ROW" || next[0].word() == "ROWS") {
+			return true
+		}
+	}
+	if len(next) == 0 {
+		return false
+	}
+	return next[0].kind == tokenNumber || next[0].kind == tokenPlaceholder || next[0].is('?') || next[0].is('(')
+}
+
+// Returns the name starting at the token given, such as that of a table,
+// including its qualifier, where quoted parts are unquoted, along with the
+// position of its last token.
+func qualifiedName(tokens []token, start int) (string, int) {
+	parts := []string{tokens[start].name()}
+	i := start
+	for i+2 < len(tokens) && tokens[i+1].is('.') && (tokens[i+2].kind == tokenWord || tokens[i+2].kind == tokenIdentifier) {
+		parts = append(parts, tokens[i+2].name())
+		i += 2
+	}
+	return strings.Join(parts, "."), i
+}
+
+func containsName(list []string, name string) bool {
+	for _, s := range list {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Keywords that are neither tables nor columns, and are thus never returned
+// as names.
+var nameKeywords = map[string]struct{}{
+	"SELECT": {}, "DISTINCT": {}, "ALL": {}, "AS": {}, "FROM": {}, "JOIN": {}, "INNER": {}, "LEFT": {}, "RIGHT": {},
+	"FULL": {}, "OUTER": {}, "CROSS": {}, "NATURAL": {}, "LATERAL": {}, "ONLY": {}, "BY": {}, "FIRST": {}, "NEXT": {},
+	"ROWS": {}, "ROW": {}, "WITH": {}, "RECURSIVE": {}, "AND": {}, "OR": {}, "NOT": {}, "IS": {}, "NULL": {},
+	"TRUE": {}, "FALSE": {}, "IN": {}, "LIKE": {}, "ILIKE": {}, "BETWEEN": {}, "CASE": {}, "WHEN": {}, "THEN": {},
+	"ELSE": {}, "END": {}, "EXISTS": {}, "ASC": {}, "DESC": {}, "NULLS": {}, "VALUES": {}, "INSERT": {}, "INTO": {},
+	"UPDATE": {}, "DELETE": {}, "TABLE": {}, "EXPLAIN": {}, "INTERVAL": {},
+}
+
+// Every query in parentheses, such as a subquery, lists its tables on its
+// own, while parentheses of expressions, such as function calls, do not.
+type nameScope struct {
+	query bool
+	from  bool
+}
+
+// ExpressionNames returns the names, in lower case without their qualifier,
+// that the query refers to other than as a column of the first select list
+// of a statement, where these are returned under their own name, and other
+// than as tables or functions. These are names used in expressions,
+// conditions, ordering, under an alias, or in subqueries, and names that are
+// aliases themselves, which might return values without these being named in
+// the results. Keywords other than those of queries, such as the arguments of
+// functions, are returned as names as well. Names within strings and comments
+// are ignored, using the syntax of the driver.
+func (t DriverType) ExpressionNames(query string) []string {
+	var (
+		found  []string
+		tokens = lex(query, t.driver() == driverMySQL)
+		scopes = []*nameScope{{}}
+		list   bool
+		set    bool
+		expect bool
+		prev   string
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		s, top := scopes[len(scopes)-1], len(scopes) == 1
+		switch {
+		case tok.kind == tokenWord || tok.kind == tokenIdentifier:
+			word := tok.word()
+			_, keyword := nameKeywords[word]
+			_, clause := clauseKeywords[word]
+			_, union := setKeywords[word]
+			if keyword || clause || union {
+				switch word {
+				case "SELECT", "DELETE", "UPDATE", "TABLE", "VALUES":
+					s.query = true
+				}
+				if clause || union {
+					s.from = false
+				}
+				switch {
+				case word == "SELECT" && top:
+					list = !set
+				case word == "FROM" && s.query:
+					s.from, expect = true, true
+				case word == "JOIN" || word == "INTO" || word == "TABLE" && prev == "" ||
+					word == "UPDATE" && prev != "FOR" && prev != "KEY":
+					expect = true
+				}
+				if top && (word == "FROM" || clause || union) {
+					list = false
+				}
+				set = set || union && top
+				prev = word
+				continue
+			}
+
+			// Qualifiers are skipped to the last part of the name.
+			_, j := qualifiedName(tokens, i)
+			var next token
+			if j+1 < len(tokens) {
+				next = tokens[j+1]
+			}
+
+			plain := list && top && (prev == "SELECT" || prev == "DISTINCT" || prev == "ALL" || prev == ",") &&
+				(next.text == "" || next.is(',') || next.is(';') || next.word() == "FROM")
+			name := tokens[j].name()
+			switch {
+			case expect:
+				expect = false
+			case next.is('('), plain:
+			case !containsName(found, name):
+				found = append(found, name)
+			}
+			i, prev = j, name
+		case tok.is('('):
+			scopes = append(scopes, &nameScope{})
+			expect, prev = false, "("
+		case tok.is(')'):
+			if !top {
+				scopes = scopes[:len(scopes)-1]
+			}
+			expect, prev = false, ")"
+		case tok.is(','):
+			expect, prev = s.from, ","
+		case tok.is(';'):
+			scopes = []*nameScope{{}}
+			list, set, expect, prev = false, false, false, ""
+		default:
+			expect, prev = false, tok.text
+		}
+	}
+
+	return found
+}
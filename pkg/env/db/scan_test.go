@@ -0,0 +1,259 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnboundedTables(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    []string
+	}{
+		{
+			"query without tables",
+			"pgx",
+			`select 1;`,
+			nil,
+		},
+		{
+			"query without where clause nor limit",
+			"pgx",
+			`select * from events`,
+			[]string{"events"},
+		},
+		{
+			"query with where clause",
+			"pgx",
+			`select * from events where id = 1`,
+			nil,
+		},
+		{
+			"query with limit",
+			"mysql",
+			`SELECT * FROM events LIMIT 10`,
+			nil,
+		},
+		{
+			"query with fetch",
+			"pgx",
+			`SELECT * FROM events FETCH FIRST 10 ROWS ONLY`,
+			nil,
+		},
+		{
+			"query with limit all",
+			"pgx",
+			`SELECT * FROM events LIMIT ALL`,
+			[]string{"events"},
+		},
+		{
+			"query with limit null",
+			"pgx",
+			`SELECT * FROM events LIMIT NULL OFFSET 10`,
+			[]string{"events"},
+		},
+		{
+			"query with limit placeholder",
+			"mysql",
+			`SELECT * FROM events LIMIT ?`,
+			nil,
+		},
+		{
+			"query with fetch of a single row",
+			"pgx",
+			`SELECT * FROM events FETCH NEXT ROW ONLY`,
+			nil,
+		},
+		{
+			"query with fetch of null rows",
+			"pgx",
+			`SELECT * FROM events FETCH FIRST NULL ROWS ONLY`,
+			[]string{"events"},
+		},
+		{
+			"qualified and quoted table names",
+			"pgx",
+			`SELECT * FROM public."Events" e JOIN "audit".logs l ON l.id = e.id`,
+			[]string{"public.events", "audit.logs"},
+		},
+		{
+			"tables separated by commas",
+			"mysql",
+			"SELECT * FROM events e, `logs` l, users",
+			[]string{"events", "logs", "users"},
+		},
+		{
+			"subquery bounded on its own",
+			"pgx",
+			`SELECT * FROM users WHERE id IN (SELECT user_id FROM events)`,
+			[]string{"events"},
+		},
+		{
+			"subquery in common table expression",
+			"pgx",
+			`WITH recent AS (SELECT * FROM events LIMIT 10) SELECT * FROM recent`,
+			[]string{"recent"},
+		},
+		{
+			"each query of union bounded on its own",
+			"pgx",
+			`SELECT id FROM events WHERE id > 1 UNION SELECT id FROM logs`,
+			[]string{"logs"},
+		},
+		{
+			"update and delete without where clause",
+			"pgx",
+			`UPDATE events SET seen = true; DELETE FROM logs; DELETE FROM users WHERE id = 1`,
+			[]string{"events", "logs"},
+		},
+		{
+			"table statement",
+			"pgx",
+			`TABLE events`,
+			[]string{"events"},
+		},
+		{
+			"function returning rows",
+			"pgx",
+			`SELECT * FROM generate_series(1, 10)`,
+			nil,
+		},
+		{
+			"row locking clause",
+			"pgx",
+			`SELECT * FROM events WHERE id = 1 FOR UPDATE`,
+			nil,
+		},
+		{
+			"only modifier",
+			"pgx",
+			`SELECT * FROM ONLY events`,
+			[]string{"events"},
+		},
+		{
+			"keywords in strings and comments ignored",
+			"pgx",
+			`SELECT 'where' FROM events -- limit 1`,
+			[]string{"events"},
+		},
+		{
+			"where clause in string ignored",
+			"mysql",
+			`SELECT "where limit" FROM events /* where */`,
+			[]string{"events"},
+		},
+		{
+			"tables following hash comment of mysql",
+			"mysql",
+			"SELECT * FROM events # '\n, logs -- '",
+			[]string{"events", "logs"},
+		},
+		{
+			"tables in executable comment of mysql",
+			"mysql",
+			`SELECT * FROM events WHERE id = 1 /*! UNION SELECT * FROM logs */`,
+			[]string{"logs"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, DriverType(tc.driver).UnboundedTables(tc.given))
+		})
+	}
+}
+
+func TestExpressionNames(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    []string
+	}{
+		{
+			"columns of the results",
+			"pgx",
+			`SELECT id, u.ssn, "Name" FROM public.users u`,
+			[]string{"u"},
+		},
+		{
+			"wildcard",
+			"pgx",
+			`SELECT * FROM users`,
+			nil,
+		},
+		{
+			"columns in expressions and conditions",
+			"pgx",
+			`SELECT upper(name), id + 1 FROM users WHERE ssn LIKE '1%' ORDER BY token`,
+			[]string{"name", "id", "ssn", "token"},
+		},
+		{
+			"column under an alias",
+			"mysql",
+			"SELECT ssn AS x, `token` y FROM users",
+			[]string{"ssn", "x", "token", "y"},
+		},
+		{
+			"columns of subqueries and unions",
+			"pgx",
+			`SELECT ssn FROM (SELECT ssn FROM users) t UNION SELECT token FROM users`,
+			[]string{"ssn", "t", "token"},
+		},
+		{
+			"columns following from in functions",
+			"pgx",
+			`SELECT trim(leading '0' from ssn), extract(year from born) FROM users`,
+			[]string{"leading", "ssn", "year", "born"},
+		},
+		{
+			"tables following commas",
+			"pgx",
+			`SELECT id FROM users, events WHERE users.id = events.id`,
+			[]string{"id"},
+		},
+		{
+			"names in strings and comments ignored",
+			"pgx",
+			`SELECT id FROM users WHERE name = $$ssn$$ -- and ssn = 1`,
+			[]string{"name"},
+		},
+		{
+			"names in executable comments of mysql",
+			"mysql",
+			`SELECT name /*! , ssn AS x */ FROM users /*+ BKA(users) */`,
+			[]string{"ssn", "x", "users"},
+		},
+		{
+			"names following hash and double dash of mysql",
+			"mysql",
+			"SELECT name, 1 --ssn\nFROM users # ssn",
+			[]string{"ssn"},
+		},
+		{
+			"each statement on its own",
+			"pgx",
+			`SELECT ssn FROM users; SELECT id FROM users WHERE ssn = '1'`,
+			[]string{"ssn"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, DriverType(tc.driver).ExpressionNames(tc.given))
+		})
+	}
+}
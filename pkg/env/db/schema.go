@@ -0,0 +1,23 @@
+package db
+
+const (
+	driverMySQLSchemaQuery = `select table_schema, table_name, column_name, data_type, is_nullable ` +
+		`from information_schema.columns where table_schema = database() ` +
+		`order by table_schema, table_name, ordinal_position;`
+	driverPostgreSQLSchemaQuery = `select table_schema, table_name, column_name, data_type, is_nullable ` +
+		`from information_schema.columns where table_schema not in ('pg_catalog', 'information_schema') ` +
+		`order by table_schema, table_name, ordinal_position;`
+)
+
+// SchemaQuery returns the query listing the columns of every table visible
+// to the connection, excluding the system catalogs.
+func (t DriverType) SchemaQuery() string {
+	switch t.driver() {
+	case driverMySQL:
+		return driverMySQLSchemaQuery
+	case driverPostgreSQL:
+		return driverPostgreSQLSchemaQuery
+	default:
+		return ""
+	}
+}
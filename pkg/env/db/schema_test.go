@@ -0,0 +1,31 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaQuery(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expected    string
+	}{
+		{"MySQL driver", "mysql", driverMySQLSchemaQuery},
+		{"PostgreSQL driver", "pgx", driverPostgreSQLSchemaQuery},
+		{"PostgreSQL driver alias", "postgres", driverPostgreSQLSchemaQuery},
+		{"invalid driver", "test", ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, DriverType(tc.given).SchemaQuery())
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// Open returns the database for the connection string, where every new
+// connection runs the statements setting up the session, if any, before it is
+// handed to the pool, so that these apply to every query regardless of the
+// connection it runs on. The session is not set up again when a connection
+// is reused, thus changes to it committed by a query persist.
+func (d *Env) Open() (*sql.DB, error) {
+	return d.OpenDatabase(d.Name)
+}
+
+// OpenDatabase returns another database on the same server, as Open does.
+func (d *Env) OpenDatabase(name string) (*sql.DB, error) {
+	return open(d.Driver.String(), d.databaseDSN(name), d.InitSQL)
+}
+
+func open(name, dsn string, statements []string) (*sql.DB, error) {
+	if len(statements) == 0 {
+		return sql.Open(name, dsn)
+	}
+
+	// The driver registered under the name is only reachable through a
+	// database opened using it, which makes no connection by itself.
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	_ = db.Close()
+
+	var connector driver.Connector = &dsnConnector{dsn: dsn, driver: d}
+	if dc, ok := d.(driver.DriverContext); ok {
+		if connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	return sql.OpenDB(&sessionConnector{Connector: connector, statements: statements}), nil
+}
+
+// Drivers without a connector of their own open connections from the
+// connection string every time, as the database would.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c *dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// A connection failing to set up the session is closed rather than pooled, as
+// queries run using it would not be subject to the session settings.
+type sessionConnector struct {
+	driver.Connector
+	statements []string
+}
+
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.New("unable to set up session: driver cannot run statements")
+	}
+	for _, statement := range c.statements {
+		if _, err := execer.ExecContext(ctx, statement, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unable to set up session using statement %q: %w", statement, err)
+		}
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenSession(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		statements  []string
+		mock        func(sqlmock.Sqlmock)
+		error       bool
+		want        string
+	}{
+		{
+			"session set up on new connection",
+			[]string{"SET ROLE readonly", "SET search_path TO app"},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SET ROLE readonly").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("SET search_path TO app").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			false,
+			``,
+		},
+		{
+			"session failing to set up",
+			[]string{"SET ROLE readonly", "SET search_path TO app"},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("SET ROLE readonly").WillReturnError(errors.New("role does not exist"))
+			},
+			true,
+			`unable to set up session using statement "SET ROLE readonly": role does not exist`,
+		},
+		{
+			"no session set up without statements",
+			nil,
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			false,
+			``,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			mockDB, mock, err := sqlmock.NewWithDSN(tc.description)
+			require.NoError(t, err)
+			defer mockDB.Close()
+			tc.mock(mock)
+
+			db, err := open("sqlmock", tc.description, tc.statements)
+			require.NoError(t, err)
+			defer db.Close()
+
+			conn, err := db.Conn(context.Background())
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+				_ = conn.Close()
+			}
+
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
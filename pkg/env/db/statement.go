@@ -0,0 +1,142 @@
+package db
+
+// Statements following a common table expression, which type the statement
+// starting with it.
+var cteStatements = map[string]struct{}{
+	"SELECT": {}, "INSERT": {}, "UPDATE": {}, "DELETE": {}, "MERGE": {}, "VALUES": {}, "TABLE": {},
+}
+
+// Statements modifying data, which type a common table expression whose body
+// holds any, whatever the statement following it.
+var modifyingStatements = map[string]struct{}{
+	"INSERT": {}, "UPDATE": {}, "DELETE": {}, "MERGE": {},
+}
+
+// Options of the EXPLAIN statement preceding the statement it wraps, when not
+// given in parentheses.
+var explainOptions = map[string]struct{}{
+	"ANALYZE": {}, "ANALYSE": {}, "VERBOSE": {}, "FORMAT": {}, "TREE": {}, "JSON": {}, "TRADITIONAL": {},
+}
+
+// Statements returns the type of each statement of the query, being the
+// keyword it starts with, in upper case, such as SELECT or INSERT. Statements
+// starting with a common table expression are typed by the statement that
+// follows it, thus "WITH ... DELETE" is a DELETE, unless the body of the
+// expression modifies data, thus "WITH d AS (DELETE ...) SELECT ..." is a
+// DELETE as well. As EXPLAIN ANALYZE runs the statement it wraps, it is typed
+// by that statement. Keywords within strings, quoted identifiers and comments
+// are ignored, using the syntax of the driver.
+func (t DriverType) Statements(query string) []string {
+	var (
+		types    []string
+		kind     string
+		cte      bool
+		modifies string
+		analyze  bool
+		options  bool
+		prev     string
+		depth    int
+	)
+
+	end := func() {
+		if cte && modifies != "" {
+			kind = modifies
+		}
+		if kind == "" && cte {
+			kind = "WITH"
+		}
+		if kind == "" && analyze {
+			kind = "EXPLAIN"
+		}
+		if kind != "" {
+			types = append(types, kind)
+		}
+		kind, cte, modifies, analyze, options, prev, depth = "", false, "", false, false, "", 0
+	}
+
+	for _, tok := range lex(query, t.driver() == driverMySQL) {
+		switch {
+		case tok.kind == tokenWord:
+			word := tok.word()
+			_, modifying := modifyingStatements[word]
+			// Rows are locked, rather than updated, using FOR [NO KEY] UPDATE.
+			modifying = modifying && prev != "FOR" && prev != "KEY"
+			switch {
+			case kind == "EXPLAIN" && (word == "ANALYZE" || word == "ANALYSE") && (prev == "EXPLAIN" || options):
+				kind, analyze = "", true
+			case kind != "":
+			case cte:
+				if _, ok := cteStatements[word]; ok && depth == 0 {
+					kind = word
+				} else if modifying && modifies == "" {
+					modifies = word
+				}
+			case word == "WITH" && depth == 0:
+				cte = true
+			case analyze:
+				if _, ok := explainOptions[word]; !ok && depth == 0 {
+					kind = word
+				}
+			default:
+				kind = word
+			}
+			prev = word
+		case tok.is('('):
+			// Options of EXPLAIN can be given in parentheses following it.
+			options = kind == "EXPLAIN" && prev == "EXPLAIN" && depth == 0
+			depth++
+		case tok.is(')'):
+			depth--
+			if depth == 0 {
+				options = false
+			}
+		case tok.is(';'):
+			end()
+		}
+	}
+	end()
+
+	return types
+}
+
+// HasStatement reports whether the query holds anything to execute, other
+// than whitespace, comments and empty statements.
+func HasStatement(query string) bool {
+	for _, tok := range lex(query, false) {
+		if !tok.is(';') {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitStatements returns the statements of the query, without the semicolons
+// separating these, or any surrounding whitespace and comments, leaving out
+// empty statements. Semicolons within strings, quoted identifiers and
+// comments do not separate statements, using the syntax of the driver.
+func (t DriverType) SplitStatements(query string) []string {
+	var (
+		statements []string
+		start      = -1
+		end        int
+	)
+
+	for _, tok := range lex(query, t.driver() == driverMySQL) {
+		if tok.is(';') {
+			if start >= 0 {
+				statements = append(statements, query[start:end])
+			}
+			start = -1
+			continue
+		}
+		if start < 0 {
+			start = tok.start
+		}
+		end = tok.start + len(tok.text)
+	}
+	if start >= 0 {
+		statements = append(statements, query[start:end])
+	}
+
+	return statements
+}
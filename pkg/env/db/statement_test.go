@@ -0,0 +1,186 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatements(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    []string
+	}{
+		{
+			"empty query",
+			"pgx",
+			` ; `,
+			nil,
+		},
+		{
+			"single statement",
+			"pgx",
+			`select 1;`,
+			[]string{"SELECT"},
+		},
+		{
+			"several statements",
+			"pgx",
+			`insert into audit.events values (1); Update test set id = 2; delete from test`,
+			[]string{"INSERT", "UPDATE", "DELETE"},
+		},
+		{
+			"statement after comments",
+			"pgx",
+			"-- select\n/* select */ delete from test;",
+			[]string{"DELETE"},
+		},
+		{
+			"statement with keywords in strings and identifiers",
+			"pgx",
+			`select 'x; delete from test', "a;b", $$; drop table test;$$ from test;`,
+			[]string{"SELECT"},
+		},
+		{
+			"MySQL statement with keywords in strings and identifiers",
+			"mysql",
+			"select \"x; delete\", 'it\\'s; drop', `a;b` from test;",
+			[]string{"SELECT"},
+		},
+		{
+			"statement in parentheses",
+			"pgx",
+			`(select 1) union (select 2);`,
+			[]string{"SELECT"},
+		},
+		{
+			"common table expression followed by select",
+			"pgx",
+			`with recursive t(n) as (select 1 union all select n + 1 from t) select * from t;`,
+			[]string{"SELECT"},
+		},
+		{
+			"common table expressions followed by delete",
+			"pgx",
+			`with a as (select id from test), b as (select 1) delete from test where id in (select id from a);`,
+			[]string{"DELETE"},
+		},
+		{
+			"common table expression modifying data followed by select",
+			"pgx",
+			`WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d`,
+			[]string{"DELETE"},
+		},
+		{
+			"common table expression locking rows followed by select",
+			"pgx",
+			`with a as (select * from t for no key update) select * from a; with b as (select * from t for update) select 1`,
+			[]string{"SELECT", "SELECT"},
+		},
+		{
+			"explain without analyze",
+			"pgx",
+			`explain delete from t; explain (verbose, format json) update t set a = 1`,
+			[]string{"EXPLAIN", "EXPLAIN"},
+		},
+		{
+			"explain analyze typed by statement run",
+			"pgx",
+			`EXPLAIN ANALYZE DELETE FROM t; explain analyse verbose select 1`,
+			[]string{"DELETE", "SELECT"},
+		},
+		{
+			"explain analyze in parentheses typed by statement run",
+			"pgx",
+			`EXPLAIN (FORMAT JSON, ANALYZE, BUFFERS) DELETE FROM t; explain (analyze) with d as (delete from t returning *) select * from d`,
+			[]string{"DELETE", "DELETE"},
+		},
+		{
+			"explain of column named analyze",
+			"pgx",
+			`explain select analyze from t`,
+			[]string{"EXPLAIN"},
+		},
+		{
+			"MySQL explain analyze typed by statement run",
+			"mysql",
+			"EXPLAIN ANALYZE FORMAT=TREE SELECT * FROM t",
+			[]string{"SELECT"},
+		},
+		{
+			"common table expression without statement",
+			"pgx",
+			`with a as (select 1)`,
+			[]string{"WITH"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := DriverType(tc.driver).Statements(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestHasStatement(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expected    bool
+	}{
+		{"empty query", ``, false},
+		{"whitespace and empty statements", " \t\n;\r\n;", false},
+		{"line comments", "-- select 1;\n--", false},
+		{"block comments", `/* select 1; */ /* select 2;`, false},
+		{"statement after comments", "-- test\n/* test */ select 1;", true},
+		{"statement without keyword", `(1)`, true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := HasStatement(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       string
+		expected    []string
+	}{
+		{"no statements", "pgx", ` ;; -- ;`, nil},
+		{"statements separated", "pgx", " SET ROLE readonly; SET search_path TO app, public;; ", []string{"SET ROLE readonly", "SET search_path TO app, public"}},
+		{"semicolons in strings and identifiers", "pgx", `SET application_name = 'a;b'; SET search_path TO "x;y"`, []string{`SET application_name = 'a;b'`, `SET search_path TO "x;y"`}},
+		{"semicolons in dollar-quoted strings", "pgx", `DO $$ BEGIN PERFORM 1; END $$; SELECT 1`, []string{`DO $$ BEGIN PERFORM 1; END $$`, `SELECT 1`}},
+		{"semicolons in strings with backslashes", "mysql", `SET @a = 'it\'s;'; SET @b = 1`, []string{`SET @a = 'it\'s;'`, `SET @b = 1`}},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, DriverType(tc.driver).SplitStatements(tc.given))
+		})
+	}
+}
@@ -1,6 +1,14 @@
 package env
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const fileSuffix = "_FILE"
 
 type Error struct {
 	Name string
@@ -17,3 +25,36 @@ type TypeError struct {
 func (e *TypeError) Error() string {
 	return fmt.Sprintf("unable to convert environment variable: %s", e.Name)
 }
+
+// KeyValues parses a comma-separated list of key=value pairs, skipping empty
+// entries. Keys cannot be empty, while values can.
+func KeyValues(s string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, errors.New("invalid key=value pair")
+		}
+		values[key] = strings.TrimSpace(value)
+	}
+	return values, nil
+}
+
+// Secret returns the value of the environment variable, unless the variable of
+// the same name suffixed with _FILE is set, in which case the contents of the
+// file it points to are returned instead, without trailing newlines. Secrets
+// mounted as files do not show up in process listings.
+func Secret(name string) (string, error) {
+	if path := strings.TrimSpace(os.Getenv(name + fileSuffix)); path != "" {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file for %s: %w", name, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	}
+	return os.Getenv(name), nil
+}
@@ -0,0 +1,78 @@
+package limits
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const defaultMaxRequestBytes = 1 << 20
+
+type Env struct {
+	RatePerMinute   int
+	RateBurst       int
+	MaxRequestBytes int64
+	RequestTimeout  time.Duration
+	MaxRows         int
+	MaxQueryBytes   int
+}
+
+func NewLimitsEnv() *Env {
+	return &Env{}
+}
+
+func (l *Env) Populate() error {
+	if s := os.Getenv("RATE_LIMIT_PER_MINUTE"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "RATE_LIMIT_PER_MINUTE"}
+		}
+		l.RatePerMinute = int(n)
+	}
+
+	l.RateBurst = l.RatePerMinute
+	if s := os.Getenv("RATE_LIMIT_BURST"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil || n == 0 {
+			return &env.TypeError{Name: "RATE_LIMIT_BURST"}
+		}
+		l.RateBurst = int(n)
+	}
+
+	l.MaxRequestBytes = defaultMaxRequestBytes
+	if s := os.Getenv("MAX_REQUEST_BYTES"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n <= 0 {
+			return &env.TypeError{Name: "MAX_REQUEST_BYTES"}
+		}
+		l.MaxRequestBytes = n
+	}
+
+	if s := os.Getenv("REQUEST_TIMEOUT"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return &env.TypeError{Name: "REQUEST_TIMEOUT"}
+		}
+		l.RequestTimeout = d
+	}
+
+	if s := os.Getenv("MAX_ROWS"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "MAX_ROWS"}
+		}
+		l.MaxRows = int(n)
+	}
+
+	if s := os.Getenv("MAX_QUERY_BYTES"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "MAX_QUERY_BYTES"}
+		}
+		l.MaxQueryBytes = int(n)
+	}
+
+	return nil
+}
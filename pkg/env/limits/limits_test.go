@@ -0,0 +1,170 @@
+package limits
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLimitsEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewLimitsEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes},
+			false,
+			``,
+		},
+		{
+			"rate limit set without burst",
+			func() {
+				t.Setenv("RATE_LIMIT_PER_MINUTE", "60")
+			},
+			&Env{RatePerMinute: 60, RateBurst: 60, MaxRequestBytes: defaultMaxRequestBytes},
+			false,
+			``,
+		},
+		{
+			"rate limit set with burst",
+			func() {
+				t.Setenv("RATE_LIMIT_PER_MINUTE", "60")
+				t.Setenv("RATE_LIMIT_BURST", "5")
+			},
+			&Env{RatePerMinute: 60, RateBurst: 5, MaxRequestBytes: defaultMaxRequestBytes},
+			false,
+			``,
+		},
+		{
+			"invalid RATE_LIMIT_PER_MINUTE environment variable",
+			func() {
+				t.Setenv("RATE_LIMIT_PER_MINUTE", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: RATE_LIMIT_PER_MINUTE`,
+		},
+		{
+			"invalid RATE_LIMIT_BURST environment variable",
+			func() {
+				t.Setenv("RATE_LIMIT_PER_MINUTE", "60")
+				t.Setenv("RATE_LIMIT_BURST", "0")
+			},
+			&Env{RatePerMinute: 60, RateBurst: 60},
+			true,
+			`unable to convert environment variable: RATE_LIMIT_BURST`,
+		},
+		{
+			"maximum request size set",
+			func() {
+				t.Setenv("MAX_REQUEST_BYTES", "1024")
+			},
+			&Env{MaxRequestBytes: 1024},
+			false,
+			``,
+		},
+		{
+			"invalid MAX_REQUEST_BYTES environment variable",
+			func() {
+				t.Setenv("MAX_REQUEST_BYTES", "0")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes},
+			true,
+			`unable to convert environment variable: MAX_REQUEST_BYTES`,
+		},
+		{
+			"request timeout set",
+			func() {
+				t.Setenv("REQUEST_TIMEOUT", "30s")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes, RequestTimeout: 30 * time.Second},
+			false,
+			``,
+		},
+		{
+			"invalid REQUEST_TIMEOUT environment variable",
+			func() {
+				t.Setenv("REQUEST_TIMEOUT", "-1s")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes},
+			true,
+			`unable to convert environment variable: REQUEST_TIMEOUT`,
+		},
+		{
+			"maximum rows set",
+			func() {
+				t.Setenv("MAX_ROWS", "1000")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes, MaxRows: 1000},
+			false,
+			``,
+		},
+		{
+			"invalid MAX_ROWS environment variable",
+			func() {
+				t.Setenv("MAX_ROWS", "-1")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes},
+			true,
+			`unable to convert environment variable: MAX_ROWS`,
+		},
+		{
+			"maximum query size set",
+			func() {
+				t.Setenv("MAX_QUERY_BYTES", "65536")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes, MaxQueryBytes: 65536},
+			false,
+			``,
+		},
+		{
+			"invalid MAX_QUERY_BYTES environment variable",
+			func() {
+				t.Setenv("MAX_QUERY_BYTES", "test")
+			},
+			&Env{MaxRequestBytes: defaultMaxRequestBytes},
+			true,
+			`unable to convert environment variable: MAX_QUERY_BYTES`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"os"
+
+	"go.uber.org/zap/zapcore"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type Env struct {
+	Level  zapcore.Level
+	Format string
+}
+
+func NewLoggingEnv() *Env {
+	return &Env{}
+}
+
+func (l *Env) Populate() error {
+	l.Level = zapcore.InfoLevel
+	if s := os.Getenv("LOG_LEVEL"); s != "" {
+		level, err := zapcore.ParseLevel(s)
+		if err != nil {
+			return &env.TypeError{Name: "LOG_LEVEL"}
+		}
+		l.Level = level
+	}
+
+	l.Format = FormatJSON
+	if s := os.Getenv("LOG_FORMAT"); s != "" {
+		if s != FormatJSON && s != FormatText {
+			return &env.TypeError{Name: "LOG_FORMAT"}
+		}
+		l.Format = s
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewLoggingEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewLoggingEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{Level: zapcore.InfoLevel, Format: FormatJSON},
+			false,
+			``,
+		},
+		{
+			"log level and format set",
+			func() {
+				t.Setenv("LOG_LEVEL", "debug")
+				t.Setenv("LOG_FORMAT", "text")
+			},
+			&Env{Level: zapcore.DebugLevel, Format: FormatText},
+			false,
+			``,
+		},
+		{
+			"invalid LOG_LEVEL environment variable",
+			func() {
+				t.Setenv("LOG_LEVEL", "test")
+			},
+			&Env{},
+			true,
+			`unable to convert environment variable: LOG_LEVEL`,
+		},
+		{
+			"invalid LOG_FORMAT environment variable",
+			func() {
+				t.Setenv("LOG_FORMAT", "test")
+			},
+			&Env{Level: zapcore.InfoLevel, Format: FormatJSON},
+			true,
+			`unable to convert environment variable: LOG_FORMAT`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
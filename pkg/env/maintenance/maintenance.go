@@ -0,0 +1,69 @@
+package maintenance
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const (
+	defaultMessage    = "The service is under maintenance"
+	defaultRetryAfter = 5 * time.Minute
+)
+
+type Env struct {
+	File       string
+	Message    string
+	RetryAfter time.Duration
+}
+
+func NewMaintenanceEnv() *Env {
+	return &Env{Message: defaultMessage, RetryAfter: defaultRetryAfter}
+}
+
+// Populate leaves maintenance mode unavailable unless a flag file is set.
+func (m *Env) Populate() error {
+	m.File = strings.TrimSpace(os.Getenv("MAINTENANCE_FILE"))
+
+	if s := strings.TrimSpace(os.Getenv("MAINTENANCE_MESSAGE")); s != "" {
+		m.Message = s
+	}
+
+	if s := os.Getenv("MAINTENANCE_RETRY_AFTER"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return &env.TypeError{Name: "MAINTENANCE_RETRY_AFTER"}
+		}
+		m.RetryAfter = d
+	}
+
+	return nil
+}
+
+func (m *Env) Enabled() bool {
+	return m.File != ""
+}
+
+// Active reports whether the flag file exists, which is checked anew every
+// time, so that maintenance mode can be toggled without a restart. The message
+// is taken from the file when it is not empty, while a file that exists but
+// cannot be read still enables maintenance mode.
+func (m *Env) Active() (string, bool) {
+	if !m.Enabled() {
+		return "", false
+	}
+
+	content, err := os.ReadFile(filepath.Clean(m.File))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false
+	}
+	if s := strings.TrimSpace(string(content)); err == nil && s != "" {
+		return s, true
+	}
+	return m.Message, true
+}
@@ -0,0 +1,161 @@
+package maintenance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMaintenanceEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewMaintenanceEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+	assert.Equal(t, defaultMessage, actual.Message)
+	assert.Equal(t, defaultRetryAfter, actual.RetryAfter)
+	assert.False(t, actual.Enabled())
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{Message: defaultMessage, RetryAfter: defaultRetryAfter},
+			false,
+			``,
+		},
+		{
+			"flag file, message and retry after set",
+			func() {
+				t.Setenv("MAINTENANCE_FILE", " /etc/gabi/maintenance ")
+				t.Setenv("MAINTENANCE_MESSAGE", "Database upgrade in progress")
+				t.Setenv("MAINTENANCE_RETRY_AFTER", "1h")
+			},
+			&Env{File: "/etc/gabi/maintenance", Message: "Database upgrade in progress", RetryAfter: time.Hour},
+			false,
+			``,
+		},
+		{
+			"zero MAINTENANCE_RETRY_AFTER environment variable",
+			func() {
+				t.Setenv("MAINTENANCE_RETRY_AFTER", "0s")
+			},
+			&Env{Message: defaultMessage},
+			false,
+			``,
+		},
+		{
+			"invalid MAINTENANCE_RETRY_AFTER environment variable",
+			func() {
+				t.Setenv("MAINTENANCE_RETRY_AFTER", "test")
+			},
+			&Env{Message: defaultMessage, RetryAfter: defaultRetryAfter},
+			true,
+			`unable to convert environment variable: MAINTENANCE_RETRY_AFTER`,
+		},
+		{
+			"negative MAINTENANCE_RETRY_AFTER environment variable",
+			func() {
+				t.Setenv("MAINTENANCE_RETRY_AFTER", "-1s")
+			},
+			&Env{Message: defaultMessage, RetryAfter: defaultRetryAfter},
+			true,
+			`unable to convert environment variable: MAINTENANCE_RETRY_AFTER`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := NewMaintenanceEnv()
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestActive(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	message := filepath.Join(dir, "message")
+	require.NoError(t, os.WriteFile(message, []byte("Database upgrade in progress\n"), 0o600))
+
+	cases := []struct {
+		description string
+		given       string
+		active      bool
+		message     string
+	}{
+		{
+			"no flag file set",
+			"",
+			false,
+			``,
+		},
+		{
+			"flag file missing",
+			filepath.Join(dir, "missing"),
+			false,
+			``,
+		},
+		{
+			"empty flag file",
+			empty,
+			true,
+			defaultMessage,
+		},
+		{
+			"flag file with message",
+			message,
+			true,
+			`Database upgrade in progress`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			e := NewMaintenanceEnv()
+			e.File = tc.given
+
+			actual, ok := e.Active()
+
+			assert.Equal(t, tc.active, ok)
+			assert.Equal(t, tc.message, actual)
+		})
+	}
+}
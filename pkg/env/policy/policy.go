@@ -0,0 +1,312 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const caseInsensitiveFlag = "(?i)"
+
+// MaskValue replaces every value of a masked column in the results.
+const MaskValue = "****"
+
+// Statement types that only read from the database, where EXPLAIN only plans
+// the statement, as EXPLAIN ANALYZE is typed by the statement it runs.
+var readStatements = map[string]struct{}{
+	"SELECT": {}, "SHOW": {}, "EXPLAIN": {}, "DESCRIBE": {}, "DESC": {}, "VALUES": {}, "TABLE": {},
+}
+
+type Env struct {
+	Allow []*regexp.Regexp
+	Deny  []*regexp.Regexp
+
+	// Columns whose values are masked, given as names or as shell-style
+	// patterns, such as "*_token", in lower case.
+	Mask []string
+
+	// Types of statements allowed, such as SELECT or INSERT, in upper case,
+	// with any type allowed when empty.
+	Statements []string
+
+	// Tables that queries cannot scan in full, without either a WHERE clause
+	// or a LIMIT, unless forced, given as names in lower case.
+	LargeTables []string
+}
+
+func NewPolicyEnv() *Env {
+	return &Env{}
+}
+
+func (p *Env) Populate() error {
+	if path := os.Getenv("POLICY_FILE_PATH"); path != "" {
+		content, err := os.ReadFile(filepath.Clean(path))
+		if err != nil {
+			return fmt.Errorf("unable to read policy file: %w", err)
+		}
+
+		if err := json.Unmarshal(content, &p); err != nil {
+			return fmt.Errorf("unable to unmarshal policy file: %w", err)
+		}
+	}
+
+	if s := os.Getenv("QUERY_ALLOW_REGEX"); s != "" {
+		re, err := compile(s)
+		if err != nil {
+			return err
+		}
+		p.Allow = []*regexp.Regexp{re}
+	}
+
+	if s := os.Getenv("QUERY_DENY_REGEX"); s != "" {
+		re, err := compile(s)
+		if err != nil {
+			return err
+		}
+		p.Deny = []*regexp.Regexp{re}
+	}
+
+	if s := os.Getenv("QUERY_ALLOW_STATEMENTS"); s != "" {
+		p.Statements = statementTypes(strings.Split(s, ","))
+	}
+
+	if s := os.Getenv("QUERY_LARGE_TABLES"); s != "" {
+		p.LargeTables = tableNames(strings.Split(s, ","))
+	}
+
+	if s := os.Getenv("QUERY_MASK_COLUMNS"); s != "" {
+		mask, err := maskPatterns(strings.Split(s, ","))
+		if err != nil {
+			return err
+		}
+		p.Mask = mask
+	}
+
+	return nil
+}
+
+// IsAllowed reports whether the query passes the policy. Deny patterns are
+// evaluated first and always win; when allow patterns are set, the query must
+// then match at least one of them.
+func (p *Env) IsAllowed(query string) bool {
+	for _, re := range p.Deny {
+		if re.MatchString(query) {
+			return false
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, re := range p.Allow {
+		if re.MatchString(query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAllowedStatement reports whether every statement type given is allowed,
+// returning the first one that is not otherwise.
+func (p *Env) IsAllowedStatement(types []string) (string, bool) {
+	if len(p.Statements) == 0 {
+		return "", true
+	}
+	for _, kind := range types {
+		if !contains(p.Statements, strings.ToUpper(kind)) {
+			return kind, false
+		}
+	}
+	return "", true
+}
+
+// WriteStatements returns the allowed statement types that can write to the
+// database, being all but those known to only read from it.
+func (p *Env) WriteStatements() []string {
+	var write []string
+	for _, kind := range p.Statements {
+		if _, ok := readStatements[kind]; !ok {
+			write = append(write, kind)
+		}
+	}
+	return write
+}
+
+// MaskedColumns reports, for each of the columns given, whether its values are
+// masked, or returns nil when none are. Columns are matched by name without
+// any qualifier, as results do not carry the table a column originates from,
+// thus a qualified name such as "users.ssn" masks any column named "ssn".
+func (p *Env) MaskedColumns(cols []string) []bool {
+	if len(p.Mask) == 0 {
+		return nil
+	}
+
+	var masked []bool
+	for i, col := range cols {
+		if p.isMasked(col) {
+			if masked == nil {
+				masked = make([]bool, len(cols))
+			}
+			masked[i] = true
+		}
+	}
+	return masked
+}
+
+// MaskedNames returns those of the names a query refers to in expressions,
+// rather than as columns of its results, that are masked, or nil when none
+// are. As values computed from, or filtered by, a masked column are not
+// masked themselves, queries referring to these are refused.
+func (p *Env) MaskedNames(names []string) []string {
+	var masked []string
+	for _, name := range names {
+		if p.isMasked(name) {
+			masked = append(masked, name)
+		}
+	}
+	return masked
+}
+
+func (p *Env) isMasked(col string) bool {
+	name := unqualified(strings.ToLower(col))
+	for _, pattern := range p.Mask {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LargeTablesScanned returns those of the tables scanned in full that are
+// large, or nil when none are. Tables are matched by name without any
+// qualifier, as queries can omit it, thus "events" matches "audit.events".
+func (p *Env) LargeTablesScanned(tables []string) []string {
+	var large []string
+	for _, name := range tables {
+		if contains(p.LargeTables, unqualified(strings.ToLower(name))) {
+			large = append(large, name)
+		}
+	}
+	return large
+}
+
+// Patterns returns the allow and deny patterns as originally configured.
+func (p *Env) Patterns() ([]string, []string) {
+	return patterns(p.Allow), patterns(p.Deny)
+}
+
+func (p *Env) UnmarshalJSON(b []byte) error {
+	raw := struct {
+		Allow       []string `json:"allow"`
+		Deny        []string `json:"deny"`
+		Mask        []string `json:"mask"`
+		Statements  []string `json:"statements"`
+		LargeTables []string `json:"large_tables"`
+	}{}
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("unable to unmarshal policy file: %w", err)
+	}
+
+	p.Allow, p.Deny = nil, nil
+
+	for _, s := range raw.Allow {
+		re, err := compile(s)
+		if err != nil {
+			return err
+		}
+		p.Allow = append(p.Allow, re)
+	}
+
+	for _, s := range raw.Deny {
+		re, err := compile(s)
+		if err != nil {
+			return err
+		}
+		p.Deny = append(p.Deny, re)
+	}
+
+	mask, err := maskPatterns(raw.Mask)
+	if err != nil {
+		return err
+	}
+	p.Mask = mask
+	p.Statements = statementTypes(raw.Statements)
+	p.LargeTables = tableNames(raw.LargeTables)
+
+	return nil
+}
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(caseInsensitiveFlag + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile policy pattern: %w", err)
+	}
+
+	return re, nil
+}
+
+func maskPatterns(list []string) ([]string, error) {
+	var mask []string
+	for _, s := range list {
+		s = unqualified(strings.ToLower(strings.TrimSpace(s)))
+		if s == "" {
+			continue
+		}
+		if _, err := path.Match(s, ""); err != nil {
+			return nil, fmt.Errorf("unable to compile mask pattern: %w", err)
+		}
+		mask = append(mask, s)
+	}
+	return mask, nil
+}
+
+func statementTypes(list []string) []string {
+	var types []string
+	for _, s := range list {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" || contains(types, s) {
+			continue
+		}
+		types = append(types, s)
+	}
+	return types
+}
+
+func tableNames(list []string) []string {
+	var names []string
+	for _, s := range list {
+		s = unqualified(strings.ToLower(strings.TrimSpace(s)))
+		if s == "" || contains(names, s) {
+			continue
+		}
+		names = append(names, s)
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func unqualified(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func patterns(list []*regexp.Regexp) []string {
+	s := make([]string, 0, len(list))
+	for _, re := range list {
+		s = append(s, strings.TrimPrefix(re.String(), caseInsensitiveFlag))
+	}
+	return s
+}
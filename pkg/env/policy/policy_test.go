@@ -0,0 +1,491 @@
+package policy
+
+import (
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPolicyEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewPolicyEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func() string
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no policy set",
+			func() string {
+				return ""
+			},
+			&Env{},
+			false,
+			``,
+		},
+		{
+			"using environment variables with allow and deny patterns set",
+			func() string {
+				t.Setenv("QUERY_ALLOW_REGEX", `^select`)
+				t.Setenv("QUERY_DENY_REGEX", `pii\.`)
+				return ""
+			},
+			&Env{
+				Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)},
+				Deny:  []*regexp.Regexp{regexp.MustCompile(`(?i)pii\.`)},
+			},
+			false,
+			``,
+		},
+		{
+			"using policy file with allow and deny patterns set",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"allow":["^select"], "deny":["pii\\.", "secrets"]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				return file.Name()
+			},
+			&Env{
+				Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)},
+				Deny:  []*regexp.Regexp{regexp.MustCompile(`(?i)pii\.`), regexp.MustCompile(`(?i)secrets`)},
+			},
+			false,
+			``,
+		},
+		{
+			"using policy file and environment variables with deny patterns set",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"deny":["pii\\."]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				t.Setenv("QUERY_DENY_REGEX", `secrets`)
+				return file.Name()
+			},
+			&Env{
+				Deny: []*regexp.Regexp{regexp.MustCompile(`(?i)secrets`)},
+			},
+			false,
+			``,
+		},
+		{
+			"using policy file and environment variables with masked columns set",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"mask":["SSN", "users.password"]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				return file.Name()
+			},
+			&Env{Mask: []string{"ssn", "password"}},
+			false,
+			``,
+		},
+		{
+			"using policy file and environment variables with large tables set",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"large_tables":["Events"]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				t.Setenv("QUERY_LARGE_TABLES", "audit.Logs, events,,logs")
+				return file.Name()
+			},
+			&Env{LargeTables: []string{"logs", "events"}},
+			false,
+			``,
+		},
+		{
+			"using environment variables with masked columns set",
+			func() string {
+				t.Setenv("QUERY_MASK_COLUMNS", "ssn, *_Token,,")
+				return ""
+			},
+			&Env{Mask: []string{"ssn", "*_token"}},
+			false,
+			``,
+		},
+		{
+			"using policy file and environment variables with statement types set",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"statements":["select", "INSERT"]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				t.Setenv("QUERY_ALLOW_STATEMENTS", "Select, show,,select")
+				return file.Name()
+			},
+			&Env{Statements: []string{"SELECT", "SHOW"}},
+			false,
+			``,
+		},
+		{
+			"invalid mask pattern in environment variable",
+			func() string {
+				t.Setenv("QUERY_MASK_COLUMNS", "[ssn")
+				return ""
+			},
+			&Env{},
+			true,
+			`unable to compile mask pattern`,
+		},
+		{
+			"invalid policy file",
+			func() string {
+				t.Setenv("POLICY_FILE_PATH", "test")
+				return ""
+			},
+			&Env{},
+			true,
+			`unable to read policy file: open test`,
+		},
+		{
+			"invalid policy file JSON content",
+			func() string {
+				file, err := os.CreateTemp("", "policy-")
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = file.WriteString(`{"deny:["test"]}`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("POLICY_FILE_PATH", file.Name())
+				return file.Name()
+			},
+			&Env{},
+			true,
+			`unable to unmarshal policy file`,
+		},
+		{
+			"invalid pattern in environment variable",
+			func() string {
+				t.Setenv("QUERY_DENY_REGEX", `(test`)
+				return ""
+			},
+			&Env{},
+			true,
+			`unable to compile policy pattern`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			file := tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+				os.Remove(file)
+			})
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       Env
+		query       string
+		expected    bool
+	}{
+		{
+			"no patterns set",
+			Env{},
+			`select 1;`,
+			true,
+		},
+		{
+			"query matching allow pattern",
+			Env{Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)}},
+			`SELECT 1;`,
+			true,
+		},
+		{
+			"query not matching allow pattern",
+			Env{Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)}},
+			`delete from test;`,
+			false,
+		},
+		{
+			"query matching deny pattern",
+			Env{Deny: []*regexp.Regexp{regexp.MustCompile(`(?i)pii\.`)}},
+			`select * from PII.users;`,
+			false,
+		},
+		{
+			"query matching both allow and deny patterns",
+			Env{
+				Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)},
+				Deny:  []*regexp.Regexp{regexp.MustCompile(`(?i)pii\.`)},
+			},
+			`select * from pii.users;`,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := tc.given.IsAllowed(tc.query)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIsAllowedStatement(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       Env
+		types       []string
+		expected    bool
+		want        string
+	}{
+		{
+			"no statement types set",
+			Env{},
+			[]string{"DELETE"},
+			true,
+			``,
+		},
+		{
+			"statement types allowed",
+			Env{Statements: []string{"SELECT", "INSERT"}},
+			[]string{"SELECT", "insert"},
+			true,
+			``,
+		},
+		{
+			"statement type not allowed",
+			Env{Statements: []string{"SELECT", "INSERT"}},
+			[]string{"SELECT", "DELETE", "UPDATE"},
+			false,
+			`DELETE`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual, ok := tc.given.IsAllowedStatement(tc.types)
+
+			assert.Equal(t, tc.expected, ok)
+			assert.Equal(t, tc.want, actual)
+		})
+	}
+}
+
+func TestWriteStatements(t *testing.T) {
+	t.Parallel()
+
+	actual := (&Env{Statements: []string{"SELECT", "INSERT", "EXPLAIN", "UPDATE"}}).WriteStatements()
+
+	assert.Equal(t, []string{"INSERT", "UPDATE"}, actual)
+}
+
+func TestMaskedColumns(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		columns     []string
+		expected    []bool
+	}{
+		{
+			"no masked columns set",
+			nil,
+			[]string{"id", "ssn"},
+			nil,
+		},
+		{
+			"no columns matching",
+			[]string{"ssn"},
+			[]string{"id", "name"},
+			nil,
+		},
+		{
+			"column matching by name regardless of case",
+			[]string{"ssn"},
+			[]string{"id", "SSN"},
+			[]bool{false, true},
+		},
+		{
+			"columns matching by pattern",
+			[]string{"*_token"},
+			[]string{"api_token", "id", "refresh_token"},
+			[]bool{true, false, true},
+		},
+		{
+			"qualified column name matching",
+			[]string{"password"},
+			[]string{"id", "users.password"},
+			[]bool{false, true},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			given := Env{Mask: tc.given}
+
+			assert.Equal(t, tc.expected, given.MaskedColumns(tc.columns))
+		})
+	}
+}
+
+func TestMaskedNames(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		names       []string
+		expected    []string
+	}{
+		{
+			"no masked columns set",
+			nil,
+			[]string{"ssn"},
+			nil,
+		},
+		{
+			"no names matching",
+			[]string{"ssn"},
+			[]string{"id", "name"},
+			nil,
+		},
+		{
+			"names matching by name and pattern",
+			[]string{"ssn", "*_token"},
+			[]string{"ssn", "id", "api_token"},
+			[]string{"ssn", "api_token"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			given := Env{Mask: tc.given}
+
+			assert.Equal(t, tc.expected, given.MaskedNames(tc.names))
+		})
+	}
+}
+
+func TestLargeTablesScanned(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		tables      []string
+		expected    []string
+	}{
+		{
+			"no large tables set",
+			nil,
+			[]string{"events"},
+			nil,
+		},
+		{
+			"no tables matching",
+			[]string{"events"},
+			[]string{"users"},
+			nil,
+		},
+		{
+			"tables matching by name regardless of qualifier",
+			[]string{"events", "logs"},
+			[]string{"audit.events", "users", "LOGS"},
+			[]string{"audit.events", "LOGS"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			given := Env{LargeTables: tc.given}
+
+			assert.Equal(t, tc.expected, given.LargeTablesScanned(tc.tables))
+		})
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	t.Parallel()
+
+	given := Env{
+		Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)},
+		Deny:  []*regexp.Regexp{regexp.MustCompile(`(?i)pii\.`), regexp.MustCompile(`(?i)secrets`)},
+	}
+
+	allow, deny := given.Patterns()
+
+	assert.Equal(t, []string{`^select`}, allow)
+	assert.Equal(t, []string{`pii\.`, `secrets`}, deny)
+}
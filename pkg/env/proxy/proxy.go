@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const (
+	defaultUserHeader      = "X-Forwarded-User"
+	defaultRequestIDHeader = "X-Request-Id"
+	defaultClientIPHeader  = "X-Forwarded-For"
+)
+
+type Env struct {
+	TrustedProxies  []*net.IPNet
+	UserHeader      string
+	RequestIDHeader string
+	ClientIPHeader  string
+}
+
+func NewProxyEnv() *Env {
+	return &Env{
+		UserHeader:      defaultUserHeader,
+		RequestIDHeader: defaultRequestIDHeader,
+		ClientIPHeader:  defaultClientIPHeader,
+	}
+}
+
+// Populate sets the trusted proxies, given as addresses or networks in CIDR
+// notation, without which no forwarding headers are honored.
+func (p *Env) Populate() error {
+	for _, entry := range strings.Split(os.Getenv("PROXY_TRUSTED_CIDRS"), ",") {
+		s := strings.TrimSpace(entry)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return &env.TypeError{Name: "PROXY_TRUSTED_CIDRS"}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.TrustedProxies = append(p.TrustedProxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return &env.TypeError{Name: "PROXY_TRUSTED_CIDRS"}
+		}
+		p.TrustedProxies = append(p.TrustedProxies, network)
+	}
+
+	for _, h := range []struct {
+		name   string
+		header *string
+	}{
+		{"PROXY_USER_HEADER", &p.UserHeader},
+		{"PROXY_REQUEST_ID_HEADER", &p.RequestIDHeader},
+		{"PROXY_CLIENT_IP_HEADER", &p.ClientIPHeader},
+	} {
+		if s := strings.TrimSpace(os.Getenv(h.name)); s != "" {
+			*h.header = http.CanonicalHeaderKey(s)
+		}
+	}
+
+	return nil
+}
+
+func (p *Env) Enabled() bool {
+	return len(p.TrustedProxies) > 0
+}
+
+// IsTrusted reports whether the address, with or without a port, belongs to
+// any of the trusted proxies.
+func (p *Env) IsTrusted(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client, being the peer unless it is a
+// trusted proxy, in which case the client IP header is walked from the right,
+// skipping over further trusted proxies, as only the rightmost addresses were
+// appended by these. Without trusted proxies, the header is never honored.
+func (p *Env) ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !p.IsTrusted(peer) {
+		return peer
+	}
+
+	client := peer
+	addresses := strings.Split(strings.Join(r.Header.Values(p.ClientIPHeader), ","), ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		s := strings.TrimSpace(addresses[i])
+		if s == "" {
+			continue
+		}
+		if net.ParseIP(s) == nil {
+			break
+		}
+		client = s
+		if !p.IsTrusted(s) {
+			break
+		}
+	}
+	return client
+}
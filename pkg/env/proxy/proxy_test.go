@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProxyEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewProxyEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+	assert.False(t, actual.Enabled())
+	assert.Equal(t, defaultUserHeader, actual.UserHeader)
+	assert.Equal(t, defaultRequestIDHeader, actual.RequestIDHeader)
+	assert.Equal(t, defaultClientIPHeader, actual.ClientIPHeader)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{UserHeader: "X-Forwarded-User", RequestIDHeader: "X-Request-Id", ClientIPHeader: "X-Forwarded-For"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set",
+			func() {
+				t.Setenv("PROXY_TRUSTED_CIDRS", "10.0.0.0/8, 127.0.0.1,::1")
+				t.Setenv("PROXY_USER_HEADER", "x-auth-request-user")
+				t.Setenv("PROXY_REQUEST_ID_HEADER", "X-Correlation-Id")
+				t.Setenv("PROXY_CLIENT_IP_HEADER", "X-Real-IP")
+			},
+			&Env{
+				TrustedProxies: []*net.IPNet{
+					{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+					{IP: net.IP{127, 0, 0, 1}, Mask: net.CIDRMask(32, 32)},
+					{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+				},
+				UserHeader:      "X-Auth-Request-User",
+				RequestIDHeader: "X-Correlation-Id",
+				ClientIPHeader:  "X-Real-Ip",
+			},
+			false,
+			``,
+		},
+		{
+			"invalid trusted proxy address",
+			func() {
+				t.Setenv("PROXY_TRUSTED_CIDRS", "10.0.0.0/8,test")
+			},
+			nil,
+			true,
+			`unable to convert environment variable: PROXY_TRUSTED_CIDRS`,
+		},
+		{
+			"invalid trusted proxy network",
+			func() {
+				t.Setenv("PROXY_TRUSTED_CIDRS", "10.0.0.0/33")
+			},
+			nil,
+			true,
+			`unable to convert environment variable: PROXY_TRUSTED_CIDRS`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := NewProxyEnv()
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestIsTrusted(t *testing.T) {
+	t.Parallel()
+
+	p := &Env{TrustedProxies: []*net.IPNet{
+		{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)},
+		{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	}}
+
+	cases := []struct {
+		description string
+		given       string
+		expected    bool
+	}{
+		{"trusted address", "10.1.2.3", true},
+		{"trusted address with port", "10.1.2.3:8080", true},
+		{"trusted IPv6 address with port", "[::1]:8080", true},
+		{"untrusted address", "192.0.2.1:1234", false},
+		{"invalid address", "test", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, p.IsTrusted(tc.given))
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	trusted := []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}}
+
+	cases := []struct {
+		description string
+		proxies     []*net.IPNet
+		peer        string
+		header      []string
+		expected    string
+	}{
+		{
+			"header ignored without trusted proxies",
+			nil,
+			"10.0.0.1:1234",
+			[]string{"203.0.113.1"},
+			"10.0.0.1",
+		},
+		{
+			"header ignored from untrusted peer",
+			trusted,
+			"192.0.2.1:1234",
+			[]string{"203.0.113.1"},
+			"192.0.2.1",
+		},
+		{
+			"client taken from trusted peer",
+			trusted,
+			"10.0.0.1:1234",
+			[]string{"203.0.113.1"},
+			"203.0.113.1",
+		},
+		{
+			"spoofed addresses skipped",
+			trusted,
+			"10.0.0.1:1234",
+			[]string{"198.51.100.1, 203.0.113.1, 10.0.0.2"},
+			"203.0.113.1",
+		},
+		{
+			"addresses across several headers",
+			trusted,
+			"10.0.0.1:1234",
+			[]string{"198.51.100.1", "203.0.113.1"},
+			"203.0.113.1",
+		},
+		{
+			"invalid address in header",
+			trusted,
+			"10.0.0.1:1234",
+			[]string{"203.0.113.1, test"},
+			"10.0.0.1",
+		},
+		{
+			"header missing",
+			trusted,
+			"10.0.0.1:1234",
+			nil,
+			"10.0.0.1",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			p := NewProxyEnv()
+			p.TrustedProxies = tc.proxies
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.peer
+			for _, value := range tc.header {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			assert.Equal(t, tc.expected, p.ClientIP(r))
+		})
+	}
+}
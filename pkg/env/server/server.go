@@ -0,0 +1,97 @@
+package server
+
+import (
+	"compress/gzip"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+const defaultShutdownGracePeriod = 25 * time.Second
+
+// The level gzip defaults to, as a trade-off between speed and size.
+const defaultCompressionLevel = 6
+
+// Windows shorter than this would restart a service that is merely busy.
+const minStallTimeout = time.Minute
+
+// Base paths are made of segments of unreserved URL characters only, so that
+// these need no escaping, nor can be confused with route variables.
+var basePathPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+type Env struct {
+	ShutdownGracePeriod time.Duration
+	TLSCertFile         string
+	TLSKeyFile          string
+	BasePath            string
+	CompressionLevel    int
+
+	// Request handling is stalled once no request completed for this long
+	// while requests are in flight, failing the liveness check, unless zero.
+	StallTimeout time.Duration
+}
+
+func NewServerEnv() *Env {
+	return &Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel}
+}
+
+func (s *Env) Populate() error {
+	if period := os.Getenv("SHUTDOWN_GRACE_PERIOD"); period != "" {
+		d, err := time.ParseDuration(period)
+		if err != nil || d < 0 {
+			return &env.TypeError{Name: "SHUTDOWN_GRACE_PERIOD"}
+		}
+		s.ShutdownGracePeriod = d
+	}
+
+	// The certificate and the key are only useful together.
+	s.TLSCertFile = strings.TrimSpace(os.Getenv("SERVER_TLS_CERT_FILE"))
+	s.TLSKeyFile = strings.TrimSpace(os.Getenv("SERVER_TLS_KEY_FILE"))
+	if s.TLSCertFile != "" && s.TLSKeyFile == "" {
+		return &env.Error{Name: "SERVER_TLS_KEY_FILE"}
+	}
+	if s.TLSKeyFile != "" && s.TLSCertFile == "" {
+		return &env.Error{Name: "SERVER_TLS_CERT_FILE"}
+	}
+
+	// Every route is served under the base path, when set, with a trailing
+	// slash being ignored, thus "/" is the same as not setting it.
+	if path := strings.TrimRight(strings.TrimSpace(os.Getenv("BASE_PATH")), "/"); path != "" {
+		if !basePathPattern.MatchString(path) {
+			return &env.TypeError{Name: "BASE_PATH"}
+		}
+		s.BasePath = path
+	}
+
+	// Levels range from 1 (fastest) to 9 (smallest), with 0 disabling
+	// compression.
+	if level := os.Getenv("RESPONSE_COMPRESSION_LEVEL"); level != "" {
+		n, err := strconv.Atoi(level)
+		if err != nil || n < gzip.NoCompression || n > gzip.BestCompression {
+			return &env.TypeError{Name: "RESPONSE_COMPRESSION_LEVEL"}
+		}
+		s.CompressionLevel = n
+	}
+
+	if timeout := os.Getenv("LIVENESS_STALL_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d < 0 || (d > 0 && d < minStallTimeout) {
+			return &env.TypeError{Name: "LIVENESS_STALL_TIMEOUT"}
+		}
+		s.StallTimeout = d
+	}
+
+	return nil
+}
+
+func (s *Env) CompressionEnabled() bool {
+	return s.CompressionLevel != gzip.NoCompression
+}
+
+func (s *Env) TLSEnabled() bool {
+	return s.TLSCertFile != ""
+}
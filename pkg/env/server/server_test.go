@@ -0,0 +1,199 @@
+package server
+
+import (
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServerEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewServerEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+	assert.Equal(t, defaultShutdownGracePeriod, actual.ShutdownGracePeriod)
+	assert.True(t, actual.CompressionEnabled())
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			false,
+			``,
+		},
+		{
+			"shutdown grace period set",
+			func() {
+				t.Setenv("SHUTDOWN_GRACE_PERIOD", "1m")
+			},
+			&Env{ShutdownGracePeriod: time.Minute, CompressionLevel: defaultCompressionLevel},
+			false,
+			``,
+		},
+		{
+			"TLS certificate and key set",
+			func() {
+				t.Setenv("SERVER_TLS_CERT_FILE", "/etc/gabi/tls.crt")
+				t.Setenv("SERVER_TLS_KEY_FILE", "/etc/gabi/tls.key")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel, TLSCertFile: "/etc/gabi/tls.crt", TLSKeyFile: "/etc/gabi/tls.key"},
+			false,
+			``,
+		},
+		{
+			"TLS certificate set without key",
+			func() {
+				t.Setenv("SERVER_TLS_CERT_FILE", "/etc/gabi/tls.crt")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel, TLSCertFile: "/etc/gabi/tls.crt"},
+			true,
+			`unable to access environment variable: SERVER_TLS_KEY_FILE`,
+		},
+		{
+			"TLS key set without certificate",
+			func() {
+				t.Setenv("SERVER_TLS_KEY_FILE", "/etc/gabi/tls.key")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel, TLSKeyFile: "/etc/gabi/tls.key"},
+			true,
+			`unable to access environment variable: SERVER_TLS_CERT_FILE`,
+		},
+		{
+			"base path set",
+			func() {
+				t.Setenv("BASE_PATH", "/tools/gabi/")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel, BasePath: "/tools/gabi"},
+			false,
+			``,
+		},
+		{
+			"root base path set",
+			func() {
+				t.Setenv("BASE_PATH", "/")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			false,
+			``,
+		},
+		{
+			"base path without leading slash",
+			func() {
+				t.Setenv("BASE_PATH", "tools/gabi")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: BASE_PATH`,
+		},
+		{
+			"base path with invalid characters",
+			func() {
+				t.Setenv("BASE_PATH", "/tools/{gabi}")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: BASE_PATH`,
+		},
+		{
+			"compression level set",
+			func() {
+				t.Setenv("RESPONSE_COMPRESSION_LEVEL", "9")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: gzip.BestCompression},
+			false,
+			``,
+		},
+		{
+			"compression disabled",
+			func() {
+				t.Setenv("RESPONSE_COMPRESSION_LEVEL", "0")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: gzip.NoCompression},
+			false,
+			``,
+		},
+		{
+			"invalid RESPONSE_COMPRESSION_LEVEL environment variable",
+			func() {
+				t.Setenv("RESPONSE_COMPRESSION_LEVEL", "10")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: RESPONSE_COMPRESSION_LEVEL`,
+		},
+		{
+			"liveness stall timeout set",
+			func() {
+				t.Setenv("LIVENESS_STALL_TIMEOUT", "10m")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel, StallTimeout: 10 * time.Minute},
+			false,
+			``,
+		},
+		{
+			"LIVENESS_STALL_TIMEOUT environment variable below minimum",
+			func() {
+				t.Setenv("LIVENESS_STALL_TIMEOUT", "30s")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: LIVENESS_STALL_TIMEOUT`,
+		},
+		{
+			"invalid SHUTDOWN_GRACE_PERIOD environment variable",
+			func() {
+				t.Setenv("SHUTDOWN_GRACE_PERIOD", "test")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: SHUTDOWN_GRACE_PERIOD`,
+		},
+		{
+			"negative SHUTDOWN_GRACE_PERIOD environment variable",
+			func() {
+				t.Setenv("SHUTDOWN_GRACE_PERIOD", "-1s")
+			},
+			&Env{ShutdownGracePeriod: defaultShutdownGracePeriod, CompressionLevel: defaultCompressionLevel},
+			true,
+			`unable to convert environment variable: SHUTDOWN_GRACE_PERIOD`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := NewServerEnv()
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -2,21 +2,82 @@ package splunk
 
 import (
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/app-sre/gabi/pkg/env"
 )
 
+const (
+	TimeFormatSeconds      = "seconds"
+	TimeFormatMilliseconds = "milliseconds"
+	TimeFormatRFC3339      = "rfc3339"
+)
+
+// The HTTP Event Collector endpoints events can be sent to, being either the
+// event endpoint, taking events wrapped in their metadata, or the raw one,
+// taking the bare events.
+const (
+	CollectorEvent = "event"
+	CollectorRaw   = "raw"
+)
+
+const (
+	defaultBreakerCooldown = 30 * time.Second
+	defaultSpoolMaxEvents  = 10000
+	defaultAckTimeout      = 30 * time.Second
+
+	SpoolOverflowReject = "reject"
+	SpoolOverflowDrop   = "drop"
+)
+
+var channelPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
 type Env struct {
 	Index     string
+	IndexMap  map[string]string
 	Endpoint  string
+	Endpoints []string
 	Token     string
 	Host      string
 	Namespace string
 	Pod       string
+
+	HealthCheck bool
+	UserAgent   string
+	TimeFormat  string
+	Collector   string
+
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	SpoolDir       string
+	SpoolMaxEvents int
+	SpoolMaxBytes  int64
+	SpoolOverflow  string
+
+	Channel    string
+	Ack        bool
+	AckTimeout time.Duration
+
+	OAuthTokenURL     string
+	OAuthClientID     string
+	OAuthClientSecret string
+	OAuthScopes       []string
+
+	InsecureEndpoint string
+	CAFile           string
 }
 
 func NewSplunkEnv() *Env {
-	return &Env{}
+	return &Env{
+		BreakerCooldown: defaultBreakerCooldown,
+		SpoolMaxEvents:  defaultSpoolMaxEvents,
+		AckTimeout:      defaultAckTimeout,
+		SpoolOverflow:   SpoolOverflowReject,
+	}
 }
 
 func (s *Env) Populate() error {
@@ -26,14 +87,63 @@ func (s *Env) Populate() error {
 	}
 	s.Index = index
 
+	// Events of the namespaces listed are sent to their own index.
+	if mapping := os.Getenv("SPLUNK_INDEX_MAP"); mapping != "" {
+		indexes, err := env.KeyValues(mapping)
+		if err != nil {
+			return &env.TypeError{Name: "SPLUNK_INDEX_MAP"}
+		}
+		for _, index := range indexes {
+			if index == "" {
+				return &env.TypeError{Name: "SPLUNK_INDEX_MAP"}
+			}
+		}
+		if len(indexes) > 0 {
+			s.IndexMap = indexes
+		}
+	}
+
 	endpoint := os.Getenv("SPLUNK_ENDPOINT")
 	if endpoint == "" {
 		return &env.Error{Name: "SPLUNK_ENDPOINT"}
 	}
 	s.Endpoint = endpoint
 
-	token := os.Getenv("SPLUNK_TOKEN")
-	if token == "" {
+	if endpoints := os.Getenv("SPLUNK_ENDPOINTS"); endpoints != "" {
+		for _, e := range strings.Split(endpoints, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				s.Endpoints = append(s.Endpoints, e)
+			}
+		}
+	}
+
+	// A static token is only required when not using OAuth2.
+	if tokenURL := os.Getenv("SPLUNK_OAUTH_TOKEN_URL"); tokenURL != "" {
+		s.OAuthTokenURL = tokenURL
+
+		clientID := os.Getenv("SPLUNK_OAUTH_CLIENT_ID")
+		if clientID == "" {
+			return &env.Error{Name: "SPLUNK_OAUTH_CLIENT_ID"}
+		}
+		s.OAuthClientID = clientID
+
+		clientSecret, err := env.Secret("SPLUNK_OAUTH_CLIENT_SECRET")
+		if err != nil {
+			return err
+		}
+		if clientSecret == "" {
+			return &env.Error{Name: "SPLUNK_OAUTH_CLIENT_SECRET"}
+		}
+		s.OAuthClientSecret = clientSecret
+
+		s.OAuthScopes = strings.Fields(os.Getenv("SPLUNK_OAUTH_SCOPES"))
+	}
+
+	token, err := env.Secret("SPLUNK_TOKEN")
+	if err != nil {
+		return err
+	}
+	if token == "" && s.OAuthTokenURL == "" {
 		return &env.Error{Name: "SPLUNK_TOKEN"}
 	}
 	s.Token = token
@@ -56,5 +166,144 @@ func (s *Env) Populate() error {
 	}
 	s.Pod = pod
 
+	s.UserAgent = os.Getenv("SPLUNK_USER_AGENT")
+
+	if healthCheck := os.Getenv("SPLUNK_HEALTH_CHECK"); healthCheck != "" {
+		check, err := strconv.ParseBool(healthCheck)
+		if err != nil {
+			return &env.TypeError{Name: "SPLUNK_HEALTH_CHECK"}
+		}
+		s.HealthCheck = check
+	}
+
+	if format := os.Getenv("SPLUNK_TIME_FORMAT"); format != "" {
+		switch format = strings.ToLower(format); format {
+		case TimeFormatSeconds, TimeFormatMilliseconds, TimeFormatRFC3339:
+			s.TimeFormat = format
+		default:
+			return &env.TypeError{Name: "SPLUNK_TIME_FORMAT"}
+		}
+	}
+
+	if collector := os.Getenv("SPLUNK_COLLECTOR"); collector != "" {
+		switch collector = strings.ToLower(collector); collector {
+		case CollectorEvent, CollectorRaw:
+			s.Collector = collector
+		default:
+			return &env.TypeError{Name: "SPLUNK_COLLECTOR"}
+		}
+	}
+
+	if threshold := os.Getenv("SPLUNK_BREAKER_THRESHOLD"); threshold != "" {
+		n, err := strconv.Atoi(threshold)
+		if err != nil || n < 0 {
+			return &env.TypeError{Name: "SPLUNK_BREAKER_THRESHOLD"}
+		}
+		s.BreakerThreshold = n
+	}
+
+	if cooldown := os.Getenv("SPLUNK_BREAKER_COOLDOWN"); cooldown != "" {
+		d, err := time.ParseDuration(cooldown)
+		if err != nil || d <= 0 {
+			return &env.TypeError{Name: "SPLUNK_BREAKER_COOLDOWN"}
+		}
+		s.BreakerCooldown = d
+	}
+
+	s.SpoolDir = os.Getenv("SPLUNK_SPOOL_DIR")
+
+	if maxEvents := os.Getenv("SPLUNK_SPOOL_MAX_EVENTS"); maxEvents != "" {
+		n, err := strconv.Atoi(maxEvents)
+		if err != nil || n <= 0 {
+			return &env.TypeError{Name: "SPLUNK_SPOOL_MAX_EVENTS"}
+		}
+		s.SpoolMaxEvents = n
+	}
+
+	if maxBytes := os.Getenv("SPLUNK_SPOOL_MAX_BYTES"); maxBytes != "" {
+		n, err := strconv.ParseInt(maxBytes, 10, 64)
+		if err != nil || n <= 0 {
+			return &env.TypeError{Name: "SPLUNK_SPOOL_MAX_BYTES"}
+		}
+		s.SpoolMaxBytes = n
+	}
+
+	if overflow := os.Getenv("SPLUNK_SPOOL_OVERFLOW"); overflow != "" {
+		switch overflow = strings.ToLower(overflow); overflow {
+		case SpoolOverflowReject, SpoolOverflowDrop:
+			s.SpoolOverflow = overflow
+		default:
+			return &env.TypeError{Name: "SPLUNK_SPOOL_OVERFLOW"}
+		}
+	}
+
+	if channel := os.Getenv("SPLUNK_CHANNEL"); channel != "" {
+		if !channelPattern.MatchString(channel) {
+			return &env.TypeError{Name: "SPLUNK_CHANNEL"}
+		}
+		s.Channel = channel
+	}
+
+	// Indexer acknowledgement is tracked per channel, thus one is required.
+	if ack := os.Getenv("SPLUNK_ACK"); ack != "" {
+		enabled, err := strconv.ParseBool(ack)
+		if err != nil {
+			return &env.TypeError{Name: "SPLUNK_ACK"}
+		}
+		if enabled && s.Channel == "" {
+			return &env.Error{Name: "SPLUNK_CHANNEL"}
+		}
+		s.Ack = enabled
+	}
+
+	// The raw endpoint requires a channel, whether acknowledgement is enabled
+	// or not.
+	if s.Collector == CollectorRaw && s.Channel == "" {
+		return &env.Error{Name: "SPLUNK_CHANNEL"}
+	}
+
+	if timeout := os.Getenv("SPLUNK_ACK_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return &env.TypeError{Name: "SPLUNK_ACK_TIMEOUT"}
+		}
+		s.AckTimeout = d
+	}
+
+	// Certificates of Splunk are verified against the CA bundle, when given,
+	// rather than the system roots.
+	s.CAFile = os.Getenv("SPLUNK_CA_FILE")
+
+	// Verification can only be skipped for one of the endpoints, named explicitly.
+	if endpoint := strings.TrimSpace(os.Getenv("SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT")); endpoint != "" {
+		found := false
+		for _, e := range s.AllEndpoints() {
+			if e == endpoint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &env.TypeError{Name: "SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT"}
+		}
+		s.InsecureEndpoint = endpoint
+	}
+
 	return nil
 }
+
+// AllEndpoints returns the primary endpoint followed by any additional
+// endpoints used for failover, without duplicates.
+func (s *Env) AllEndpoints() []string {
+	endpoints := []string{s.Endpoint}
+
+	seen := map[string]bool{s.Endpoint: true}
+	for _, e := range s.Endpoints {
+		if !seen[e] {
+			seen[e] = true
+			endpoints = append(endpoints, e)
+		}
+	}
+
+	return endpoints
+}
@@ -2,7 +2,9 @@ package splunk
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,6 +41,520 @@ func TestPopulate(t *testing.T) {
 			false,
 			``,
 		},
+		{
+			"all environment variables set with health check enabled",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_HEALTH_CHECK", "true")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", HealthCheck: true},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with custom User-Agent",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_USER_AGENT", "test/1.0")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", UserAgent: "test/1.0"},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_HEALTH_CHECK environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_HEALTH_CHECK", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_HEALTH_CHECK`,
+		},
+		{
+			"all environment variables set with failover endpoints",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_ENDPOINTS", "test1, test2,,")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Endpoints: []string{"test1", "test2"}, Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with IPv6 endpoints",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "https://[::1]:8088")
+				t.Setenv("SPLUNK_ENDPOINTS", "[2001:db8::1]:8088, https://[2001:db8::2]:8088/")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{Index: "test", Endpoint: "https://[::1]:8088", Endpoints: []string{"[2001:db8::1]:8088", "https://[2001:db8::2]:8088/"}, Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with circuit breaker enabled",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_BREAKER_THRESHOLD", "5")
+				t.Setenv("SPLUNK_BREAKER_COOLDOWN", "1m")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", BreakerThreshold: 5, BreakerCooldown: time.Minute},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_BREAKER_THRESHOLD environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_BREAKER_THRESHOLD", "-1")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_BREAKER_THRESHOLD`,
+		},
+		{
+			"invalid SPLUNK_BREAKER_COOLDOWN environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_BREAKER_COOLDOWN", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_BREAKER_COOLDOWN`,
+		},
+		{
+			"all environment variables set with spool enabled",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_SPOOL_DIR", "/tmp/spool")
+				t.Setenv("SPLUNK_SPOOL_MAX_EVENTS", "100")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", SpoolDir: "/tmp/spool", SpoolMaxEvents: 100},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_SPOOL_MAX_EVENTS environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_SPOOL_MAX_EVENTS", "0")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_SPOOL_MAX_EVENTS`,
+		},
+		{
+			"all environment variables set with spool size limit and overflow policy",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_SPOOL_DIR", "/tmp/spool")
+				t.Setenv("SPLUNK_SPOOL_MAX_BYTES", "1048576")
+				t.Setenv("SPLUNK_SPOOL_OVERFLOW", "Drop")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", SpoolDir: "/tmp/spool", SpoolMaxBytes: 1048576, SpoolOverflow: "drop"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with index map",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_INDEX_MAP", "team-a=audit-a, team-b = audit-b")
+			},
+			&Env{Index: "test", IndexMap: map[string]string{"team-a": "audit-a", "team-b": "audit-b"}, Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_INDEX_MAP environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_INDEX_MAP", "team-a")
+			},
+			&Env{Index: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_INDEX_MAP`,
+		},
+		{
+			"SPLUNK_INDEX_MAP environment variable with empty index",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_INDEX_MAP", "team-a=")
+			},
+			&Env{Index: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_INDEX_MAP`,
+		},
+		{
+			"invalid SPLUNK_SPOOL_MAX_BYTES environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_SPOOL_MAX_BYTES", "-1")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_SPOOL_MAX_BYTES`,
+		},
+		{
+			"invalid SPLUNK_SPOOL_OVERFLOW environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_SPOOL_OVERFLOW", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_SPOOL_OVERFLOW`,
+		},
+		{
+			"all environment variables set with indexer acknowledgement enabled",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_CHANNEL", "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d")
+				t.Setenv("SPLUNK_ACK", "true")
+				t.Setenv("SPLUNK_ACK_TIMEOUT", "10s")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", Channel: "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d", Ack: true, AckTimeout: 10 * time.Second},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_CHANNEL environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_CHANNEL", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_CHANNEL`,
+		},
+		{
+			"missing SPLUNK_CHANNEL environment variable with indexer acknowledgement enabled",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_ACK", "true")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to access environment variable: SPLUNK_CHANNEL`,
+		},
+		{
+			"all environment variables set with raw collector endpoint",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_CHANNEL", "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d")
+				t.Setenv("SPLUNK_COLLECTOR", "RAW")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", Channel: "0aec3eb1-8b9f-4a5e-9c1d-2f4e6a8b0c3d", Collector: "raw"},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_COLLECTOR environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_COLLECTOR", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_COLLECTOR`,
+		},
+		{
+			"missing SPLUNK_CHANNEL environment variable with raw collector endpoint",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_COLLECTOR", "raw")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", Collector: "raw"},
+			true,
+			`unable to access environment variable: SPLUNK_CHANNEL`,
+		},
+		{
+			"invalid SPLUNK_ACK_TIMEOUT environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_ACK_TIMEOUT", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_ACK_TIMEOUT`,
+		},
+		{
+			"all environment variables set with TLS verification skipped for failover endpoint",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_ENDPOINTS", "test1")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT", "test1")
+			},
+			&Env{Index: "test", Endpoint: "test", Endpoints: []string{"test1"}, Token: "test123", Host: "test", Namespace: "test", Pod: "test", InsecureEndpoint: "test1"},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with CA file",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_CA_FILE", "/etc/splunk/ca.crt")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", CAFile: "/etc/splunk/ca.crt"},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT", "test1")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_INSECURE_SKIP_VERIFY_ENDPOINT`,
+		},
+		{
+			"all environment variables set with token read from file",
+			func() {
+				path := filepath.Join(t.TempDir(), "token")
+				if err := os.WriteFile(path, []byte("secret123\r\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("SPLUNK_TOKEN_FILE", path)
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "secret123", Host: "test", Namespace: "test", Pod: "test"},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_TOKEN_FILE environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN_FILE", "/nonexistent/token")
+			},
+			&Env{Index: "test", Endpoint: "test"},
+			true,
+			`unable to read secret file for SPLUNK_TOKEN: open /nonexistent/token`,
+		},
+		{
+			"all environment variables set with OAuth2 client secret read from file",
+			func() {
+				path := filepath.Join(t.TempDir(), "client-secret")
+				if err := os.WriteFile(path, []byte("secret456\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_OAUTH_TOKEN_URL", "test")
+				t.Setenv("SPLUNK_OAUTH_CLIENT_ID", "test")
+				t.Setenv("SPLUNK_OAUTH_CLIENT_SECRET_FILE", path)
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{
+				Index: "test", Endpoint: "test", Host: "test", Namespace: "test", Pod: "test",
+				OAuthTokenURL: "test", OAuthClientID: "test", OAuthClientSecret: "secret456", OAuthScopes: []string{},
+			},
+			false,
+			``,
+		},
+		{
+			"all environment variables set with OAuth2 instead of token",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_OAUTH_TOKEN_URL", "test")
+				t.Setenv("SPLUNK_OAUTH_CLIENT_ID", "test")
+				t.Setenv("SPLUNK_OAUTH_CLIENT_SECRET", "test456")
+				t.Setenv("SPLUNK_OAUTH_SCOPES", "audit.write audit.read")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+			},
+			&Env{
+				Index: "test", Endpoint: "test", Host: "test", Namespace: "test", Pod: "test",
+				OAuthTokenURL: "test", OAuthClientID: "test", OAuthClientSecret: "test456", OAuthScopes: []string{"audit.write", "audit.read"},
+			},
+			false,
+			``,
+		},
+		{
+			"missing required SPLUNK_OAUTH_CLIENT_ID environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_OAUTH_TOKEN_URL", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", OAuthTokenURL: "test"},
+			true,
+			`unable to access environment variable: SPLUNK_OAUTH_CLIENT_ID`,
+		},
+		{
+			"missing required SPLUNK_OAUTH_CLIENT_SECRET environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_OAUTH_TOKEN_URL", "test")
+				t.Setenv("SPLUNK_OAUTH_CLIENT_ID", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", OAuthTokenURL: "test", OAuthClientID: "test"},
+			true,
+			`unable to access environment variable: SPLUNK_OAUTH_CLIENT_SECRET`,
+		},
+		{
+			"all environment variables set with time format",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_TIME_FORMAT", "RFC3339")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test", TimeFormat: TimeFormatRFC3339},
+			false,
+			``,
+		},
+		{
+			"invalid SPLUNK_TIME_FORMAT environment variable",
+			func() {
+				t.Setenv("SPLUNK_INDEX", "test")
+				t.Setenv("SPLUNK_ENDPOINT", "test")
+				t.Setenv("SPLUNK_TOKEN", "test123")
+				t.Setenv("HOST", "test")
+				t.Setenv("NAMESPACE", "test")
+				t.Setenv("POD_NAME", "test")
+				t.Setenv("SPLUNK_TIME_FORMAT", "test")
+			},
+			&Env{Index: "test", Endpoint: "test", Token: "test123", Host: "test", Namespace: "test", Pod: "test"},
+			true,
+			`unable to convert environment variable: SPLUNK_TIME_FORMAT`,
+		},
 		{
 			"missing required SPLUNK_INDEX environment variable",
 			func() {
@@ -136,3 +652,38 @@ func TestPopulate(t *testing.T) {
 		})
 	}
 }
+
+func TestAllEndpoints(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *Env
+		expected    []string
+	}{
+		{
+			"only primary endpoint set",
+			&Env{Endpoint: "test"},
+			[]string{"test"},
+		},
+		{
+			"primary and failover endpoints set",
+			&Env{Endpoint: "test", Endpoints: []string{"test1", "test2"}},
+			[]string{"test", "test1", "test2"},
+		},
+		{
+			"duplicate endpoints set",
+			&Env{Endpoint: "test", Endpoints: []string{"test1", "test", "test1"}},
+			[]string{"test", "test1"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.given.AllEndpoints())
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/app-sre/gabi/pkg/env"
+)
+
+type Env struct {
+	Endpoint  string
+	Exemplars bool
+}
+
+func NewTracingEnv() *Env {
+	return &Env{}
+}
+
+// Populate only determines whether tracing is enabled, the exporter itself
+// is configured using the standard OpenTelemetry environment variables.
+func (t *Env) Populate() error {
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"} {
+		if endpoint := os.Getenv(name); endpoint != "" {
+			t.Endpoint = endpoint
+			break
+		}
+	}
+
+	// Exemplars link the latency metrics to traces, though not every
+	// Prometheus setup accepts these, thus these are opt-in.
+	if s := os.Getenv("METRICS_EXEMPLARS"); s != "" {
+		exemplars, err := strconv.ParseBool(s)
+		if err != nil {
+			return &env.TypeError{Name: "METRICS_EXEMPLARS"}
+		}
+		t.Exemplars = exemplars
+	}
+
+	return nil
+}
+
+func (t *Env) Enabled() bool {
+	return t.Endpoint != ""
+}
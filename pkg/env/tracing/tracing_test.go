@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTracingEnv(t *testing.T) {
+	t.Parallel()
+
+	actual := NewTracingEnv()
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Env{}, actual)
+}
+
+func TestPopulate(t *testing.T) {
+	cases := []struct {
+		description string
+		given       func()
+		expected    *Env
+		enabled     bool
+		error       bool
+		want        string
+	}{
+		{
+			"no environment variables set",
+			func() {
+			},
+			&Env{},
+			false,
+			false,
+			``,
+		},
+		{
+			"collector endpoint set",
+			func() {
+				t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+			},
+			&Env{Endpoint: "http://localhost:4318"},
+			true,
+			false,
+			``,
+		},
+		{
+			"traces collector endpoint set",
+			func() {
+				t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+				t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4318/v1/traces")
+			},
+			&Env{Endpoint: "http://localhost:4318/v1/traces"},
+			true,
+			false,
+			``,
+		},
+		{
+			"exemplars enabled",
+			func() {
+				t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")
+				t.Setenv("METRICS_EXEMPLARS", "true")
+			},
+			&Env{Endpoint: "http://localhost:4318", Exemplars: true},
+			true,
+			false,
+			``,
+		},
+		{
+			"invalid METRICS_EXEMPLARS environment variable",
+			func() {
+				t.Setenv("METRICS_EXEMPLARS", "test")
+			},
+			&Env{},
+			false,
+			true,
+			`unable to convert environment variable: METRICS_EXEMPLARS`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			tc.given()
+			t.Cleanup(func() {
+				os.Clearenv()
+			})
+
+			actual := &Env{}
+			err := actual.Populate()
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.want)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.enabled, actual.Enabled())
+		})
+	}
+}
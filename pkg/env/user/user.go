@@ -4,23 +4,34 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/app-sre/gabi/pkg/env"
 )
 
-const ExpiryDateLayout = "2006-01-02"
+const (
+	ExpiryDateLayout = "2006-01-02"
+
+	groupPrefix = "group:"
+
+	defaultWarningDays = 7
+)
 
 type Env struct {
-	Expiration time.Time `json:"expiration"`
-	Users      []string  `json:"users"`
+	Expiration  time.Time `json:"expiration"`
+	Users       []string  `json:"users"`
+	WarningDays int       `json:"-"`
+	GraceDays   int       `json:"-"`
 }
 
 func NewUserEnv() *Env {
-	return &Env{}
+	return &Env{WarningDays: defaultWarningDays}
 }
 
 func (u *Env) Populate() error {
@@ -46,6 +57,22 @@ func (u *Env) Populate() error {
 		return &env.Error{Name: "EXPIRATION_DATE"}
 	}
 
+	if days := os.Getenv("EXPIRATION_WARNING_DAYS"); days != "" {
+		n, err := strconv.ParseUint(days, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "EXPIRATION_WARNING_DAYS"}
+		}
+		u.WarningDays = int(n)
+	}
+
+	if days := os.Getenv("EXPIRATION_GRACE_DAYS"); days != "" {
+		n, err := strconv.ParseUint(days, 10, 0)
+		if err != nil {
+			return &env.TypeError{Name: "EXPIRATION_GRACE_DAYS"}
+		}
+		u.GraceDays = int(n)
+	}
+
 	if users := os.Getenv("AUTHORIZED_USERS"); users != "" {
 		ss := strings.Split(users, ",")
 		aux := make([]string, 0, len(ss))
@@ -65,6 +92,58 @@ func (u *Env) IsExpired() bool {
 	return u.Expiration.Before(time.Now())
 }
 
+func (u *Env) IsExpiring() bool {
+	return !u.IsExpired() && u.DaysRemaining() <= u.WarningDays
+}
+
+func (u *Env) DaysRemaining() int {
+	if u.IsExpired() {
+		return 0
+	}
+	return int(math.Ceil(time.Until(u.Expiration).Hours() / 24))
+}
+
+// InGracePeriod reports whether the instance has expired, but is still within
+// the grace period following the expiration date, if any.
+func (u *Env) InGracePeriod() bool {
+	return u.IsExpired() && time.Now().Before(u.graceEnd())
+}
+
+func (u *Env) GraceDaysRemaining() int {
+	if !u.InGracePeriod() {
+		return 0
+	}
+	return int(math.Ceil(time.Until(u.graceEnd()).Hours() / 24))
+}
+
+func (u *Env) graceEnd() time.Time {
+	return u.Expiration.AddDate(0, 0, u.GraceDays)
+}
+
+// IsAuthorized reports whether the user is permitted by any entry on the list.
+// Entries are either usernames, shell-style patterns such as "team-*", or groups
+// in the form of "group:<name>" matched against the groups given.
+func (u *Env) IsAuthorized(user string, groups []string) bool {
+	for _, entry := range u.Users {
+		if name := strings.TrimPrefix(entry, groupPrefix); name != entry {
+			for _, g := range groups {
+				if g == name {
+					return true
+				}
+			}
+			continue
+		}
+		if entry == user {
+			return true
+		}
+		if ok, err := path.Match(entry, user); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (u *Env) MarshalJSON() ([]byte, error) {
 	type alias Env
 
@@ -16,6 +16,7 @@ func TestNewUserEnv(t *testing.T) {
 
 	require.NotNil(t, actual)
 	assert.IsType(t, &Env{}, actual)
+	assert.Equal(t, 7, actual.WarningDays)
 }
 
 func TestPopulate(t *testing.T) {
@@ -132,6 +133,50 @@ func TestPopulate(t *testing.T) {
 			false,
 			``,
 		},
+		{
+			"using environment variables with expiration warning days set",
+			func() string {
+				t.Setenv("EXPIRATION_DATE", "2023-01-01")
+				t.Setenv("EXPIRATION_WARNING_DAYS", "14")
+				return ""
+			},
+			&Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), WarningDays: 14},
+			false,
+			``,
+		},
+		{
+			"using environment variables with invalid expiration warning days set",
+			func() string {
+				t.Setenv("EXPIRATION_DATE", "2023-01-01")
+				t.Setenv("EXPIRATION_WARNING_DAYS", "-1")
+				return ""
+			},
+			&Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			true,
+			`unable to convert environment variable: EXPIRATION_WARNING_DAYS`,
+		},
+		{
+			"using environment variables with expiration grace days set",
+			func() string {
+				t.Setenv("EXPIRATION_DATE", "2023-01-01")
+				t.Setenv("EXPIRATION_GRACE_DAYS", "3")
+				return ""
+			},
+			&Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), GraceDays: 3},
+			false,
+			``,
+		},
+		{
+			"using environment variables with invalid expiration grace days set",
+			func() string {
+				t.Setenv("EXPIRATION_DATE", "2023-01-01")
+				t.Setenv("EXPIRATION_GRACE_DAYS", "test")
+				return ""
+			},
+			&Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			true,
+			`unable to convert environment variable: EXPIRATION_GRACE_DAYS`,
+		},
 		{
 			"invalid configuration file",
 			func() string {
@@ -223,6 +268,178 @@ func TestIsExpired(t *testing.T) {
 	}
 }
 
+func TestIsExpiring(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       Env
+		expected    bool
+		days        int
+	}{
+		{
+			"expiration date outside of warning period",
+			Env{Expiration: time.Now().AddDate(0, 0, 30), WarningDays: 7},
+			false,
+			30,
+		},
+		{
+			"expiration date within warning period",
+			Env{Expiration: time.Now().AddDate(0, 0, 3), WarningDays: 7},
+			true,
+			3,
+		},
+		{
+			"expiration date within warning period with warning disabled",
+			Env{Expiration: time.Now().AddDate(0, 0, 3)},
+			false,
+			3,
+		},
+		{
+			"past expiration date",
+			Env{Expiration: time.Now().AddDate(0, 0, -1), WarningDays: 7},
+			false,
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.given.IsExpiring())
+			assert.Equal(t, tc.days, tc.given.DaysRemaining())
+		})
+	}
+}
+
+func TestInGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       Env
+		expected    bool
+		days        int
+	}{
+		{
+			"expiration date not reached",
+			Env{Expiration: time.Now().AddDate(0, 0, 3), GraceDays: 7},
+			false,
+			0,
+		},
+		{
+			"past expiration date within grace period",
+			Env{Expiration: time.Now().AddDate(0, 0, -2), GraceDays: 7},
+			true,
+			5,
+		},
+		{
+			"past expiration date after grace period",
+			Env{Expiration: time.Now().AddDate(0, 0, -10), GraceDays: 7},
+			false,
+			0,
+		},
+		{
+			"past expiration date with grace period disabled",
+			Env{Expiration: time.Now().AddDate(0, 0, -1)},
+			false,
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, tc.given.InGracePeriod())
+			assert.Equal(t, tc.days, tc.given.GraceDaysRemaining())
+		})
+	}
+}
+
+func TestIsAuthorized(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       Env
+		user        string
+		groups      []string
+		expected    bool
+	}{
+		{
+			"no users set",
+			Env{},
+			"test",
+			nil,
+			false,
+		},
+		{
+			"user on the list",
+			Env{Users: []string{"test"}},
+			"test",
+			nil,
+			true,
+		},
+		{
+			"user not on the list",
+			Env{Users: []string{"test"}},
+			"test2",
+			nil,
+			false,
+		},
+		{
+			"user matching wildcard entry",
+			Env{Users: []string{"team-*"}},
+			"team-test",
+			nil,
+			true,
+		},
+		{
+			"user not matching wildcard entry",
+			Env{Users: []string{"team-?"}},
+			"team-test",
+			nil,
+			false,
+		},
+		{
+			"user with group on the list",
+			Env{Users: []string{"group:sre"}},
+			"test",
+			[]string{"dev", "sre"},
+			true,
+		},
+		{
+			"user without group on the list",
+			Env{Users: []string{"group:sre"}},
+			"test",
+			[]string{"dev"},
+			false,
+		},
+		{
+			"user named like group entry",
+			Env{Users: []string{"group:sre"}},
+			"sre",
+			nil,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := tc.given.IsAuthorized(tc.user, tc.groups)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
 func TestMarshalJSON(t *testing.T) {
 	t.Parallel()
 
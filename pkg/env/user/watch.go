@@ -0,0 +1,77 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// Kubernetes updates mounted ConfigMaps by atomically swapping this symbolic link.
+	configMapDataLink = "..data"
+
+	// Wait for a burst of file system events to settle before reloading.
+	reloadDelay = 250 * time.Millisecond
+)
+
+// Watch reloads users configuration whenever the file set using the
+// CONFIG_FILE_PATH environment variable changes, passing either the newly
+// populated configuration or an error to the callback. The parent directory is
+// watched rather than the file itself so that updates to mounted ConfigMaps,
+// which replace the file, are not missed.
+func Watch(ctx context.Context, callback func(*Env, error)) error {
+	path := os.Getenv("CONFIG_FILE_PATH")
+	if path == "" {
+		return nil
+	}
+	path = filepath.Clean(path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create users file watcher: %w", err)
+	}
+
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("unable to watch users file: %w", err)
+	}
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+
+		var reload <-chan time.Time
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reload:
+				reload = nil
+				u := NewUserEnv()
+				callback(u, u.Populate())
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Chmod) || event.Has(fsnotify.Remove) {
+					continue
+				}
+				if event.Name != path && filepath.Base(event.Name) != configMapDataLink {
+					continue
+				}
+				reload = time.After(reloadDelay)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				callback(nil, fmt.Errorf("unable to watch users file: %w", err))
+			}
+		}
+	}()
+
+	return nil
+}
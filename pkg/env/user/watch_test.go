@@ -0,0 +1,91 @@
+package user
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	cases := []struct {
+		description string
+		given       string
+		expected    *Env
+		error       bool
+		want        string
+	}{
+		{
+			"configuration file updated with valid content",
+			`{"expiration":"2023-01-02", "users":["test2"]}`,
+			&Env{Expiration: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), Users: []string{"test2"}, WarningDays: 7},
+			false,
+			``,
+		},
+		{
+			"configuration file updated with invalid content",
+			`{"expiration":"2023-01-02", "users:["test2"]}`,
+			nil,
+			true,
+			`unable to unmarshal users file`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+
+			err := os.WriteFile(path, []byte(`{"expiration":"2023-01-01", "users":["test"]}`), 0o600)
+			require.NoError(t, err)
+
+			t.Setenv("CONFIG_FILE_PATH", path)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			type result struct {
+				env *Env
+				err error
+			}
+			results := make(chan result, 8)
+
+			err = Watch(ctx, func(u *Env, err error) {
+				results <- result{u, err}
+			})
+			require.NoError(t, err)
+
+			err = os.WriteFile(path, []byte(tc.given), 0o600)
+			require.NoError(t, err)
+
+			var actual result
+			select {
+			case actual = <-results:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for reload")
+			}
+
+			if tc.error {
+				require.Error(t, actual.err)
+				assert.Contains(t, actual.err.Error(), tc.want)
+			} else {
+				require.NoError(t, actual.err)
+				assert.Equal(t, tc.expected, actual.env)
+			}
+		})
+	}
+}
+
+func TestWatchWithoutConfigurationFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE_PATH", "")
+
+	err := Watch(context.Background(), func(u *Env, err error) {
+		t.Fatal("unexpected reload")
+	})
+
+	require.NoError(t, err)
+}
@@ -3,24 +3,92 @@ package gabi
 import (
 	"database/sql"
 	"encoding/base64"
-	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/cache"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	cacheenv "github.com/app-sre/gabi/pkg/env/cache"
+	"github.com/app-sre/gabi/pkg/env/cors"
 	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/maintenance"
+	"github.com/app-sre/gabi/pkg/env/policy"
+	"github.com/app-sre/gabi/pkg/env/proxy"
+	"github.com/app-sre/gabi/pkg/env/server"
+	"github.com/app-sre/gabi/pkg/env/splunk"
 	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/app-sre/gabi/pkg/health"
+	"github.com/app-sre/gabi/pkg/metrics"
 	"go.uber.org/zap"
 )
 
 type Config struct {
-	DB          *sql.DB
-	DBEnv       *db.Env
-	UserEnv     *user.Env
-	LoggerAudit audit.Audit
-	SplunkAudit audit.Audit
-	Logger      *zap.SugaredLogger
-	Encoder     *base64.Encoding
+	DB             *sql.DB
+	Databases      map[string]*sql.DB
+	DBEnv          *db.Env
+	UserEnv        *user.Env
+	AuthEnv        *auth.Env
+	PolicyEnv      *policy.Env
+	LimitsEnv      *limits.Env
+	SplunkEnv      *splunk.Env
+	AuditingEnv    *auditing.Env
+	CORSEnv        *cors.Env
+	ProxyEnv       *proxy.Env
+	ServerEnv      *server.Env
+	CacheEnv       *cacheenv.Env
+	MaintenanceEnv *maintenance.Env
+	LoggerAudit    audit.Audit
+	SplunkAudit    audit.Audit
+	Cache          *cache.Cache
+	Pinger         *health.Pinger
+	Connector      *health.Connector
+	Watchdog       *health.Watchdog
+	Metrics        *metrics.Metrics
+	Logger         *zap.SugaredLogger
+	Encoder        *base64.Encoding
+	Clock          audit.Clock
+	Production     bool
+
+	mu       sync.RWMutex
+	draining atomic.Bool
+}
+
+func (c *Config) CurrentUserEnv() *user.Env {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.UserEnv
+}
+
+func (c *Config) SetUserEnv(u *user.Env) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.UserEnv = u
+}
+
+// Database returns the database selected by the client, being the configured
+// one when none is, together with whether the selected one is allowed.
+func (c *Config) Database(name string) (*sql.DB, bool) {
+	if name == "" || c.DBEnv != nil && name == c.DBEnv.Name {
+		return c.DB, true
+	}
+	if c.DBEnv == nil || !c.DBEnv.IsAllowedDatabase(name) {
+		return nil, false
+	}
+	database, ok := c.Databases[name]
+	return database, ok
+}
+
+// Draining reports whether the service is shutting down and no longer
+// accepts new requests.
+func (c *Config) Draining() bool {
+	return c.draining.Load()
 }
 
-func Production() bool {
-	return os.Getenv("ENVIRONMENT") == "production"
+func (c *Config) SetDraining(draining bool) {
+	c.draining.Store(draining)
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+// AuditPreview returns the audit event for the query given, together with the
+// context it would be submitted in, encoded exactly as the audit backend would
+// send it, without running the query, nor sending the event.
+func AuditPreview(cfg *gabi.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request models.AuditPreviewRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			if middleware.IsRequestTooLarge(err) {
+				middleware.RequestTooLarge(cfg, w, r)
+				return
+			}
+			if middleware.IsMalformedBody(err) {
+				middleware.MalformedBody(cfg, w, r, err)
+				return
+			}
+			cfg.Logger.Errorf("Unable to decode request body: %s", err)
+			if errors.Is(err, io.EOF) {
+				http.Error(w, "Request body cannot be empty", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+			return
+		}
+
+		if request.Query == "" {
+			http.Error(w, "Query cannot be empty", http.StatusBadRequest)
+			return
+		}
+		if middleware.QueryTooLarge(cfg, w, r, request.Query) {
+			return
+		}
+
+		var fields, names map[string]string
+		if cfg.AuditingEnv != nil {
+			fields, names = cfg.AuditingEnv.Fields, cfg.AuditingEnv.FieldNames
+		}
+		if err := audit.ValidateTags(request.Tags, fields, names); err != nil {
+			l := "Invalid query tags"
+			cfg.Logger.Errorf("%s: %s", l, err)
+			http.Error(w, fmt.Sprintf("%s: %s", l, err), http.StatusBadRequest)
+			return
+		}
+
+		content, err := middleware.PreviewAudit(cfg, r, &request)
+		if err != nil {
+			cfg.Logger.Errorf("Unable to encode audit: %s", err)
+			http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write(append(content, '\n'))
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditPreview(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		body        string
+		code        int
+		want        []string
+		unwanted    []string
+	}{
+		{
+			"query previewed as sent to Splunk",
+			`{"query": "select * from tokens where token = 'secret';", "args": [1], "tags": {"ticket": "OPS-1"}}`,
+			200,
+			[]string{
+				`"index":"audit","host":"gabi.example.com","source":"gabi","sourcetype":"json","time":1672531200}`,
+				`"query":"select * from tokens where token = ?;"`,
+				`"username":"test"`,
+				`"namespace":"test"`,
+				`"args":["REDACTED"]`,
+				`"team":"sre"`,
+				`"ticket":"OPS-1"`,
+			},
+			[]string{`secret`, `"success"`},
+		},
+		{
+			"mock context used in place of the request",
+			`{"query": "select 1;", "user": "mock", "executed": true, "error": "relation does not exist\nDETAIL: secret"}`,
+			200,
+			[]string{`"username":"mock"`, `"success":false`, `"error":"relation does not exist"`},
+			[]string{`secret`},
+		},
+		{
+			"rejection previewed",
+			`{"query": "select 1;", "rejection": "Query is not permitted by policy", "executed": true}`,
+			200,
+			[]string{`"rejection":"Query is not permitted by policy"`},
+			[]string{`"success"`},
+		},
+		{
+			"empty query",
+			`{"query": ""}`,
+			400,
+			[]string{`Query cannot be empty`},
+			nil,
+		},
+		{
+			"invalid tags",
+			`{"query": "select 1;", "tags": {"user": "admin"}}`,
+			400,
+			[]string{`Invalid query tags`},
+			nil,
+		},
+		{
+			"invalid request body",
+			`{"query": 1}`,
+			400,
+			[]string{`Unable to decode request body`},
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			sa, err := audit.NewSplunkAudit(&splunk.Env{
+				Endpoint:  "https://splunk.example.com",
+				Index:     "audit",
+				Host:      "gabi.example.com",
+				Namespace: "test",
+			}, audit.WithFieldNames(map[string]string{"user": "username"}))
+			require.NoError(t, err)
+
+			var sent bytes.Buffer
+			la := audit.NewStreamAudit(&sent, "", "", nil)
+
+			cfg := &gabi.Config{
+				DBEnv: &db.Env{Driver: "pgx"},
+				AuditingEnv: &auditing.Env{
+					RedactLiterals: true,
+					Fields:         map[string]string{"team": "sre"},
+					FieldNames:     map[string]string{"user": "username"},
+				},
+				LoggerAudit: la,
+				SplunkAudit: sa,
+				Clock:       audit.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+				Logger:      logger,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/audit/preview", strings.NewReader(tc.body))
+			r = r.WithContext(middleware.WithUser(r.Context(), "test"))
+
+			AuditPreview(cfg).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			for _, s := range tc.want {
+				assert.Contains(t, w.Body.String(), s)
+			}
+			for _, s := range tc.unwanted {
+				assert.NotContains(t, w.Body.String(), s)
+			}
+			// Nothing is audited, as the query is never run.
+			assert.Empty(t, sent.String())
+		})
+	}
+}
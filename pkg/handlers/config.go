@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+const (
+	auditBackendSplunk = "splunk"
+
+	redactedValue = "REDACTED"
+)
+
+// Config returns the effective configuration of the running instance, with
+// any secrets redacted.
+func Config(cfg *gabi.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := &models.ConfigResponse{
+			Production: cfg.Production,
+		}
+
+		if dbe := cfg.DBEnv; dbe != nil {
+			response.Database = models.DatabaseConfig{
+				Driver:     dbe.Driver.String(),
+				Host:       dbe.Host,
+				Port:       dbe.Port,
+				Name:       dbe.Name,
+				Username:   dbe.Username,
+				Password:   redact(dbe.Password),
+				AllowWrite: dbe.AllowWrite,
+				Databases:  dbe.Databases,
+			}
+		}
+
+		if se := cfg.SplunkEnv; se != nil {
+			response.Audit = models.AuditConfig{
+				Backend:     auditBackendSplunk,
+				Endpoint:    redactURL(se.Endpoint),
+				Token:       redact(se.Token),
+				Index:       se.Index,
+				IndexMap:    se.IndexMap,
+				HealthCheck: se.HealthCheck,
+			}
+			for _, e := range se.Endpoints {
+				response.Audit.Endpoints = append(response.Audit.Endpoints, redactURL(e))
+			}
+		} else if ae := cfg.AuditingEnv; ae != nil {
+			response.Audit = models.AuditConfig{Backend: ae.Backend}
+		}
+
+		if usere := cfg.CurrentUserEnv(); usere != nil {
+			response.Users = models.UsersConfig{
+				Expiration:  usere.Expiration.Format(user.ExpiryDateLayout),
+				Expired:     usere.IsExpired(),
+				WarningDays: usere.WarningDays,
+				GraceDays:   usere.GraceDays,
+				Users:       usere.Users,
+			}
+		}
+
+		if pe := cfg.PolicyEnv; pe != nil {
+			allow, deny := pe.Patterns()
+			response.Policy = models.PolicyConfig{
+				Allow:       allow,
+				Deny:        deny,
+				Mask:        pe.Mask,
+				Statements:  pe.Statements,
+				LargeTables: pe.LargeTables,
+			}
+		}
+
+		if le := cfg.LimitsEnv; le != nil {
+			response.Limits = models.LimitsConfig{
+				RatePerMinute: le.RatePerMinute,
+				RateBurst:     le.RateBurst,
+			}
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redactedValue
+}
+
+// Endpoints can carry credentials as part of the URL.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return redactedValue
+	}
+	if u.User != nil {
+		u.User = url.User(redactedValue)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redactedValue
+	}
+	return u.String()
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+const encodeBufferSize = 32 * 1024
+
+// encodeWriter is implemented by both the buffered writer of the response, and
+// the buffer rows are kept in until the response is started.
+type encodeWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// encodeQueryResponse writes the response exactly as encoding/json would, but
+// writes the results, which can be large, directly instead of relying on
+// reflection for every value. Values are those returned by the query handler:
+// nil, strings and numbers, with anything else left to encoding/json.
+func encodeQueryResponse(w io.Writer, response *models.QueryResponse) error {
+	// The remaining attributes are few, and are thus encoded as usual.
+	r := *response
+	r.Result = nil
+	rest, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+	return encodeRows(w, "result", response.Result, rest)
+}
+
+// encodeQueryResponseV2 is the same as encodeQueryResponse, for the second
+// version of the response envelope.
+func encodeQueryResponseV2(w io.Writer, response *models.QueryResponseV2) error {
+	r := *response
+	r.Rows = nil
+	rest, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+	return encodeRows(w, "rows", response.Rows, rest)
+}
+
+// encodeRows writes the rows in place of the first attribute of the response
+// encoded without them, which is what encoding/json writes for no rows.
+func encodeRows(w io.Writer, name string, rows [][]interface{}, rest []byte) error {
+	// Write errors are kept by the buffered writer, and returned on Flush.
+	b := bufio.NewWriterSize(w, encodeBufferSize)
+
+	if rows == nil {
+		b.Write(rest)
+	} else {
+		b.WriteString(`{"` + name + `":[`)
+		for i, row := range rows {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encodeRow(b, row); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		b.Write(bytes.TrimPrefix(rest, []byte(`{"`+name+`":null`)))
+	}
+	b.WriteByte('\n')
+
+	return b.Flush()
+}
+
+// resultStream is implemented by the writers sending rows as these are read,
+// rather than once all of them have been.
+type resultStream interface {
+	Started() bool
+	Rows() int
+	WriteRow(row []interface{}) error
+	WriteError(err error)
+	Close(truncated bool) error
+}
+
+// jsonWriter writes rows in the JSON response as these are read from the
+// database, once these no longer fit in the buffer. Until then, rows are only
+// kept, thus errors that happen before are answered as usual, while errors
+// that happen after are written as partial results, as the status code has
+// been sent already. The row count, and whether rows were left out, are then
+// sent as trailers, as for NDJSON.
+type jsonWriter struct {
+	w       http.ResponseWriter
+	b       *bufio.Writer
+	pending bytes.Buffer
+	version int
+	names   []interface{}
+	rows    int
+
+	response models.QueryResponse
+}
+
+func newJSONWriter(w http.ResponseWriter, version int, names []interface{}, columns []models.Column, warning string) *jsonWriter {
+	j := &jsonWriter{
+		w:       w,
+		version: version,
+		names:   names,
+		response: models.QueryResponse{
+			Columns: columns,
+			Warning: warning,
+		},
+	}
+	// The column names are the first row of the first version only.
+	if version != middleware.APIVersion2 {
+		_ = encodeRow(&j.pending, names)
+	}
+	return j
+}
+
+// Started reports whether any part of the response has been sent.
+func (j *jsonWriter) Started() bool {
+	return j != nil && j.b != nil
+}
+
+// Rows returns the number of rows written, not including the column names.
+func (j *jsonWriter) Rows() int {
+	return j.rows
+}
+
+// WriteRow encodes the row as a whole before writing it, so that no part of a
+// row that cannot be encoded is sent.
+func (j *jsonWriter) WriteRow(row []interface{}) error {
+	n := j.pending.Len()
+	if j.rows > 0 || j.version != middleware.APIVersion2 {
+		j.pending.WriteByte(',')
+	}
+	if err := encodeRow(&j.pending, row); err != nil {
+		j.pending.Truncate(n)
+		return err
+	}
+	j.rows++
+
+	switch {
+	case j.b == nil && j.pending.Len() > encodeBufferSize:
+		j.start()
+	case j.b != nil:
+		j.b.Write(j.pending.Bytes())
+		j.pending.Reset()
+		if j.rows%ndjsonFlushRows == 0 {
+			j.flush()
+		}
+	}
+	return nil
+}
+
+func (j *jsonWriter) WriteError(err error) {
+	j.response.Error = err.Error()
+
+	var partial *audit.PartialError
+	if errors.As(err, &partial) {
+		j.response.Partial = &models.PartialResult{Row: partial.Row, Error: partial.Err.Error()}
+	}
+}
+
+// Close writes the remaining attributes following the rows, which are written
+// in place of the rows encoding/json would write for none.
+func (j *jsonWriter) Close(truncated bool) error {
+	j.start()
+
+	r := j.response
+	r.Result = [][]interface{}{j.names}
+	r.Truncated = truncated
+
+	var (
+		rest []byte
+		err  error
+	)
+	if j.version == middleware.APIVersion2 {
+		v := queryResponseV2(&r)
+		v.Rows = nil
+		rest, err = json.Marshal(v)
+		rest = bytes.TrimPrefix(rest, []byte(`{"rows":null`))
+	} else {
+		r.Result = nil
+		rest, err = json.Marshal(&r)
+		rest = bytes.TrimPrefix(rest, []byte(`{"result":null`))
+	}
+	if err != nil {
+		return err
+	}
+
+	j.b.WriteByte(']')
+	j.b.Write(rest)
+	j.b.WriteByte('\n')
+	j.flush()
+	if err := j.b.Flush(); err != nil {
+		return err
+	}
+	resultHeaders(j.w.Header(), j.rows, truncated)
+	return nil
+}
+
+func (j *jsonWriter) start() {
+	if j.b != nil {
+		return
+	}
+	name := "result"
+	j.w.Header().Set("Cache-Control", "private, no-store")
+	j.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if j.version == middleware.APIVersion2 {
+		name = "rows"
+		j.w.Header().Set("Content-Type", contentTypeV2)
+	}
+	j.w.Header().Set("Trailer", middleware.RowCountHeader+", "+middleware.TruncatedHeader)
+	j.w.WriteHeader(http.StatusOK)
+
+	j.b = bufio.NewWriterSize(j.w, encodeBufferSize)
+	j.b.WriteString(`{"` + name + `":[`)
+	j.b.Write(j.pending.Bytes())
+	j.pending.Reset()
+}
+
+func (j *jsonWriter) flush() {
+	if err := j.b.Flush(); err != nil {
+		return
+	}
+	if f, ok := j.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func encodeRow(b encodeWriter, row []interface{}) error {
+	if row == nil {
+		b.WriteString("null")
+		return nil
+	}
+
+	b.WriteByte('[')
+	for i, value := range row {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := encodeValue(b, value); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(']')
+
+	return nil
+}
+
+// encodeObject writes the row as an object keyed by the names of its columns,
+// which are unique, in the order of the columns.
+func encodeObject(b encodeWriter, names []string, row []interface{}) error {
+	b.WriteByte('{')
+	for i, value := range row {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		encodeString(b, names[i])
+		b.WriteByte(':')
+		if err := encodeValue(b, value); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+
+	return nil
+}
+
+func encodeValue(b encodeWriter, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case string:
+		encodeString(b, v)
+	case json.Number:
+		if v == "" {
+			v = "0"
+		}
+		b.WriteString(string(v))
+	default:
+		content, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		b.Write(content)
+	}
+	return nil
+}
+
+// Strings that need escaping beyond quotes and backslashes, such as those with
+// control or HTML characters, or invalid UTF-8, are rare, and are left to
+// encoding/json, which escapes them in its own particular way.
+func encodeString(b encodeWriter, s string) {
+	if !simpleString(s) {
+		content, _ := json.Marshal(s)
+		b.Write(content)
+		return
+	}
+
+	b.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c == '"' || c == '\\' {
+			b.WriteString(s[start:i])
+			b.WriteByte('\\')
+			start = i
+		}
+	}
+	b.WriteString(s[start:])
+	b.WriteByte('"')
+}
+
+func simpleString(s string) bool {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c == '<' || c == '>' || c == '&' {
+				return false
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 || r == '\u2028' || r == '\u2029' {
+			return false
+		}
+		i += size
+	}
+	return true
+}
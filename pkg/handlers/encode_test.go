@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+func TestEncodeQueryResponse(t *testing.T) {
+	t.Parallel()
+
+	nullable := true
+
+	cases := []struct {
+		description string
+		given       *models.QueryResponse
+		expected    string
+	}{
+		{
+			"results",
+			&models.QueryResponse{Result: [][]interface{}{{"id", "name"}, {"1", "test"}, {"2", nil}}},
+			`{"result":[["id","name"],["1","test"],["2",null]],"error":""}` + "\n",
+		},
+		{
+			"results with numbers, columns and warning",
+			&models.QueryResponse{
+				Result:  [][]interface{}{{"id"}, {json.Number("1.5e3")}, {json.Number("")}},
+				Warning: "test",
+				Columns: []models.Column{{Name: "id", Type: "INT4", Nullable: &nullable}},
+			},
+			`{"result":[["id"],[1.5e3],[0]],"error":"","warning":"test","columns":[{"name":"id","type":"INT4","nullable":true}]}` + "\n",
+		},
+		{
+			"results with strings that need escaping",
+			&models.QueryResponse{Result: [][]interface{}{{"a\"b\\c", "<a href='x'>&</a>", "line\nbreak\ttab\r\b\f\x00", "\u2028\u2029", "café \U0001F600", "\xff\xfe"}}},
+			``,
+		},
+		{
+			"partial results",
+			&models.QueryResponse{Result: [][]interface{}{{"id"}, {"1"}}, Error: "test", Partial: &models.PartialResult{Row: 1, Error: "test"}},
+			`{"result":[["id"],["1"]],"error":"test","partial":{"row":1,"error":"test"}}` + "\n",
+		},
+		{
+			"statement without results",
+			&models.QueryResponse{Result: [][]interface{}{}, Status: &models.StatementStatus{Message: "test"}},
+			`{"result":[],"error":"","status":{"message":"test"}}` + "\n",
+		},
+		{
+			"results with missing row",
+			&models.QueryResponse{Result: [][]interface{}{nil}},
+			`{"result":[null],"error":""}` + "\n",
+		},
+		{
+			"error without results",
+			&models.QueryResponse{Error: "test"},
+			`{"result":null,"error":"test"}` + "\n",
+		},
+		{
+			"results with other types",
+			&models.QueryResponse{Result: [][]interface{}{{true, 1, 1.5}}},
+			`{"result":[[true,1,1.5]],"error":""}` + "\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var actual, expected bytes.Buffer
+
+			require.NoError(t, encodeQueryResponse(&actual, tc.given))
+			require.NoError(t, json.NewEncoder(&expected).Encode(tc.given))
+
+			// The output must match encoding/json byte for byte.
+			assert.Equal(t, expected.String(), actual.String())
+			if tc.expected != "" {
+				assert.Equal(t, tc.expected, actual.String())
+			}
+		})
+	}
+}
+
+func TestEncodeQueryResponseV2(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *models.QueryResponseV2
+		expected    string
+	}{
+		{
+			"rows and columns",
+			&models.QueryResponseV2{
+				Rows:    [][]interface{}{{"1", "test"}, {json.Number("2"), nil}},
+				Columns: []models.Column{{Name: "id", Type: "INT4"}, {Name: "name", Type: "TEXT"}},
+				Warning: "test",
+			},
+			`{"rows":[["1","test"],[2,null]],"columns":[{"name":"id","type":"INT4"},{"name":"name","type":"TEXT"}],"warning":"test"}` + "\n",
+		},
+		{
+			"statement without results",
+			&models.QueryResponseV2{Rows: [][]interface{}{}, Columns: []models.Column{}, Status: &models.StatementStatus{Message: "test"}},
+			`{"rows":[],"columns":[],"status":{"message":"test"}}` + "\n",
+		},
+		{
+			"error without rows",
+			&models.QueryResponseV2{Error: "test"},
+			`{"rows":null,"columns":null,"error":"test"}` + "\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var actual, expected bytes.Buffer
+
+			require.NoError(t, encodeQueryResponseV2(&actual, tc.given))
+			require.NoError(t, json.NewEncoder(&expected).Encode(tc.given))
+
+			assert.Equal(t, expected.String(), actual.String())
+			assert.Equal(t, tc.expected, actual.String())
+		})
+	}
+}
+
+func benchmarkResponse(columns, rows int) *models.QueryResponse {
+	result := make([][]interface{}, 0, rows+1)
+
+	names := make([]interface{}, columns)
+	for i := range names {
+		names[i] = fmt.Sprintf("column_%d", i)
+	}
+	result = append(result, names)
+
+	for i := 0; i < rows; i++ {
+		row := make([]interface{}, columns)
+		for j := range row {
+			switch j % 3 {
+			case 0:
+				row[j] = fmt.Sprintf("value %d-%d", i, j)
+			case 1:
+				row[j] = json.Number(fmt.Sprint(i * j))
+			default:
+				row[j] = nil
+			}
+		}
+		result = append(result, row)
+	}
+
+	return &models.QueryResponse{Result: result}
+}
+
+func BenchmarkEncodeQueryResponse(b *testing.B) {
+	response := benchmarkResponse(50, 100000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = encodeQueryResponse(io.Discard, response)
+	}
+}
+
+func BenchmarkEncodeQueryResponseJSON(b *testing.B) {
+	response := benchmarkResponse(50, 100000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_ = json.NewEncoder(io.Discard).Encode(response)
+	}
+}
@@ -3,15 +3,22 @@ package handlers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/etherlabsio/healthcheck/v2"
 
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/user"
 )
 
-const healthcheckTimeout = 5 * time.Second
+const (
+	healthcheckTimeout = 5 * time.Second
+	readinessTimeout   = 2 * time.Second
+)
 
 func Healthcheck(cfg *gabi.Config) http.Handler {
 	return healthcheck.Handler(
@@ -29,5 +36,133 @@ func Healthcheck(cfg *gabi.Config) http.Handler {
 				},
 			),
 		),
+		healthcheck.WithObserver(
+			"expiration", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					usere := cfg.CurrentUserEnv()
+					date := usere.Expiration.Format(user.ExpiryDateLayout)
+					if usere.InGracePeriod() {
+						return fmt.Errorf("The service instance has expired, and stops serving queries in %d day(s) (expiration date: %s)",
+							usere.GraceDaysRemaining(), date,
+						)
+					}
+					if usere.IsExpired() {
+						return fmt.Errorf("The service instance has expired (expiration date: %s)", date)
+					}
+					if usere.IsExpiring() {
+						return fmt.Errorf("The service instance expires in %d day(s) (expiration date: %s)",
+							usere.DaysRemaining(), date,
+						)
+					}
+					return nil
+				},
+			),
+		),
+	)
+}
+
+// Liveness confirms that the process is up and able to serve requests, and,
+// when tracked, that request handling has not stalled, such as when every
+// handler is blocked.
+func Liveness(cfg *gabi.Config) http.Handler {
+	if cfg.Watchdog == nil {
+		return healthcheck.Handler()
+	}
+	return healthcheck.Handler(
+		healthcheck.WithChecker(
+			"requests", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					if stalled, inFlight, since := cfg.Watchdog.Stalled(); stalled {
+						l := "Request handling has stalled"
+						cfg.Logger.Errorf("%s: no request completed in %s (in flight: %d)", l, since.Round(time.Second), inFlight)
+						return errors.New(l)
+					}
+					return nil
+				},
+			),
+		),
 	)
 }
+
+// Readiness confirms that the dependencies required to serve queries are
+// available, optionally including the audit backend.
+func Readiness(cfg *gabi.Config) http.Handler {
+	options := []healthcheck.Option{
+		healthcheck.WithTimeout(readinessTimeout),
+		healthcheck.WithChecker(
+			"server", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					if cfg.Draining() {
+						return errors.New("The service is shutting down")
+					}
+					return nil
+				},
+			),
+		),
+		healthcheck.WithChecker(
+			"database", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					l := "Unable to connect to the database"
+					if cfg.Connector != nil && !cfg.Connector.Connected() {
+						return errors.New(l)
+					}
+					// An outage detected in the background fails readiness straight away.
+					if cfg.Pinger != nil && !cfg.Pinger.Healthy() {
+						return errors.New(l)
+					}
+					err := cfg.DB.PingContext(ctx)
+					if err != nil {
+						cfg.Logger.Errorf("%s: %s", l, err)
+						return errors.New(l)
+					}
+					return nil
+				},
+			),
+		),
+	}
+
+	// Databases selected by clients have pools of their own, which are pinged
+	// in turn, while the connector and the background pinger, when enabled,
+	// only ever watch the default database.
+	if len(cfg.Databases) > 0 {
+		names := make([]string, 0, len(cfg.Databases))
+		for name := range cfg.Databases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		options = append(options, healthcheck.WithChecker(
+			"databases", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					for _, name := range names {
+						err := cfg.Databases[name].PingContext(ctx)
+						if err != nil {
+							l := fmt.Sprintf("Unable to connect to the database: %s", name)
+							cfg.Logger.Errorf("%s: %s", l, err)
+							return errors.New(l)
+						}
+					}
+					return nil
+				},
+			),
+		))
+	}
+
+	if c, ok := cfg.SplunkAudit.(audit.Checker); ok && cfg.SplunkEnv != nil && cfg.SplunkEnv.HealthCheck {
+		options = append(options, healthcheck.WithChecker(
+			"audit", healthcheck.CheckerFunc(
+				func(ctx context.Context) error {
+					err := c.Check(ctx)
+					if err != nil {
+						l := "Unable to connect to the audit backend"
+						cfg.Logger.Errorf("%s: %s", l, err)
+						return errors.New(l)
+					}
+					return nil
+				},
+			),
+		))
+	}
+
+	return healthcheck.Handler(options...)
+}
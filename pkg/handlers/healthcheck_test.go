@@ -2,15 +2,23 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/app-sre/gabi/internal/test"
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/app-sre/gabi/pkg/health"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -21,6 +29,7 @@ func TestHealthcheck(t *testing.T) {
 	cases := []struct {
 		description string
 		given       func(sqlmock.Sqlmock)
+		user        *user.Env
 		code        int
 		body        string
 	}{
@@ -29,6 +38,7 @@ func TestHealthcheck(t *testing.T) {
 			func(mock sqlmock.Sqlmock) {
 				mock.ExpectPing()
 			},
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 30), WarningDays: 7},
 			200,
 			`{"status":"OK"}`,
 		},
@@ -37,9 +47,28 @@ func TestHealthcheck(t *testing.T) {
 			func(mock sqlmock.Sqlmock) {
 				mock.ExpectPing().WillReturnError(errors.New("test"))
 			},
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 30), WarningDays: 7},
 			503,
 			`{"database":"Unable to connect to the database"}`,
 		},
+		{
+			"database is accessible and instance has expired",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			&user.Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), WarningDays: 7},
+			200,
+			`{"status":"OK","errors":{"expiration":"The service instance has expired (expiration date: 2023-01-01)"}}`,
+		},
+		{
+			"database is accessible and instance is about to expire",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 3), WarningDays: 7},
+			200,
+			`{"status":"OK","errors":{"expiration":"The service instance expires in 3 day(s)`,
+		},
 	}
 
 	for _, tc := range cases {
@@ -59,7 +88,7 @@ func TestHealthcheck(t *testing.T) {
 
 			tc.given(mock)
 
-			expected := &gabi.Config{DB: db, Logger: logger}
+			expected := &gabi.Config{DB: db, UserEnv: tc.user, Logger: logger}
 			Healthcheck(expected).ServeHTTP(w, r)
 
 			actual := w.Result()
@@ -75,3 +104,291 @@ func TestHealthcheck(t *testing.T) {
 		})
 	}
 }
+
+func TestLiveness(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+	expected := &gabi.Config{Logger: test.DummyLogger(io.Discard).Sugar()}
+	Liveness(expected).ServeHTTP(w, r)
+
+	actual := w.Result()
+	defer func() { _ = actual.Body.Close() }()
+
+	body, _ := io.ReadAll(actual.Body)
+
+	assert.Equal(t, 200, actual.StatusCode)
+	assert.Contains(t, string(body), `{"status":"OK"}`)
+}
+
+func TestLivenessWatchdog(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		elapsed     time.Duration
+		code        int
+		body        string
+	}{
+		{
+			"request handling making progress",
+			30 * time.Second,
+			200,
+			`{"status":"OK"}`,
+		},
+		{
+			"request handling stalled",
+			2 * time.Minute,
+			503,
+			`"requests":"Request handling has stalled"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			watchdog := health.NewWatchdog(time.Minute, health.WithWatchdogClock(func() time.Time { return now }))
+			watchdog.Started()
+			now = now.Add(tc.elapsed)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+			expected := &gabi.Config{Watchdog: watchdog, Logger: test.DummyLogger(&output).Sugar()}
+			Liveness(expected).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			body, _ := io.ReadAll(actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, string(body), tc.body)
+			if tc.code != 200 {
+				assert.Contains(t, output.String(), `Request handling has stalled: no request completed in 2m0s (in flight: 1)`)
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       func(sqlmock.Sqlmock)
+		handler     func(w http.ResponseWriter, r *http.Request)
+		check       bool
+		draining    bool
+		code        int
+		body        string
+	}{
+		{
+			"database is accessible and audit backend is not checked",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			false,
+			false,
+			200,
+			`{"status":"OK"}`,
+		},
+		{
+			"database is accessible and audit backend is reachable",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+			},
+			true,
+			false,
+			200,
+			`{"status":"OK"}`,
+		},
+		{
+			"database is not accessible",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing().WillReturnError(errors.New("test"))
+			},
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+			},
+			true,
+			false,
+			503,
+			`{"database":"Unable to connect to the database"}`,
+		},
+		{
+			"database is accessible and audit backend is not reachable",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			true,
+			false,
+			503,
+			`{"audit":"Unable to connect to the audit backend"}`,
+		},
+		{
+			"database is accessible and service is shutting down",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectPing()
+			},
+			func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"text":"HEC is healthy","code":17}`)
+			},
+			true,
+			true,
+			503,
+			`{"server":"The service is shutting down"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body bytes.Buffer
+
+			db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			defer func() { _ = db.Close() }()
+
+			server := httptest.NewServer(http.HandlerFunc(tc.handler))
+			defer server.Close()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			tc.given(mock)
+
+			se := &splunk.Env{Endpoint: server.URL, HealthCheck: tc.check}
+			sa, err := audit.NewSplunkAudit(se)
+			require.NoError(t, err)
+
+			expected := &gabi.Config{DB: db, SplunkEnv: se, SplunkAudit: sa, Logger: logger}
+			expected.SetDraining(tc.draining)
+			Readiness(expected).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			err = mock.ExpectationsWereMet()
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+		})
+	}
+}
+
+func TestReadinessPinger(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+
+	// Only the background ping reaches the database.
+	mock.ExpectPing().WillReturnError(errors.New("test"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	pinger := health.NewPinger(db, time.Minute)
+	require.Error(t, pinger.Ping(context.TODO()))
+
+	expected := &gabi.Config{DB: db, SplunkEnv: &splunk.Env{}, Pinger: pinger, Logger: logger}
+	Readiness(expected).ServeHTTP(w, r)
+
+	actual := w.Result()
+	defer func() { _ = actual.Body.Close() }()
+
+	_, _ = io.Copy(&body, actual.Body)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusServiceUnavailable, actual.StatusCode)
+	assert.Contains(t, body.String(), `{"database":"Unable to connect to the database"}`)
+}
+
+func TestReadinessConnector(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	// The database is not pinged until the startup connection succeeds.
+	connector := health.NewConnector(db, time.Minute)
+
+	expected := &gabi.Config{DB: db, SplunkEnv: &splunk.Env{}, Connector: connector, Logger: logger}
+	Readiness(expected).ServeHTTP(w, r)
+
+	actual := w.Result()
+	defer func() { _ = actual.Body.Close() }()
+
+	_, _ = io.Copy(&body, actual.Body)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusServiceUnavailable, actual.StatusCode)
+	assert.Contains(t, body.String(), `{"database":"Unable to connect to the database"}`)
+}
+
+func TestReadinessDatabases(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+	reports, reportsMock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = reports.Close() }()
+
+	// Every database that can be selected is pinged next to the default one.
+	mock.ExpectPing()
+	reportsMock.ExpectPing().WillReturnError(errors.New("test"))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	expected := &gabi.Config{DB: db, Databases: map[string]*sql.DB{"reports": reports}, SplunkEnv: &splunk.Env{}, Logger: logger}
+	Readiness(expected).ServeHTTP(w, r)
+
+	actual := w.Result()
+	defer func() { _ = actual.Body.Close() }()
+
+	_, _ = io.Copy(&body, actual.Body)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	require.NoError(t, reportsMock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusServiceUnavailable, actual.StatusCode)
+	assert.Contains(t, body.String(), `{"databases":"Unable to connect to the database: reports"}`)
+}
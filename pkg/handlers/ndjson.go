@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+
+	// Rows are flushed to the client in batches, rather than one at a time.
+	ndjsonFlushRows = 100
+)
+
+// acceptsNDJSON reports whether the client asked for newline-delimited JSON
+// using the Accept header, which is otherwise ignored. The JSON array remains
+// the default, thus wildcards do not select it.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, s := range strings.Split(value, ",") {
+			media, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+			if err != nil || media != ndjsonContentType {
+				continue
+			}
+			if q, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(q, 64); err != nil || f <= 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// ndjsonWriter writes rows as JSON objects keyed by column name, one per line,
+// as these are read from the database. The response is only started with the
+// first line, thus errors that happen before are answered as usual, while
+// errors that happen after are written as a final line with only the error.
+// The row count, and whether rows were left out, are sent as trailers, as
+// these are only known once all rows have been sent.
+type ndjsonWriter struct {
+	w     http.ResponseWriter
+	b     *bufio.Writer
+	names []string
+	rows  int
+}
+
+func newNDJSONWriter(w http.ResponseWriter, names []string) *ndjsonWriter {
+	return &ndjsonWriter{w: w, names: names}
+}
+
+// Started reports whether any part of the response has been sent.
+func (n *ndjsonWriter) Started() bool {
+	return n != nil && n.b != nil
+}
+
+// Rows returns the number of rows written.
+func (n *ndjsonWriter) Rows() int {
+	return n.rows
+}
+
+func (n *ndjsonWriter) WriteRow(row []interface{}) error {
+	n.start()
+
+	if err := encodeObject(n.b, n.names, row); err != nil {
+		return err
+	}
+	n.b.WriteByte('\n')
+
+	n.rows++
+	if n.rows%ndjsonFlushRows == 0 {
+		n.flush()
+	}
+	return nil
+}
+
+func (n *ndjsonWriter) WriteError(err error) {
+	n.start()
+
+	n.b.WriteString(`{"error":`)
+	encodeString(n.b, err.Error())
+	n.b.WriteString("}\n")
+}
+
+// Close sends any rows not sent yet, and starts the response when there were
+// none at all, as is the case for statements without results. Results left
+// out are indicated by a final line, in addition to the trailers.
+func (n *ndjsonWriter) Close(truncated bool) error {
+	n.start()
+	if truncated {
+		n.b.WriteString(`{"truncated":true}` + "\n")
+	}
+	n.flush()
+	if err := n.b.Flush(); err != nil {
+		return err
+	}
+	resultHeaders(n.w.Header(), n.rows, truncated)
+	return nil
+}
+
+func (n *ndjsonWriter) start() {
+	if n.b != nil {
+		return
+	}
+	n.w.Header().Set("Cache-Control", "private, no-store")
+	n.w.Header().Set("Content-Type", ndjsonContentType)
+	n.w.Header().Set("Trailer", middleware.RowCountHeader+", "+middleware.TruncatedHeader)
+	n.w.WriteHeader(http.StatusOK)
+	n.b = bufio.NewWriterSize(n.w, encodeBufferSize)
+}
+
+func (n *ndjsonWriter) flush() {
+	if err := n.b.Flush(); err != nil {
+		return
+	}
+	if f, ok := n.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ndjsonResponse writes a complete response, such as one served from the query
+// cache, as newline-delimited JSON, skipping the row with the column names.
+func ndjsonResponse(w http.ResponseWriter, response *models.QueryResponse) {
+	var names []string
+	if len(response.Result) > 0 {
+		for _, name := range response.Result[0] {
+			s, _ := name.(string)
+			names = append(names, s)
+		}
+	}
+
+	n := newNDJSONWriter(w, names)
+	for i, row := range response.Result {
+		if i == 0 {
+			continue
+		}
+		if err := n.WriteRow(row); err != nil {
+			break
+		}
+	}
+	_ = n.Close(response.Truncated)
+}
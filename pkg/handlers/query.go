@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -10,11 +11,23 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/policy"
+	"github.com/app-sre/gabi/pkg/metrics"
 	"github.com/app-sre/gabi/pkg/middleware"
 	"github.com/app-sre/gabi/pkg/models"
+	"github.com/app-sre/gabi/pkg/telemetry"
 )
 
 const (
@@ -22,25 +35,102 @@ const (
 	base64DecodeQuery
 )
 
+const statementMessage = "Statement executed successfully, no results returned"
+
+// Responses of the second version say so, as clients might not have asked for
+// it explicitly in the Accept header.
+const contentTypeV2 = "application/json; charset=utf-8; version=2"
+
+// NULL values of masked columns are masked as well, so that these are not
+// told apart.
+var maskValue = sql.NullString{String: policy.MaskValue, Valid: true}
+
+var (
+	// Numeric types reported by the supported drivers.
+	numericTypes = map[string]struct{}{
+		"INT2": {}, "INT4": {}, "INT8": {}, "FLOAT4": {}, "FLOAT8": {}, "NUMERIC": {},
+		"TINYINT": {}, "SMALLINT": {}, "MEDIUMINT": {}, "INT": {}, "BIGINT": {},
+		"FLOAT": {}, "DOUBLE": {}, "DECIMAL": {},
+	}
+
+	// Values such as NaN or Infinity cannot be represented as JSON numbers.
+	numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+)
+
 func Query(cfg *gabi.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// The outcome is audited once the response is written, with its status
+		// code and size.
+		w, written := middleware.RecordResponse(w)
+
 		var (
-			base64Mode byte
-			request    models.QueryRequest
+			base64Mode     byte
+			includeTypes   bool
+			nativeNumbers  bool
+			nativeJSON     bool
+			partialResults bool
+			force          bool
+			request        models.QueryRequest
 		)
 
+		version, err := middleware.APIVersion(r)
+		if err != nil {
+			l := "Unsupported API version"
+			cfg.Logger.Errorf("%s: %s", l, err)
+			http.Error(w, l, http.StatusBadRequest)
+			return
+		}
+
+		ndjson := acceptsNDJSON(r)
+
 		if s := r.URL.Query().Get("base64_results"); s != "" {
 			if ok, err := strconv.ParseBool(s); err == nil && ok {
 				base64Mode |= base64EncodeResults
 			}
 		}
 
+		if s := r.URL.Query().Get("include_types"); s != "" {
+			if ok, err := strconv.ParseBool(s); err == nil && ok {
+				includeTypes = true
+			}
+		}
+		// Column types are always part of the second version.
+		if version == middleware.APIVersion2 {
+			includeTypes = true
+		}
+
+		if s := r.URL.Query().Get("native_numbers"); s != "" {
+			if ok, err := strconv.ParseBool(s); err == nil && ok {
+				nativeNumbers = true
+			}
+		}
+
+		if s := r.URL.Query().Get("native_json"); s != "" {
+			if ok, err := strconv.ParseBool(s); err == nil && ok {
+				nativeJSON = true
+			}
+		}
+
+		if s := r.URL.Query().Get("partial_results"); s != "" {
+			if ok, err := strconv.ParseBool(s); err == nil && ok {
+				partialResults = true
+			}
+		}
+
+		if s := r.URL.Query().Get("force"); s != "" {
+			if ok, err := strconv.ParseBool(s); err == nil && ok {
+				force = true
+			}
+		}
+
 		if ctxQuery := ctx.Value(middleware.ContextKeyQuery); ctxQuery != nil {
 			if s, ok := ctxQuery.(string); ok {
 				request.Query = s
 			}
+			request.Args, _ = ctx.Value(middleware.ContextKeyArgs).([]interface{})
+			request.Database = middleware.Database(ctx)
 		}
 		if request.Query == "" {
 			if s := r.URL.Query().Get("base64_query"); s != "" {
@@ -49,14 +139,22 @@ func Query(cfg *gabi.Config) http.HandlerFunc {
 				}
 			}
 
-			err := json.NewDecoder(r.Body).Decode(&request)
+			err = json.NewDecoder(r.Body).Decode(&request)
 			if err != nil {
+				if middleware.IsRequestTooLarge(err) {
+					middleware.RequestTooLarge(cfg, w, r)
+					return
+				}
+				if middleware.IsMalformedBody(err) {
+					middleware.MalformedBody(cfg, w, r, err)
+					return
+				}
 				cfg.Logger.Errorf("Unable to decode request body: %s", err)
 				if errors.Is(err, io.EOF) {
 					http.Error(w, "Request body cannot be empty", http.StatusBadRequest)
 					return
 				}
-				_ = queryErrorResponse(w, err)
+				_ = queryErrorResponse(w, err, version)
 				return
 			}
 
@@ -72,20 +170,209 @@ func Query(cfg *gabi.Config) http.HandlerFunc {
 			}
 		}
 
-		tx, err := cfg.DB.BeginTx(ctx, &sql.TxOptions{
-			ReadOnly: !cfg.DBEnv.AllowWrite,
-		})
+		if middleware.QueryTooLarge(cfg, w, r, request.Query) {
+			return
+		}
+
+		if request.Database != "" && middleware.Database(ctx) == "" {
+			ctx = middleware.WithDatabase(ctx, request.Database)
+			r = r.WithContext(ctx)
+		}
+		database, ok := cfg.Database(request.Database)
+		if !ok {
+			l := fmt.Sprintf("Database is not permitted: %s", request.Database)
+			cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+			middleware.AuditRejection(cfg, r, request.Query, audit.DecisionDeniedPolicy, l)
+			http.Error(w, l, http.StatusForbidden)
+			return
+		}
+
+		if !db.HasStatement(request.Query) {
+			l := "Query contains no statement to execute"
+			cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+			middleware.AuditRejection(cfg, r, request.Query, audit.DecisionError, l)
+			http.Error(w, l, http.StatusBadRequest)
+			return
+		}
+
+		if cfg.PolicyEnv != nil && !cfg.PolicyEnv.IsAllowed(request.Query) {
+			l := "Query is not permitted by policy"
+			cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+			middleware.AuditRejection(cfg, r, request.Query, audit.DecisionDeniedPolicy, l)
+			http.Error(w, l, http.StatusForbidden)
+			return
+		}
+
+		if cfg.PolicyEnv != nil && len(cfg.PolicyEnv.Statements) > 0 {
+			types := cfg.DBEnv.Driver.Statements(request.Query)
+			if kind, ok := cfg.PolicyEnv.IsAllowedStatement(types); !ok {
+				l := fmt.Sprintf("Statement type is not permitted by policy: %s", kind)
+				cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+				middleware.AuditRejection(cfg, r, request.Query, audit.DecisionDeniedPolicy, l)
+				http.Error(w, l, http.StatusForbidden)
+				return
+			}
+		}
+
+		// Masking only replaces the values of columns returned under their own
+		// name, thus anything else computed from these is refused.
+		if cfg.PolicyEnv != nil && len(cfg.PolicyEnv.Mask) > 0 {
+			names := cfg.DBEnv.Driver.ExpressionNames(request.Query)
+			if masked := cfg.PolicyEnv.MaskedNames(names); len(masked) > 0 {
+				l := fmt.Sprintf("Query uses masked column other than as a result column: %s", strings.Join(masked, ", "))
+				cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+				middleware.AuditRejection(cfg, r, request.Query, audit.DecisionDeniedPolicy, l)
+				http.Error(w, l, http.StatusForbidden)
+				return
+			}
+		}
+
+		if cfg.PolicyEnv != nil && len(cfg.PolicyEnv.LargeTables) > 0 {
+			tables := cfg.DBEnv.Driver.UnboundedTables(request.Query)
+			if large := cfg.PolicyEnv.LargeTablesScanned(tables); len(large) > 0 {
+				l := fmt.Sprintf("Query scans large table without a WHERE clause or LIMIT: %s", strings.Join(large, ", "))
+				if !force {
+					cfg.Logger.Errorf("%s: %s", l, middleware.User(ctx))
+					middleware.AuditRejection(cfg, r, request.Query, audit.DecisionDeniedPolicy, l)
+					http.Error(w, l+" (add either, or set force=true to run it regardless)", http.StatusForbidden)
+					return
+				}
+				cfg.Logger.Warnf("%s, forced: %s", l, middleware.User(ctx))
+				if err := middleware.AuditOverride(cfg, r, request.Query, l); err != nil {
+					cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+					http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
+					return
+				}
+			}
+		}
+
+		// Placeholders are translated to the syntax of the driver, while the
+		// query and arguments are audited as submitted.
+		query, args := request.Query, request.Args
+		if len(request.Args) > 0 {
+			query, args, err = cfg.DBEnv.Driver.TranslatePlaceholders(request.Query, request.Args)
+			if err != nil {
+				l := fmt.Sprintf("Invalid query placeholders: %s", err)
+				var placeholderErr *db.PlaceholderError
+				if errors.As(err, &placeholderErr) {
+					l = fmt.Sprintf("Query placeholders count does not match arguments count (%d != %d)", placeholderErr.Placeholders, placeholderErr.Args)
+				}
+				cfg.Logger.Error(l)
+				middleware.AuditRejection(cfg, r, request.Query, audit.DecisionError, l)
+				http.Error(w, l, http.StatusBadRequest)
+				return
+			}
+		}
+
+		user := middleware.User(ctx)
+		warning, _ := ctx.Value(middleware.ContextKeyWarning).(string)
+
+		timeout, notice, err := middleware.QueryTimeout(cfg, r)
+		if err != nil {
+			l := "Invalid query timeout"
+			cfg.Logger.Errorf("%s: %s", l, err)
+			http.Error(w, l, http.StatusBadRequest)
+			return
+		}
+		if notice != "" {
+			if warning != "" {
+				warning += "; "
+			}
+			warning += notice
+		}
+
+		// Cached results are only served once the query has passed all checks.
+		if cached, ok := ctx.Value(middleware.ContextKeyCacheResponse).(*models.QueryResponse); ok {
+			cfg.Logger.Infow("Query served from cache",
+				"user", user,
+				"query_hash", audit.QueryHash(request.Query),
+			)
+			if ndjson {
+				ndjsonResponse(w, cached)
+				return
+			}
+			queryResponse(w, cached, warning, version)
+			return
+		}
+
+		ctx, span := telemetry.Tracer().Start(ctx, "db.query",
+			trace.WithAttributes(telemetry.QueryHashKey.String(audit.QueryHash(request.Query))),
+		)
+
+		var (
+			queryErr      error
+			resultColumns []string
+			maskedColumns []string
+		)
+
+		status := metrics.StatusError
+		start := time.Now()
+		defer func() {
+			if status != metrics.StatusSuccess {
+				span.SetStatus(codes.Error, "Unable to query database")
+			}
+			span.End()
+
+			duration := time.Since(start)
+			cfg.Metrics.ObserveQuery(ctx, user, status, duration)
+			cfg.Logger.Infow("Query executed",
+				"user", user,
+				"query_hash", audit.QueryHash(request.Query),
+				"status", status,
+				"duration_ms", duration.Milliseconds(),
+			)
+
+			middleware.AuditOutcome(cfg, r, request.Query, request.Args, resultColumns, maskedColumns, written, queryErr)
+		}()
+
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		// Write access is withdrawn once the instance has expired. Read-only
+		// transactions are only ever rolled back, thus any write slipping past
+		// the policy is refused by the database, and never committed.
+		readOnly := middleware.ReadOnly(ctx, cfg)
+		tx, err := database.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
 		if err != nil {
 			cfg.Logger.Errorf("Unable to start database transaction: %s", err)
-			_ = queryErrorResponse(w, err)
+			queryErr = err
+			_ = queryErrorResponse(w, err, version)
 			return
 		}
 		defer func() { _ = tx.Rollback() }()
 
-		rows, err := tx.Query(request.Query)
+		// The database enforces the timeout as well, where supported, which stops
+		// the query even when cancellation is not noticed by the driver.
+		if stmt := cfg.DBEnv.Driver.StatementTimeout(timeout); stmt != "" {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				cfg.Logger.Errorf("Unable to set query timeout: %s", err)
+				queryErr = err
+				_ = queryErrorResponse(w, err, version)
+				return
+			}
+		}
+
+		// Only the query as submitted is audited, without the annotation.
+		if cfg.DBEnv.Annotate {
+			id, _ := ctx.Value(middleware.ContextKeyRequestID).(string)
+			query = db.Annotate(query, user, id)
+		}
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		// Nobody is left to read the response of a query canceled along with
+		// the request.
+		if err != nil && middleware.ClientCanceled(r) {
+			cfg.Logger.Infof("Query canceled as the client disconnected: %s", user)
+			queryErr = err
+			return
+		}
 		if err != nil {
 			cfg.Logger.Errorf("Unable to query database: %s", err)
-			_ = queryErrorResponse(w, err)
+			queryErr = err
+			_ = queryErrorResponse(w, err, version)
 			return
 		}
 		defer func() { _ = rows.Close() }()
@@ -94,77 +381,373 @@ func Query(cfg *gabi.Config) http.HandlerFunc {
 		cols, err := rows.Columns()
 		if err != nil {
 			cfg.Logger.Errorf("Unable to process database query: %s", err)
-			_ = queryErrorResponse(w, err)
+			queryErr = err
+			_ = queryErrorResponse(w, err, version)
 			return
 		}
 
+		var (
+			columns    []models.Column
+			numeric    []bool
+			structured []string
+		)
+		if includeTypes || nativeNumbers || nativeJSON {
+			types, err := rows.ColumnTypes()
+			if err != nil {
+				cfg.Logger.Errorf("Unable to process database query: %s", err)
+				queryErr = err
+				_ = queryErrorResponse(w, err, version)
+				return
+			}
+			if includeTypes {
+				columns = queryColumns(types)
+			}
+			if nativeNumbers && base64Mode&base64EncodeResults == 0 {
+				numeric = numericColumns(types)
+			}
+			if nativeJSON && base64Mode&base64EncodeResults == 0 {
+				structured = structuredColumns(types, cfg.DBEnv.Driver)
+			}
+		}
+
 		vals := make([]interface{}, len(cols))
 
 		var (
-			result [][]string
-			keys   []string
+			result [][]interface{}
+			keys   []interface{}
 		)
 
+		names := uniqueColumnNames(cols)
 		for i := range cols {
-			vals[i] = new(sql.RawBytes)
-			keys = append(keys, cols[i])
+			vals[i] = new(sql.NullString)
+			keys = append(keys, names[i])
 		}
 		result = append(result, keys)
+		// The columns are audited as returned, which expands those selected
+		// using a wildcard.
+		resultColumns = names
+		for i := range columns {
+			columns[i].Name = names[i]
+		}
 
+		// Values of sensitive columns never leave the database.
+		var masked []bool
+		if cfg.PolicyEnv != nil {
+			masked = cfg.PolicyEnv.MaskedColumns(cols)
+		}
+		for i := range masked {
+			if masked[i] {
+				maskedColumns = append(maskedColumns, names[i])
+			}
+		}
+
+		// Rows are sent as these are read, instead of being kept until the end,
+		// once these no longer fit in the buffer of the JSON response, which is
+		// otherwise answered as usual.
+		var stream resultStream
+		if ndjson {
+			stream = newNDJSONWriter(w, names)
+		} else {
+			stream = newJSONWriter(w, version, keys, columns, warning)
+		}
+
+		maxRows := 0
+		if cfg.LimitsEnv != nil {
+			maxRows = cfg.LimitsEnv.MaxRows
+		}
+
+		var (
+			read      int
+			truncated bool
+		)
 		for rows.Next() {
+			// The row past the limit is only fetched to tell that there are more.
+			if maxRows > 0 && read == maxRows {
+				truncated = true
+				break
+			}
+			read++
+
 			err = rows.Scan(vals...)
 			// Now you can check each element of vals for nil-ness,
 			// and you can use type introspection and type assertions
 			// to fetch the column into a typed variable.
 			if err != nil {
 				cfg.Logger.Errorf("Unable to process database query: %s", err)
-				_ = queryErrorResponse(w, err)
+				// The rows streamed so far have been sent, thus are audited as such.
+				if stream.Started() {
+					queryErr = &audit.PartialError{Row: stream.Rows(), Err: err}
+					streamErrorResponse(w, stream, version, queryErr)
+					return
+				}
+				// The rows read so far are returned, followed by the error.
+				if partialResults {
+					partial := &audit.PartialError{Row: len(result) - 1, Err: err}
+					queryErr = partial
+					queryResponse(w, &models.QueryResponse{
+						Result:  result,
+						Error:   partial.Error(),
+						Columns: columns,
+						Partial: &models.PartialResult{Row: partial.Row, Error: err.Error()},
+					}, warning, version)
+					return
+				}
+				queryErr = err
+				_ = queryErrorResponse(w, err, version)
 				return
 			}
 
-			var row []string
+			var row []interface{}
 
-			for _, value := range vals {
-				content, ok := reflect.ValueOf(value).Interface().(*sql.RawBytes)
+			for i, value := range vals {
+				content, ok := reflect.ValueOf(value).Interface().(*sql.NullString)
 				if !ok {
-					err = fmt.Errorf("unable to convert value type %T to *sql.RawBytes", value)
+					err = fmt.Errorf("unable to convert value type %T to *sql.NullString", value)
 					cfg.Logger.Errorf("Unable to process database query: %s", err)
-					_ = queryErrorResponse(w, err)
+					queryErr = err
+					streamErrorResponse(w, stream, version, err)
 					return
 				}
-				s := string(*content)
-
-				if base64Mode&base64EncodeResults != 0 {
-					s = cfg.Encoder.EncodeToString(*content)
+				if masked != nil && masked[i] {
+					row = append(row, queryValue(cfg, maskValue, base64Mode&base64EncodeResults != 0))
+					continue
+				}
+				v := queryValue(cfg, *content, base64Mode&base64EncodeResults != 0)
+				if numeric != nil && numeric[i] {
+					v = numericValue(v)
 				}
-				row = append(row, s)
+				if structured != nil && structured[i] != "" {
+					v = structuredValue(v, structured[i])
+				}
+				row = append(row, v)
+			}
+			if err := stream.WriteRow(row); err != nil {
+				cfg.Logger.Errorf("Unable to process database query: %s", err)
+				queryErr = err
+				streamErrorResponse(w, stream, version, err)
+				return
+			}
+			// Rows that have been sent are no longer kept.
+			if stream.Started() {
+				result = nil
+				continue
 			}
 			result = append(result, row)
 		}
 
 		err = rows.Err()
+		if err != nil && middleware.ClientCanceled(r) {
+			cfg.Logger.Infof("Query canceled as the client disconnected: %s", user)
+			queryErr = err
+			return
+		}
 		if err != nil {
 			cfg.Logger.Errorf("Unable to process database query: %s", err)
-			_ = queryErrorResponse(w, err)
+			queryErr = err
+			if stream.Started() {
+				queryErr = &audit.PartialError{Row: stream.Rows(), Err: err}
+			}
+			streamErrorResponse(w, stream, version, queryErr)
 			return
 		}
 
-		err = tx.Commit()
+		l := "Unable to commit database changes"
+		if readOnly {
+			l = "Unable to roll back read-only transaction"
+			err = tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
 		if err != nil {
-			cfg.Logger.Errorf("Unable to commit database changes: %s", err)
-			_ = queryErrorResponse(w, err)
+			cfg.Logger.Errorf("%s: %s", l, err)
+			queryErr = err
+			streamErrorResponse(w, stream, version, err)
+			return
+		}
+		status = metrics.StatusSuccess
+
+		// Streamed results are not cached, as these are no longer kept.
+		if ndjson || stream.Started() {
+			_ = stream.Close(truncated)
 			return
 		}
 
-		w.Header().Set("Cache-Control", "private, no-store")
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		_ = json.NewEncoder(w).Encode(&models.QueryResponse{
-			Result: result,
-		})
+		response := &models.QueryResponse{
+			Result:    result,
+			Columns:   columns,
+			Truncated: truncated,
+		}
+		if len(cols) == 0 {
+			response = &models.QueryResponse{
+				Result: [][]interface{}{},
+				Status: &models.StatementStatus{Message: statementMessage},
+			}
+		}
+
+		// Only results of read-only transactions can be cached safely.
+		if key, ok := ctx.Value(middleware.ContextKeyCacheKey).(string); ok && cfg.Cache != nil && !cfg.DBEnv.AllowWrite {
+			cfg.Cache.Set(key, response)
+		}
+
+		queryResponse(w, response, warning, version)
+	}
+}
+
+func queryResponse(w http.ResponseWriter, response *models.QueryResponse, warning string, version int) {
+	// Cached responses are shared, thus never modified.
+	r := *response
+	r.Warning = warning
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	resultHeaders(w.Header(), len(r.Result)-1, r.Truncated)
+	if version == middleware.APIVersion2 {
+		w.Header().Set("Content-Type", contentTypeV2)
+		_ = encodeQueryResponseV2(w, queryResponseV2(&r))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = encodeQueryResponse(w, &r)
+}
+
+// The number of rows returned, not including the column names, and whether
+// any rows were left out, for clients to tell without parsing the body.
+func resultHeaders(h http.Header, rows int, truncated bool) {
+	if rows < 0 {
+		rows = 0
+	}
+	h.Set(middleware.RowCountHeader, strconv.Itoa(rows))
+	if truncated {
+		h.Set(middleware.TruncatedHeader, "true")
+	}
+}
+
+// queryResponseV2 converts the response to the second version, which returns
+// the column names, given as the first row of the results in the first one,
+// together with the other attributes of the columns instead.
+func queryResponseV2(response *models.QueryResponse) *models.QueryResponseV2 {
+	r := &models.QueryResponseV2{
+		Rows:    response.Result,
+		Columns: response.Columns,
+		Error:   response.Error,
+		Warning: response.Warning,
+		Partial: response.Partial,
+		Status:  response.Status,
+
+		Truncated: response.Truncated,
+	}
+	if len(response.Result) == 0 {
+		if response.Result != nil {
+			r.Columns = []models.Column{}
+		}
+		return r
+	}
+
+	r.Rows = response.Result[1:]
+	if r.Columns == nil {
+		r.Columns = make([]models.Column, 0, len(response.Result[0]))
+		for _, name := range response.Result[0] {
+			s, _ := name.(string)
+			r.Columns = append(r.Columns, models.Column{Name: s})
+		}
 	}
+	return r
 }
 
-func queryErrorResponse(w http.ResponseWriter, err error) error {
+// NULL values are returned as JSON null, and binary values that are not valid
+// UTF-8, which would otherwise be mangled, are Base64-encoded.
+func queryValue(cfg *gabi.Config, content sql.NullString, encode bool) interface{} {
+	if !content.Valid {
+		return nil
+	}
+	if encode || !utf8.ValidString(content.String) {
+		return cfg.Encoder.EncodeToString([]byte(content.String))
+	}
+	return content.String
+}
+
+// Numeric values are returned as strings by default, which preserves their
+// exact value regardless of how clients parse JSON numbers. When requested,
+// these are instead returned as JSON numbers, written out verbatim.
+func numericValue(v interface{}) interface{} {
+	if s, ok := v.(string); ok && numberPattern.MatchString(s) {
+		return json.Number(s)
+	}
+	return v
+}
+
+func numericColumns(types []*sql.ColumnType) []bool {
+	numeric := make([]bool, len(types))
+	for i, t := range types {
+		name := strings.TrimPrefix(strings.ToUpper(t.DatabaseTypeName()), "UNSIGNED ")
+		_, numeric[i] = numericTypes[name]
+	}
+	return numeric
+}
+
+// Results are ordered, but clients often map rows to objects keyed by column
+// name, thus duplicate names (e.g. from joined tables) are given a numeric
+// suffix so that no values are lost.
+func uniqueColumnNames(cols []string) []string {
+	names := make([]string, len(cols))
+
+	taken := make(map[string]bool, len(cols))
+	for _, name := range cols {
+		taken[name] = true
+	}
+
+	used := make(map[string]bool, len(cols))
+	suffix := make(map[string]int)
+
+	for i, name := range cols {
+		unique := name
+		if used[unique] {
+			n := suffix[name]
+			if n < 2 {
+				n = 2
+			}
+			for {
+				unique = fmt.Sprintf("%s_%d", name, n)
+				n++
+				if !taken[unique] && !used[unique] {
+					break
+				}
+			}
+			suffix[name] = n
+		}
+		used[unique] = true
+		names[i] = unique
+	}
+
+	return names
+}
+
+func queryColumns(types []*sql.ColumnType) []models.Column {
+	columns := make([]models.Column, 0, len(types))
+	for _, t := range types {
+		column := models.Column{
+			Name: t.Name(),
+			Type: t.DatabaseTypeName(),
+		}
+		if nullable, ok := t.Nullable(); ok {
+			column.Nullable = &nullable
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// Errors are answered as usual until the streamed response has been started,
+// and end it afterwards.
+func streamErrorResponse(w http.ResponseWriter, stream resultStream, version int, err error) {
+	if !stream.Started() {
+		_ = queryErrorResponse(w, err, version)
+		return
+	}
+	stream.WriteError(err)
+	_ = stream.Close(false)
+}
+
+func queryErrorResponse(w http.ResponseWriter, err error, version int) error {
 	var (
 		parseError   *url.Error
 		syscallError *os.SyscallError
@@ -176,10 +759,61 @@ func queryErrorResponse(w http.ResponseWriter, err error) error {
 		return nil
 	}
 
+	status, message := http.StatusBadRequest, err.Error()
+	if driverErr := db.ClassifyError(err); driverErr != nil {
+		status, message = driverErrorResponse(driverErr)
+		if status == http.StatusServiceUnavailable {
+			http.Error(w, message, status)
+			return nil
+		}
+	}
+
+	if version == middleware.APIVersion2 {
+		w.Header().Set("Content-Type", contentTypeV2)
+		w.WriteHeader(status)
+		return json.NewEncoder(w).Encode(&models.QueryResponseV2{Error: message})
+	}
+
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 
 	return json.NewEncoder(w).Encode(&models.QueryResponse{
-		Error: err.Error(),
+		Error: message,
 	})
 }
+
+// Errors reported by the database are answered with the status matching their
+// class, and with the message of the database only, as the details might hold
+// values the client cannot otherwise see. Errors on the side of the database
+// are answered without the message at all.
+func driverErrorResponse(err *db.DriverError) (int, string) {
+	var (
+		status int
+		l      string
+	)
+
+	switch err.Class {
+	case db.ErrorSyntax:
+		status, l = http.StatusBadRequest, "Query has a syntax error"
+	case db.ErrorUndefined:
+		status, l = http.StatusBadRequest, "Query references an undefined object"
+	case db.ErrorData:
+		status, l = http.StatusBadRequest, "Query contains invalid data"
+	case db.ErrorPermission:
+		status, l = http.StatusForbidden, "Permission denied by the database"
+	case db.ErrorReadOnly:
+		status, l = http.StatusForbidden, "Query cannot write in a read-only transaction"
+	case db.ErrorConstraint:
+		status, l = http.StatusConflict, "Query violates a constraint"
+	case db.ErrorConflict:
+		status, l = http.StatusConflict, "Query conflicts with a concurrent transaction"
+	case db.ErrorTimeout:
+		status, l = http.StatusGatewayTimeout, "Query was canceled by the database"
+	case db.ErrorConnection:
+		return http.StatusServiceUnavailable, "Unable to connect to the database"
+	default:
+		return http.StatusServiceUnavailable, "Database is unable to run the query"
+	}
+
+	return status, fmt.Sprintf("%s: %s (code %s)", l, err.Message, err.Code)
+}
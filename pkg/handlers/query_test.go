@@ -2,20 +2,35 @@ package handlers
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/app-sre/gabi/internal/test"
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/cache"
+	cacheenv "github.com/app-sre/gabi/pkg/env/cache"
 	gabidb "github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/policy"
+	"github.com/app-sre/gabi/pkg/env/server"
+	"github.com/app-sre/gabi/pkg/env/splunk"
 	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -45,7 +60,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -70,7 +85,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("2")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 2;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				ctx := context.TODO()
@@ -86,6 +101,32 @@ func TestQuery(t *testing.T) {
 			`{"result":[["?column?"],["2"]],"error":""}`,
 			``,
 		},
+		{
+			"valid query with expiration warning passed via context",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				ctx := context.TODO()
+				return context.WithValue(ctx, middleware.ContextKeyWarning, "test")
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select 1;"}`)
+			},
+			200,
+			`{"result":[["?column?"],["1"]],"error":"","warning":"test"}`,
+			``,
+		},
 		{
 			"valid query with empty context value provided",
 			func() (*sql.DB, sqlmock.Sqlmock) {
@@ -96,7 +137,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				ctx := context.TODO()
@@ -122,7 +163,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -149,7 +190,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -176,7 +217,7 @@ func TestQuery(t *testing.T) {
 				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -194,43 +235,47 @@ func TestQuery(t *testing.T) {
 			``,
 		},
 		{
-			"valid query without Base64-encoded results with empty HTTP query parameters provided",
+			"valid query with column types included",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				rows := mock.NewRowsWithColumnDefinition(
+					sqlmock.NewColumn("id").OfType("INT4", 1).Nullable(false),
+					sqlmock.NewColumn("name").OfType("TEXT", "").Nullable(true),
+					sqlmock.NewColumn("created").OfType("TIMESTAMPTZ", ""),
+				).AddRow("1", "test", "2023-01-01 00:00:00+00")
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
 			},
 			func(r *http.Request) {
 				q := r.URL.Query()
-				q.Add("base64_results", "")
+				q.Add("include_types", "true")
 				r.URL.RawQuery = q.Encode()
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": "select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
 			},
 			200,
-			`{"result":[["?column?"],["1"]],"error":""}`,
+			`{"result":[["id","name","created"],["1","test","2023-01-01 00:00:00+00"]],"error":"","columns":[{"name":"id","type":"INT4","nullable":false},{"name":"name","type":"TEXT","nullable":true},{"name":"created","type":"TIMESTAMPTZ"}]}`,
 			``,
 		},
 		{
-			"valid query with no SQL statements provided",
+			"valid query with duplicate column names",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{})
+				rows := sqlmock.NewRows([]string{"id", "id"}).AddRow("1", "2")
 				mock.ExpectBegin()
-				mock.ExpectQuery(``).WillReturnRows(rows)
-				mock.ExpectCommit()
+				mock.ExpectQuery(`select a.id, b.id from a, b;`).WillReturnRows(rows)
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -239,21 +284,22 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": ""}`)
+				return bytes.NewBufferString(`{"query": "select a.id, b.id from a, b;"}`)
 			},
 			200,
-			`{"result":[null],"error":""}`,
+			`{"result":[["id","id_2"],["1","2"]],"error":""}`,
 			``,
 		},
 		{
-			"valid query for which database returned query error",
+			"valid query with arguments",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"name"}).AddRow("test")
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectQuery(`select name from test where id = \$1 and active = \$2;`).WithArgs(float64(1), true).WillReturnRows(rows)
 				mock.ExpectRollback()
 			},
 			func() context.Context {
@@ -263,23 +309,47 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query":"select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select name from test where id = $1 and active = $2;", "args": [1, true]}`)
 			},
-			400,
-			`{"result":null,"error":"test"}`,
+			200,
+			`{"result":[["name"],["test"]],"error":""}`,
 			``,
 		},
 		{
-			"valid query for which database added a log entry",
+			"valid query with arguments passed via context",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"name"}).AddRow("test")
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectQuery(`select name from test where id = \$1;`).WithArgs("test").WillReturnRows(rows)
 				mock.ExpectRollback()
 			},
+			func() context.Context {
+				ctx := context.WithValue(context.Background(), middleware.ContextKeyQuery, "select name from test where id = $1;")
+				return context.WithValue(ctx, middleware.ContextKeyArgs, []interface{}{"test"})
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return &bytes.Buffer{}
+			},
+			200,
+			`{"result":[["name"],["test"]],"error":""}`,
+			``,
+		},
+		{
+			"invalid query with arguments not matching placeholders",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
 			func() context.Context {
 				return context.TODO()
 			},
@@ -287,20 +357,45 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query":"select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select name from test where id = $1;", "args": [1, 2]}`)
 			},
 			400,
+			`Query placeholders count does not match arguments count (1 != 2)`,
+			`Query placeholders count does not match arguments count`,
+		},
+		{
+			"valid query with question mark placeholders translated",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"name"}).AddRow("test")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select name from test where id = \$1 and active = \$2;`).WithArgs(float64(1), true).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select name from test where id = ? and active = ?;", "args": [1, true]}`)
+			},
+			200,
+			`{"result":[["name"],["test"]],"error":""}`,
 			``,
-			`Unable to query database: test`,
 		},
 		{
-			"valid query for which database returned transaction error",
+			"invalid query with question mark placeholders not matching arguments",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				mock.ExpectBegin().WillReturnError(errors.New("test"))
+				// No-op.
 			},
 			func() context.Context {
 				return context.TODO()
@@ -309,23 +404,23 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query":"select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select name from test where id = ? and active = ?;", "args": [1]}`)
 			},
 			400,
-			``,
-			`Unable to start database transaction: test`,
+			`Query placeholders count does not match arguments count (2 != 1)`,
+			`Query placeholders count does not match arguments count`,
 		},
 		{
-			"valid query for which database returned commit error",
+			"valid query with NULL and empty values",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				rows := sqlmock.NewRows([]string{"id", "name", "description"}).AddRow(nil, nil, "")
 				mock.ExpectBegin()
-				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
-				mock.ExpectCommit().WillReturnError(errors.New("test"))
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -334,22 +429,20 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": "select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
 			},
-			400,
+			200,
+			`{"result":[["id","name","description"],[null,null,""]],"error":""}`,
 			``,
-			`Unable to commit database changes: test`,
 		},
 		{
-			"valid query for which database returned query row error",
+			"valid query with NULL values and Base64-encoded results",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				rows := sqlmock.NewRows([]string{"?column?"})
-				rows.AddRow("1")
-				rows.AddRow("2").RowError(1, errors.New("test"))
+				rows := sqlmock.NewRows([]string{"id", "name"}).AddRow(nil, "test")
 				mock.ExpectBegin()
 				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
 				mock.ExpectRollback()
@@ -358,24 +451,28 @@ func TestQuery(t *testing.T) {
 				return context.TODO()
 			},
 			func(r *http.Request) {
-				// No-op.
+				q := r.URL.Query()
+				q.Add("base64_results", "true")
+				r.URL.RawQuery = q.Encode()
 			},
 			func() *bytes.Buffer {
 				return bytes.NewBufferString(`{"query": "select * from test;"}`)
 			},
-			400,
+			200,
+			`{"result":[["id","name"],[null,"dGVzdA=="]],"error":""}`,
 			``,
-			`Unable to process database query: test`,
 		},
 		{
-			"valid query with database connection error",
+			"valid query with binary values",
 			func() (*sql.DB, sqlmock.Sqlmock) {
-				_, mock, _ := sqlmock.New()
-				db, _ := sql.Open("pgx", "postgres://")
+				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				// No-op.
+				rows := sqlmock.NewRows([]string{"data", "text"}).AddRow([]byte{0xde, 0xad, 0xbe, 0xef}, []byte("test"))
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
@@ -384,21 +481,121 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": "select 1;"}`)
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
 			},
-			503,
-			`Unable to connect to the database`,
+			200,
+			`{"result":[["data","text"],["3q2+7w==","test"]],"error":""}`,
 			``,
 		},
 		{
-			"invalid query with empty body",
+			"valid query with numeric values",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRowsWithColumnDefinition(
+					sqlmock.NewColumn("amount").OfType("NUMERIC", ""),
+					sqlmock.NewColumn("id").OfType("INT8", ""),
+					sqlmock.NewColumn("ratio").OfType("FLOAT8", ""),
+					sqlmock.NewColumn("name").OfType("TEXT", ""),
+				).AddRow("12345678901234567890.12", "9223372036854775807", "NaN", "1").AddRow(nil, "-9223372036854775807", "1.5e+20", "2")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
 				// No-op.
 			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
+			},
+			200,
+			`{"result":[["amount","id","ratio","name"],["12345678901234567890.12","9223372036854775807","NaN","1"],[null,"-9223372036854775807","1.5e+20","2"]],"error":""}`,
+			``,
+		},
+		{
+			"valid query with numeric values returned as JSON numbers",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRowsWithColumnDefinition(
+					sqlmock.NewColumn("amount").OfType("NUMERIC", ""),
+					sqlmock.NewColumn("id").OfType("INT8", ""),
+					sqlmock.NewColumn("ratio").OfType("FLOAT8", ""),
+					sqlmock.NewColumn("name").OfType("TEXT", ""),
+				).AddRow("12345678901234567890.12", "9223372036854775807", "NaN", "1").AddRow(nil, "-9223372036854775807", "1.5e+20", "2")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				q := r.URL.Query()
+				q.Add("native_numbers", "true")
+				r.URL.RawQuery = q.Encode()
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
+			},
+			200,
+			`{"result":[["amount","id","ratio","name"],[12345678901234567890.12,9223372036854775807,"NaN","1"],[null,-9223372036854775807,1.5e+20,"2"]],"error":""}`,
+			``,
+		},
+		{
+			"valid query with JSON and array values returned as native JSON",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRowsWithColumnDefinition(
+					sqlmock.NewColumn("data").OfType("JSONB", ""),
+					sqlmock.NewColumn("ids").OfType("_INT4", ""),
+					sqlmock.NewColumn("tags").OfType("_TEXT", ""),
+					sqlmock.NewColumn("name").OfType("TEXT", ""),
+				).AddRow(`{"a": [1, 2], "b": null}`, "{1,2,NULL}", `{"a b","c\"d",e}`, `{"a": 1}`).AddRow("[]", "{{1,2},{3,4}}", "{}", nil)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				q := r.URL.Query()
+				q.Add("native_json", "true")
+				r.URL.RawQuery = q.Encode()
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
+			},
+			200,
+			`{"result":[["data","ids","tags","name"],[{"a":[1,2],"b":null},[1,2,null],["a b","c\"d","e"],"{\"a\": 1}"],[[],[[1,2],[3,4]],[],null]],"error":""}`,
+			``,
+		},
+		{
+			"valid query with JSON and array values returned as strings by default",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRowsWithColumnDefinition(
+					sqlmock.NewColumn("data").OfType("JSONB", ""),
+					sqlmock.NewColumn("ids").OfType("_INT4", ""),
+				).AddRow(`{"a": 1}`, "{1,2}")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
 			func() context.Context {
 				return context.TODO()
 			},
@@ -406,14 +603,41 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return &bytes.Buffer{}
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
 			},
-			400,
-			`Request body cannot be empty`,
+			200,
+			`{"result":[["data","ids"],["{\"a\": 1}","{1,2}"]],"error":""}`,
 			``,
 		},
 		{
-			"invalid query with malformed JSON in the body",
+			"valid query without Base64-encoded results with empty HTTP query parameters provided",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				q := r.URL.Query()
+				q.Add("base64_results", "")
+				r.URL.RawQuery = q.Encode()
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select 1;"}`)
+			},
+			200,
+			`{"result":[["?column?"],["1"]],"error":""}`,
+			``,
+		},
+		{
+			"invalid query with no SQL statements provided",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
@@ -428,14 +652,14 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query: "select 1;"}`)
+				return bytes.NewBufferString(`{"query": ""}`)
 			},
 			400,
-			``,
-			`Unable to decode request body`,
+			`Query contains no statement to execute`,
+			`"rejection": "Query contains no statement to execute"`,
 		},
 		{
-			"invalid query with incorrect type provided in the body",
+			"invalid query with only comments provided",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
@@ -450,42 +674,280 @@ func TestQuery(t *testing.T) {
 				// No-op.
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": -1}`)
+				return bytes.NewBufferString(`{"query": " -- select 1;\n/* select 2; */ ;"}`)
 			},
 			400,
-			``,
-			`Unable to decode request body`,
+			`Query contains no statement to execute`,
+			`"rejection": "Query contains no statement to execute"`,
 		},
 		{
-			"invalid query with malformed Base64-encoded value in the body",
+			"valid statement that returns no results",
 			func() (*sql.DB, sqlmock.Sqlmock) {
 				db, mock, _ := sqlmock.New()
 				return db, mock
 			},
 			func(mock sqlmock.Sqlmock) {
-				// No-op.
+				rows := sqlmock.NewRows([]string{})
+				mock.ExpectBegin()
+				mock.ExpectQuery(`set search_path to public;`).WillReturnRows(rows)
+				mock.ExpectRollback()
 			},
 			func() context.Context {
 				return context.TODO()
 			},
 			func(r *http.Request) {
 				q := r.URL.Query()
-				q.Add("base64_query", "true")
+				q.Add("include_types", "true")
 				r.URL.RawQuery = q.Encode()
 			},
 			func() *bytes.Buffer {
-				return bytes.NewBufferString(`{"query": "dGhpcyBpcyBhIHRlc3Q=="}`)
+				return bytes.NewBufferString(`{"query": "set search_path to public;"}`)
 			},
-			400,
-			`Unable to decode Base64-encoded query`,
-			``,
+			200,
+			`{"result":[],"error":"","status":{"message":"Statement executed successfully, no results returned"}}`,
+			`"success": true, "status_code": 200, "response_bytes": 101}`,
 		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.description, func(t *testing.T) {
-			t.Parallel()
+		{
+			"valid query for which database returned query error",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query":"select 1;"}`)
+			},
+			400,
+			`{"result":null,"error":"test"}`,
+			``,
+		},
+		{
+			"valid query for which database added a log entry",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query":"select 1;"}`)
+			},
+			400,
+			``,
+			`Unable to query database: test`,
+		},
+		{
+			"valid query for which database returned transaction error",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("test"))
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query":"select 1;"}`)
+			},
+			400,
+			``,
+			`Unable to start database transaction: test`,
+		},
+		{
+			"valid query for which database returned rollback error",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback().WillReturnError(errors.New("test"))
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select 1;"}`)
+			},
+			400,
+			``,
+			`Unable to roll back read-only transaction: test`,
+		},
+		{
+			"valid query for which database returned query row error",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"})
+				rows.AddRow("1")
+				rows.AddRow("2").RowError(1, errors.New("test"))
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from test;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select * from test;"}`)
+			},
+			400,
+			``,
+			`Unable to process database query: test`,
+		},
+		{
+			"valid query with database connection error",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				_, mock, _ := sqlmock.New()
+				db, _ := sql.Open("pgx", "postgres://")
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select 1;"}`)
+			},
+			503,
+			`Unable to connect to the database`,
+			``,
+		},
+		{
+			"invalid query with empty body",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return &bytes.Buffer{}
+			},
+			400,
+			`Request body cannot be empty`,
+			``,
+		},
+		{
+			"invalid query with malformed JSON in the body",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query: "select 1;"}`)
+			},
+			400,
+			``,
+			`Unable to decode request body`,
+		},
+		{
+			"invalid query with incorrect type provided in the body",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				// No-op.
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": -1}`)
+			},
+			400,
+			``,
+			`Unable to decode request body`,
+		},
+		{
+			"invalid query with malformed Base64-encoded value in the body",
+			func() (*sql.DB, sqlmock.Sqlmock) {
+				db, mock, _ := sqlmock.New()
+				return db, mock
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(r *http.Request) {
+				q := r.URL.Query()
+				q.Add("base64_query", "true")
+				r.URL.RawQuery = q.Encode()
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "dGhpcyBpcyBhIHRlc3Q=="}`)
+			},
+			400,
+			`Unable to decode Base64-encoded query`,
+			``,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
 
 			var body, output bytes.Buffer
 
@@ -501,7 +963,9 @@ func TestQuery(t *testing.T) {
 			tc.mock(mock)
 			tc.parameters(r)
 
-			expected := &gabi.Config{DB: db, DBEnv: &gabidb.Env{}, Logger: logger, Encoder: encoder}
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), DB: db, DBEnv: &gabidb.Env{Driver: "pgx"}, LoggerAudit: la, SplunkAudit: la, Logger: logger, Encoder: encoder}
 			Query(expected).ServeHTTP(w, r.WithContext(tc.context()))
 
 			actual := w.Result()
@@ -518,3 +982,1612 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestQueryPolicy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *policy.Env
+		mock        func(sqlmock.Sqlmock)
+		code        int
+		body        string
+		want        *regexp.Regexp
+	}{
+		{
+			"query allowed by policy",
+			&policy.Env{Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)}},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"result":[["?column?"],["1"]],"error":""}`,
+			regexp.MustCompile(``),
+		},
+		{
+			"query not matching allow pattern",
+			&policy.Env{Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^delete`)}},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			403,
+			`Query is not permitted by policy`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Query is not permitted by policy"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"query matching deny pattern",
+			&policy.Env{
+				Allow: []*regexp.Regexp{regexp.MustCompile(`(?i)^select`)},
+				Deny:  []*regexp.Regexp{regexp.MustCompile(`(?i)SELECT 1`)},
+			},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			403,
+			`Query is not permitted by policy`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Query is not permitted by policy"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+		{
+			"statement type allowed by policy",
+			&policy.Env{Statements: []string{"SELECT", "INSERT"}},
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"result":[["?column?"],["1"]],"error":""}`,
+			regexp.MustCompile(``),
+		},
+		{
+			"statement type not allowed by policy",
+			&policy.Env{Statements: []string{"INSERT"}},
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			403,
+			`Statement type is not permitted by policy: SELECT`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Statement type is not permitted by policy: SELECT"}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString(`{"query": "select 1;"}`))
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			tc.mock(mock)
+
+			la := &audit.ConsoleAudit{Logger: logger}
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			ctx := context.WithValue(context.TODO(), middleware.ContextKeyUser, "test")
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{},
+				PolicyEnv:   tc.given,
+				LoggerAudit: la,
+				SplunkAudit: sa,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+			Query(expected).ServeHTTP(w, r.WithContext(ctx))
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			err := mock.ExpectationsWereMet()
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Regexp(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestQueryLargeTables(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		query       string
+		target      string
+		mock        func(sqlmock.Sqlmock)
+		code        int
+		body        string
+		want        *regexp.Regexp
+	}{
+		{
+			"query scanning large table refused",
+			"pgx",
+			`select * from public.events`,
+			"/",
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			403,
+			`Query scans large table without a WHERE clause or LIMIT: public.events (add either, or set force=true to run it regardless)`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Query scans large table without a WHERE clause or LIMIT: public.events"}`),
+		},
+		{
+			"query scanning large table forced",
+			"pgx",
+			`select * from public.events`,
+			"/?force=true",
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from public.events`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"result":[["id"],["1"]],"error":""}`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "decision": "allowed", "override": "Query scans large table without a WHERE clause or LIMIT: public.events"}`),
+		},
+		{
+			"query bounded by limit",
+			"pgx",
+			`select * from events limit 1`,
+			"/",
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from events limit 1`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"result":[["id"],["1"]],"error":""}`,
+			regexp.MustCompile(`"decision": "allowed", "read_only": true, "success": true`),
+		},
+		{
+			"query scanning other table",
+			"pgx",
+			`select * from users`,
+			"/",
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"id"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select \* from users`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"result":[["id"],["1"]],"error":""}`,
+			regexp.MustCompile(`"decision": "allowed", "read_only": true, "success": true`),
+		},
+		{
+			"query scanning large table after hash comment of mysql refused",
+			"mysql",
+			`SELECT * FROM users # '\n, events -- '`,
+			"/",
+			func(mock sqlmock.Sqlmock) {
+				// No-op.
+			},
+			403,
+			`Query scans large table without a WHERE clause or LIMIT: events (add either, or set force=true to run it regardless)`,
+			regexp.MustCompile(`"decision": "denied_policy", "rejection": "Query scans large table without a WHERE clause or LIMIT: events"`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.target, bytes.NewBufferString(`{"query": "`+tc.query+`"}`))
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			tc.mock(mock)
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			ctx := context.WithValue(context.TODO(), middleware.ContextKeyUser, "test")
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: gabidb.DriverType(tc.driver)},
+				PolicyEnv:   &policy.Env{LargeTables: []string{"events"}},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+			Query(expected).ServeHTTP(w, r.WithContext(ctx))
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			err := mock.ExpectationsWereMet()
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Regexp(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestQueryDatabase(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		database    string
+		queried     string
+		code        int
+		body        string
+		want        *regexp.Regexp
+	}{
+		{
+			"default database queried without selection",
+			``,
+			`main`,
+			200,
+			`{"result":[["name"],["main"]],"error":""}`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "decision": "allowed", "read_only": true, "success": true`),
+		},
+		{
+			"default database selected by name",
+			`main`,
+			`main`,
+			200,
+			`{"result":[["name"],["main"]],"error":""}`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "database": "main", "decision": "allowed"`),
+		},
+		{
+			"allowed database selected",
+			`reports`,
+			`reports`,
+			200,
+			`{"result":[["name"],["reports"]],"error":""}`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "database": "reports", "decision": "allowed"`),
+		},
+		{
+			"database not allowed",
+			`secrets`,
+			``,
+			403,
+			`Database is not permitted: secrets`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "\w{16}", "timestamp": \d{10}, "database": "secrets", "decision": "denied_policy", "rejection": "Database is not permitted: secrets"}`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			query := `{"query": "select current_database();", "database": "` + tc.database + `"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", bytes.NewBufferString(query))
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			mainDB, mainMock, _ := sqlmock.New()
+			defer func() { _ = mainDB.Close() }()
+			reportsDB, reportsMock, _ := sqlmock.New()
+			defer func() { _ = reportsDB.Close() }()
+
+			if mock, ok := map[string]sqlmock.Sqlmock{"main": mainMock, "reports": reportsMock}[tc.queried]; ok {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select current_database\(\);`).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow(tc.queried))
+				mock.ExpectRollback()
+			}
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			ctx := context.WithValue(context.TODO(), middleware.ContextKeyUser, "test")
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          mainDB,
+				Databases:   map[string]*sql.DB{"reports": reportsDB},
+				DBEnv:       &gabidb.Env{Name: "main", Databases: []string{"reports"}},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+			Query(expected).ServeHTTP(w, r.WithContext(ctx))
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			require.NoError(t, mainMock.ExpectationsWereMet())
+			require.NoError(t, reportsMock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Regexp(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestQueryMasking(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		driver      string
+		given       *policy.Env
+		query       string
+		parameters  string
+		code        int
+		body        string
+		want        string
+	}{
+		{
+			"masked columns replaced",
+			"pgx",
+			&policy.Env{Mask: []string{"ssn", "*_token"}},
+			`select id, ssn, api_token from users;`,
+			``,
+			200,
+			`{"result":[["id","SSN","api_token"],["1","****","****"],["2","****","****"]],"error":""}`,
+			`"success": true, "masked_columns": ["SSN", "api_token"]`,
+		},
+		{
+			"masked columns replaced with Base64-encoded results",
+			"pgx",
+			&policy.Env{Mask: []string{"ssn"}},
+			`select id, ssn, api_token from users;`,
+			`base64_results=true`,
+			200,
+			`{"result":[["id","SSN","api_token"],["MQ==","KioqKg==","dGVzdA=="],["Mg==","KioqKg==",null]],"error":""}`,
+			`"success": true, "masked_columns": ["SSN"]`,
+		},
+		{
+			"no columns masked",
+			"pgx",
+			&policy.Env{Mask: []string{"password"}},
+			`select id, ssn, api_token from users;`,
+			``,
+			200,
+			`{"result":[["id","SSN","api_token"],["1","123-45-6789","test"],["2","987-65-4321",null]],"error":""}`,
+			`"success": true, "status_code": 200, "response_bytes": 101}`,
+		},
+		{
+			"masked column in condition refused",
+			"pgx",
+			&policy.Env{Mask: []string{"ssn"}},
+			`select id from users where ssn like '1%';`,
+			``,
+			403,
+			`Query uses masked column other than as a result column: ssn`,
+			`"decision": "denied_policy", "rejection": "Query uses masked column other than as a result column: ssn"`,
+		},
+		{
+			"masked column under alias refused",
+			"pgx",
+			&policy.Env{Mask: []string{"*_token"}},
+			`select id, api_token as t from users;`,
+			``,
+			403,
+			`Query uses masked column other than as a result column: api_token`,
+			`"decision": "denied_policy", "rejection": "Query uses masked column other than as a result column: api_token"`,
+		},
+		{
+			"masked column in executable comment of mysql refused",
+			"mysql",
+			&policy.Env{Mask: []string{"ssn"}},
+			`select id /*! , ssn as x */ from users;`,
+			``,
+			403,
+			`Query uses masked column other than as a result column: ssn`,
+			`"decision": "denied_policy", "rejection": "Query uses masked column other than as a result column: ssn"`,
+		},
+		{
+			"masked column following hash comment of mysql refused",
+			"mysql",
+			&policy.Env{Mask: []string{"ssn"}},
+			"select id # '\n, ssn as x from users -- '",
+			``,
+			403,
+			`Query uses masked column other than as a result column: ssn`,
+			`"decision": "denied_policy", "rejection": "Query uses masked column other than as a result column: ssn"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			if tc.code == http.StatusOK {
+				rows := sqlmock.NewRows([]string{"id", "SSN", "api_token"}).
+					AddRow("1", "123-45-6789", "test").
+					AddRow("2", "987-65-4321", nil)
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(tc.query)).WillReturnRows(rows)
+				mock.ExpectRollback()
+			}
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: gabidb.DriverType(tc.driver)},
+				PolicyEnv:   tc.given,
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(fmt.Sprintf(`{"query": %q}`, tc.query)))
+			r.URL.RawQuery = tc.parameters
+
+			Query(expected).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.body, strings.TrimSpace(w.Body.String()))
+			assert.Contains(t, output.String(), tc.want)
+		})
+	}
+}
+
+func TestQueryAnnotation(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	defer func() { _ = db.Close() }()
+
+	rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`/* gabi user=test request_id=test__1 */ select 1;`).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "pgx", Annotate: true},
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	ctx := context.WithValue(context.TODO(), middleware.ContextKeyUser, "test")
+	ctx = context.WithValue(ctx, middleware.ContextKeyRequestID, "test*/1")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "select 1;"}`))
+
+	Query(expected).ServeHTTP(w, r.WithContext(ctx))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, output.String(), `"query": "select 1;"`)
+}
+
+func TestQueryCache(t *testing.T) {
+	t.Parallel()
+
+	var output, server bytes.Buffer
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&server, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer s.Close()
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	// The database is only queried once, with the cached results served after.
+	rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+	sa.SetHTTPClient(http.DefaultClient)
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "pgx"},
+		CacheEnv:    &cacheenv.Env{Size: 10, TTL: time.Minute, PerUser: true},
+		LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+		SplunkAudit: sa,
+		Cache:       cache.New(10, time.Minute),
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+	handler := middleware.Audit(expected)(Query(expected))
+
+	for _, query := range []string{"select 1;", "  select   1 ;"} {
+		body := fmt.Sprintf(`{"query": %q}`, query)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+		r.Header.Set("X-Forwarded-User", "test")
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `{"result":[["?column?"],["1"]],"error":""}`)
+	}
+
+	// Cached results are served as newline-delimited JSON when requested.
+	body := `{"query": "select 1;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r.Header.Set("X-Forwarded-User", "test")
+	r.Header.Set("Accept", "application/x-ndjson")
+
+	handler.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"?column?":"1"}`+"\n", w.Body.String())
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, strings.Count(output.String(), `"cache_hit": true`))
+	assert.Contains(t, output.String(), `Query served from cache`)
+	assert.Equal(t, 2, strings.Count(server.String(), `"cache_hit":true`))
+}
+
+func TestQueryOutcome(t *testing.T) {
+	t.Parallel()
+
+	var output, server bytes.Buffer
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&server, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer s.Close()
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test\ndetails"))
+	mock.ExpectRollback()
+
+	sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+	sa.SetHTTPClient(http.DefaultClient)
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "pgx"},
+		LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+		SplunkAudit: sa,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	body := `{"query": "select 1;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r.Header.Set("X-Forwarded-User", "test")
+
+	middleware.Audit(expected)(Query(expected)).ServeHTTP(w, r)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 2, strings.Count(output.String(), "AUDIT\t"))
+	assert.Contains(t, output.String(), `"success": false, "error": "test", "status_code": 400`)
+	assert.Contains(t, server.String(), `"success":false,"error":"test"`)
+	assert.NotContains(t, server.String(), `details`)
+}
+
+func TestQueryDriverErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		code        int
+		body        string
+		class       string
+	}{
+		{
+			"syntax error",
+			&pgconn.PgError{Severity: "ERROR", Code: "42601", Message: `syntax error at or near "selec"`, Position: 1},
+			400,
+			`{"result":null,"error":"Query has a syntax error: syntax error at or near \"selec\" (code 42601)"}`,
+			`syntax_error`,
+		},
+		{
+			"permission error",
+			&mysql.MySQLError{Number: 1142, Message: "SELECT command denied to user 'test'@'localhost' for table 'test'"},
+			403,
+			`{"result":null,"error":"Permission denied by the database: SELECT command denied to user 'test'@'localhost' for table 'test' (code 1142)"}`,
+			`permission_denied`,
+		},
+		{
+			"constraint violation without details",
+			&pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint \"test_pkey\"", Detail: "Key (id)=(1) already exists."},
+			409,
+			`Query violates a constraint: duplicate key value violates unique constraint`,
+			`constraint_violation`,
+		},
+		{
+			"timeout",
+			&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"},
+			504,
+			`Query was canceled by the database: canceling statement due to statement timeout (code 57014)`,
+			`timeout`,
+		},
+		{
+			"connection error without message",
+			&pgconn.PgError{Code: "28P01", Message: "password authentication failed for user \"test\""},
+			503,
+			"Unable to connect to the database\n",
+			`connection`,
+		},
+		{
+			"unknown error",
+			&pgconn.PgError{Severity: "ERROR", Code: "XX000", Message: "test"},
+			400,
+			`{"result":null,"error":"ERROR: test (SQLSTATE XX000)"}`,
+			``,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select 1;`).WillReturnError(tc.given)
+			mock.ExpectRollback()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+
+			middleware.Audit(expected)(Query(expected)).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.body)
+			assert.NotContains(t, w.Body.String(), `already exists`)
+			if tc.class == "" {
+				assert.NotContains(t, output.String(), `"error_class"`)
+				return
+			}
+			assert.Contains(t, output.String(), `"error_class": "`+tc.class+`"`)
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	rows := sqlmock.NewRows([]string{"?column?"}).AddRow("1")
+	mock.ExpectBegin()
+	mock.ExpectExec(`set local statement_timeout = 300000;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "pgx", QueryTimeout: 30 * time.Second, MaxQueryTimeout: 5 * time.Minute},
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/?timeout=1h", bytes.NewBufferString(`{"query": "select 1;"}`))
+
+	Query(expected).ServeHTTP(w, r)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"warning":"The requested query timeout exceeds the maximum allowed, using 5m0s instead"`)
+	assert.Contains(t, output.String(), `"timeout_ms": 300000, "read_only": true, "success": true, "status_code": 200`)
+}
+
+func TestQueryClientDisconnect(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	// The delay is cut short by the cancellation of the query context, which
+	// also rolls the transaction back, asynchronously.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`select pg_sleep\(60\);`).WillDelayFor(time.Minute).WillReturnRows(sqlmock.NewRows([]string{"pg_sleep"}))
+
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "mysql"},
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	done := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		r.Header.Set("X-Forwarded-User", "test")
+		middleware.Audit(expected)(Query(expected)).ServeHTTP(w, r)
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	body := `{"query": "select pg_sleep(60);"}`
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, strings.NewReader(body))
+	require.NoError(t, err)
+
+	_, err = http.DefaultClient.Do(r)
+	require.Error(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not canceled along with the request")
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Contains(t, output.String(), `Query canceled as the client disconnected`)
+	// The error is the one returned by the database once the context is canceled.
+	assert.Contains(t, output.String(), `"success": false, "error": "canceling query due to user request", "error_class": "client_canceled"}`)
+}
+
+type passthroughConverter struct{}
+
+func (passthroughConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	return v, nil
+}
+
+func TestQueryPartialResults(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		partial     bool
+		code        int
+		body        string
+		want        string
+	}{
+		{
+			"row scan failure without partial results",
+			false,
+			400,
+			`{"result":null,"error":"sql: Scan error on column index 0, name \"?column?\": unsupported Scan, storing driver.Value type struct {} into type *string"}`,
+			`"success": false, "error": "sql: Scan error on column index 0`,
+		},
+		{
+			"row scan failure with partial results",
+			true,
+			200,
+			`{"result":[["?column?"],["1"],["2"]],"error":"unable to read row 2: sql: Scan error on column index 0, name \"?column?\": unsupported Scan, storing driver.Value type struct {} into type *string","partial":{"row":2,"error":"sql: Scan error on column index 0, name \"?column?\": unsupported Scan, storing driver.Value type struct {} into type *string"}}`,
+			`"success": false, "error": "unable to read row 2: sql: Scan error on column index 0`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			// Values are passed through as-is, so that these can fail to scan.
+			db, mock, _ := sqlmock.New(sqlmock.ValueConverterOption(passthroughConverter{}))
+			defer func() { _ = db.Close() }()
+
+			rows := mock.NewRows([]string{"?column?"}).AddRow("1").AddRow("2").AddRow(struct{}{}).AddRow("4")
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+			mock.ExpectRollback()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "select 1;"}`))
+			if tc.partial {
+				r.URL.RawQuery = "partial_results=true"
+			}
+
+			Query(expected).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.body, strings.TrimSpace(w.Body.String()))
+			assert.Contains(t, output.String(), tc.want)
+			assert.Equal(t, tc.partial, strings.Contains(output.String(), `"partial": true`))
+		})
+	}
+}
+
+func TestQueryNDJSON(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		mock        func(sqlmock.Sqlmock)
+		code        int
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			"valid query with rows",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "name", "id"}).AddRow("1", "test", "2").AddRow("3", nil, "4")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			"application/x-ndjson",
+			`{"id":"1","name":"test","id_2":"2"}` + "\n" + `{"id":"3","name":null,"id_2":"4"}` + "\n",
+			`"success": true`,
+		},
+		{
+			"valid query without rows",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id"})
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			"application/x-ndjson",
+			``,
+			`"success": true`,
+		},
+		{
+			"invalid query failing before any rows are sent",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectRollback()
+			},
+			400,
+			"application/json; charset=utf-8",
+			`{"result":null,"error":"test"}` + "\n",
+			`"success": false, "error": "test"`,
+		},
+		{
+			"row scan failure after rows are sent",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id"}).AddRow("1").AddRow(struct{}{})
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			"application/x-ndjson",
+			`{"id":"1"}` + "\n" + `{"error":"unable to read row 1: sql: Scan error on column index 0, name \"id\": unsupported Scan, storing driver.Value type struct {} into type *string"}` + "\n",
+			`"success": false, "error": "unable to read row 1: sql: Scan error on column index 0`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New(sqlmock.ValueConverterOption(passthroughConverter{}))
+			defer func() { _ = db.Close() }()
+
+			tc.mock(mock)
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "select 1;"}`))
+			r.Header.Set("Accept", "application/x-ndjson")
+
+			Query(expected).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.body, w.Body.String())
+			assert.Contains(t, output.String(), tc.want)
+		})
+	}
+}
+
+func TestQueryStreamedJSON(t *testing.T) {
+	t.Parallel()
+
+	// Rows no longer fitting in the buffer are sent as these are read.
+	var rows []string
+	value := strings.Repeat("x", 1024)
+	for i := 0; i < 40; i++ {
+		rows = append(rows, `["`+value+`"]`)
+	}
+	streamed := strings.Join(rows, ",")
+	scanErr := `sql: Scan error on column index 0, name \"id\": unsupported Scan, storing driver.Value type struct {} into type *string`
+
+	cases := []struct {
+		description string
+		url         string
+		failing     bool
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			"results of the first version",
+			"/",
+			false,
+			"application/json; charset=utf-8",
+			`{"result":[["id"],` + streamed + `],"error":""}` + "\n",
+			`"success": true`,
+		},
+		{
+			"results of the second version",
+			"/?api_version=2",
+			false,
+			"application/json; charset=utf-8; version=2",
+			`{"rows":[` + streamed + `],"columns":[{"name":"id","type":""}]}` + "\n",
+			`"success": true`,
+		},
+		{
+			"row scan failure after rows are sent",
+			"/",
+			true,
+			"application/json; charset=utf-8",
+			`{"result":[["id"],` + streamed + `],"error":"unable to read row 40: ` + scanErr + `","partial":{"row":40,"error":"` + scanErr + `"}}` + "\n",
+			`"success": false, "error": "unable to read row 40: sql: Scan error on column index 0`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New(sqlmock.ValueConverterOption(passthroughConverter{}))
+			defer func() { _ = db.Close() }()
+
+			rows := mock.NewRows([]string{"id"})
+			for i := 0; i < 40; i++ {
+				rows.AddRow(value)
+			}
+			if tc.failing {
+				rows.AddRow(struct{}{})
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+			mock.ExpectRollback()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tc.url, bytes.NewBufferString(`{"query": "select 1;"}`))
+
+			Query(expected).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			assert.Equal(t, http.StatusOK, actual.StatusCode)
+			assert.Equal(t, tc.contentType, actual.Header.Get("Content-Type"))
+			assert.Equal(t, tc.body, w.Body.String())
+			assert.Empty(t, actual.Header.Get("X-Gabi-Row-Count"))
+			assert.Equal(t, "40", actual.Trailer.Get("X-Gabi-Row-Count"))
+			assert.Contains(t, output.String(), tc.want)
+		})
+	}
+}
+
+func TestQueryCompressed(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			"JSON response",
+			"",
+			"application/json; charset=utf-8",
+			`{"result":[["id","name"],["1","test"],["2","test"]],"error":""}` + "\n",
+		},
+		{
+			"NDJSON response",
+			"application/x-ndjson",
+			"application/x-ndjson",
+			`{"id":"1","name":"test"}` + "\n" + `{"id":"2","name":"test"}` + "\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New(sqlmock.ValueConverterOption(passthroughConverter{}))
+			defer func() { _ = db.Close() }()
+
+			rows := mock.NewRows([]string{"id", "name"}).AddRow("1", "test").AddRow("2", "test")
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+			mock.ExpectRollback()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				ServerEnv:   &server.Env{CompressionLevel: 6},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "select 1;"}`))
+			r.Header.Set("Accept-Encoding", "gzip")
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+
+			middleware.Compress(expected)(Query(expected)).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+			zr, err := gzip.NewReader(w.Body)
+			require.NoError(t, err)
+			actual, err := io.ReadAll(zr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.body, string(actual))
+		})
+	}
+}
+
+func TestQueryAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		mock        func(sqlmock.Sqlmock)
+		url         string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{
+			"first version by default",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "name"}).AddRow("1", "test")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			"/",
+			"",
+			200,
+			"application/json; charset=utf-8",
+			`{"result":[["id","name"],["1","test"]],"error":""}` + "\n",
+		},
+		{
+			"second version using query parameter",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "name"}).AddRow("1", "test").AddRow("2", nil)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			"/?api_version=2",
+			"",
+			200,
+			"application/json; charset=utf-8; version=2",
+			`{"rows":[["1","test"],["2",null]],"columns":[{"name":"id","type":""},{"name":"name","type":""}]}` + "\n",
+		},
+		{
+			"second version using Accept header",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id"})
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			"/",
+			"application/json; version=2",
+			200,
+			"application/json; charset=utf-8; version=2",
+			`{"rows":[],"columns":[{"name":"id","type":""}]}` + "\n",
+		},
+		{
+			"query parameter taking precedence over Accept header",
+			func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id"}).AddRow("1")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			"/?api_version=1",
+			"application/json; version=2",
+			200,
+			"application/json; charset=utf-8",
+			`{"result":[["id"],["1"]],"error":""}` + "\n",
+		},
+		{
+			"second version for statement without results",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnRows(mock.NewRows(nil))
+				mock.ExpectRollback()
+			},
+			"/?api_version=2",
+			"",
+			200,
+			"application/json; charset=utf-8; version=2",
+			`{"rows":[],"columns":[],"status":{"message":"Statement executed successfully, no results returned"}}` + "\n",
+		},
+		{
+			"second version for invalid query",
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select 1;`).WillReturnError(errors.New("test"))
+				mock.ExpectRollback()
+			},
+			"/?api_version=2",
+			"",
+			400,
+			"application/json; charset=utf-8; version=2",
+			`{"rows":null,"columns":null,"error":"test"}` + "\n",
+		},
+		{
+			"unsupported version",
+			func(mock sqlmock.Sqlmock) {
+			},
+			"/?api_version=3",
+			"",
+			400,
+			"text/plain; charset=utf-8",
+			"Unsupported API version\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			tc.mock(mock)
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, tc.url, bytes.NewBufferString(`{"query": "select 1;"}`))
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+
+			Query(expected).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.body, w.Body.String())
+		})
+	}
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		want        bool
+	}{
+		{"no media type requested", "", false},
+		{"any media type requested", "*/*", false},
+		{"JSON requested", "application/json", false},
+		{"NDJSON requested", "application/x-ndjson", true},
+		{"NDJSON requested among others", "application/json;q=0.5, application/x-ndjson", true},
+		{"NDJSON refused", "application/x-ndjson;q=0", false},
+		{"invalid media type requested", "application/x-ndjson;;", false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+			if tc.given != "" {
+				r.Header.Set("Accept", tc.given)
+			}
+
+			assert.Equal(t, tc.want, acceptsNDJSON(r))
+		})
+	}
+}
+
+func TestUniqueColumnNames(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []string
+		expected    []string
+	}{
+		{
+			"column names without duplicates",
+			[]string{"id", "name"},
+			[]string{"id", "name"},
+		},
+		{
+			"column names with duplicates",
+			[]string{"id", "name", "id", "id"},
+			[]string{"id", "name", "id_2", "id_3"},
+		},
+		{
+			"column names with duplicates colliding with existing column names",
+			[]string{"id", "id", "id_2"},
+			[]string{"id", "id_3", "id_2"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			actual := uniqueColumnNames(tc.given)
+
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestQueryTransaction(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		allowWrite  bool
+		postExpiry  bool
+		readOnly    bool
+		err         error
+	}{
+		{"read-only transaction rolled back", false, false, true, nil},
+		{"read-write transaction committed", true, false, false, nil},
+		{"read-only transaction after expiration rolled back", true, true, true, nil},
+		{"read-write transaction for which database returned commit error", true, false, false, errors.New("test")},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select 1;`).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+			if tc.readOnly {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit().WillReturnError(tc.err)
+			}
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx", AllowWrite: tc.allowWrite},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+			r = r.WithContext(context.WithValue(r.Context(), middleware.ContextKeyPostExpiry, tc.postExpiry))
+
+			middleware.Audit(cfg)(Query(cfg)).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			if tc.err != nil {
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				assert.Contains(t, output.String(), `Unable to commit database changes: test`)
+				return
+			}
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tc.readOnly {
+				assert.Contains(t, output.String(), `"read_only": true, "success": true`)
+			} else {
+				assert.NotContains(t, output.String(), `"read_only"`)
+			}
+		})
+	}
+}
+
+func TestQueryRowLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		maxRows     int
+		ndjson      bool
+		body        string
+		rowCount    string
+		truncated   string
+	}{
+		{
+			"results without limit",
+			0,
+			false,
+			`{"result":[["id"],["1"],["2"],["3"]],"error":""}` + "\n",
+			"3",
+			"",
+		},
+		{
+			"results within limit",
+			3,
+			false,
+			`{"result":[["id"],["1"],["2"],["3"]],"error":""}` + "\n",
+			"3",
+			"",
+		},
+		{
+			"results truncated at limit",
+			2,
+			false,
+			`{"result":[["id"],["1"],["2"]],"error":"","truncated":true}` + "\n",
+			"2",
+			"true",
+		},
+		{
+			"streamed results truncated at limit",
+			2,
+			true,
+			`{"id":"1"}` + "\n" + `{"id":"2"}` + "\n" + `{"truncated":true}` + "\n",
+			"2",
+			"true",
+		},
+		{
+			"streamed results within limit",
+			3,
+			true,
+			`{"id":"1"}` + "\n" + `{"id":"2"}` + "\n" + `{"id":"3"}` + "\n",
+			"3",
+			"",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`select id from test;`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1").AddRow("2").AddRow("3"))
+			mock.ExpectRollback()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx"},
+				LimitsEnv:   &limits.Env{MaxRows: tc.maxRows},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"query": "select id from test;"}`))
+			if tc.ndjson {
+				r.Header.Set("Accept", "application/x-ndjson")
+			}
+
+			Query(cfg).ServeHTTP(w, r)
+
+			require.NoError(t, mock.ExpectationsWereMet())
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			headers := actual.Header
+			if tc.ndjson {
+				headers = actual.Trailer
+			}
+			assert.Equal(t, http.StatusOK, actual.StatusCode)
+			assert.Equal(t, tc.body, w.Body.String())
+			assert.Equal(t, tc.rowCount, headers.Get("X-Gabi-Row-Count"))
+			assert.Equal(t, tc.truncated, headers.Get("X-Gabi-Truncated"))
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/cache"
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+const (
+	schemaCacheKey = "schema"
+	schemaCacheTTL = 1 * time.Minute
+)
+
+// Schema returns the tables, and their columns, visible to the connection,
+// leaving out any tables that queries are not permitted to select from.
+func Schema(cfg *gabi.Config) http.HandlerFunc {
+	schemaCache := cache.New(1, schemaCacheTTL)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		query := cfg.DBEnv.Driver.SchemaQuery()
+		cached, ok := schemaCache.Get(schemaCacheKey)
+
+		if err := middleware.AuditQuery(cfg, r, query, ok); err != nil {
+			cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+			http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
+			return
+		}
+
+		timeout, _, err := middleware.QueryTimeout(cfg, r)
+		if err != nil {
+			l := "Invalid query timeout"
+			cfg.Logger.Errorf("%s: %s", l, err)
+			http.Error(w, l, http.StatusBadRequest)
+			return
+		}
+
+		response, _ := cached.(*models.SchemaResponse)
+		if response == nil {
+			tables, err := schemaTables(ctx, cfg, query, timeout)
+			if err != nil {
+				cfg.Logger.Errorf("Unable to introspect database schema: %s", err)
+				_ = queryErrorResponse(w, err, middleware.APIVersion1)
+				return
+			}
+			response = &models.SchemaResponse{Tables: tables}
+			schemaCache.Set(schemaCacheKey, response)
+		}
+
+		w.Header().Set("Cache-Control", "private, no-store")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// A table is only listed when selecting from it would be permitted by the
+// query policy, whether qualified by its schema or not, so that tables denied
+// by policy are not revealed. The schema is read from a read-only transaction
+// bounded by the query timeout, as any query is.
+func schemaTables(ctx context.Context, cfg *gabi.Config, query string, timeout time.Duration) ([]models.Table, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tx, err := cfg.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if stmt := cfg.DBEnv.Driver.StatementTimeout(timeout); stmt != "" {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		var schema, table, column, dataType, nullable string
+		if err := rows.Scan(&schema, &table, &column, &dataType, &nullable); err != nil {
+			return nil, err
+		}
+
+		if cfg.PolicyEnv != nil && (!cfg.PolicyEnv.IsAllowed(fmt.Sprintf("select * from %s.%s;", schema, table)) ||
+			!cfg.PolicyEnv.IsAllowed(fmt.Sprintf("select * from %s;", table))) {
+			continue
+		}
+
+		n := len(tables)
+		if n == 0 || tables[n-1].Schema != schema || tables[n-1].Name != table {
+			tables = append(tables, models.Table{Schema: schema, Name: table})
+			n++
+		}
+
+		isNullable := nullable == "YES"
+		tables[n-1].Columns = append(tables[n-1].Columns, models.Column{
+			Name:     column,
+			Type:     dataType,
+			Nullable: &isNullable,
+		})
+	}
+
+	return tables, rows.Err()
+}
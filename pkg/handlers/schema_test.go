@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	gabidb "github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/policy"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/app-sre/gabi/pkg/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *policy.Env
+		timeout     time.Duration
+		mock        func(sqlmock.Sqlmock)
+		code        int
+		body        string
+	}{
+		{
+			"tables listed",
+			nil,
+			0,
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"}).
+					AddRow("public", "books", "id", "integer", "NO").
+					AddRow("public", "books", "title", "text", "YES").
+					AddRow("public", "persons", "id", "integer", "NO")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"tables":[{"schema":"public","name":"books","columns":[{"name":"id","type":"integer","nullable":false},{"name":"title","type":"text","nullable":true}]},{"schema":"public","name":"persons","columns":[{"name":"id","type":"integer","nullable":false}]}]}`,
+		},
+		{
+			"tables denied by policy left out",
+			&policy.Env{Deny: []*regexp.Regexp{regexp.MustCompile(`(?i)persons`)}},
+			0,
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"}).
+					AddRow("public", "books", "id", "integer", "NO").
+					AddRow("public", "persons", "id", "integer", "NO")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"tables":[{"schema":"public","name":"books","columns":[{"name":"id","type":"integer","nullable":false}]}]}`,
+		},
+		{
+			"tables denied by policy without schema left out",
+			&policy.Env{Deny: []*regexp.Regexp{regexp.MustCompile(`(?i)from persons;`)}},
+			0,
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"}).
+					AddRow("public", "books", "id", "integer", "NO").
+					AddRow("public", "persons", "id", "integer", "NO")
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"tables":[{"schema":"public","name":"books","columns":[{"name":"id","type":"integer","nullable":false}]}]}`,
+		},
+		{
+			"schema read with query timeout",
+			nil,
+			5 * time.Second,
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"}).
+					AddRow("public", "books", "id", "integer", "NO")
+				mock.ExpectBegin()
+				mock.ExpectExec(`set local statement_timeout = 5000;`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"tables":[{"schema":"public","name":"books","columns":[{"name":"id","type":"integer","nullable":false}]}]}`,
+		},
+		{
+			"no tables visible",
+			nil,
+			0,
+			func(mock sqlmock.Sqlmock) {
+				rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"})
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			200,
+			`{"tables":[]}`,
+		},
+		{
+			"unable to query database",
+			nil,
+			0,
+			func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`select table_schema, table_name`).WillReturnError(errors.New("test"))
+				mock.ExpectRollback()
+			},
+			400,
+			`{"result":null,"error":"test"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			db, mock, _ := sqlmock.New()
+			defer func() { _ = db.Close() }()
+
+			tc.mock(mock)
+
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DB:          db,
+				DBEnv:       &gabidb.Env{Driver: "pgx", QueryTimeout: tc.timeout},
+				PolicyEnv:   tc.given,
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+			Schema(expected).ServeHTTP(w, r.WithContext(middleware.WithUser(r.Context(), "test")))
+
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.body, strings.TrimSpace(w.Body.String()))
+			assert.Regexp(t, `AUDIT\s{"user": "test", "query_hash": "[0-9a-f]{16}", "timestamp": \d{10}, "decision": "allowed"}`, output.String())
+		})
+	}
+}
+
+func TestSchemaCache(t *testing.T) {
+	t.Parallel()
+
+	var output, server bytes.Buffer
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&server, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer s.Close()
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	db, mock, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	// The database is only queried once, with the cached schema served after.
+	rows := sqlmock.NewRows([]string{"table_schema", "table_name", "column_name", "data_type", "is_nullable"}).
+		AddRow("public", "books", "id", "integer", "NO")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`select table_schema, table_name`).WillReturnRows(rows)
+	mock.ExpectRollback()
+
+	sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+	sa.SetHTTPClient(http.DefaultClient)
+
+	expected := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DB:          db,
+		DBEnv:       &gabidb.Env{Driver: "pgx"},
+		LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+		SplunkAudit: sa,
+		Logger:      logger,
+	}
+	handler := Schema(expected)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.Header.Set("X-Forwarded-User", "test")
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"books"`)
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 2, strings.Count(server.String(), `"query":"select table_schema, table_name`))
+	assert.Equal(t, 1, strings.Count(server.String(), `"cache_hit":true`))
+}
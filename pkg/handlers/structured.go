@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/app-sre/gabi/pkg/env/db"
+)
+
+// JSON types reported by the supported drivers.
+var jsonTypes = map[string]struct{}{
+	"JSON": {}, "JSONB": {},
+}
+
+// Array types are reported by PostgreSQL using the name of the element type
+// prefixed with an underscore, such as "_INT4".
+const arrayTypePrefix = "_"
+
+// Whitespace is allowed around the elements of arrays.
+const arraySpace = " \t\n\r"
+
+// structuredColumns returns, for each column, the type name of the columns
+// holding JSON documents or arrays, and an empty name for any other column.
+// Arrays are only known to PostgreSQL.
+func structuredColumns(types []*sql.ColumnType, driver db.DriverType) []string {
+	structured := make([]string, len(types))
+	for i, t := range types {
+		name := strings.ToUpper(t.DatabaseTypeName())
+		if _, ok := jsonTypes[name]; ok || driver.HasArrays() && strings.HasPrefix(name, arrayTypePrefix) {
+			structured[i] = name
+		}
+	}
+	return structured
+}
+
+// structuredValue returns JSON documents as they are, rather than as strings,
+// and arrays as JSON arrays, with numeric, boolean and JSON elements given as
+// such. Values that cannot be decoded are returned unchanged.
+func structuredValue(v interface{}, typeName string) interface{} {
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	if strings.HasPrefix(typeName, arrayTypePrefix) {
+		elements, ok := parseArray(s)
+		if !ok {
+			return v
+		}
+		return arrayValue(elements, strings.TrimPrefix(typeName, arrayTypePrefix))
+	}
+	return elementValue(s, typeName)
+}
+
+func arrayValue(elements []interface{}, typeName string) []interface{} {
+	values := make([]interface{}, len(elements))
+	for i, element := range elements {
+		switch e := element.(type) {
+		case []interface{}:
+			values[i] = arrayValue(e, typeName)
+		case string:
+			values[i] = elementValue(e, typeName)
+		}
+	}
+	return values
+}
+
+func elementValue(s, typeName string) interface{} {
+	if _, ok := jsonTypes[typeName]; ok {
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+		return s
+	}
+	if _, ok := numericTypes[typeName]; ok && numberPattern.MatchString(s) {
+		return json.Number(s)
+	}
+	if typeName == "BOOL" && (s == "t" || s == "f") {
+		return s == "t"
+	}
+	return s
+}
+
+// parseArray parses the text representation of a PostgreSQL array, such as
+// {1,2,NULL} or {{"a b","c"},{d,e}}, into its elements, which are either
+// strings, nil for NULL, or nested arrays.
+func parseArray(s string) ([]interface{}, bool) {
+	// Arrays with bounds other than the default are prefixed with these,
+	// such as [0:1]={1,2}.
+	if strings.HasPrefix(s, "[") {
+		i := strings.Index(s, "=")
+		if i < 0 {
+			return nil, false
+		}
+		s = s[i+1:]
+	}
+
+	elements, rest, ok := parseArrayElements(s)
+	if !ok || rest != "" {
+		return nil, false
+	}
+	return elements, true
+}
+
+func parseArrayElements(s string) ([]interface{}, string, bool) {
+	if !strings.HasPrefix(s, "{") {
+		return nil, s, false
+	}
+	s = s[1:]
+
+	elements := []interface{}{}
+	if strings.HasPrefix(s, "}") {
+		return elements, s[1:], true
+	}
+
+	for {
+		var (
+			element interface{}
+			ok      bool
+		)
+		s = strings.TrimLeft(s, arraySpace)
+		switch {
+		case strings.HasPrefix(s, "{"):
+			element, s, ok = parseArrayElements(s)
+		case strings.HasPrefix(s, `"`):
+			element, s, ok = parseQuotedElement(s)
+		default:
+			i := strings.IndexAny(s, ",}")
+			if i < 0 {
+				return nil, s, false
+			}
+			value := strings.TrimSpace(s[:i])
+			if !strings.EqualFold(value, "NULL") {
+				element = value
+			}
+			s, ok = s[i:], true
+		}
+		if !ok {
+			return nil, s, false
+		}
+		elements = append(elements, element)
+
+		s = strings.TrimLeft(s, arraySpace)
+		switch {
+		case strings.HasPrefix(s, ","):
+			s = s[1:]
+		case strings.HasPrefix(s, "}"):
+			return elements, s[1:], true
+		default:
+			return nil, s, false
+		}
+	}
+}
+
+// Quoted elements escape quotes and backslashes using a backslash.
+func parseQuotedElement(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			i++
+			if i < len(s) {
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", s, false
+}
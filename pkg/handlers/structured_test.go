@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredValue(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       interface{}
+		typeName    string
+		expected    interface{}
+	}{
+		{"JSON document", `{"a": 1}`, "JSON", json.RawMessage(`{"a": 1}`)},
+		{"invalid JSON document", `{"a": `, "JSONB", `{"a": `},
+		{"NULL value", nil, "JSONB", nil},
+		{"integer array", "{1,-2,NULL}", "_INT4", []interface{}{json.Number("1"), json.Number("-2"), nil}},
+		{"numeric array with special values", "{1.5,NaN}", "_NUMERIC", []interface{}{json.Number("1.5"), "NaN"}},
+		{"boolean array", "{t,f}", "_BOOL", []interface{}{true, false}},
+		{"text array with quoted elements", `{"a,b","c\\d", "NULL" ,null}`, "_TEXT", []interface{}{"a,b", `c\d`, "NULL", nil}},
+		{"JSONB array", `{"{\"a\": 1}","[]"}`, "_JSONB", []interface{}{json.RawMessage(`{"a": 1}`), json.RawMessage(`[]`)}},
+		{"multidimensional array", "{{1,2},{3,4}}", "_INT8", []interface{}{[]interface{}{json.Number("1"), json.Number("2")}, []interface{}{json.Number("3"), json.Number("4")}}},
+		{"empty array", "{}", "_TEXT", []interface{}{}},
+		{"array with bounds", "[0:1]={a,b}", "_TEXT", []interface{}{"a", "b"}},
+		{"malformed array", "{a,b", "_TEXT", "{a,b"},
+		{"malformed array with trailing data", "{a}b", "_TEXT", "{a}b"},
+		{"malformed array with unterminated quote", `{"a}`, "_TEXT", `{"a}`},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tc.expected, structuredValue(tc.given, tc.typeName))
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/models"
+	"github.com/app-sre/gabi/pkg/version"
+)
+
+func Version(cfg *gabi.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(&models.VersionResponse{
+			Version:   version.Version(),
+			Commit:    version.Commit(),
+			BuildDate: version.BuildDate(),
+			GoVersion: version.GoVersion(),
+		})
+	}
+}
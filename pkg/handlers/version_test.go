@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/models"
+	"github.com/app-sre/gabi/pkg/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	var actual models.VersionResponse
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+	expected := &gabi.Config{Logger: test.DummyLogger(io.Discard).Sugar()}
+	Version(expected).ServeHTTP(w, r)
+
+	err := json.NewDecoder(w.Result().Body).Decode(&actual)
+
+	require.NoError(t, err)
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, version.Version(), actual.Version)
+	assert.NotEmpty(t, actual.Commit)
+	assert.NotEmpty(t, actual.BuildDate)
+	assert.Equal(t, runtime.Version(), actual.GoVersion)
+}
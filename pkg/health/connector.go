@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 1 * time.Second
+	defaultMaxBackoff     = 30 * time.Second
+)
+
+// Connector establishes the first connection to the database on startup,
+// retrying with an exponential backoff, so that the service can be started
+// before the database is ready to accept connections.
+type Connector struct {
+	db      *sql.DB
+	timeout time.Duration
+
+	pingTimeout    time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	onAttempt func(attempt int, err error, delay time.Duration)
+
+	connected atomic.Bool
+}
+
+type ConnectorOption func(*Connector)
+
+// WithBackoff sets the delay before the first retry, which is doubled after
+// every failed attempt, up to the given maximum.
+func WithBackoff(initial, maximum time.Duration) ConnectorOption {
+	return func(c *Connector) {
+		c.initialBackoff = initial
+		c.maxBackoff = maximum
+	}
+}
+
+// WithAttempt sets a callback invoked after every failed attempt, along with
+// the error of the attempt and the delay before the next one.
+func WithAttempt(callback func(attempt int, err error, delay time.Duration)) ConnectorOption {
+	return func(c *Connector) {
+		c.onAttempt = callback
+	}
+}
+
+// NewConnector returns a connector for the given database, which gives up once
+// the timeout elapses without the database becoming reachable.
+func NewConnector(db *sql.DB, timeout time.Duration, options ...ConnectorOption) *Connector {
+	c := &Connector{
+		db:             db,
+		timeout:        timeout,
+		pingTimeout:    defaultPingTimeout,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Connected reports whether the database was reached since startup.
+func (c *Connector) Connected() bool {
+	return c.connected.Load()
+}
+
+// Run pings the database until a ping succeeds, the timeout elapses, or the
+// context is canceled, and returns the error of the last attempt otherwise.
+func (c *Connector) Run(ctx context.Context) error {
+	deadline := time.Now().Add(c.timeout)
+	delay := c.initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := c.ping(ctx)
+		if err == nil {
+			c.connected.Store(true)
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("unable to connect to the database after %d attempt(s): %w", attempt, err)
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		if c.onAttempt != nil {
+			c.onAttempt(attempt, err, delay)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > c.maxBackoff {
+			delay = c.maxBackoff
+		}
+	}
+}
+
+func (c *Connector) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.pingTimeout)
+	defer cancel()
+
+	return c.db.PingContext(ctx)
+}
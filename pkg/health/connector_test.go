@@ -0,0 +1,122 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConnector(t *testing.T) {
+	t.Parallel()
+
+	db, _, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	actual := NewConnector(db, time.Minute)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Connector{}, actual)
+	assert.False(t, actual.Connected())
+	assert.Equal(t, defaultInitialBackoff, actual.initialBackoff)
+	assert.Equal(t, defaultMaxBackoff, actual.maxBackoff)
+}
+
+func TestConnectorRun(t *testing.T) {
+	t.Parallel()
+
+	type attempt struct {
+		attempt int
+		delay   time.Duration
+	}
+
+	cases := []struct {
+		description string
+		failures    int
+		timeout     time.Duration
+		error       bool
+		want        []attempt
+	}{
+		{
+			"connected on first attempt",
+			0,
+			time.Minute,
+			false,
+			nil,
+		},
+		{
+			"connected after retries with backoff",
+			3,
+			time.Minute,
+			false,
+			[]attempt{{1, 10 * time.Millisecond}, {2, 20 * time.Millisecond}, {3, 25 * time.Millisecond}},
+		},
+		{
+			"timeout elapsed before connecting",
+			100,
+			50 * time.Millisecond,
+			true,
+			nil,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			defer func() { _ = db.Close() }()
+
+			for i := 0; i < tc.failures; i++ {
+				mock.ExpectPing().WillReturnError(errors.New("test"))
+			}
+			mock.ExpectPing()
+
+			var attempts []attempt
+			c := NewConnector(db, tc.timeout,
+				WithBackoff(10*time.Millisecond, 25*time.Millisecond),
+				WithAttempt(func(n int, err error, delay time.Duration) {
+					attempts = append(attempts, attempt{n, delay})
+				}),
+			)
+
+			err := c.Run(context.TODO())
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unable to connect to the database after")
+				assert.False(t, c.Connected())
+				return
+			}
+
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+			assert.True(t, c.Connected())
+			assert.Equal(t, tc.want, attempts)
+		})
+	}
+}
+
+func TestConnectorRunCanceled(t *testing.T) {
+	t.Parallel()
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectPing().WillReturnError(errors.New("test"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewConnector(db, time.Minute, WithAttempt(func(int, error, time.Duration) {
+		cancel()
+	}))
+
+	err := c.Run(ctx)
+
+	require.ErrorIs(t, err, context.Canceled)
+	assert.False(t, c.Connected())
+}
@@ -0,0 +1,99 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+const defaultPingTimeout = 5 * time.Second
+
+// Pinger periodically pings the database connection pool, which keeps idle
+// connections from going stale, and detects outages before queries fail.
+type Pinger struct {
+	db       *sql.DB
+	interval time.Duration
+	timeout  time.Duration
+
+	onStateChange func(healthy bool, err error)
+
+	mu      sync.Mutex
+	healthy bool
+}
+
+type PingerOption func(*Pinger)
+
+func WithPingTimeout(timeout time.Duration) PingerOption {
+	return func(p *Pinger) {
+		p.timeout = timeout
+	}
+}
+
+// WithStateChange sets a callback invoked whenever the database transitions
+// between healthy and unhealthy, along with the error of the failed ping.
+func WithStateChange(callback func(healthy bool, err error)) PingerOption {
+	return func(p *Pinger) {
+		p.onStateChange = callback
+	}
+}
+
+// NewPinger returns a pinger for the given database, which is assumed to be
+// healthy until a ping fails.
+func NewPinger(db *sql.DB, interval time.Duration, options ...PingerOption) *Pinger {
+	p := &Pinger{
+		db:       db,
+		interval: interval,
+		timeout:  defaultPingTimeout,
+		healthy:  true,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	return p
+}
+
+func (p *Pinger) Healthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.healthy
+}
+
+// Ping pings the database once, and updates the health accordingly.
+func (p *Pinger) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	err := p.db.PingContext(ctx)
+	healthy := err == nil
+
+	p.mu.Lock()
+	changed := p.healthy != healthy
+	p.healthy = healthy
+	p.mu.Unlock()
+
+	if changed && p.onStateChange != nil {
+		p.onStateChange(healthy, err)
+	}
+
+	return err
+}
+
+// Run pings the database at the configured interval until the context is
+// canceled.
+func (p *Pinger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = p.Ping(ctx)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPinger(t *testing.T) {
+	t.Parallel()
+
+	db, _, _ := sqlmock.New()
+	defer func() { _ = db.Close() }()
+
+	actual := NewPinger(db, time.Minute)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Pinger{}, actual)
+	assert.True(t, actual.Healthy())
+	assert.Equal(t, defaultPingTimeout, actual.timeout)
+}
+
+func TestPingerPing(t *testing.T) {
+	t.Parallel()
+
+	type change struct {
+		healthy bool
+		err     error
+	}
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectPing()
+	mock.ExpectPing().WillReturnError(errors.New("test"))
+	mock.ExpectPing().WillReturnError(errors.New("test"))
+	mock.ExpectPing()
+
+	var changes []change
+	p := NewPinger(db, time.Minute, WithStateChange(func(healthy bool, err error) {
+		changes = append(changes, change{healthy, err})
+	}))
+
+	require.NoError(t, p.Ping(context.TODO()))
+	assert.True(t, p.Healthy())
+
+	require.Error(t, p.Ping(context.TODO()))
+	assert.False(t, p.Healthy())
+
+	require.Error(t, p.Ping(context.TODO()))
+	assert.False(t, p.Healthy())
+
+	require.NoError(t, p.Ping(context.TODO()))
+	assert.True(t, p.Healthy())
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []change{{false, errors.New("test")}, {true, nil}}, changes)
+}
+
+func TestPingerRun(t *testing.T) {
+	t.Parallel()
+
+	db, mock, _ := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectPing().WillReturnError(errors.New("test"))
+
+	var (
+		mu      sync.Mutex
+		healthy = true
+	)
+	p := NewPinger(db, 10*time.Millisecond, WithStateChange(func(h bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		healthy = h
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go p.Run(ctx)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return !healthy
+	}, time.Second, 10*time.Millisecond)
+	assert.False(t, p.Healthy())
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Watchdog tracks the progress of request handling, which has stalled once no
+// request completed for longer than the window while requests are in flight,
+// such as when every handler is blocked on a stuck audit write. An idle
+// service never stalls, as the window starts anew with the first request.
+type Watchdog struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu       sync.Mutex
+	inFlight int
+	progress time.Time
+}
+
+type WatchdogOption func(*Watchdog)
+
+// WithWatchdogClock sets the function returning the current time, for tests.
+func WithWatchdogClock(now func() time.Time) WatchdogOption {
+	return func(w *Watchdog) {
+		w.now = now
+	}
+}
+
+// NewWatchdog returns a watchdog considering request handling stalled after
+// the given window elapses without any request completing.
+func NewWatchdog(window time.Duration, options ...WatchdogOption) *Watchdog {
+	w := &Watchdog{
+		window: window,
+		now:    time.Now,
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	return w
+}
+
+// Started records a request being received.
+func (w *Watchdog) Started() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inFlight == 0 {
+		w.progress = w.now()
+	}
+	w.inFlight++
+}
+
+// Finished records a request having completed, whatever its outcome.
+func (w *Watchdog) Finished() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.inFlight--
+	w.progress = w.now()
+}
+
+// Stalled reports whether request handling has stalled, along with the number
+// of requests in flight, and how long ago the last one completed.
+func (w *Watchdog) Stalled() (bool, int, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inFlight == 0 {
+		return false, 0, 0
+	}
+	since := w.now().Sub(w.progress)
+	return since > w.window, w.inFlight, since
+}
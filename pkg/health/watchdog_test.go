@@ -0,0 +1,62 @@
+package health
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWatchdog(t *testing.T) {
+	t.Parallel()
+
+	actual := NewWatchdog(time.Minute)
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Watchdog{}, actual)
+
+	stalled, inFlight, _ := actual.Stalled()
+	assert.False(t, stalled)
+	assert.Zero(t, inFlight)
+}
+
+func TestWatchdogStalled(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := NewWatchdog(time.Minute, WithWatchdogClock(func() time.Time { return now }))
+
+	// An idle service never stalls, however long it stays idle.
+	now = now.Add(time.Hour)
+	stalled, _, _ := w.Stalled()
+	assert.False(t, stalled)
+
+	// The window starts with the first request in flight.
+	w.Started()
+	w.Started()
+	now = now.Add(time.Minute)
+	stalled, inFlight, since := w.Stalled()
+	assert.False(t, stalled)
+	assert.Equal(t, 2, inFlight)
+	assert.Equal(t, time.Minute, since)
+
+	// Any request completing is progress.
+	now = now.Add(30 * time.Second)
+	w.Finished()
+	now = now.Add(45 * time.Second)
+	stalled, inFlight, _ = w.Stalled()
+	assert.False(t, stalled)
+	assert.Equal(t, 1, inFlight)
+
+	now = now.Add(30 * time.Second)
+	stalled, inFlight, since = w.Stalled()
+	assert.True(t, stalled)
+	assert.Equal(t, 1, inFlight)
+	assert.Equal(t, 75*time.Second, since)
+
+	w.Finished()
+	stalled, inFlight, _ = w.Stalled()
+	assert.False(t, stalled)
+	assert.Zero(t, inFlight)
+}
@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The range of levels the logger can be changed to at runtime, where the least
+// verbose level still allows the change itself to be logged.
+const (
+	mostVerboseLevel  = zapcore.DebugLevel
+	leastVerboseLevel = zapcore.WarnLevel
+)
+
+// IncreaseVerbosity lowers the level by one step, down to the debug level,
+// and returns the resulting level.
+func IncreaseVerbosity(level zap.AtomicLevel) zapcore.Level {
+	if l := level.Level(); l > mostVerboseLevel {
+		level.SetLevel(l - 1)
+	}
+	return level.Level()
+}
+
+// DecreaseVerbosity raises the level by one step, up to the warning level,
+// and returns the resulting level.
+func DecreaseVerbosity(level zap.AtomicLevel) zapcore.Level {
+	if l := level.Level(); l < leastVerboseLevel {
+		level.SetLevel(l + 1)
+	}
+	return level.Level()
+}
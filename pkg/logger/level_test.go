@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestIncreaseVerbosity(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       zapcore.Level
+		expected    zapcore.Level
+	}{
+		{"from warning level", zapcore.WarnLevel, zapcore.InfoLevel},
+		{"from info level", zapcore.InfoLevel, zapcore.DebugLevel},
+		{"from debug level", zapcore.DebugLevel, zapcore.DebugLevel},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			level := zap.NewAtomicLevelAt(tc.given)
+
+			assert.Equal(t, tc.expected, IncreaseVerbosity(level))
+			assert.Equal(t, tc.expected, level.Level())
+		})
+	}
+}
+
+func TestDecreaseVerbosity(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       zapcore.Level
+		expected    zapcore.Level
+	}{
+		{"from debug level", zapcore.DebugLevel, zapcore.InfoLevel},
+		{"from info level", zapcore.InfoLevel, zapcore.WarnLevel},
+		{"from warning level", zapcore.WarnLevel, zapcore.WarnLevel},
+		{"from error level", zapcore.ErrorLevel, zapcore.ErrorLevel},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			level := zap.NewAtomicLevelAt(tc.given)
+
+			assert.Equal(t, tc.expected, DecreaseVerbosity(level))
+			assert.Equal(t, tc.expected, level.Level())
+		})
+	}
+}
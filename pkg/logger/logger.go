@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/app-sre/gabi/pkg/env/logging"
+)
+
+// New builds a logger using the given level, which can then be changed at
+// runtime.
+func New(env *logging.Env, level zap.AtomicLevel) (*zap.Logger, error) {
+	cfg := zap.NewProductionConfig()
+	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	cfg.Sampling = nil
+
+	if env.Format == logging.FormatText {
+		cfg = zap.NewDevelopmentConfig()
+	}
+	cfg.Level = level
+
+	l, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build logger: %w", err)
+	}
+
+	return l, nil
+}
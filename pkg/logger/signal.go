@@ -0,0 +1,41 @@
+//go:build !windows
+
+package logger
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WatchLevel changes the level at runtime, where SIGUSR1 increases and SIGUSR2
+// decreases the verbosity, until the context is canceled.
+func WatchLevel(ctx context.Context, logger *zap.SugaredLogger, level zap.AtomicLevel) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-signals:
+				from := level.Level()
+
+				var to zapcore.Level
+				if sig == syscall.SIGUSR1 {
+					to = IncreaseVerbosity(level)
+				} else {
+					to = DecreaseVerbosity(level)
+				}
+				logger.Warnf("Log level changed: %s -> %s (signal: %s)", from, to, sig)
+			}
+		}
+	}()
+}
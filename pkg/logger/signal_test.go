@@ -0,0 +1,61 @@
+//go:build !windows
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestWatchLevel(t *testing.T) {
+	var output syncBuffer
+
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	logger := zap.New(zapcore.NewCore(encoder, zapcore.AddSync(&output), level)).Sugar()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	WatchLevel(ctx, logger, level)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool {
+		return level.Level() == zapcore.DebugLevel
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool {
+		return strings.Contains(output.String(), "Log level changed: debug -> info")
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Contains(t, output.String(), "Log level changed: info -> debug (signal: user defined signal 1)")
+	assert.Equal(t, zapcore.InfoLevel, level.Level())
+}
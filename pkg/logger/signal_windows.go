@@ -0,0 +1,11 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// WatchLevel is a no-op, as changing the level using signals is not supported
+// on Windows.
+func WatchLevel(ctx context.Context, logger *zap.SugaredLogger, level zap.AtomicLevel) {}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsSource is implemented by database connection pools, such as sql.DB.
+type StatsSource interface {
+	Stats() sql.DBStats
+}
+
+// The statistics are read from every registered connection pool whenever the
+// metrics are scraped, thus these are never stale.
+type dbStatsCollector struct {
+	mu      sync.RWMutex
+	sources map[string]StatsSource
+
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*dbStatsCollector)(nil)
+
+func newDBStatsCollector(labels prometheus.Labels) *dbStatsCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "db", name), help, []string{"database"}, labels)
+	}
+
+	return &dbStatsCollector{
+		sources:      make(map[string]StatsSource),
+		maxOpen:      desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:         desc("open_connections", "Number of established connections to the database, both in use and idle."),
+		inUse:        desc("in_use_connections", "Number of connections to the database currently in use."),
+		idle:         desc("idle_connections", "Number of idle connections to the database."),
+		waitCount:    desc("wait_count_total", "Total number of connections waited for."),
+		waitDuration: desc("wait_duration_seconds_total", "Total time spent waiting for new connections."),
+	}
+}
+
+func (c *dbStatsCollector) add(database string, source StatsSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sources[database] = source
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	databases := make([]string, 0, len(c.sources))
+	for database := range c.sources {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+
+	for _, database := range databases {
+		stats := c.sources[database].Stats()
+
+		ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections), database)
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections), database)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), database)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), database)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), database)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), database)
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStats sql.DBStats
+
+func (f fakeStats) Stats() sql.DBStats {
+	return sql.DBStats(f)
+}
+
+func TestObserveDB(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.ObserveDB("main", fakeStats{MaxOpenConnections: 10, OpenConnections: 4, InUse: 3, Idle: 1, WaitCount: 5, WaitDuration: 2 * time.Second})
+	m.ObserveDB("replica", fakeStats{OpenConnections: 1, Idle: 1})
+
+	expected := `
+# HELP gabi_db_idle_connections Number of idle connections to the database.
+# TYPE gabi_db_idle_connections gauge
+gabi_db_idle_connections{database="main",namespace="test"} 1
+gabi_db_idle_connections{database="replica",namespace="test"} 1
+# HELP gabi_db_in_use_connections Number of connections to the database currently in use.
+# TYPE gabi_db_in_use_connections gauge
+gabi_db_in_use_connections{database="main",namespace="test"} 3
+gabi_db_in_use_connections{database="replica",namespace="test"} 0
+# HELP gabi_db_max_open_connections Maximum number of open connections to the database.
+# TYPE gabi_db_max_open_connections gauge
+gabi_db_max_open_connections{database="main",namespace="test"} 10
+gabi_db_max_open_connections{database="replica",namespace="test"} 0
+# HELP gabi_db_open_connections Number of established connections to the database, both in use and idle.
+# TYPE gabi_db_open_connections gauge
+gabi_db_open_connections{database="main",namespace="test"} 4
+gabi_db_open_connections{database="replica",namespace="test"} 1
+# HELP gabi_db_wait_count_total Total number of connections waited for.
+# TYPE gabi_db_wait_count_total counter
+gabi_db_wait_count_total{database="main",namespace="test"} 5
+gabi_db_wait_count_total{database="replica",namespace="test"} 0
+# HELP gabi_db_wait_duration_seconds_total Total time spent waiting for new connections.
+# TYPE gabi_db_wait_duration_seconds_total counter
+gabi_db_wait_duration_seconds_total{database="main",namespace="test"} 2
+gabi_db_wait_duration_seconds_total{database="replica",namespace="test"} 0
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"gabi_db_max_open_connections", "gabi_db_open_connections", "gabi_db_in_use_connections",
+		"gabi_db_idle_connections", "gabi_db_wait_count_total", "gabi_db_wait_duration_seconds_total",
+	)
+	require.NoError(t, err)
+}
+
+func TestObserveDBWithoutDatabases(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(""), "gabi_db_open_connections")
+	require.NoError(t, err)
+}
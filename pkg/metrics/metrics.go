@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const metricsNamespace = "gabi"
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	queriesTotal     *prometheus.CounterVec
+	userQueriesTotal *prometheus.CounterVec
+	responsesTotal   *prometheus.CounterVec
+	queryDuration    *prometheus.HistogramVec
+	requestDuration  *prometheus.HistogramVec
+	breakerState     prometheus.Gauge
+	spoolDepth       prometheus.Gauge
+	spoolBytes       prometheus.Gauge
+	spoolOverflows   *prometheus.CounterVec
+	queriesInFlight  prometheus.Gauge
+	tlsInsecure      prometheus.Gauge
+	dbStats          *dbStatsCollector
+	exemplars        bool
+}
+
+type Option func(*Metrics)
+
+// WithExemplars attaches the ID of the trace, when sampled, as an exemplar to
+// the latency observations, which are only exposed in the OpenMetrics format.
+func WithExemplars(enabled bool) Option {
+	return func(m *Metrics) {
+		m.exemplars = enabled
+	}
+}
+
+// New creates and registers all collectors with a dedicated registry, so that
+// multiple instances, such as created in tests, never collide with each other.
+func New(namespace string, options ...Option) *Metrics {
+	labels := prometheus.Labels{"namespace": namespace}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		queriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "queries_total",
+			Help:        "Total number of executed queries by result status.",
+			ConstLabels: labels,
+		}, []string{"status"}),
+		userQueriesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "user_queries_total",
+			Help:        "Total number of executed queries by user.",
+			ConstLabels: labels,
+		}, []string{"user"}),
+		responsesTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "responses_total",
+			Help:        "Total number of query endpoint responses by status code.",
+			ConstLabels: labels,
+		}, []string{"code"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "query_duration_seconds",
+			Help:        "Time spent executing queries against the database.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   metricsNamespace,
+			Name:        "request_duration_seconds",
+			Help:        "End-to-end latency of query endpoint requests.",
+			ConstLabels: labels,
+			Buckets:     prometheus.DefBuckets,
+		}, []string{"code"}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "audit_circuit_breaker_state",
+			Help:        "State of the Splunk audit circuit breaker (0 closed, 1 half-open, 2 open).",
+			ConstLabels: labels,
+		}),
+		spoolDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "audit_spool_depth",
+			Help:        "Number of audit events spooled on disk, awaiting delivery.",
+			ConstLabels: labels,
+		}),
+		spoolBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "audit_spool_bytes",
+			Help:        "Total size of the audit events spooled on disk, awaiting delivery.",
+			ConstLabels: labels,
+		}),
+		spoolOverflows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "audit_spool_overflows_total",
+			Help:        "Total number of audit events that did not fit into the spool, by whether these were dropped or rejected.",
+			ConstLabels: labels,
+		}, []string{"action"}),
+		queriesInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "queries_in_flight",
+			Help:        "Number of queries currently being executed.",
+			ConstLabels: labels,
+		}),
+		tlsInsecure: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "audit_tls_insecure",
+			Help:        "Whether TLS verification is disabled for any audit endpoint (1 insecure, 0 verified).",
+			ConstLabels: labels,
+		}),
+		dbStats: newDBStatsCollector(labels),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.queriesTotal,
+		m.userQueriesTotal,
+		m.responsesTotal,
+		m.queryDuration,
+		m.requestDuration,
+		m.breakerState,
+		m.spoolDepth,
+		m.spoolBytes,
+		m.spoolOverflows,
+		m.queriesInFlight,
+		m.tlsInsecure,
+		m.dbStats,
+	)
+
+	return m
+}
+
+// Handler serves the OpenMetrics format to scrapers asking for it only when
+// exemplars are enabled, as not every Prometheus setup accepts these.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{EnableOpenMetrics: m.exemplars})
+}
+
+func (m *Metrics) ObserveQuery(ctx context.Context, user, status string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.queriesTotal.WithLabelValues(status).Inc()
+	m.userQueriesTotal.WithLabelValues(user).Inc()
+	m.observe(ctx, m.queryDuration.WithLabelValues(status), duration)
+}
+
+func (m *Metrics) ObserveRequest(ctx context.Context, code int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	s := strconv.Itoa(code)
+	m.responsesTotal.WithLabelValues(s).Inc()
+	m.observe(ctx, m.requestDuration.WithLabelValues(s), duration)
+}
+
+// Only sampled traces are linked to, as others never reach the tracing backend.
+func (m *Metrics) observe(ctx context.Context, o prometheus.Observer, duration time.Duration) {
+	sc := trace.SpanContextFromContext(ctx)
+	if e, ok := o.(prometheus.ExemplarObserver); ok && m.exemplars && sc.IsSampled() {
+		e.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+	o.Observe(duration.Seconds())
+}
+
+func (m *Metrics) SetAuditBreakerState(state int) {
+	if m == nil {
+		return
+	}
+	m.breakerState.Set(float64(state))
+}
+
+func (m *Metrics) SetAuditSpoolDepth(depth int) {
+	if m == nil {
+		return
+	}
+	m.spoolDepth.Set(float64(depth))
+}
+
+func (m *Metrics) SetAuditSpoolBytes(bytes int64) {
+	if m == nil {
+		return
+	}
+	m.spoolBytes.Set(float64(bytes))
+}
+
+func (m *Metrics) ObserveAuditSpoolOverflow(dropped bool) {
+	if m == nil {
+		return
+	}
+	action := "rejected"
+	if dropped {
+		action = "dropped"
+	}
+	m.spoolOverflows.WithLabelValues(action).Inc()
+}
+
+func (m *Metrics) SetQueriesInFlight(n int) {
+	if m == nil {
+		return
+	}
+	m.queriesInFlight.Set(float64(n))
+}
+
+// ObserveDB exports the connection pool statistics of the given database,
+// labelled by its name, replacing any pool previously observed under it.
+func (m *Metrics) ObserveDB(database string, db StatsSource) {
+	if m == nil {
+		return
+	}
+	m.dbStats.add(database, db)
+}
+
+func (m *Metrics) SetAuditTLSInsecure(insecure bool) {
+	if m == nil {
+		return
+	}
+	value := 0.0
+	if insecure {
+		value = 1
+	}
+	m.tlsInsecure.Set(value)
+}
@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	actual := New("test")
+
+	require.NotNil(t, actual)
+	assert.IsType(t, &Metrics{}, actual)
+
+	// Creating another instance must not collide with collectors already registered.
+	assert.NotPanics(t, func() { _ = New("test") })
+}
+
+func TestObserveQuery(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.ObserveQuery(context.TODO(), "test", StatusSuccess, time.Second)
+	m.ObserveQuery(context.TODO(), "test", StatusError, time.Second)
+	m.ObserveQuery(context.TODO(), "test2", StatusSuccess, time.Second)
+
+	expected := `
+# HELP gabi_queries_total Total number of executed queries by result status.
+# TYPE gabi_queries_total counter
+gabi_queries_total{namespace="test",status="error"} 1
+gabi_queries_total{namespace="test",status="success"} 2
+# HELP gabi_user_queries_total Total number of executed queries by user.
+# TYPE gabi_user_queries_total counter
+gabi_user_queries_total{namespace="test",user="test"} 2
+gabi_user_queries_total{namespace="test",user="test2"} 1
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"gabi_queries_total", "gabi_user_queries_total",
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.queryDuration))
+}
+
+func TestObserveRequest(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.ObserveRequest(context.TODO(), http.StatusOK, time.Second)
+	m.ObserveRequest(context.TODO(), http.StatusForbidden, time.Second)
+	m.ObserveRequest(context.TODO(), http.StatusOK, time.Second)
+
+	expected := `
+# HELP gabi_responses_total Total number of query endpoint responses by status code.
+# TYPE gabi_responses_total counter
+gabi_responses_total{code="200",namespace="test"} 2
+gabi_responses_total{code="403",namespace="test"} 1
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_responses_total")
+	require.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requestDuration))
+}
+
+func TestSetAuditBreakerState(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.SetAuditBreakerState(2)
+
+	expected := `
+# HELP gabi_audit_circuit_breaker_state State of the Splunk audit circuit breaker (0 closed, 1 half-open, 2 open).
+# TYPE gabi_audit_circuit_breaker_state gauge
+gabi_audit_circuit_breaker_state{namespace="test"} 2
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_audit_circuit_breaker_state")
+	require.NoError(t, err)
+}
+
+func TestSetAuditSpoolDepth(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.SetAuditSpoolDepth(3)
+
+	expected := `
+# HELP gabi_audit_spool_depth Number of audit events spooled on disk, awaiting delivery.
+# TYPE gabi_audit_spool_depth gauge
+gabi_audit_spool_depth{namespace="test"} 3
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_audit_spool_depth")
+	require.NoError(t, err)
+}
+
+func TestSetAuditSpoolBytes(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.SetAuditSpoolBytes(1024)
+
+	expected := `
+# HELP gabi_audit_spool_bytes Total size of the audit events spooled on disk, awaiting delivery.
+# TYPE gabi_audit_spool_bytes gauge
+gabi_audit_spool_bytes{namespace="test"} 1024
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_audit_spool_bytes")
+	require.NoError(t, err)
+}
+
+func TestObserveAuditSpoolOverflow(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.ObserveAuditSpoolOverflow(true)
+	m.ObserveAuditSpoolOverflow(true)
+	m.ObserveAuditSpoolOverflow(false)
+
+	expected := `
+# HELP gabi_audit_spool_overflows_total Total number of audit events that did not fit into the spool, by whether these were dropped or rejected.
+# TYPE gabi_audit_spool_overflows_total counter
+gabi_audit_spool_overflows_total{action="dropped",namespace="test"} 2
+gabi_audit_spool_overflows_total{action="rejected",namespace="test"} 1
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_audit_spool_overflows_total")
+	require.NoError(t, err)
+}
+
+func TestSetQueriesInFlight(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.SetQueriesInFlight(4)
+
+	expected := `
+# HELP gabi_queries_in_flight Number of queries currently being executed.
+# TYPE gabi_queries_in_flight gauge
+gabi_queries_in_flight{namespace="test"} 4
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_queries_in_flight")
+	require.NoError(t, err)
+}
+
+func TestSetAuditTLSInsecure(t *testing.T) {
+	t.Parallel()
+
+	m := New("test")
+	m.SetAuditTLSInsecure(true)
+
+	expected := `
+# HELP gabi_audit_tls_insecure Whether TLS verification is disabled for any audit endpoint (1 insecure, 0 verified).
+# TYPE gabi_audit_tls_insecure gauge
+gabi_audit_tls_insecure{namespace="test"} 1
+`
+	err := testutil.GatherAndCompare(m.registry, strings.NewReader(expected), "gabi_audit_tls_insecure")
+	require.NoError(t, err)
+}
+
+func TestObserveWithoutMetrics(t *testing.T) {
+	t.Parallel()
+
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ObserveQuery(context.TODO(), "test", StatusSuccess, time.Second)
+		m.ObserveRequest(context.TODO(), http.StatusOK, time.Second)
+		m.SetAuditBreakerState(0)
+		m.SetAuditSpoolDepth(0)
+		m.SetAuditSpoolBytes(0)
+		m.ObserveAuditSpoolOverflow(false)
+		m.SetQueriesInFlight(0)
+		m.SetAuditTLSInsecure(false)
+		m.ObserveDB("test", fakeStats{})
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	var body bytes.Buffer
+
+	m := New("test")
+	m.ObserveQuery(context.TODO(), "test", StatusSuccess, time.Second)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/metrics", &bytes.Buffer{})
+
+	m.Handler().ServeHTTP(w, r)
+
+	actual := w.Result()
+	defer func() { _ = actual.Body.Close() }()
+
+	_, _ = io.Copy(&body, actual.Body)
+
+	assert.Equal(t, http.StatusOK, actual.StatusCode)
+	assert.Contains(t, body.String(), `gabi_queries_total{namespace="test",status="success"} 1`)
+	assert.Contains(t, body.String(), `go_goroutines`)
+}
+
+func TestExemplars(t *testing.T) {
+	t.Parallel()
+
+	traceID := trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+	cases := []struct {
+		description string
+		exemplars   bool
+		flags       trace.TraceFlags
+		want        bool
+	}{
+		{"exemplars enabled with sampled trace", true, trace.FlagsSampled, true},
+		{"exemplars enabled with trace not sampled", true, 0, false},
+		{"exemplars disabled", false, trace.FlagsSampled, false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body bytes.Buffer
+
+			ctx := trace.ContextWithSpanContext(context.TODO(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    traceID,
+				SpanID:     trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+				TraceFlags: tc.flags,
+			}))
+
+			m := New("test", WithExemplars(tc.exemplars))
+			m.ObserveQuery(ctx, "test", StatusSuccess, time.Second)
+			m.ObserveRequest(ctx, http.StatusOK, time.Second)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/metrics", &bytes.Buffer{})
+			r.Header.Set("Accept", "application/openmetrics-text")
+
+			m.Handler().ServeHTTP(w, r)
+
+			_, _ = io.Copy(&body, w.Result().Body)
+
+			exemplar := `# {trace_id="0102030405060708090a0b0c0d0e0f10"} 1`
+			if tc.want {
+				assert.Regexp(t, `gabi_query_duration_seconds_bucket\{[^}]*\} 1 `+regexp.QuoteMeta(exemplar), body.String())
+				assert.Regexp(t, `gabi_request_duration_seconds_bucket\{[^}]*\} 1 `+regexp.QuoteMeta(exemplar), body.String())
+			} else {
+				assert.NotContains(t, body.String(), exemplar)
+			}
+			assert.Contains(t, body.String(), `gabi_queries_total{namespace="test",status="success"} 1`)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+)
+
+// Admin refuses requests from users other than the administrators, in
+// production only, for endpoints meant for operating GABI itself rather than
+// for querying the database. The attempts are audited, as for any other
+// refused request.
+func Admin(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		if !cfg.Production {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := User(r.Context())
+			if cfg.AuthEnv != nil && cfg.AuthEnv.IsAdmin(user) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			l := "User is not an administrator"
+			cfg.Logger.Errorf("%s: %s", l, user)
+			AuditRejection(cfg, r, "", audit.DecisionDeniedAuth, l)
+			http.Error(w, l, http.StatusForbidden)
+		})
+	}
+}
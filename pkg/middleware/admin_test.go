@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		production  bool
+		given       *auth.Env
+		user        string
+		code        int
+		audit       string
+	}{
+		{
+			"any user outside of production",
+			false,
+			&auth.Env{},
+			"test",
+			200,
+			``,
+		},
+		{
+			"administrator in production",
+			true,
+			&auth.Env{Admins: []string{"admin", "test"}},
+			"test",
+			200,
+			``,
+		},
+		{
+			"user other than administrators in production",
+			true,
+			&auth.Env{Admins: []string{"admin"}},
+			"test",
+			403,
+			`"rejection": "User is not an administrator"`,
+		},
+		{
+			"no administrators set in production",
+			true,
+			nil,
+			"test",
+			403,
+			`"rejection": "User is not an administrator"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{AuthEnv: tc.given, LoggerAudit: la, SplunkAudit: la, Logger: logger, Production: tc.production}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+			r = r.WithContext(WithUser(r.Context(), tc.user))
+
+			Admin(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, output.String(), tc.audit)
+		})
+	}
+}
@@ -4,31 +4,39 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel/codes"
+
 	gabi "github.com/app-sre/gabi/pkg"
 	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/cache"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/db"
 	"github.com/app-sre/gabi/pkg/models"
+	"github.com/app-sre/gabi/pkg/telemetry"
 )
 
 func Audit(cfg *gabi.Config) Middleware {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
-			now := time.Now()
+			now := auditNow(cfg)
 
 			var (
 				b       bytes.Buffer
 				request models.QueryRequest
-				user    string
 			)
 
 			if s := r.Header.Get(contentLengthHeader); s == "" {
 				l := fmt.Sprintf("Request without required header: %s", contentLengthHeader)
+				AuditRejection(cfg, r, "", audit.DecisionError, l)
 				http.Error(w, l, http.StatusBadRequest)
 				return
 			}
@@ -40,20 +48,23 @@ func Audit(cfg *gabi.Config) Middleware {
 				}
 			}
 
-			if ctxUser := ctx.Value(ContextKeyUser); ctxUser != nil {
-				if s, ok := ctxUser.(string); ok {
-					user = s
-				}
-			} else {
-				user = r.Header.Get(forwardedUserHeader)
-			}
+			user := requestUser(cfg, r)
 			if user == "" {
-				l := fmt.Sprintf("Request without required header: %s", forwardedUserHeader)
+				l := fmt.Sprintf("Request without required header: %s", proxyEnv(cfg).UserHeader)
+				AuditRejection(cfg, r, "", audit.DecisionError, l)
 				http.Error(w, l, http.StatusBadRequest)
 				return
 			}
 
 			if _, err := io.Copy(&b, r.Body); err != nil {
+				if IsRequestTooLarge(err) {
+					RequestTooLarge(cfg, w, r)
+					return
+				}
+				if IsMalformedBody(err) {
+					MalformedBody(cfg, w, r, err)
+					return
+				}
 				cfg.Logger.Errorf("Unable to copy request body: %s", err)
 				http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
 				return
@@ -65,6 +76,8 @@ func Audit(cfg *gabi.Config) Middleware {
 			err := json.Unmarshal(b.Bytes(), &request)
 			if err != nil {
 				cfg.Logger.Debugf("Unable to unmarshal request body: %s", err)
+				// The handler answers as it does without auditing.
+				AuditRejection(cfg, r, "", audit.DecisionError, "Unable to decode request body")
 				h.ServeHTTP(w, r)
 				return
 			}
@@ -74,27 +87,516 @@ func Audit(cfg *gabi.Config) Middleware {
 				if err != nil {
 					l := "Unable to decode Base64-encoded query"
 					cfg.Logger.Errorf("%s: %s", l, err)
+					AuditRejection(cfg, r, "", audit.DecisionError, l)
 					http.Error(w, l, http.StatusBadRequest)
 					return
 				}
 				request.Query = string(bytes)
 			}
 
+			// Redacting literals parses the query, thus the size is checked first.
+			if QueryTooLarge(cfg, w, r, request.Query) {
+				return
+			}
+
+			timeout, _, err := QueryTimeout(cfg, r)
+			if err != nil {
+				l := "Invalid query timeout"
+				cfg.Logger.Errorf("%s: %s", l, err)
+				AuditRejection(cfg, r, request.Query, audit.DecisionError, l)
+				http.Error(w, l, http.StatusBadRequest)
+				return
+			}
+
+			var fields, names map[string]string
+			if cfg.AuditingEnv != nil {
+				fields, names = cfg.AuditingEnv.Fields, cfg.AuditingEnv.FieldNames
+			}
+			if err := audit.ValidateTags(request.Tags, fields, names); err != nil {
+				l := "Invalid query tags"
+				cfg.Logger.Errorf("%s: %s", l, err)
+				AuditRejection(cfg, r, request.Query, audit.DecisionError, fmt.Sprintf("%s: %s", l, err))
+				http.Error(w, fmt.Sprintf("%s: %s", l, err), http.StatusBadRequest)
+				return
+			}
+			if len(request.Tags) > 0 {
+				ctx = context.WithValue(ctx, ContextKeyTags, request.Tags)
+			}
+			// A database that is not permitted is refused before the query is
+			// audited as started, yet audited as selected.
+			if request.Database != "" {
+				ctx = WithDatabase(ctx, request.Database)
+				if cfg.DBEnv == nil || !cfg.DBEnv.IsAllowedDatabase(request.Database) {
+					l := fmt.Sprintf("Database is not permitted: %s", request.Database)
+					cfg.Logger.Errorf("%s: %s", l, user)
+					AuditRejection(cfg, r.WithContext(ctx), request.Query, audit.DecisionDeniedPolicy, l)
+					http.Error(w, l, http.StatusForbidden)
+					return
+				}
+			}
+
+			if _, err := APIVersion(r); err != nil {
+				l := "Unsupported API version"
+				cfg.Logger.Errorf("%s: %s", l, err)
+				AuditRejection(cfg, r, request.Query, audit.DecisionError, l)
+				http.Error(w, l, http.StatusBadRequest)
+				return
+			}
+
+			includeArgs := cfg.AuditingEnv != nil && cfg.AuditingEnv.IncludeArgs
+
+			// The query might still be refused by the checks of the handler, thus
+			// the decision is only audited with the rejection or the outcome.
 			query := &audit.QueryData{
-				Query:     request.Query,
-				User:      user,
-				Timestamp: now.Unix(),
+				Query:        auditQuery(cfg, request.Query),
+				User:         user,
+				Timestamp:    now.Unix(),
+				RequestID:    requestID(ctx),
+				AuthProvider: AuthProvider(ctx),
+				Args:         audit.QueryArgs(request.Args, includeArgs),
+				Timeout:      timeout,
+				PostExpiry:   PostExpiry(ctx),
+			}
+			if twoPhase(cfg) {
+				query.Phase = audit.PhaseStarted
+			}
+			auditDatabase(ctx, cfg, query)
+			auditClientIP(cfg, r, query)
+			auditFields(ctx, cfg, query)
+
+			if cfg.Cache != nil {
+				key := cacheKey(cfg, r, user, &request)
+				if cached, ok := cfg.Cache.Get(key); ok {
+					query.CacheHit = true
+					ctx = context.WithValue(ctx, ContextKeyCacheResponse, cached)
+				}
+				ctx = context.WithValue(ctx, ContextKeyCacheKey, key)
 			}
+			auditSample(cfg, query)
 			_ = cfg.LoggerAudit.Write(query)
 
-			if err := cfg.SplunkAudit.Write(query); err != nil {
-				cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
-				http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
-				return
+			if query.SampleRate == 0 || query.Sampled {
+				_, span := telemetry.Tracer().Start(ctx, "audit.write")
+				if err := writeAudit(cfg, query); err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, "Unable to send audit to Splunk")
+					span.End()
+					cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+					http.Error(w, "An internal error has occurred", http.StatusInternalServerError)
+					return
+				}
+				span.End()
 			}
 
 			ctx = context.WithValue(ctx, ContextKeyQuery, request.Query)
+			ctx = context.WithValue(ctx, ContextKeyArgs, request.Args)
 			h.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// AuditRejection audits a request refused for the given reason, with the
+// decision telling whether it was denied by policy, for lack of authorization,
+// or for being invalid.
+func AuditRejection(cfg *gabi.Config, r *http.Request, query, decision, reason string) {
+	q := &audit.QueryData{
+		Query:        auditQuery(cfg, query),
+		User:         requestUser(cfg, r),
+		Timestamp:    auditNow(cfg).Unix(),
+		RequestID:    requestID(r.Context()),
+		AuthProvider: AuthProvider(r.Context()),
+		Decision:     decision,
+		Rejection:    reason,
+		PostExpiry:   PostExpiry(r.Context()),
+	}
+	auditDatabase(r.Context(), cfg, q)
+	auditClientIP(cfg, r, q)
+	auditFields(r.Context(), cfg, q)
+	_ = cfg.LoggerAudit.Write(q)
+
+	if err := writeAudit(cfg, q); err != nil {
+		cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+	}
+}
+
+// AuditOverride audits a query forced through by the client, despite being
+// refused for the given reason otherwise. An error is returned when the audit
+// could not be sent to Splunk, in which case the query must not be run.
+func AuditOverride(cfg *gabi.Config, r *http.Request, query, reason string) error {
+	q := &audit.QueryData{
+		Query:        auditQuery(cfg, query),
+		User:         requestUser(cfg, r),
+		Timestamp:    auditNow(cfg).Unix(),
+		RequestID:    requestID(r.Context()),
+		AuthProvider: AuthProvider(r.Context()),
+		Decision:     audit.DecisionAllowed,
+		Override:     reason,
+		PostExpiry:   PostExpiry(r.Context()),
+	}
+	auditDatabase(r.Context(), cfg, q)
+	auditClientIP(cfg, r, q)
+	auditFields(r.Context(), cfg, q)
+	_ = cfg.LoggerAudit.Write(q)
+
+	return writeAudit(cfg, q)
+}
+
+// AuditLifecycle audits an event in the life of the service itself, such as
+// its startup, or the users being reloaded, next to the audited queries. Such
+// events never carry a query, and a failure to send these is only logged.
+func AuditLifecycle(cfg *gabi.Config, eventType, detail string) {
+	q := &audit.QueryData{
+		Timestamp: auditNow(cfg).Unix(),
+		EventType: eventType,
+		Detail:    detail,
+	}
+	auditDatabase(context.Background(), cfg, q)
+	auditFields(context.Background(), cfg, q)
+	_ = cfg.LoggerAudit.Write(q)
+
+	if err := writeAudit(cfg, q); err != nil {
+		cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+	}
+}
+
+// AuditQuery audits a query run on behalf of the user by GABI itself, such as
+// when introspecting the schema. An error is returned when the audit could not
+// be sent to Splunk, in which case the query must not be run.
+func AuditQuery(cfg *gabi.Config, r *http.Request, query string, cacheHit bool) error {
+	q := &audit.QueryData{
+		Query:        auditQuery(cfg, query),
+		User:         requestUser(cfg, r),
+		Timestamp:    auditNow(cfg).Unix(),
+		RequestID:    requestID(r.Context()),
+		AuthProvider: AuthProvider(r.Context()),
+		Decision:     audit.DecisionAllowed,
+		CacheHit:     cacheHit,
+		PostExpiry:   PostExpiry(r.Context()),
+	}
+	auditDatabase(r.Context(), cfg, q)
+	auditClientIP(cfg, r, q)
+	auditFields(r.Context(), cfg, q)
+	_ = cfg.LoggerAudit.Write(q)
+
+	return writeAudit(cfg, q)
+}
+
+// PreviewAudit returns the event that would be audited for the query, encoded
+// as the audit backend would send it, with literals redacted, arguments
+// included, and static fields added as configured, without sending it. The
+// tags must have been validated already.
+func PreviewAudit(cfg *gabi.Config, r *http.Request, request *models.AuditPreviewRequest) ([]byte, error) {
+	ctx := r.Context()
+	if len(request.Tags) > 0 {
+		ctx = context.WithValue(ctx, ContextKeyTags, request.Tags)
+	}
+	if request.Database != "" {
+		ctx = WithDatabase(ctx, request.Database)
+	}
+
+	includeArgs := cfg.AuditingEnv != nil && cfg.AuditingEnv.IncludeArgs
+
+	q := &audit.QueryData{
+		Query:        auditQuery(cfg, request.Query),
+		User:         request.User,
+		AuthProvider: AuthProvider(ctx),
+		Timestamp:    auditNow(cfg).Unix(),
+		RequestID:    requestID(ctx),
+		Rejection:    request.Rejection,
+		Args:         audit.QueryArgs(request.Args, includeArgs),
+		PostExpiry:   PostExpiry(ctx),
+	}
+	if q.User == "" {
+		q.User = requestUser(cfg, r)
+	}
+	if twoPhase(cfg) && request.Rejection == "" {
+		q.Phase = outcomePhase(request.Executed, request.Error != "")
+	}
+	// Rejections are previewed as denied by policy, the most common reason.
+	if request.Rejection != "" {
+		q.Decision = audit.DecisionDeniedPolicy
+	}
+	if request.Executed && request.Rejection == "" {
+		q.Executed, q.Success = true, request.Error == ""
+		q.Decision = audit.DecisionAllowed
+		if request.Error != "" {
+			q.Error = audit.QueryError(errors.New(request.Error))
+			q.Decision = audit.DecisionError
+		}
+		q.ReadOnly = ReadOnly(ctx, cfg)
+	}
+	auditDatabase(ctx, cfg, q)
+	auditClientIP(cfg, r, q)
+	if q.ClientIP != "" && request.ClientIP != "" {
+		q.ClientIP = request.ClientIP
+	}
+	auditFields(ctx, cfg, q)
+
+	return audit.Preview(cfg.SplunkAudit, q)
+}
+
+// Audit writes are bounded by their own timeout, when set, rather than by the
+// timeout of the query, and one that times out is either refused or let
+// through as configured, with the latter only being logged.
+func writeAudit(cfg *gabi.Config, q *audit.QueryData) error {
+	ae := cfg.AuditingEnv
+	if ae == nil || ae.Timeout <= 0 {
+		return cfg.SplunkAudit.Write(q)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ae.Timeout)
+	defer cancel()
+
+	err := audit.WriteContext(ctx, cfg.SplunkAudit, q)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && ae.TimeoutPolicy == auditing.TimeoutPolicyAllow {
+		cfg.Logger.Warnf("Audit timed out after %s, continuing as configured: %s", ae.Timeout, err)
+		return nil
+	}
+	return err
+}
+
+// AuditOutcome audits the outcome of an executed query, successful or not,
+// together with the columns of its results, when configured, and the columns
+// masked, if any. Unlike the audit preceding the query, a failure to send it
+// to Splunk is only logged, as the query has already run.
+func AuditOutcome(cfg *gabi.Config, r *http.Request, query string, args []interface{}, columns, masked []string, response *ResponseStats, err error) {
+	includeArgs := cfg.AuditingEnv != nil && cfg.AuditingEnv.IncludeArgs
+
+	q := &audit.QueryData{
+		Query:        auditQuery(cfg, query),
+		User:         requestUser(cfg, r),
+		Timestamp:    auditNow(cfg).Unix(),
+		RequestID:    requestID(r.Context()),
+		AuthProvider: AuthProvider(r.Context()),
+		Args:         audit.QueryArgs(args, includeArgs),
+		Executed:     true,
+		Success:      err == nil,
+		Error:        audit.QueryError(err),
+		PostExpiry:   PostExpiry(r.Context()),
+		ReadOnly:     ReadOnly(r.Context(), cfg),
+		Masked:       masked,
+	}
+	if cfg.AuditingEnv != nil && cfg.AuditingEnv.IncludeColumns {
+		q.Columns = columns
+	}
+	if response != nil {
+		q.StatusCode, q.ResponseBytes = response.Code, response.Bytes
+	}
+	q.Timeout, _, _ = QueryTimeout(cfg, r)
+	var partial *audit.PartialError
+	q.Partial = errors.As(err, &partial)
+	if err != nil && ClientCanceled(r) {
+		q.ErrorClass = audit.ErrorClassClientCanceled
+	} else if driverErr := db.ClassifyError(err); driverErr != nil {
+		q.ErrorClass = string(driverErr.Class)
+	}
+	q.Decision = outcomeDecision(err, q.ErrorClass)
+	if twoPhase(cfg) {
+		q.Phase = outcomePhase(true, err != nil)
+	}
+	auditDatabase(r.Context(), cfg, q)
+	auditClientIP(cfg, r, q)
+	auditFields(r.Context(), cfg, q)
+	// Failed queries are always audited.
+	if err == nil {
+		auditSample(cfg, q)
+	}
+	_ = cfg.LoggerAudit.Write(q)
+
+	if q.SampleRate > 0 && !q.Sampled {
+		return
+	}
+	if err := writeAudit(cfg, q); err != nil {
+		cfg.Logger.Errorf("Unable to send audit to Splunk: %s", err)
+	}
+}
+
+// Writes refused by the database within read-only transactions are denied by
+// policy, as the transaction is only read-only as configured.
+func outcomeDecision(err error, class string) string {
+	switch {
+	case err == nil:
+		return audit.DecisionAllowed
+	case class == string(db.ErrorReadOnly):
+		return audit.DecisionDeniedPolicy
+	default:
+		return audit.DecisionError
+	}
+}
+
+// The phase of a query audited in two phases, telling whether it has been
+// started, or whether it completed or failed once executed.
+func outcomePhase(executed, failed bool) string {
+	switch {
+	case !executed:
+		return audit.PhaseStarted
+	case failed:
+		return audit.PhaseFailed
+	default:
+		return audit.PhaseCompleted
+	}
+}
+
+func twoPhase(cfg *gabi.Config) bool {
+	return cfg.AuditingEnv != nil && cfg.AuditingEnv.TwoPhase
+}
+
+// ClientCanceled reports whether the request was canceled as the client
+// disconnected, which cancels the queries run on its behalf, as opposed to
+// having timed out.
+func ClientCanceled(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// ResponseStats holds the status code and the number of bytes written of a
+// response, as recorded by the writer returned from RecordResponse.
+type ResponseStats struct {
+	Code  int
+	Bytes int64
+}
+
+// RecordResponse wraps the writer so that the status code and size of the
+// response are recorded, including when streamed, while keeping the optional
+// interfaces implemented by the writer, such as http.Flusher.
+func RecordResponse(w http.ResponseWriter) (http.ResponseWriter, *ResponseStats) {
+	stats := &ResponseStats{}
+	return httpsnoop.Wrap(w, httpsnoop.Hooks{
+		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				next(code)
+				if stats.Code == 0 {
+					stats.Code = code
+				}
+			}
+		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				n, err := next(b)
+				if stats.Code == 0 {
+					stats.Code = http.StatusOK
+				}
+				stats.Bytes += int64(n)
+				return n, err
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				n, err := next(src)
+				if stats.Code == 0 {
+					stats.Code = http.StatusOK
+				}
+				stats.Bytes += n
+				return n, err
+			}
+		},
+	}), stats
+}
+
+// The identity of the database is only audited when explicitly configured, as
+// the host might be considered sensitive.
+func auditDatabase(ctx context.Context, cfg *gabi.Config, q *audit.QueryData) {
+	// The database selected by the client is always audited, as only the
+	// request tells which one was queried.
+	if name := Database(ctx); name != "" {
+		q.Database = name
+	}
+	ae, dbe := cfg.AuditingEnv, cfg.DBEnv
+	if ae == nil || dbe == nil {
+		return
+	}
+	if ae.IncludeDatabaseName && q.Database == "" {
+		q.Database = dbe.Name
+	}
+	if ae.IncludeDatabaseHost {
+		q.DatabaseHost = dbe.Host
+	}
+}
+
+// The client IP is only audited when explicitly configured, and is taken from
+// the forwarding header only when set by a trusted proxy.
+func auditClientIP(cfg *gabi.Config, r *http.Request, q *audit.QueryData) {
+	if ae := cfg.AuditingEnv; ae != nil && ae.IncludeClientIP {
+		q.ClientIP = ClientIP(cfg, r)
+	}
+}
+
+// Only the audited query is redacted, the original is run against the database.
+func auditQuery(cfg *gabi.Config, query string) string {
+	ae, dbe := cfg.AuditingEnv, cfg.DBEnv
+	if ae == nil || dbe == nil || !ae.RedactLiterals {
+		return query
+	}
+	return dbe.Driver.RedactLiterals(query)
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(ContextKeyRequestID).(string)
+	return id
+}
+
+func auditNow(cfg *gabi.Config) time.Time {
+	if cfg.Clock != nil {
+		return cfg.Clock.Now()
+	}
+	return audit.SystemClock.Now()
+}
+
+// Tags supplied by the client are added next to the static fields, which
+// these have been validated not to replace.
+func auditFields(ctx context.Context, cfg *gabi.Config, q *audit.QueryData) {
+	if cfg.AuditingEnv != nil {
+		q.Fields = cfg.AuditingEnv.Fields
+	}
+
+	tags, _ := ctx.Value(ContextKeyTags).(map[string]string)
+	if len(tags) == 0 {
+		return
+	}
+	fields := make(map[string]string, len(q.Fields)+len(tags))
+	for name, value := range q.Fields {
+		fields[name] = value
+	}
+	for name, value := range tags {
+		fields[name] = value
+	}
+	q.Fields = fields
+}
+
+// Sampling only applies to read-only access, thus queries that can write are
+// always audited, as are queries served after the expiration date. Skipped
+// queries are still logged, together with the decision, but are not sent to
+// Splunk.
+func auditSample(cfg *gabi.Config, q *audit.QueryData) {
+	ae, dbe := cfg.AuditingEnv, cfg.DBEnv
+	if ae == nil || dbe == nil || dbe.AllowWrite || q.PostExpiry || ae.SampleRate <= 0 || ae.SampleRate >= 1 {
+		return
+	}
+	q.SampleRate = ae.SampleRate
+	q.Sampled = audit.Sampled(q.Query, ae.SampleRate)
+}
+
+// Results are only shared between users when explicitly configured, and the
+// request parameters are part of the key, as these affect the results.
+func cacheKey(cfg *gabi.Config, r *http.Request, user string, request *models.QueryRequest) string {
+	if cfg.CacheEnv != nil && !cfg.CacheEnv.PerUser {
+		user = ""
+	}
+	// Responses differ by version, however it was requested, and by database.
+	params := r.URL.Query()
+	if version, err := APIVersion(r); err == nil {
+		params.Set("api_version", strconv.Itoa(version))
+	}
+	if request.Database != "" {
+		params.Set("database", request.Database)
+	}
+	return cache.Key(user, request.Query, request.Args, params.Encode())
+}
+
+// The authenticated user is always audited when known, falling back to the
+// forwarded user only where no authorization took place.
+func requestUser(cfg *gabi.Config, r *http.Request) string {
+	if user := User(r.Context()); user != "" {
+		return user
+	}
+	return forwardedUser(cfg, r)
+}
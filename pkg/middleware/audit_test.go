@@ -4,17 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/app-sre/gabi/internal/test"
 	gabi "github.com/app-sre/gabi/pkg"
 	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auditing"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/env/proxy"
 	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/jackc/pgconn"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -65,9 +74,43 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			`{"query":"select 1;","user":"test","namespace":"test","pod":"test"}`,
-			regexp.MustCompile(`AUDIT\s{"Query": "select 1;", "User": "test", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 			`select 1;`,
 		},
+		{
+			"valid query with arguments",
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint:  s.URL,
+					Host:      "test",
+					Namespace: "test",
+					Pod:       "test",
+				}
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(b *bytes.Buffer) func(r *http.Request) {
+				return func(r *http.Request) {
+					r.Header.Set("Content-Length", fmt.Sprint(b.Len()))
+					r.Header.Set("X-Forwarded-User", "test")
+				}
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select $1;", "args": ["secret"]}`)
+			},
+			func(b *bytes.Buffer) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(b, r.Body)
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+				}
+			},
+			200,
+			``,
+			`{"query":"select $1;","user":"test","namespace":"test","pod":"test","args":["REDACTED"]}`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "[0-9a-f]{16}", "timestamp": \d{10}, "args": \["REDACTED"\]}`),
+			`select \$1;`,
+		},
 		{
 			"valid Base64-encoded query",
 			func(s *httptest.Server) *splunk.Env {
@@ -102,7 +145,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			`{"query":"select 1;","user":"test","namespace":"test","pod":"test"}`,
-			regexp.MustCompile(`AUDIT\s{"Query": "select 1;", "User": "test", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 			`select 1;`,
 		},
 		{
@@ -136,7 +179,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			`{"query":"select 1;","user":"test2","namespace":"test","pod":"test"}`,
-			regexp.MustCompile(`AUDIT\s{"Query": "select 1;", "User": "test2", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test2", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 			`select 1;`,
 		},
 		{
@@ -173,7 +216,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			`{"query":"select 1;","user":"test","namespace":"test","pod":"test"}`,
-			regexp.MustCompile(`AUDIT\s{"Query": "select 1;", "User": "test", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "354b7196c9ba5fb4", "query": "select 1;"}`),
 			`select 1;`,
 		},
 		{
@@ -204,7 +247,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			``,
-			regexp.MustCompile(`AUDIT\s{"Query": "", "User": "test", "Timestamp": \d{10}}`),
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}}.*AUDIT query\s{"query_hash": "e3b0c44298fc1c14", "query": ""}`),
 			``,
 		},
 		{
@@ -354,7 +397,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			``,
-			regexp.MustCompile(`Unable to unmarshal request body`),
+			regexp.MustCompile(`(?s)Unable to unmarshal request body.*"decision": "error", "rejection": "Unable to decode request body"}`),
 			``,
 		},
 		{
@@ -384,7 +427,7 @@ func TestAudit(t *testing.T) {
 			200,
 			``,
 			``,
-			regexp.MustCompile(`Unable to unmarshal request body`),
+			regexp.MustCompile(`(?s)Unable to unmarshal request body.*"decision": "error", "rejection": "Unable to decode request body"}`),
 			``,
 		},
 		{
@@ -413,7 +456,7 @@ func TestAudit(t *testing.T) {
 			400,
 			`Request without required header: Content-Length`,
 			``,
-			regexp.MustCompile(``),
+			regexp.MustCompile(`"decision": "error", "rejection": "Request without required header: Content-Length"}`),
 			``,
 		},
 		{
@@ -442,7 +485,7 @@ func TestAudit(t *testing.T) {
 			400,
 			`Request without required header: X-Forwarded-User`,
 			``,
-			regexp.MustCompile(``),
+			regexp.MustCompile(`"decision": "error", "rejection": "Request without required header: X-Forwarded-User"}`),
 			``,
 		},
 		{
@@ -479,7 +522,41 @@ func TestAudit(t *testing.T) {
 			400,
 			`Unable to decode Base64-encoded query`,
 			``,
-			regexp.MustCompile(``),
+			regexp.MustCompile(`"decision": "error", "rejection": "Unable to decode Base64-encoded query"}`),
+			``,
+		},
+		{
+			"invalid query with unsupported API version",
+			func(s *httptest.Server) *splunk.Env {
+				return &splunk.Env{
+					Endpoint: s.URL,
+				}
+			},
+			func() context.Context {
+				return context.TODO()
+			},
+			func(b *bytes.Buffer) func(r *http.Request) {
+				return func(r *http.Request) {
+					r.Header.Set("Content-Length", fmt.Sprint(b.Len()))
+					r.Header.Set("X-Forwarded-User", "test")
+					q := r.URL.Query()
+					q.Add("api_version", "3")
+					r.URL.RawQuery = q.Encode()
+				}
+			},
+			func() *bytes.Buffer {
+				return bytes.NewBufferString(`{"query": "select 1;"}`)
+			},
+			func(b *bytes.Buffer) func(w http.ResponseWriter, r *http.Request) {
+				return func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.Copy(b, r.Body)
+					fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+				}
+			},
+			400,
+			`Unsupported API version`,
+			``,
+			regexp.MustCompile(`"decision": "error", "rejection": "Unsupported API version"}`),
 			``,
 		},
 	}
@@ -510,7 +587,7 @@ func TestAudit(t *testing.T) {
 
 			tc.headers(tc.request())(r)
 
-			expected := &gabi.Config{LoggerAudit: la, SplunkAudit: sa, Logger: logger, Encoder: encoder}
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), LoggerAudit: la, SplunkAudit: sa, Logger: logger, Encoder: encoder}
 			Audit(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				query, _ = r.Context().Value(ContextKeyQuery).(string)
 			})).ServeHTTP(w, r.WithContext(tc.context()))
@@ -528,3 +605,890 @@ func TestAudit(t *testing.T) {
 		})
 	}
 }
+
+func TestAuditAuthenticatedUser(t *testing.T) {
+	t.Parallel()
+
+	var (
+		output, server bytes.Buffer
+		handled        string
+	)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(&server, r.Body)
+		fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+	}))
+	defer s.Close()
+
+	logger := test.DummyLogger(&output).Sugar()
+
+	sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+	sa.SetHTTPClient(http.DefaultClient)
+
+	cfg := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		UserEnv:     &user.Env{Users: []string{"test"}},
+		LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+		SplunkAudit: sa,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	body := `{"query": "select 1;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r.Header.Set("X-Forwarded-User", "test")
+
+	// Changing the forwarded user after authorization must not change the user
+	// being audited.
+	tamper := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("X-Forwarded-User", "test2")
+			h.ServeHTTP(w, r)
+		})
+	}
+
+	Authorization(cfg)(tamper(Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = User(r.Context())
+	})))).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test", handled)
+	assert.Contains(t, server.String(), `"user":"test"`)
+	assert.Regexp(t, `AUDIT\s{"user": "test",`, output.String())
+}
+
+func TestAuditDatabase(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		body        string
+		code        int
+		want        string
+		server      string
+	}{
+		{
+			"database identity not included",
+			&auditing.Env{},
+			`{"query": "select 1;"}`,
+			200,
+			`AUDIT	{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": `,
+			`"user":"test","namespace"`,
+		},
+		{
+			"database name included",
+			&auditing.Env{IncludeDatabaseName: true},
+			`{"query": "select 1;"}`,
+			200,
+			`, "database": "main"}`,
+			`"user":"test","database":"main","namespace"`,
+		},
+		{
+			"database name and host included",
+			&auditing.Env{IncludeDatabaseName: true, IncludeDatabaseHost: true},
+			`{"query": "select 1;"}`,
+			200,
+			`"database": "main", "database_host": "db.example.com"`,
+			`"user":"test","database":"main","database_host":"db.example.com","namespace"`,
+		},
+		{
+			"database selected by client included",
+			&auditing.Env{},
+			`{"query": "select 1;", "database": "reports"}`,
+			200,
+			`, "database": "reports"}`,
+			`"user":"test","database":"reports","namespace"`,
+		},
+		{
+			"database selected by client in place of configured one",
+			&auditing.Env{IncludeDatabaseName: true},
+			`{"query": "select 1;", "database": "reports"}`,
+			200,
+			`, "database": "reports"}`,
+			`"user":"test","database":"reports","namespace"`,
+		},
+		{
+			"database not permitted refused",
+			&auditing.Env{},
+			`{"query": "select 1;", "database": "other"}`,
+			403,
+			`, "database": "other", "decision": "denied_policy", "rejection": "Database is not permitted: other"}`,
+			`"user":"test","decision":"denied_policy","database":"other","namespace"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output, server bytes.Buffer
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(&server, r.Body)
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DBEnv:       &db.Env{Host: "db.example.com", Name: "main", Databases: []string{"reports"}, Password: "test123"},
+				AuditingEnv: tc.given,
+				LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+				SplunkAudit: sa,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := tc.body
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, output.String(), tc.want)
+			assert.Contains(t, server.String(), tc.server)
+			assert.NotContains(t, server.String(), `test123`)
+		})
+	}
+}
+
+func TestAuditClock(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	cfg := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+		Clock:       audit.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	body := `{"query": "select 1;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r = r.WithContext(WithUser(r.Context(), "test"))
+
+	Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+	AuditRejection(cfg, r, "select 1;", audit.DecisionDeniedPolicy, "test")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, output.String(), `AUDIT	{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200}`)
+	assert.Contains(t, output.String(), `AUDIT	{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": 1672531200, "decision": "denied_policy", "rejection": "test"}`)
+}
+
+func TestAuditClientIP(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		proxies     []*net.IPNet
+		want        string
+	}{
+		{
+			"client IP not audited",
+			&auditing.Env{},
+			nil,
+			`"timestamp": 1672531200}`,
+		},
+		{
+			"client IP of the peer audited without trusted proxies",
+			&auditing.Env{IncludeClientIP: true},
+			nil,
+			`"timestamp": 1672531200, "client_ip": "10.0.0.1"}`,
+		},
+		{
+			"client IP forwarded by trusted proxy audited",
+			&auditing.Env{IncludeClientIP: true},
+			[]*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+			`"timestamp": 1672531200, "client_ip": "203.0.113.1"}`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			pe := proxy.NewProxyEnv()
+			pe.TrustedProxies = tc.proxies
+
+			cfg := &gabi.Config{
+				AuditingEnv: tc.given,
+				ProxyEnv:    pe,
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+				Clock:       audit.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.RemoteAddr = "10.0.0.1:1234"
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-For", "203.0.113.1")
+			r = r.WithContext(WithUser(r.Context(), "test"))
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, output.String(), `AUDIT	{"user": "test", "query_hash": "354b7196c9ba5fb4", `+tc.want)
+		})
+	}
+}
+
+func TestAuditRedactLiterals(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	cfg := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DBEnv:       &db.Env{Driver: "pgx"},
+		AuditingEnv: &auditing.Env{RedactLiterals: true},
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	body := `{"query": "select * from tokens where token = 'secret' and id = 42;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r = r.WithContext(WithUser(r.Context(), "test"))
+
+	var query string
+	Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ = r.Context().Value(ContextKeyQuery).(string)
+		AuditOutcome(cfg, r, query, nil, nil, nil, nil, nil)
+	})).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "select * from tokens where token = 'secret' and id = 42;", query)
+	assert.Contains(t, output.String(), `"query": "select * from tokens where token = ? and id = ?;"`)
+	assert.NotContains(t, output.String(), `secret`)
+}
+
+func TestAuditRequestID(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+	la := &audit.ConsoleAudit{Logger: logger}
+
+	cfg := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		DBEnv:       &db.Env{Driver: "pgx"},
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Encoder:     base64.StdEncoding,
+	}
+
+	body := `{"query": "select 1;"}`
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+	r.Header.Set("X-Request-Id", "test")
+	r = r.WithContext(WithUser(r.Context(), "test"))
+
+	// Both the event preceding the query and its outcome carry the request ID.
+	RequestID(cfg)(Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AuditOutcome(cfg, r, "select 1;", nil, nil, nil, nil, nil)
+	}))).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Regexp(t, `AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d+, "request_id": "test"}`, output.String())
+	assert.Regexp(t, `AUDIT\s{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": \d+, "request_id": "test", "decision": "allowed", "read_only": true, "success": true}`, output.String())
+}
+
+func TestAuditTwoPhase(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		err         error
+		want        []string
+		unwanted    string
+	}{
+		{
+			"successful query audited in two phases",
+			&auditing.Env{TwoPhase: true},
+			nil,
+			[]string{`"request_id": "test", "phase": "started"}`, `"decision": "allowed", "phase": "completed", "read_only": true, "success": true}`},
+			``,
+		},
+		{
+			"failed query audited in two phases",
+			&auditing.Env{TwoPhase: true},
+			errors.New("test"),
+			[]string{`"request_id": "test", "phase": "started"}`, `"decision": "error", "phase": "failed", "read_only": true, "success": false, "error": "test"}`},
+			``,
+		},
+		{
+			"outcome of successful query sent in one phase",
+			&auditing.Env{},
+			nil,
+			[]string{`"request_id": "test"}`, `"decision": "allowed", "read_only": true, "success": true}`},
+			`"phase"`,
+		},
+		{
+			"outcome of failed query sent in one phase",
+			&auditing.Env{},
+			errors.New("test"),
+			[]string{`"request_id": "test"}`, `"decision": "error", "read_only": true, "success": false, "error": "test"}`},
+			`"phase"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output, sent bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DBEnv:       &db.Env{Driver: "pgx"},
+				AuditingEnv: tc.given,
+				LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+				SplunkAudit: &audit.ConsoleAudit{Logger: test.DummyLogger(&sent).Sugar()},
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Request-Id", "test")
+			r = r.WithContext(WithUser(r.Context(), "test"))
+
+			RequestID(cfg)(Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				AuditOutcome(cfg, r, "select 1;", nil, nil, nil, nil, tc.err)
+			}))).ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			for _, want := range tc.want {
+				assert.Contains(t, sent.String(), want)
+			}
+			if tc.unwanted != "" {
+				assert.NotContains(t, sent.String(), tc.unwanted)
+			}
+			// Every outcome is still logged.
+			assert.Contains(t, output.String(), `"success"`)
+		})
+	}
+}
+
+func TestAuditTags(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		code        int
+		body        string
+		want        []string
+	}{
+		{
+			"query without tags",
+			`{"query": "select 1;"}`,
+			200,
+			``,
+			[]string{`"team": "sre"`},
+		},
+		{
+			"query with tags",
+			`{"query": "select 1;", "tags": {"ticket": "JIRA-123", "purpose": "incident"}}`,
+			200,
+			``,
+			[]string{`"purpose": "incident", "team": "sre", "ticket": "JIRA-123"}`, `"success": true, "purpose": "incident", "team": "sre", "ticket": "JIRA-123"}`},
+		},
+		{
+			"tag replacing audit field",
+			`{"query": "select 1;", "tags": {"User": "admin"}}`,
+			400,
+			`Invalid query tags: tag cannot replace audit field: User`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: tag cannot replace audit field: User", "team": "sre"}`},
+		},
+		{
+			"tag replacing renamed audit field",
+			`{"query": "select 1;", "tags": {"account": "admin"}}`,
+			400,
+			`Invalid query tags: tag cannot replace audit field: account`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: tag cannot replace audit field: account", "team": "sre"}`},
+		},
+		{
+			"tag replacing static field",
+			`{"query": "select 1;", "tags": {"team": "other"}}`,
+			400,
+			`Invalid query tags: tag cannot replace static field: team`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: tag cannot replace static field: team", "team": "sre"}`},
+		},
+		{
+			"too many tags",
+			`{"query": "select 1;", "tags": {"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6", "g": "7", "h": "8", "i": "9", "j": "10", "k": "11"}}`,
+			400,
+			`Invalid query tags: too many tags: 11 (maximum: 10)`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: too many tags: 11 (maximum: 10)", "team": "sre"}`},
+		},
+		{
+			"invalid tag name",
+			`{"query": "select 1;", "tags": {"ticket id": "JIRA-123"}}`,
+			400,
+			`Invalid query tags: invalid tag name: "ticket id"`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: invalid tag name: \"ticket id\"", "team": "sre"}`},
+		},
+		{
+			"tag value too long",
+			`{"query": "select 1;", "tags": {"ticket": "` + strings.Repeat("a", 257) + `"}}`,
+			400,
+			`Invalid query tags: invalid value for tag: ticket`,
+			[]string{`"decision": "error", "rejection": "Invalid query tags: invalid value for tag: ticket", "team": "sre"}`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{
+				ProxyEnv: test.ProxyEnv(),
+				DBEnv:    &db.Env{Driver: "pgx"},
+				AuditingEnv: &auditing.Env{
+					Fields:     map[string]string{"team": "sre"},
+					FieldNames: map[string]string{"user": "account"},
+				},
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tc.given))
+			r.Header.Set("Content-Length", fmt.Sprint(len(tc.given)))
+			r = r.WithContext(WithUser(r.Context(), "test"))
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				AuditOutcome(cfg, r, "select 1;", nil, nil, nil, nil, nil)
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.body)
+			for _, want := range tc.want {
+				assert.Contains(t, output.String(), want)
+			}
+		})
+	}
+}
+
+func TestAuditSampling(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		write       bool
+		want        string
+		audited     bool
+	}{
+		{
+			"sampling disabled",
+			&auditing.Env{SampleRate: 1},
+			false,
+			`"timestamp": `,
+			true,
+		},
+		{
+			"query skipped by sampling",
+			&auditing.Env{SampleRate: 0.000001},
+			false,
+			`"sample_rate": 0.000001, "sampled": false}`,
+			false,
+		},
+		{
+			"query always audited with write access",
+			&auditing.Env{SampleRate: 0.000001},
+			true,
+			`"timestamp": `,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output, server bytes.Buffer
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(&server, r.Body)
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				DBEnv:       &db.Env{AllowWrite: tc.write},
+				AuditingEnv: tc.given,
+				LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+				SplunkAudit: sa,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, output.String(), tc.want)
+			assert.Equal(t, tc.audited, server.Len() > 0)
+
+			// Failed queries are always audited.
+			server.Reset()
+			AuditOutcome(cfg, r, "select 1;", nil, nil, nil, nil, errors.New("test"))
+			assert.Contains(t, server.String(), `"success":false,"error":"test"`)
+		})
+	}
+}
+
+func TestAuditTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		code        int
+		handled     bool
+		want        string
+	}{
+		{
+			"slow audit without timeout",
+			&auditing.Env{},
+			http.StatusOK,
+			true,
+			``,
+		},
+		{
+			"slow audit within timeout",
+			&auditing.Env{Timeout: 5 * time.Second},
+			http.StatusOK,
+			true,
+			``,
+		},
+		{
+			"slow audit timed out and rejected",
+			&auditing.Env{Timeout: 10 * time.Millisecond, TimeoutPolicy: auditing.TimeoutPolicyReject},
+			http.StatusInternalServerError,
+			false,
+			`Unable to send audit to Splunk: `,
+		},
+		{
+			"slow audit timed out and allowed",
+			&auditing.Env{Timeout: 10 * time.Millisecond, TimeoutPolicy: auditing.TimeoutPolicyAllow},
+			http.StatusOK,
+			true,
+			`Audit timed out after 10ms, continuing as configured: `,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				output  bytes.Buffer
+				handled bool
+			)
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				AuditingEnv: tc.given,
+				LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+				SplunkAudit: sa,
+				Logger:      logger,
+				Encoder:     base64.StdEncoding,
+			}
+
+			body := `{"query": "select 1;"}`
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.handled, handled)
+			assert.Contains(t, output.String(), tc.want)
+			assert.Equal(t, tc.handled, AuditQuery(cfg, r, "select 1;", false) == nil)
+		})
+	}
+}
+
+func TestRecordResponse(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       func(w http.ResponseWriter)
+		code        int
+		bytes       int64
+	}{
+		{
+			"response written without status code",
+			func(w http.ResponseWriter) {
+				_, _ = io.WriteString(w, "test")
+			},
+			http.StatusOK,
+			4,
+		},
+		{
+			"response written with status code",
+			func(w http.ResponseWriter) {
+				http.Error(w, "test", http.StatusBadRequest)
+			},
+			http.StatusBadRequest,
+			5,
+		},
+		{
+			"response streamed with flushes",
+			func(w http.ResponseWriter) {
+				for i := 0; i < 3; i++ {
+					_, _ = io.WriteString(w, "test\n")
+					w.(http.Flusher).Flush()
+				}
+			},
+			http.StatusOK,
+			15,
+		},
+		{
+			"response not written",
+			func(w http.ResponseWriter) {
+				// No-op.
+			},
+			0,
+			0,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			w, actual := RecordResponse(recorder)
+
+			tc.given(w)
+
+			assert.Equal(t, tc.code, actual.Code)
+			assert.Equal(t, tc.bytes, actual.Bytes)
+			assert.Equal(t, int64(recorder.Body.Len()), actual.Bytes)
+		})
+	}
+}
+
+func TestAuditOutcomeResponse(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+	la := &audit.ConsoleAudit{Logger: logger}
+	cfg := &gabi.Config{ProxyEnv: test.ProxyEnv(), LoggerAudit: la, SplunkAudit: la, Logger: logger}
+
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+	AuditOutcome(cfg, r, "select 1;", nil, nil, nil, &ResponseStats{Code: http.StatusOK, Bytes: 42}, nil)
+
+	assert.Contains(t, output.String(), `"success": true, "status_code": 200, "response_bytes": 42}`)
+}
+
+func TestAuditOutcomeColumns(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auditing.Env
+		expected    string
+	}{
+		{
+			"columns audited when included",
+			&auditing.Env{IncludeColumns: true},
+			`"success": true, "columns": ["id", "name"], "masked_columns": ["name"]}`,
+		},
+		{
+			"columns not audited by default",
+			&auditing.Env{},
+			`"success": true, "masked_columns": ["name"]}`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+			cfg := &gabi.Config{ProxyEnv: test.ProxyEnv(), AuditingEnv: tc.given, LoggerAudit: la, SplunkAudit: la, Logger: logger}
+
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+			AuditOutcome(cfg, r, "select * from test;", nil, []string{"id", "name"}, []string{"name"}, nil, nil)
+
+			assert.Contains(t, output.String(), tc.expected)
+		})
+	}
+}
+
+func TestAuditOutcomeDecision(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		expected    string
+	}{
+		{
+			"successful query allowed",
+			nil,
+			`"decision": "allowed", "success": true}`,
+		},
+		{
+			"failed query audited as error",
+			errors.New("test"),
+			`"decision": "error", "success": false, "error": "test"}`,
+		},
+		{
+			"write refused in read-only transaction denied by policy",
+			&pgconn.PgError{Severity: "ERROR", Code: "25006", Message: "cannot execute INSERT in a read-only transaction"},
+			`"decision": "denied_policy", "success": false`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+			cfg := &gabi.Config{ProxyEnv: test.ProxyEnv(), LoggerAudit: la, SplunkAudit: la, Logger: logger}
+
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+			AuditOutcome(cfg, r, "select 1;", nil, nil, nil, nil, tc.given)
+
+			assert.Contains(t, output.String(), tc.expected)
+		})
+	}
+}
+
+func TestAuditLifecycle(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	logger := test.DummyLogger(&output).Sugar()
+	la := &audit.ConsoleAudit{Logger: logger}
+	cfg := &gabi.Config{
+		ProxyEnv:    test.ProxyEnv(),
+		LoggerAudit: la,
+		SplunkAudit: la,
+		Logger:      logger,
+		Clock:       audit.NewFakeClock(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)),
+	}
+
+	AuditLifecycle(cfg, audit.EventReload, "users: 2")
+
+	assert.Contains(t, output.String(), `"timestamp": 1672531200, "event_type": "config_reload", "detail": "users: 2"}`)
+	assert.NotContains(t, output.String(), "Unable to send audit")
+}
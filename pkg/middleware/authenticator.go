@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/auth"
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator identifies the user a request is made on behalf of, from the
+// credentials of the request. An empty user is returned when the request does
+// not carry such credentials, letting the next authenticator of the chain try,
+// while credentials that are carried but invalid result in an error.
+type Authenticator interface {
+	Name() string
+	Authenticate(r *http.Request) (string, error)
+}
+
+// Authenticators taking the user from a header are named when the request is
+// refused for lack of a user.
+type headerAuthenticator interface {
+	Header() string
+}
+
+// Authenticators returns the chain of authenticators configured, tried in
+// order, where the first identifying the user wins. Without any providers
+// configured, the trusted header, when enabled, is tried before the forwarded
+// user header, unless the trusted header is exclusive.
+func Authenticators(cfg *gabi.Config) []Authenticator {
+	ae := cfg.AuthEnv
+	if ae == nil {
+		ae = auth.NewAuthEnv()
+	}
+
+	providers := ae.Providers
+	if len(providers) == 0 {
+		if ae.Enabled() {
+			providers = append(providers, auth.ProviderTrustedHeader)
+		}
+		if !ae.Enabled() || !ae.Exclusive {
+			providers = append(providers, auth.ProviderForwardedHeader)
+		}
+	}
+
+	chain := make([]Authenticator, 0, len(providers))
+	for _, p := range providers {
+		switch p {
+		case auth.ProviderTrustedHeader:
+			chain = append(chain, &trustedHeaderAuthenticator{ae: ae})
+		case auth.ProviderForwardedHeader:
+			chain = append(chain, &forwardedHeaderAuthenticator{cfg: cfg})
+		case auth.ProviderBearer:
+			chain = append(chain, &bearerAuthenticator{ae: ae})
+		case auth.ProviderClientCert:
+			chain = append(chain, &clientCertAuthenticator{})
+		}
+	}
+	return chain
+}
+
+// The user is taken from the header set by the proxy, as forwarded by trusted
+// proxies.
+type forwardedHeaderAuthenticator struct {
+	cfg *gabi.Config
+}
+
+func (a *forwardedHeaderAuthenticator) Name() string {
+	return auth.ProviderForwardedHeader
+}
+
+func (a *forwardedHeaderAuthenticator) Header() string {
+	return proxyEnv(a.cfg).UserHeader
+}
+
+func (a *forwardedHeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	return forwardedUser(a.cfg, r), nil
+}
+
+// The trusted header is only honored alongside the shared secret, as otherwise
+// any client could claim to be any user.
+type trustedHeaderAuthenticator struct {
+	ae *auth.Env
+}
+
+func (a *trustedHeaderAuthenticator) Name() string {
+	return auth.ProviderTrustedHeader
+}
+
+func (a *trustedHeaderAuthenticator) Header() string {
+	return a.ae.UserHeader
+}
+
+func (a *trustedHeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	user := r.Header.Get(a.ae.UserHeader)
+	if user == "" {
+		return "", nil
+	}
+	if !a.ae.IsSecretValid(r.Header.Get(a.ae.SecretHeader)) {
+		return user, errInvalidCredentials
+	}
+	return user, nil
+}
+
+// The bearer token identifies the single user it is configured for, such as a
+// service account.
+type bearerAuthenticator struct {
+	ae *auth.Env
+}
+
+func (a *bearerAuthenticator) Name() string {
+	return auth.ProviderBearer
+}
+
+func (a *bearerAuthenticator) Header() string {
+	return authorizationHeader
+}
+
+func (a *bearerAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return "", nil
+	}
+	if !a.ae.IsTokenValid(token) {
+		return "", errInvalidCredentials
+	}
+	return a.ae.BearerUser, nil
+}
+
+// The user is the common name of the client certificate, which has already
+// been verified during the handshake.
+type clientCertAuthenticator struct{}
+
+func (a *clientCertAuthenticator) Name() string {
+	return auth.ProviderClientCert
+}
+
+func (a *clientCertAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", nil
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationChain(t *testing.T) {
+	t.Parallel()
+
+	verified := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{Subject: pkix.Name{CommonName: cn}}}}}
+	}
+
+	ae := &auth.Env{
+		BearerToken:  "token",
+		BearerUser:   "service",
+		ClientCAFile: "/etc/gabi/ca.crt",
+		Providers:    []string{"client_cert", "bearer", "forwarded_header"},
+	}
+
+	cases := []struct {
+		description string
+		request     func(*http.Request)
+		code        int
+		body        string
+		user        string
+		provider    string
+	}{
+		{
+			"client certificate identifying user",
+			func(r *http.Request) {
+				r.TLS = verified("test")
+				r.Header.Set("Authorization", "Bearer token")
+				r.Header.Set("X-Forwarded-User", "admin")
+			},
+			200,
+			``,
+			`test`,
+			`client_cert`,
+		},
+		{
+			"bearer token identifying user without client certificate",
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer token")
+				r.Header.Set("X-Forwarded-User", "admin")
+			},
+			200,
+			``,
+			`service`,
+			`bearer`,
+		},
+		{
+			"client certificate without common name",
+			func(r *http.Request) {
+				r.TLS = verified("")
+				r.Header.Set("Authorization", "Bearer token")
+			},
+			200,
+			``,
+			`service`,
+			`bearer`,
+		},
+		{
+			"forwarded user without other credentials",
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "admin")
+			},
+			200,
+			``,
+			`admin`,
+			`forwarded_header`,
+		},
+		{
+			"invalid bearer token",
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer test")
+				r.Header.Set("X-Forwarded-User", "admin")
+			},
+			401,
+			`Request cannot be authenticated`,
+			``,
+			`bearer`,
+		},
+		{
+			"no credentials",
+			func(r *http.Request) {
+				// No-op.
+			},
+			400,
+			`Request without required header: Authorization, X-Forwarded-User`,
+			``,
+			``,
+		},
+		{
+			"identified user not permitted",
+			func(r *http.Request) {
+				r.TLS = verified("test2")
+			},
+			403,
+			`User does not have required permissions`,
+			``,
+			`client_cert`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				output     bytes.Buffer
+				actualUser string
+				provider   string
+			)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+			tc.request(r)
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				UserEnv:     &user.Env{Users: []string{"test", "service", "admin"}},
+				AuthEnv:     ae,
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+			}
+			Authorization(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actualUser, provider = User(r.Context()), AuthProvider(r.Context())
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.body)
+			assert.Equal(t, tc.user, actualUser)
+			if tc.code == 200 {
+				assert.Equal(t, tc.provider, provider)
+				return
+			}
+			// Refused requests are audited along with the provider involved.
+			if tc.provider != "" {
+				assert.Contains(t, output.String(), `"auth_provider": "`+tc.provider+`", "decision": "denied_auth"`)
+			}
+		})
+	}
+}
@@ -1,38 +1,81 @@
 package middleware
 
 import (
-	"context"
 	"fmt"
 	"net/http"
+	"strings"
 
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/telemetry"
 )
 
+// Authorization identifies the user using the chain of authenticators, and
+// refuses requests from users that are not permitted.
 func Authorization(cfg *gabi.Config) Middleware {
 	return func(h http.Handler) http.Handler {
+		chain := Authenticators(cfg)
+
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 
-			user := r.Header.Get(forwardedUserHeader)
+			var (
+				user     string
+				provider string
+				headers  []string
+			)
+			for _, a := range chain {
+				u, err := a.Authenticate(r)
+				if err != nil {
+					l := "Request cannot be authenticated"
+					cfg.Logger.Errorf("%s: invalid %s credentials (user: %s, remote address: %s)", l, a.Name(), u, r.RemoteAddr)
+					AuditRejection(cfg, r.WithContext(WithAuthProvider(ctx, a.Name())), "", audit.DecisionDeniedAuth, l)
+					http.Error(w, l, http.StatusUnauthorized)
+					return
+				}
+				if u != "" {
+					user, provider = u, a.Name()
+					break
+				}
+				if ha, ok := a.(headerAuthenticator); ok {
+					headers = append(headers, ha.Header())
+				}
+			}
+
 			if user == "" {
-				l := fmt.Sprintf("Request without required header: %s", forwardedUserHeader)
+				if len(headers) == 0 {
+					l := "Request cannot be authenticated"
+					cfg.Logger.Errorf("%s: missing credentials (remote address: %s)", l, r.RemoteAddr)
+					http.Error(w, l, http.StatusUnauthorized)
+					return
+				}
+				l := fmt.Sprintf("Request without required header: %s", strings.Join(headers, ", "))
 				http.Error(w, l, http.StatusBadRequest)
 				return
 			}
+			ctx = WithAuthProvider(WithUser(ctx, user), provider)
 
-			if len(cfg.UserEnv.Users) == 0 {
+			usere := cfg.CurrentUserEnv()
+			if len(usere.Users) == 0 {
 				http.Error(w, "Request cannot be authorized", http.StatusUnauthorized)
 				return
 			}
-			for _, u := range cfg.UserEnv.Users {
-				if user == u {
-					ctx = context.WithValue(ctx, ContextKeyUser, u)
-					h.ServeHTTP(w, r.WithContext(ctx))
-					return
+
+			var groups []string
+			for _, entry := range strings.Split(ForwardedHeader(cfg, r, forwardedGroupsHeader), ",") {
+				if s := strings.TrimSpace(entry); s != "" {
+					groups = append(groups, s)
 				}
 			}
+
+			if usere.IsAuthorized(user, groups) {
+				telemetry.SetUser(ctx, user)
+				h.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
 			l := "User does not have required permissions"
 			cfg.Logger.Errorf("%s: %s", l, user)
+			AuditRejection(cfg, r.WithContext(ctx), "", audit.DecisionDeniedAuth, l)
 			http.Error(w, l, http.StatusForbidden)
 		})
 	}
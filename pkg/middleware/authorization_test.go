@@ -2,13 +2,20 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/app-sre/gabi/internal/test"
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/app-sre/gabi/pkg/env/proxy"
+	"github.com/app-sre/gabi/pkg/env/splunk"
 	"github.com/app-sre/gabi/pkg/env/user"
 	"github.com/stretchr/testify/assert"
 )
@@ -94,6 +101,47 @@ func TestAuthorization(t *testing.T) {
 			``,
 			`test`,
 		},
+		{
+			"users set with wildcard entry matching user",
+			&user.Env{Users: []string{"team-*"}},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "team-test")
+			},
+			200,
+			``,
+			`team-test`,
+		},
+		{
+			"users set with wildcard entry not matching user",
+			&user.Env{Users: []string{"team-*"}},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "test")
+			},
+			403,
+			`User does not have required permissions`,
+			``,
+		},
+		{
+			"users set with group entry matching user group",
+			&user.Env{Users: []string{"group:sre"}},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "test")
+				r.Header.Set("X-Forwarded-Groups", "dev, sre")
+			},
+			200,
+			``,
+			`test`,
+		},
+		{
+			"users set with group entry without user groups",
+			&user.Env{Users: []string{"group:sre"}},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "sre")
+			},
+			403,
+			`User does not have required permissions`,
+			``,
+		},
 	}
 
 	for _, tc := range cases {
@@ -109,11 +157,20 @@ func TestAuthorization(t *testing.T) {
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
 
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
 			logger := test.DummyLogger(io.Discard).Sugar()
 
+			la := &audit.ConsoleAudit{Logger: logger}
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
 			tc.headers(r)
 
-			expected := &gabi.Config{Logger: logger, UserEnv: tc.given}
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), Logger: logger, UserEnv: tc.given, LoggerAudit: la, SplunkAudit: sa}
 			Authorization(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				s, ok := r.Context().Value(ContextKeyUser).(string)
 				if !ok {
@@ -133,3 +190,222 @@ func TestAuthorization(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorizationTrustedHeader(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *auth.Env
+		headers     func(*http.Request)
+		code        int
+		body        string
+		user        string
+	}{
+		{
+			"trusted header with valid secret",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Auth-Request-User", "test")
+				r.Header.Set("X-Gabi-Auth-Secret", "secret")
+			},
+			200,
+			``,
+			`test`,
+		},
+		{
+			"trusted header taking precedence over forwarded user",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "test2")
+				r.Header.Set("X-Auth-Request-User", "test")
+				r.Header.Set("X-Gabi-Auth-Secret", "secret")
+			},
+			200,
+			``,
+			`test`,
+		},
+		{
+			"trusted header with invalid secret",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Auth-Request-User", "test")
+				r.Header.Set("X-Gabi-Auth-Secret", "test")
+			},
+			401,
+			`Request cannot be authenticated`,
+			``,
+		},
+		{
+			"trusted header without secret",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Auth-Request-User", "test")
+			},
+			401,
+			`Request cannot be authenticated`,
+			``,
+		},
+		{
+			"forwarded user without trusted header",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "test")
+			},
+			200,
+			``,
+			`test`,
+		},
+		{
+			"forwarded user without trusted header when exclusive",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret", Exclusive: true},
+			func(r *http.Request) {
+				r.Header.Set("X-Forwarded-User", "test")
+			},
+			400,
+			`Request without required header: X-Auth-Request-User`,
+			``,
+		},
+		{
+			"trusted header with user not permitted",
+			&auth.Env{UserHeader: "X-Auth-Request-User", SecretHeader: "X-Gabi-Auth-Secret", Secret: "secret"},
+			func(r *http.Request) {
+				r.Header.Set("X-Auth-Request-User", "test2")
+				r.Header.Set("X-Gabi-Auth-Secret", "secret")
+			},
+			403,
+			`User does not have required permissions`,
+			``,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			usere := &user.Env{Users: []string{"test"}}
+
+			var (
+				body bytes.Buffer
+				user string
+			)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			tc.headers(r)
+
+			expected := &gabi.Config{
+				ProxyEnv:    test.ProxyEnv(),
+				Logger:      logger,
+				UserEnv:     usere,
+				AuthEnv:     tc.given,
+				LoggerAudit: la,
+				SplunkAudit: sa,
+			}
+			Authorization(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user = User(r.Context())
+			})).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Equal(t, tc.user, user)
+		})
+	}
+}
+
+func TestAuthorizationTrustedProxy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		peer        string
+		code        int
+		user        string
+	}{
+		{
+			"forwarded user from trusted proxy",
+			"10.0.0.1:1234",
+			200,
+			`test`,
+		},
+		{
+			"forwarded user from untrusted peer",
+			"192.0.2.1:1234",
+			400,
+			``,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				body   bytes.Buffer
+				actual string
+			)
+
+			pe := proxy.NewProxyEnv()
+			pe.TrustedProxies = []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}}
+			pe.UserHeader = "X-Auth-Request-User"
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+			r.RemoteAddr = tc.peer
+			r.Header.Set("X-Auth-Request-User", "test")
+			// The default header is no longer honored, once another is set.
+			r.Header.Set("X-Forwarded-User", "admin")
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{
+				UserEnv:     &user.Env{Users: []string{"test", "admin"}},
+				ProxyEnv:    pe,
+				LoggerAudit: la,
+				SplunkAudit: la,
+				Logger:      logger,
+			}
+			Authorization(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual = User(r.Context())
+			})).ServeHTTP(w, r)
+
+			_, _ = io.Copy(&body, w.Result().Body)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.user, actual)
+			if tc.code == 400 {
+				assert.Contains(t, body.String(), `Request without required header: X-Auth-Request-User`)
+			}
+		})
+	}
+}
+
+func TestUser(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	assert.Equal(t, "", User(ctx))
+	assert.Equal(t, "test", User(WithUser(ctx, "test")))
+	assert.Equal(t, "", User(context.WithValue(ctx, ContextKeyUser, 1)))
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+)
+
+func BodyLimit(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.LimitsEnv == nil || cfg.LimitsEnv.MaxRequestBytes <= 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			limit := cfg.LimitsEnv.MaxRequestBytes
+
+			if r.ContentLength > limit {
+				RequestTooLarge(cfg, w, r)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsRequestTooLarge reports whether reading the request body failed because
+// it exceeded the maximum size allowed.
+func IsRequestTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
+
+// RequestTooLarge refuses and audits an oversized request, without including
+// any of its content.
+func RequestTooLarge(cfg *gabi.Config, w http.ResponseWriter, r *http.Request) {
+	l := "Request body is too large"
+	user := User(r.Context())
+	cfg.Logger.Errorf("%s: %s", l, user)
+	AuditRejection(cfg, r, "", audit.DecisionDeniedPolicy, fmt.Sprintf("%s (limit: %d bytes)", l, cfg.LimitsEnv.MaxRequestBytes))
+	http.Error(w, l, http.StatusRequestEntityTooLarge)
+}
+
+// QueryTooLarge refuses and audits a query exceeding the maximum size allowed
+// to be parsed, without including it, as the query is never parsed then, and
+// reports whether it did. The limit applies to the query once decoded, thus
+// independently of the size of the request body.
+func QueryTooLarge(cfg *gabi.Config, w http.ResponseWriter, r *http.Request, query string) bool {
+	if cfg.LimitsEnv == nil || cfg.LimitsEnv.MaxQueryBytes <= 0 || len(query) <= cfg.LimitsEnv.MaxQueryBytes {
+		return false
+	}
+	l := "Query is too large"
+	cfg.Logger.Errorf("%s: %s (size: %d bytes)", l, User(r.Context()), len(query))
+	AuditRejection(cfg, r, "", audit.DecisionDeniedPolicy, fmt.Sprintf("%s (limit: %d bytes)", l, cfg.LimitsEnv.MaxQueryBytes))
+	http.Error(w, l, http.StatusBadRequest)
+	return true
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *limits.Env
+		request     string
+		length      bool
+		code        int
+		body        string
+		want        *regexp.Regexp
+	}{
+		{
+			"request without limit set",
+			&limits.Env{},
+			strings.Repeat("a", 1024),
+			true,
+			200,
+			``,
+			regexp.MustCompile(`^$`),
+		},
+		{
+			"request within the limit",
+			&limits.Env{MaxRequestBytes: 1024},
+			strings.Repeat("a", 1024),
+			true,
+			200,
+			``,
+			regexp.MustCompile(`^$`),
+		},
+		{
+			"request with content length over the limit",
+			&limits.Env{MaxRequestBytes: 16},
+			strings.Repeat("a", 1024),
+			true,
+			413,
+			`Request body is too large`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Request body is too large \(limit: 16 bytes\)"}`),
+		},
+		{
+			"request without content length over the limit",
+			&limits.Env{MaxRequestBytes: 16},
+			strings.Repeat("a", 1024),
+			false,
+			413,
+			`Request body is too large`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Request body is too large \(limit: 16 bytes\)"}`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), Logger: logger, LimitsEnv: tc.given, LoggerAudit: la, SplunkAudit: sa}
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); IsRequestTooLarge(err) {
+					RequestTooLarge(expected, w, r)
+				}
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.request))
+			r.Header.Set("X-Forwarded-User", "test")
+			if !tc.length {
+				r.ContentLength = -1
+			}
+
+			BodyLimit(expected)(dummyHandler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Regexp(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestQueryTooLarge(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *limits.Env
+		query       string
+		code        int
+		body        string
+		want        *regexp.Regexp
+	}{
+		{
+			"query without limit set",
+			&limits.Env{},
+			"select " + strings.Repeat("1", 1024) + ";",
+			200,
+			``,
+			regexp.MustCompile(`"query_hash": "[0-9a-f]{16}", "timestamp": \d{10}}`),
+		},
+		{
+			"query within the limit",
+			&limits.Env{MaxQueryBytes: 1024},
+			"select 1;",
+			200,
+			``,
+			regexp.MustCompile(`"query_hash": "354b7196c9ba5fb4", "timestamp": \d{10}}`),
+		},
+		{
+			"query over the limit",
+			&limits.Env{MaxQueryBytes: 16},
+			"select " + strings.Repeat("1", 1024) + ";",
+			400,
+			`Query is too large`,
+			regexp.MustCompile(`AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Query is too large \(limit: 16 bytes\)"}`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				output bytes.Buffer
+				called bool
+			)
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			cfg := &gabi.Config{ProxyEnv: test.ProxyEnv(), Logger: logger, LimitsEnv: tc.given, LoggerAudit: la, SplunkAudit: la}
+
+			body := fmt.Sprintf(`{"query": %q}`, tc.query)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			r.Header.Set("Content-Length", fmt.Sprint(len(body)))
+			r.Header.Set("X-Forwarded-User", "test")
+
+			Audit(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.body)
+			assert.Equal(t, tc.code == 200, called)
+			assert.Regexp(t, tc.want, output.String())
+			if tc.code == 400 {
+				assert.NotContains(t, output.String(), strings.Repeat("1", 1024))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+const bearerScheme = "Bearer "
+
+// ClientAuth refuses requests that do not carry the configured bearer token,
+// or a client certificate verified against the configured CA bundle, before
+// any other handling, regardless of the user the request claims to be.
+func ClientAuth(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ae := cfg.AuthEnv
+			if ae == nil || !ae.ClientAuthEnabled() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			l := "Request cannot be authenticated"
+
+			// Certificates that fail verification are already refused during the
+			// handshake, thus only their absence is checked here.
+			if ae.ClientCertRequired() && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+				cfg.Logger.Errorf("%s: missing client certificate (remote address: %s)", l, r.RemoteAddr)
+				http.Error(w, l, http.StatusUnauthorized)
+				return
+			}
+
+			if ae.BearerRequired() && !ae.IsTokenValid(bearerToken(r)) {
+				cfg.Logger.Errorf("%s: missing or invalid bearer token (remote address: %s)", l, r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, l, http.StatusUnauthorized)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get(authorizationHeader)
+	if len(header) < len(bearerScheme) || !strings.EqualFold(header[:len(bearerScheme)], bearerScheme) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(bearerScheme):])
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientAuth(t *testing.T) {
+	t.Parallel()
+
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+
+	cases := []struct {
+		description string
+		given       *auth.Env
+		request     func(*http.Request)
+		code        int
+		body        string
+		log         string
+	}{
+		{
+			"client authentication not configured",
+			&auth.Env{},
+			func(r *http.Request) {
+				// No-op.
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"bearer token set with valid token",
+			&auth.Env{BearerToken: "test"},
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer test")
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"bearer token set with lowercase scheme",
+			&auth.Env{BearerToken: "test"},
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "bearer test")
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"bearer token set with invalid token",
+			&auth.Env{BearerToken: "test"},
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer test2")
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing or invalid bearer token`,
+		},
+		{
+			"bearer token set with different scheme",
+			&auth.Env{BearerToken: "test"},
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Basic test")
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing or invalid bearer token`,
+		},
+		{
+			"bearer token set without token",
+			&auth.Env{BearerToken: "test"},
+			func(r *http.Request) {
+				// No-op.
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing or invalid bearer token`,
+		},
+		{
+			"client CA set with verified certificate",
+			&auth.Env{ClientCAFile: "/etc/gabi/ca.crt"},
+			func(r *http.Request) {
+				r.TLS = verified
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"client CA set without certificate",
+			&auth.Env{ClientCAFile: "/etc/gabi/ca.crt"},
+			func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{}
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing client certificate`,
+		},
+		{
+			"client CA set without TLS",
+			&auth.Env{ClientCAFile: "/etc/gabi/ca.crt"},
+			func(r *http.Request) {
+				// No-op.
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing client certificate`,
+		},
+		{
+			"client CA and bearer token set with both valid",
+			&auth.Env{BearerToken: "test", ClientCAFile: "/etc/gabi/ca.crt"},
+			func(r *http.Request) {
+				r.TLS = verified
+				r.Header.Set("Authorization", "Bearer test")
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"client CA and bearer token set without token",
+			&auth.Env{BearerToken: "test", ClientCAFile: "/etc/gabi/ca.crt"},
+			func(r *http.Request) {
+				r.TLS = verified
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing or invalid bearer token`,
+		},
+		{
+			"bearer token set as provider without token",
+			&auth.Env{BearerToken: "test", BearerUser: "service", Providers: []string{"bearer", "forwarded_header"}},
+			func(r *http.Request) {
+				// No-op.
+			},
+			200,
+			``,
+			``,
+		},
+		{
+			"client CA set as provider with bearer token set without token",
+			&auth.Env{BearerToken: "test", ClientCAFile: "/etc/gabi/ca.crt", Providers: []string{"client_cert"}},
+			func(r *http.Request) {
+				// No-op.
+			},
+			401,
+			`Request cannot be authenticated`,
+			`Request cannot be authenticated: missing or invalid bearer token`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+			tc.request(r)
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			})
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			expected := &gabi.Config{Logger: logger, AuthEnv: tc.given}
+			ClientAuth(expected)(dummyHandler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			var body bytes.Buffer
+			_, _ = body.ReadFrom(actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Contains(t, output.String(), tc.log)
+		})
+	}
+}
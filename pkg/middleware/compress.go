@@ -0,0 +1,145 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/felixge/httpsnoop"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+// Compress gzip-compresses responses on the fly for clients accepting it,
+// including streamed ones, which are compressed as these are written, and
+// flushed along with the response, so that memory stays bounded.
+func Compress(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		if cfg.ServerEnv == nil || !cfg.ServerEnv.CompressionEnabled() {
+			return h
+		}
+		level := cfg.ServerEnv.CompressionLevel
+
+		// Writers are reused between responses, as each holds sizeable buffers.
+		pool := &sync.Pool{New: func() interface{} {
+			zw, _ := gzip.NewWriterLevel(io.Discard, level)
+			return zw
+		}}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acceptsGzip(r) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			cw := &compressWriter{w: w, pool: pool}
+			h.ServeHTTP(cw.wrap(), r)
+			if err := cw.close(); err != nil {
+				cfg.Logger.Errorf("Unable to compress response: %s", err)
+			}
+		})
+	}
+}
+
+// acceptsGzip reports whether the client accepts gzip-encoded responses using
+// the Accept-Encoding header, unless explicitly refused using a zero quality.
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, s := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(s, ";")
+			switch strings.ToLower(strings.TrimSpace(coding)) {
+			case "gzip", "x-gzip", "*":
+			default:
+				continue
+			}
+			if q := strings.TrimSpace(params); strings.HasPrefix(q, "q=") {
+				if f, err := strconv.ParseFloat(strings.TrimPrefix(q, "q="), 64); err == nil && f == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// The response is only compressed once its status code is known, as neither
+// responses without a body, nor those already encoded by the handler, are.
+type compressWriter struct {
+	w    http.ResponseWriter
+	pool *sync.Pool
+
+	decided bool
+	zw      *gzip.Writer
+}
+
+func (c *compressWriter) wrap() http.ResponseWriter {
+	return httpsnoop.Wrap(c.w, httpsnoop.Hooks{
+		WriteHeader: func(next httpsnoop.WriteHeaderFunc) httpsnoop.WriteHeaderFunc {
+			return func(code int) {
+				c.decide(code)
+				next(code)
+			}
+		},
+		Write: func(next httpsnoop.WriteFunc) httpsnoop.WriteFunc {
+			return func(b []byte) (int, error) {
+				c.decide(http.StatusOK)
+				if c.zw != nil {
+					return c.zw.Write(b)
+				}
+				return next(b)
+			}
+		},
+		ReadFrom: func(next httpsnoop.ReadFromFunc) httpsnoop.ReadFromFunc {
+			return func(src io.Reader) (int64, error) {
+				c.decide(http.StatusOK)
+				if c.zw != nil {
+					return io.Copy(c.zw, src)
+				}
+				return next(src)
+			}
+		},
+		Flush: func(next httpsnoop.FlushFunc) httpsnoop.FlushFunc {
+			return func() {
+				if c.zw != nil {
+					_ = c.zw.Flush()
+				}
+				next()
+			}
+		},
+	})
+}
+
+func (c *compressWriter) decide(code int) {
+	// Informational responses precede the final one.
+	if c.decided || code < http.StatusOK {
+		return
+	}
+	c.decided = true
+
+	header := c.w.Header()
+	if code == http.StatusNoContent || code == http.StatusNotModified || header.Get(contentEncodingHeader) != "" {
+		return
+	}
+	header.Set(contentEncodingHeader, "gzip")
+	header.Del("Content-Length")
+
+	c.zw = c.pool.Get().(*gzip.Writer)
+	c.zw.Reset(c.w)
+}
+
+func (c *compressWriter) close() error {
+	if c.zw == nil {
+		return nil
+	}
+	err := c.zw.Close()
+	// The pooled writer must not keep the response writer around.
+	c.zw.Reset(io.Discard)
+	c.pool.Put(c.zw)
+	c.zw = nil
+	return err
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decompress(t *testing.T, b []byte) string {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat(`{"id":"1","name":"test"}`+"\n", 1000)
+
+	cases := []struct {
+		description string
+		given       *server.Env
+		encoding    string
+		handler     http.HandlerFunc
+		code        int
+		compressed  bool
+	}{
+		{
+			"response compressed for client accepting gzip",
+			&server.Env{CompressionLevel: 6},
+			"gzip, deflate",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "24000")
+				_, _ = io.WriteString(w, body)
+			},
+			200,
+			true,
+		},
+		{
+			"error response compressed for client accepting any encoding",
+			&server.Env{CompressionLevel: 1},
+			"*",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = io.Copy(w, strings.NewReader(body))
+			},
+			400,
+			true,
+		},
+		{
+			"response not compressed for client not accepting gzip",
+			&server.Env{CompressionLevel: 6},
+			"",
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, body)
+			},
+			200,
+			false,
+		},
+		{
+			"response not compressed for client refusing gzip",
+			&server.Env{CompressionLevel: 6},
+			"gzip;q=0, identity",
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, body)
+			},
+			200,
+			false,
+		},
+		{
+			"response not compressed with compression disabled",
+			&server.Env{},
+			"gzip",
+			func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, body)
+			},
+			200,
+			false,
+		},
+		{
+			"response without content not compressed",
+			&server.Env{CompressionLevel: 6},
+			"gzip",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			204,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+			if tc.encoding != "" {
+				r.Header.Set("Accept-Encoding", tc.encoding)
+			}
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			cfg := &gabi.Config{ServerEnv: tc.given, Logger: logger}
+			Compress(cfg)(tc.handler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			if !tc.compressed {
+				assert.Empty(t, actual.Header.Get("Content-Encoding"))
+				if tc.code != http.StatusNoContent {
+					assert.Equal(t, body, w.Body.String())
+				}
+				return
+			}
+			assert.Equal(t, "gzip", actual.Header.Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", actual.Header.Get("Vary"))
+			assert.Empty(t, actual.Header.Get("Content-Length"))
+			assert.Less(t, w.Body.Len(), len(body))
+			assert.Equal(t, body, decompress(t, w.Body.Bytes()))
+		})
+	}
+}
+
+func TestCompressStreaming(t *testing.T) {
+	t.Parallel()
+
+	var flushed string
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	cfg := &gabi.Config{ServerEnv: &server.Env{CompressionLevel: 6}, Logger: logger}
+	Compress(cfg)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(rw, "first\n")
+		rw.(http.Flusher).Flush()
+
+		// Everything written so far reaches the client once flushed.
+		zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		b := make([]byte, 64)
+		n, _ := zr.Read(b)
+		flushed = string(b[:n])
+
+		_, _ = io.WriteString(rw, "second\n")
+	})).ServeHTTP(w, r)
+
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "first\n", flushed)
+	assert.Equal(t, "first\nsecond\n", decompress(t, w.Body.Bytes()))
+}
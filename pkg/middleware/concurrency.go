@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"golang.org/x/sync/semaphore"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/db"
+)
+
+// Concurrency limits how many queries are executed at the same time, which,
+// unlike the connection pool, gives explicit admission control. Requests over
+// the limit either wait for a slot, up to the queue timeout, or are refused
+// straight away, depending on the policy set.
+func Concurrency(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		if cfg.DBEnv == nil || cfg.DBEnv.MaxConcurrentQueries == 0 {
+			return h
+		}
+		dbe := cfg.DBEnv
+
+		sem := semaphore.NewWeighted(int64(dbe.MaxConcurrentQueries))
+
+		var inFlight atomic.Int64
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			var acquired bool
+			if dbe.ConcurrencyPolicy == db.ConcurrencyPolicyReject {
+				acquired = sem.TryAcquire(1)
+			} else {
+				wait, cancel := context.WithTimeout(ctx, dbe.QueueTimeout)
+				acquired = sem.Acquire(wait, 1) == nil
+				cancel()
+			}
+
+			if !acquired {
+				l := "Too many concurrent queries"
+				query, _ := ctx.Value(ContextKeyQuery).(string)
+				cfg.Logger.Errorf("%s: %s", l, User(ctx))
+				AuditRejection(cfg, r, query, audit.DecisionDeniedPolicy, l)
+				http.Error(w, l, http.StatusTooManyRequests)
+				return
+			}
+			defer sem.Release(1)
+
+			cfg.Metrics.SetQueriesInFlight(int(inFlight.Add(1)))
+			defer func() { cfg.Metrics.SetQueriesInFlight(int(inFlight.Add(-1))) }()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/app-sre/gabi/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type nopAudit struct{}
+
+func (nopAudit) Write(q *audit.QueryData) error {
+	return nil
+}
+
+func TestConcurrency(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *db.Env
+		code        int
+		body        string
+		want        string
+	}{
+		{
+			"request without limit set",
+			&db.Env{},
+			200,
+			``,
+			``,
+		},
+		{
+			"request over the limit with reject policy",
+			&db.Env{MaxConcurrentQueries: 1, ConcurrencyPolicy: db.ConcurrencyPolicyReject},
+			429,
+			`Too many concurrent queries`,
+			`AUDIT	{"user": "test", "query_hash": "354b7196c9ba5fb4", "timestamp": `,
+		},
+		{
+			"request over the limit with queue policy",
+			&db.Env{MaxConcurrentQueries: 1, ConcurrencyPolicy: db.ConcurrencyPolicyQueue, QueueTimeout: 50 * time.Millisecond},
+			429,
+			`Too many concurrent queries`,
+			`"rejection": "Too many concurrent queries"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			expected := &gabi.Config{
+				DBEnv:       tc.given,
+				LoggerAudit: &audit.ConsoleAudit{Logger: logger},
+				SplunkAudit: nopAudit{},
+				Metrics:     metrics.New("test"),
+				Logger:      logger,
+			}
+
+			started, release := make(chan struct{}), make(chan struct{})
+			handler := Concurrency(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/blocking" {
+					close(started)
+					<-release
+				}
+			}))
+
+			// Hold the only slot available for the duration of the test.
+			done := make(chan struct{})
+			if tc.given.MaxConcurrentQueries > 0 {
+				go func() {
+					defer close(done)
+					handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/blocking", &bytes.Buffer{}))
+				}()
+				<-started
+			} else {
+				close(done)
+			}
+
+			ctx := WithUser(context.Background(), "test")
+			ctx = context.WithValue(ctx, ContextKeyQuery, "select 1;")
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{}).WithContext(ctx)
+
+			handler.ServeHTTP(w, r)
+			close(release)
+			<-done
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.body)
+			assert.Contains(t, output.String(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+const corsMaxAge = "600"
+
+// Response headers that browser-based clients are allowed to read.
+var corsExposedHeaders = []string{requestIDHeader, daysRemainingHeader, graceDaysHeader, RowCountHeader, TruncatedHeader}
+
+func CORS(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ce := cfg.CORSEnv
+			if ce == nil || !ce.Enabled() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			if !ce.IsOriginAllowed(origin) {
+				if preflight {
+					http.Error(w, "Origin is not allowed", http.StatusForbidden)
+					return
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if ce.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if !ce.IsMethodAllowed(r.Header.Get("Access-Control-Request-Method")) {
+				http.Error(w, "Method is not allowed", http.StatusForbidden)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(ce.AllowedMethods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(ce.AllowedHeaders, ", "))
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/cors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+
+	allowed := &cors.Env{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type"},
+	}
+
+	cases := []struct {
+		description string
+		given       *cors.Env
+		method      string
+		headers     map[string]string
+		code        int
+		handled     bool
+		want        map[string]string
+	}{
+		{
+			"CORS not configured",
+			nil,
+			http.MethodPost,
+			map[string]string{"Origin": "https://example.com"},
+			200,
+			true,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"CORS disabled",
+			&cors.Env{},
+			http.MethodPost,
+			map[string]string{"Origin": "https://example.com"},
+			200,
+			true,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			"request without origin",
+			allowed,
+			http.MethodPost,
+			map[string]string{},
+			200,
+			true,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			"request from allowed origin",
+			allowed,
+			http.MethodPost,
+			map[string]string{"Origin": "https://example.com"},
+			200,
+			true,
+			map[string]string{
+				"Access-Control-Allow-Origin":      "https://example.com",
+				"Access-Control-Allow-Credentials": "",
+				"Access-Control-Expose-Headers":    "X-Request-Id, X-Gabi-Days-Remaining, X-Gabi-Grace-Days-Remaining, X-Gabi-Row-Count, X-Gabi-Truncated",
+			},
+		},
+		{
+			"request from allowed origin with credentials",
+			&cors.Env{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true},
+			http.MethodPost,
+			map[string]string{"Origin": "https://example.com"},
+			200,
+			true,
+			map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true"},
+		},
+		{
+			"request from origin not allowed",
+			allowed,
+			http.MethodPost,
+			map[string]string{"Origin": "https://example.org"},
+			200,
+			true,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"preflight request from allowed origin",
+			allowed,
+			http.MethodOptions,
+			map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST"},
+			204,
+			false,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			"preflight request from origin not allowed",
+			allowed,
+			http.MethodOptions,
+			map[string]string{"Origin": "https://example.org", "Access-Control-Request-Method": "POST"},
+			403,
+			false,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"preflight request with method not allowed",
+			allowed,
+			http.MethodOptions,
+			map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "DELETE"},
+			403,
+			false,
+			map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var handled bool
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(tc.method, "/", &bytes.Buffer{})
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handled = true
+			})
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), CORSEnv: tc.given, Logger: test.DummyLogger(io.Discard).Sugar()}
+			CORS(expected)(dummyHandler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Equal(t, tc.handled, handled)
+			for k, v := range tc.want {
+				assert.Equal(t, v, actual.Header.Get(k), k)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+)
+
+const contentEncodingHeader = "Content-Encoding"
+
+// Decompress transparently decompresses gzip-encoded request bodies. The size
+// of the decompressed body is limited the same way as the body itself, so
+// that a small compressed body cannot expand without bounds.
+func Decompress(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get(contentEncodingHeader)))
+			switch encoding {
+			case "", "identity":
+				h.ServeHTTP(w, r)
+				return
+			case "gzip", "x-gzip":
+			default:
+				l := fmt.Sprintf("Request body encoding is not supported: %s", encoding)
+				cfg.Logger.Error(l)
+				AuditRejection(cfg, r, "", audit.DecisionError, l)
+				http.Error(w, l, http.StatusUnsupportedMediaType)
+				return
+			}
+
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				if IsRequestTooLarge(err) {
+					RequestTooLarge(cfg, w, r)
+					return
+				}
+				MalformedBody(cfg, w, r, err)
+				return
+			}
+
+			var body io.ReadCloser = &gzipBody{Reader: zr, body: r.Body}
+			if cfg.LimitsEnv != nil && cfg.LimitsEnv.MaxRequestBytes > 0 {
+				body = http.MaxBytesReader(w, body, cfg.LimitsEnv.MaxRequestBytes)
+			}
+
+			r.Header.Del(contentEncodingHeader)
+			r.Body = body
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsMalformedBody reports whether reading the request body failed because it
+// could not be decompressed.
+func IsMalformedBody(err error) bool {
+	var decompressError *decompressError
+	return errors.As(err, &decompressError)
+}
+
+// MalformedBody refuses and audits a request whose body cannot be
+// decompressed.
+func MalformedBody(cfg *gabi.Config, w http.ResponseWriter, r *http.Request, err error) {
+	l := "Request body is not valid gzip"
+	cfg.Logger.Errorf("%s: %s", l, err)
+	AuditRejection(cfg, r, "", audit.DecisionError, l)
+	http.Error(w, l, http.StatusBadRequest)
+}
+
+type decompressError struct {
+	err error
+}
+
+func (e *decompressError) Error() string {
+	return fmt.Sprintf("unable to decompress request body: %s", e.err)
+}
+
+func (e *decompressError) Unwrap() error {
+	return e.err
+}
+
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipBody) Read(p []byte) (int, error) {
+	n, err := g.Reader.Read(p)
+	if err != nil && err != io.EOF && !IsRequestTooLarge(err) {
+		err = &decompressError{err: err}
+	}
+	return n, err
+}
+
+func (g *gzipBody) Close() error {
+	_ = g.Reader.Close()
+	return g.body.Close()
+}
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func compress(t *testing.T, s string) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+
+	zw := gzip.NewWriter(&b)
+	_, err := zw.Write([]byte(s))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	return b.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	t.Parallel()
+
+	query := `{"query": "select 1;"}`
+
+	cases := []struct {
+		description string
+		given       *limits.Env
+		encoding    string
+		request     func(*testing.T) []byte
+		code        int
+		body        string
+		want        string
+	}{
+		{
+			"request without encoding",
+			&limits.Env{},
+			"",
+			func(t *testing.T) []byte { return []byte(query) },
+			200,
+			query,
+			``,
+		},
+		{
+			"request with gzip encoding",
+			&limits.Env{MaxRequestBytes: 1024},
+			"gzip",
+			func(t *testing.T) []byte { return compress(t, query) },
+			200,
+			query,
+			``,
+		},
+		{
+			"request with gzip encoding over the limit once decompressed",
+			&limits.Env{MaxRequestBytes: 1024},
+			"GZIP",
+			func(t *testing.T) []byte { return compress(t, strings.Repeat("a", 1<<20)) },
+			413,
+			`Request body is too large`,
+			`Request body is too large (limit: 1024 bytes)`,
+		},
+		{
+			"request with malformed gzip header",
+			&limits.Env{},
+			"gzip",
+			func(t *testing.T) []byte { return []byte(query) },
+			400,
+			`Request body is not valid gzip`,
+			`"decision": "error", "rejection": "Request body is not valid gzip"`,
+		},
+		{
+			"request with truncated gzip data",
+			&limits.Env{},
+			"gzip",
+			func(t *testing.T) []byte {
+				b := compress(t, strings.Repeat("a", 1024))
+				return b[:len(b)-8]
+			},
+			400,
+			`Request body is not valid gzip`,
+			`"decision": "error", "rejection": "Request body is not valid gzip"`,
+		},
+		{
+			"request with unsupported encoding",
+			&limits.Env{},
+			"br",
+			func(t *testing.T) []byte { return []byte(query) },
+			415,
+			`Request body encoding is not supported: br`,
+			`"rejection": "Request body encoding is not supported: br"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				body, output bytes.Buffer
+				server       bytes.Buffer
+			)
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(&server, r.Body)
+				_, _ = w.Write([]byte(`{"Code":0,"Text":""}`))
+			}))
+			defer s.Close()
+
+			content := tc.request(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(content))
+			r.Header.Set("X-Forwarded-User", "test")
+			if tc.encoding != "" {
+				r.Header.Set("Content-Encoding", tc.encoding)
+			}
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), LimitsEnv: tc.given, LoggerAudit: &audit.ConsoleAudit{Logger: logger}, SplunkAudit: sa, Logger: logger}
+			BodyLimit(expected)(Decompress(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var b bytes.Buffer
+
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+				if _, err := io.Copy(&b, r.Body); err != nil {
+					switch {
+					case IsRequestTooLarge(err):
+						RequestTooLarge(expected, w, r)
+					case IsMalformedBody(err):
+						MalformedBody(expected, w, r, err)
+					}
+					return
+				}
+				_, _ = w.Write(b.Bytes())
+			}))).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Contains(t, output.String(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+func Draining(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.Draining() {
+				w.Header().Set("Connection", "close")
+				http.Error(w, "The service is shutting down", http.StatusServiceUnavailable)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraining(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       bool
+		code        int
+		body        string
+	}{
+		{
+			"service is serving requests",
+			false,
+			200,
+			``,
+		},
+		{
+			"service is shutting down",
+			true,
+			503,
+			`The service is shutting down`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			})
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			expected := &gabi.Config{Logger: logger}
+			expected.SetDraining(tc.given)
+			Draining(expected)(dummyHandler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+		})
+	}
+}
@@ -1,24 +1,60 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
 	"github.com/app-sre/gabi/pkg/env/user"
+	"github.com/app-sre/gabi/pkg/models"
 )
 
 func Expiration(cfg *gabi.Config) Middleware {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if cfg.UserEnv.IsExpired() {
+			ctx := r.Context()
+
+			usere := cfg.CurrentUserEnv()
+			date := usere.Expiration.Format(user.ExpiryDateLayout)
+
+			// Read-only queries are still served during the grace period, if any,
+			// with every response carrying a warning.
+			if usere.InGracePeriod() {
+				days := usere.GraceDaysRemaining()
+				l := fmt.Sprintf("The service instance has expired, and stops serving queries in %d day(s), which are read-only until then (expiration date: %s)", days, date)
+				cfg.Logger.Warn(l)
+				w.Header().Set(graceDaysHeader, strconv.Itoa(days))
+				ctx = context.WithValue(ctx, ContextKeyWarning, l)
+				ctx = context.WithValue(ctx, ContextKeyPostExpiry, true)
+				h.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			if usere.IsExpired() {
 				l := "The service instance has expired"
-				cfg.Logger.Errorf("%s (expiration date: %s)", l,
-					cfg.UserEnv.Expiration.Format(user.ExpiryDateLayout),
-				)
-				http.Error(w, l, http.StatusServiceUnavailable)
+				cfg.Logger.Errorf("%s (expiration date: %s)", l, date)
+				AuditRejection(cfg, r, "", audit.DecisionDeniedPolicy, l)
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(&models.ExpirationResponse{
+					Error:      l,
+					Expiration: usere.Expiration,
+				})
 				return
 			}
-			h.ServeHTTP(w, r)
+
+			if usere.IsExpiring() {
+				days := usere.DaysRemaining()
+				l := fmt.Sprintf("The service instance expires in %d day(s) (expiration date: %s)", days, date)
+				w.Header().Set(daysRemainingHeader, strconv.Itoa(days))
+				ctx = context.WithValue(ctx, ContextKeyWarning, l)
+			}
+
+			h.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
@@ -10,6 +10,7 @@ import (
 
 	"github.com/app-sre/gabi/internal/test"
 	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
 	"github.com/app-sre/gabi/pkg/env/user"
 	"github.com/stretchr/testify/assert"
 )
@@ -22,43 +23,71 @@ func TestExpiration(t *testing.T) {
 		given       *user.Env
 		code        int
 		body        string
+		days        string
+		warning     string
 	}{
 		{
 			"instance has not expired",
-			&user.Env{Expiration: time.Now().AddDate(0, 0, 1)},
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 30), WarningDays: 7},
 			200,
 			``,
+			``,
+			``,
+		},
+		{
+			"instance is about to expire",
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 3), WarningDays: 7},
+			200,
+			``,
+			`3`,
+			`The service instance expires in 3 day(s)`,
+		},
+		{
+			"instance is about to expire with warning disabled",
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 3)},
+			200,
+			``,
+			``,
+			``,
 		},
 		{
 			"instance has expired",
-			&user.Env{Expiration: time.Now().AddDate(0, 0, -1)},
-			503,
-			`The service instance has expired`,
+			&user.Env{Expiration: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+			403,
+			`{"error":"The service instance has expired","expiration":"2023-01-01T00:00:00Z"}`,
+			``,
+			``,
 		},
 		{
 			"invalid instance without expiration date",
 			&user.Env{},
-			503,
+			403,
 			`The service instance has expired`,
+			``,
+			``,
 		},
 	}
 
-	dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
-
 	for _, tc := range cases {
 		tc := tc
 		t.Run(tc.description, func(t *testing.T) {
 			t.Parallel()
 
-			var body bytes.Buffer
+			var (
+				body    bytes.Buffer
+				warning string
+			)
 
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
 
 			logger := test.DummyLogger(io.Discard).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
 
-			expected := &gabi.Config{Logger: logger, UserEnv: tc.given}
-			Expiration(expected)(dummyHandler).ServeHTTP(w, r)
+			expected := &gabi.Config{Logger: logger, UserEnv: tc.given, LoggerAudit: la, SplunkAudit: la}
+			Expiration(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				warning, _ = r.Context().Value(ContextKeyWarning).(string)
+			})).ServeHTTP(w, r)
 
 			actual := w.Result()
 			defer func() { _ = actual.Body.Close() }()
@@ -67,6 +96,84 @@ func TestExpiration(t *testing.T) {
 
 			assert.Equal(t, tc.code, actual.StatusCode)
 			assert.Contains(t, body.String(), tc.body)
+			assert.Equal(t, tc.days, actual.Header.Get("X-Gabi-Days-Remaining"))
+			assert.Contains(t, warning, tc.warning)
+		})
+	}
+}
+
+func TestExpirationGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *user.Env
+		code        int
+		days        string
+		warning     string
+		expired     bool
+	}{
+		{
+			"instance has expired within grace period",
+			&user.Env{Expiration: time.Now().AddDate(0, 0, -1), GraceDays: 3},
+			200,
+			`2`,
+			`The service instance has expired, and stops serving queries in 2 day(s)`,
+			true,
+		},
+		{
+			"instance has expired after grace period",
+			&user.Env{Expiration: time.Now().AddDate(0, 0, -5), GraceDays: 3},
+			403,
+			``,
+			``,
+			false,
+		},
+		{
+			"instance has not expired with grace period set",
+			&user.Env{Expiration: time.Now().AddDate(0, 0, 30), GraceDays: 3},
+			200,
+			``,
+			``,
+			false,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				warning string
+				expired bool
+			)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+			var output bytes.Buffer
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{Logger: logger, UserEnv: tc.given, LoggerAudit: la, SplunkAudit: la}
+			Expiration(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				warning, _ = r.Context().Value(ContextKeyWarning).(string)
+				expired = PostExpiry(r.Context())
+			})).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Equal(t, tc.days, actual.Header.Get("X-Gabi-Grace-Days-Remaining"))
+			assert.Contains(t, warning, tc.warning)
+			assert.Equal(t, tc.expired, expired)
+			// Requests refused once expired are audited as denied by policy.
+			if tc.code == http.StatusForbidden {
+				assert.Contains(t, output.String(), `"decision": "denied_policy", "rejection": "The service instance has expired"`)
+			}
 		})
 	}
 }
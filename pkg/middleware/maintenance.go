@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+)
+
+// Maintenance rejects requests while the maintenance flag file exists, so
+// that the database can be worked on without taking the service down. The
+// attempts are audited, as for any other rejected request.
+func Maintenance(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		me := cfg.MaintenanceEnv
+		if me == nil || !me.Enabled() {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			message, ok := me.Active()
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			l := "Service under maintenance"
+			cfg.Logger.Errorf("%s: %s", l, requestUser(cfg, r))
+			AuditRejection(cfg, r, "", audit.DecisionDeniedPolicy, l)
+			if me.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(me.RetryAfter.Seconds()))))
+			}
+			http.Error(w, message, http.StatusServiceUnavailable)
+		})
+	}
+}
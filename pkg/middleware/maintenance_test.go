@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/maintenance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	flag := filepath.Join(dir, "maintenance")
+	require.NoError(t, os.WriteFile(flag, []byte("Database upgrade in progress\n"), 0o600))
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	cases := []struct {
+		description string
+		given       *maintenance.Env
+		code        int
+		body        string
+		retryAfter  string
+		audit       string
+	}{
+		{
+			"maintenance mode not configured",
+			nil,
+			200,
+			``,
+			``,
+			``,
+		},
+		{
+			"flag file missing",
+			&maintenance.Env{File: filepath.Join(dir, "missing"), Message: "The service is under maintenance", RetryAfter: time.Minute},
+			200,
+			``,
+			``,
+			``,
+		},
+		{
+			"flag file with message",
+			&maintenance.Env{File: flag, Message: "The service is under maintenance", RetryAfter: time.Minute},
+			503,
+			`Database upgrade in progress`,
+			`60`,
+			`"rejection": "Service under maintenance"`,
+		},
+		{
+			"empty flag file without retry after",
+			&maintenance.Env{File: empty, Message: "The service is under maintenance"},
+			503,
+			`The service is under maintenance`,
+			``,
+			`"rejection": "Service under maintenance"`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+			r = r.WithContext(WithUser(r.Context(), "test"))
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			})
+
+			logger := test.DummyLogger(&output).Sugar()
+			la := &audit.ConsoleAudit{Logger: logger}
+
+			expected := &gabi.Config{
+				MaintenanceEnv: tc.given,
+				LoggerAudit:    la,
+				SplunkAudit:    la,
+				Logger:         logger,
+			}
+			Maintenance(expected)(dummyHandler).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Equal(t, tc.retryAfter, actual.Header.Get("Retry-After"))
+			if tc.audit != "" {
+				assert.Contains(t, output.String(), tc.audit)
+			} else {
+				assert.NotContains(t, output.String(), "AUDIT")
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+func Metrics(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m := httpsnoop.CaptureMetrics(h, w, r)
+			cfg.Metrics.ObserveRequest(r.Context(), m.Code, m.Duration)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       http.HandlerFunc
+		code        int
+		want        string
+	}{
+		{
+			"successful request",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			}),
+			200,
+			`gabi_responses_total{code="200",namespace="test"} 1`,
+		},
+		{
+			"failed request",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "test", http.StatusForbidden)
+			}),
+			403,
+			`gabi_responses_total{code="403",namespace="test"} 1`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			expected := &gabi.Config{Logger: logger, Metrics: metrics.New("test")}
+			Metrics(expected)(tc.given).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			m := httptest.NewRecorder()
+			expected.Metrics.Handler().ServeHTTP(m, httptest.NewRequest(http.MethodGet, "/metrics", &bytes.Buffer{}))
+
+			_, _ = io.Copy(&body, m.Result().Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.want)
+		})
+	}
+}
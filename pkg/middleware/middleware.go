@@ -1,19 +1,128 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/proxy"
 )
 
 type ctxKey string
 
 const (
-	ContextKeyUser  ctxKey = "user"
-	ContextKeyQuery ctxKey = "query"
+	ContextKeyUser     ctxKey = "user"
+	ContextKeyQuery    ctxKey = "query"
+	ContextKeyArgs     ctxKey = "args"
+	ContextKeyTags     ctxKey = "tags"
+	ContextKeyDatabase ctxKey = "database"
+	ContextKeyWarning  ctxKey = "warning"
+
+	ContextKeyAuthProvider ctxKey = "auth_provider"
+
+	ContextKeyPostExpiry ctxKey = "post_expiry"
+
+	ContextKeyRequestID ctxKey = "request_id"
+
+	ContextKeyCacheKey      ctxKey = "cache_key"
+	ContextKeyCacheResponse ctxKey = "cache_response"
 )
 
 const (
-	contentLengthHeader = "Content-Length"
-	forwardedUserHeader = "X-Forwarded-User"
+	authorizationHeader   = "Authorization"
+	contentLengthHeader   = "Content-Length"
+	forwardedGroupsHeader = "X-Forwarded-Groups"
+	daysRemainingHeader   = "X-Gabi-Days-Remaining"
+	graceDaysHeader       = "X-Gabi-Grace-Days-Remaining"
+	requestIDHeader       = "X-Request-Id"
 )
 
+// Headers describing the results of a query, set by the query handler.
+const (
+	RowCountHeader  = "X-Gabi-Row-Count"
+	TruncatedHeader = "X-Gabi-Truncated"
+)
+
+// Forwarding headers are honored from no peer, as no trusted proxies are set,
+// when no proxy configuration is given.
+var defaultProxyEnv = proxy.NewProxyEnv()
+
 type Middleware func(http.Handler) http.Handler
+
+// WithUser returns a copy of the context carrying the authenticated user,
+// which is then used throughout the request, such as when auditing.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, ContextKeyUser, user)
+}
+
+// User returns the authenticated user carried by the context, if any.
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(ContextKeyUser).(string)
+	return user
+}
+
+// WithDatabase returns a copy of the context carrying the database selected
+// by the client, which is then audited in place of the configured one.
+func WithDatabase(ctx context.Context, database string) context.Context {
+	return context.WithValue(ctx, ContextKeyDatabase, database)
+}
+
+// Database returns the database selected by the client carried by the
+// context, if any.
+func Database(ctx context.Context) string {
+	database, _ := ctx.Value(ContextKeyDatabase).(string)
+	return database
+}
+
+// WithAuthProvider returns a copy of the context carrying the name of the
+// authentication provider having identified the user.
+func WithAuthProvider(ctx context.Context, provider string) context.Context {
+	return context.WithValue(ctx, ContextKeyAuthProvider, provider)
+}
+
+// AuthProvider returns the authentication provider carried by the context,
+// if any.
+func AuthProvider(ctx context.Context) string {
+	provider, _ := ctx.Value(ContextKeyAuthProvider).(string)
+	return provider
+}
+
+// PostExpiry reports whether the request is served during the grace period
+// following the expiration date of the instance.
+func PostExpiry(ctx context.Context) bool {
+	expired, _ := ctx.Value(ContextKeyPostExpiry).(bool)
+	return expired
+}
+
+// ReadOnly reports whether the query of the request runs in a read-only
+// transaction, which the database enforces, unless write access is enabled
+// and the instance has not expired yet.
+func ReadOnly(ctx context.Context, cfg *gabi.Config) bool {
+	return cfg.DBEnv != nil && (!cfg.DBEnv.AllowWrite || PostExpiry(ctx))
+}
+
+func proxyEnv(cfg *gabi.Config) *proxy.Env {
+	if cfg.ProxyEnv != nil {
+		return cfg.ProxyEnv
+	}
+	return defaultProxyEnv
+}
+
+// ForwardedHeader returns the value of the forwarding header, such as the
+// forwarded user, which is only honored when the immediate peer is a trusted
+// proxy, thus never when no trusted proxies are set.
+func ForwardedHeader(cfg *gabi.Config, r *http.Request, header string) string {
+	if !proxyEnv(cfg).IsTrusted(r.RemoteAddr) {
+		return ""
+	}
+	return r.Header.Get(header)
+}
+
+func forwardedUser(cfg *gabi.Config, r *http.Request) string {
+	return ForwardedHeader(cfg, r, proxyEnv(cfg).UserHeader)
+}
+
+// ClientIP returns the address of the client, as forwarded by trusted proxies.
+func ClientIP(cfg *gabi.Config, r *http.Request) string {
+	return proxyEnv(cfg).ClientIP(r)
+}
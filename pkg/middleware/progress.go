@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+// Progress tracks the requests in flight, and those completing, for the
+// liveness check to tell when request handling has stalled. Only requests
+// which can block, such as on audit writes, are to be tracked, as others would
+// complete regardless, hiding the stall.
+func Progress(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		if cfg.Watchdog == nil {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg.Watchdog.Started()
+			defer cfg.Watchdog.Finished()
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
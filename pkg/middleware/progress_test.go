@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/health"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	watchdog := health.NewWatchdog(time.Minute, health.WithWatchdogClock(func() time.Time { return now }))
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+	cfg := &gabi.Config{Watchdog: watchdog, Logger: logger}
+
+	var inFlight int
+	Progress(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The request is in flight while handled, and stalls once the window
+		// elapses without any other completing.
+		now = now.Add(2 * time.Minute)
+		var stalled bool
+		stalled, inFlight, _ = watchdog.Stalled()
+		assert.True(t, stalled)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{}))
+
+	assert.Equal(t, 1, inFlight)
+
+	stalled, inFlight, _ := watchdog.Stalled()
+	assert.False(t, stalled)
+	assert.Zero(t, inFlight)
+}
+
+func TestProgressWithoutWatchdog(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+	logger := test.DummyLogger(io.Discard).Sugar()
+
+	Progress(&gabi.Config{Logger: logger})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
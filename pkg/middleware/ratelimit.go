@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+)
+
+const rateLimitSweepInterval = 1 * time.Minute
+
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+
+	mu    sync.Mutex
+	users map[string]*rate.Limiter
+	swept time.Time
+}
+
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	return &rateLimiter{
+		limit: rate.Limit(float64(perMinute) / 60),
+		burst: burst,
+		users: make(map[string]*rate.Limiter),
+	}
+}
+
+// reserve takes a token from the bucket of the given user, returning how long
+// to wait before retrying when none is available.
+func (l *rateLimiter) reserve(user string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Users whose bucket has refilled completely are indistinguishable from
+	// new users, so it is safe to forget about them.
+	if now.Sub(l.swept) >= rateLimitSweepInterval {
+		for k, v := range l.users {
+			if v.TokensAt(now) >= float64(l.burst) {
+				delete(l.users, k)
+			}
+		}
+		l.swept = now
+	}
+
+	limiter, ok := l.users[user]
+	if !ok {
+		limiter = rate.NewLimiter(l.limit, l.burst)
+		l.users[user] = limiter
+	}
+
+	reservation := limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		reservation.CancelAt(now)
+	}
+
+	return delay
+}
+
+func RateLimit(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		if cfg.LimitsEnv == nil || cfg.LimitsEnv.RatePerMinute == 0 {
+			return h
+		}
+		limiter := newRateLimiter(cfg.LimitsEnv.RatePerMinute, cfg.LimitsEnv.RateBurst)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := User(r.Context())
+			if user == "" {
+				user = forwardedUser(cfg, r)
+			}
+
+			if delay := limiter.reserve(user, time.Now()); delay > 0 {
+				l := "Rate limit exceeded"
+				cfg.Logger.Errorf("%s: %s", l, user)
+				AuditRejection(cfg, r, "", audit.DecisionDeniedPolicy, l)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+				http.Error(w, l, http.StatusTooManyRequests)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/audit"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/app-sre/gabi/pkg/env/splunk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *limits.Env
+		requests    int
+		code        int
+		body        string
+		retry       string
+		want        *regexp.Regexp
+	}{
+		{
+			"rate limit not set",
+			&limits.Env{},
+			10,
+			200,
+			``,
+			``,
+			regexp.MustCompile(``),
+		},
+		{
+			"requests within rate limit",
+			&limits.Env{RatePerMinute: 60, RateBurst: 3},
+			3,
+			200,
+			``,
+			``,
+			regexp.MustCompile(``),
+		},
+		{
+			"requests exceeding rate limit",
+			&limits.Env{RatePerMinute: 60, RateBurst: 3},
+			4,
+			429,
+			`Rate limit exceeded`,
+			`1`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Rate limit exceeded"}.*AUDIT query\s{"query_hash": "e3b0c44298fc1c14", "query": ""}`),
+		},
+		{
+			"requests exceeding low rate limit",
+			&limits.Env{RatePerMinute: 1, RateBurst: 1},
+			2,
+			429,
+			`Rate limit exceeded`,
+			`60`,
+			regexp.MustCompile(`(?s)AUDIT\s{"user": "test", "query_hash": "e3b0c44298fc1c14", "timestamp": \d{10}, "decision": "denied_policy", "rejection": "Rate limit exceeded"}.*AUDIT query\s{"query_hash": "e3b0c44298fc1c14", "query": ""}`),
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var body, output bytes.Buffer
+
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, `{"Code":0,"Text":""}`)
+			}))
+			defer s.Close()
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			la := &audit.ConsoleAudit{Logger: logger}
+			sa := &audit.SplunkAudit{SplunkEnv: &splunk.Env{Endpoint: s.URL}}
+			sa.SetHTTPClient(http.DefaultClient)
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), Logger: logger, LimitsEnv: tc.given, LoggerAudit: la, SplunkAudit: sa}
+			handler := RateLimit(expected)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tc.requests; i++ {
+				w = httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+				r.Header.Set("X-Forwarded-User", "test")
+				handler.ServeHTTP(w, r)
+			}
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			_, _ = io.Copy(&body, actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Equal(t, tc.retry, actual.Header.Get("Retry-After"))
+			assert.Regexp(t, tc.want, output.String())
+		})
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(60, 1)
+
+	assert.Equal(t, time.Duration(0), limiter.reserve("test", now))
+	assert.Equal(t, time.Second, limiter.reserve("test", now))
+	assert.Equal(t, time.Duration(0), limiter.reserve("test2", now))
+	assert.Len(t, limiter.users, 2)
+
+	// Users idle long enough to have their bucket refilled are removed.
+	later := now.Add(rateLimitSweepInterval)
+	assert.Equal(t, time.Duration(0), limiter.reserve("test", later))
+	assert.Len(t, limiter.users, 1)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		generated   bool
+	}{
+		{
+			"request without request ID set",
+			``,
+			true,
+		},
+		{
+			"request with request ID set",
+			`test`,
+			false,
+		},
+		{
+			"request with request ID that is too long",
+			strings.Repeat("a", maxRequestIDLength+1),
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var actual string
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+			if tc.given != "" {
+				r.Header.Set(requestIDHeader, tc.given)
+			}
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual, _ = r.Context().Value(ContextKeyRequestID).(string)
+			})
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			expected := &gabi.Config{ProxyEnv: test.ProxyEnv(), Logger: logger}
+			RequestID(expected)(dummyHandler).ServeHTTP(w, r)
+
+			assert.Equal(t, actual, w.Result().Header.Get(requestIDHeader))
+			if tc.generated {
+				assert.Len(t, actual, 32)
+			} else {
+				assert.Equal(t, tc.given, actual)
+			}
+		})
+	}
+}
+
+func TestRequestIDTrustedProxy(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		proxies     []*net.IPNet
+		peer        string
+		generated   bool
+	}{
+		{
+			"request ID from trusted proxy",
+			[]*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+			"10.0.0.1:1234",
+			false,
+		},
+		{
+			"request ID from untrusted peer",
+			[]*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(8, 32)}},
+			"192.0.2.1:1234",
+			true,
+		},
+		{
+			"request ID without trusted proxies set",
+			nil,
+			"10.0.0.1:1234",
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var actual string
+
+			pe := proxy.NewProxyEnv()
+			pe.TrustedProxies = tc.proxies
+			pe.RequestIDHeader = "X-Correlation-Id"
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", &bytes.Buffer{})
+			r.RemoteAddr = tc.peer
+			r.Header.Set("X-Correlation-Id", "test")
+
+			dummyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actual, _ = r.Context().Value(ContextKeyRequestID).(string)
+			})
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			expected := &gabi.Config{ProxyEnv: pe, Logger: logger}
+			RequestID(expected)(dummyHandler).ServeHTTP(w, r)
+
+			assert.Equal(t, actual, w.Result().Header.Get(requestIDHeader))
+			if tc.generated {
+				assert.Len(t, actual, 32)
+			} else {
+				assert.Equal(t, "test", actual)
+			}
+		})
+	}
+}
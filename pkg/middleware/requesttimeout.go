@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/models"
+)
+
+// RequestTimeout bounds the total time spent on a request, cancelling its
+// context once the timeout has passed. Requests yet to send a response are
+// answered with the 504 Gateway Timeout status code, while responses already
+// being sent are aborted, so that clients never mistake these as complete.
+func RequestTimeout(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.LimitsEnv == nil || cfg.LimitsEnv.RequestTimeout <= 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			timeout := cfg.LimitsEnv.RequestTimeout
+
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, header: make(http.Header)}
+
+			done := make(chan struct{})
+			panicked := make(chan interface{}, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicked <- p
+						return
+					}
+					close(done)
+				}()
+				h.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case p := <-panicked:
+				panic(p)
+			case <-done:
+				return
+			case <-ctx.Done():
+			}
+
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			// The handler keeps running until it notices the cancellation, thus
+			// anything written from now on is discarded.
+			tw.timedOut = true
+
+			l := fmt.Sprintf("Request exceeded the timeout of %s", timeout)
+			cfg.Logger.Errorf("%s: %s", l, r.URL.Path)
+
+			if tw.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusGatewayTimeout)
+			_ = json.NewEncoder(w).Encode(&models.QueryResponse{Error: l})
+		})
+	}
+}
+
+// The handler is given its own headers, so that these can be modified while
+// the timeout response is being written. These are only copied over once the
+// handler starts writing the response.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+var (
+	_ http.ResponseWriter = (*timeoutWriter)(nil)
+	_ http.Flusher        = (*timeoutWriter)(nil)
+)
+
+func (t *timeoutWriter) Header() http.Header {
+	return t.header
+}
+
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	t.writeHeader(http.StatusOK)
+
+	return t.w.Write(b)
+}
+
+func (t *timeoutWriter) WriteHeader(code int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timedOut {
+		return
+	}
+	t.writeHeader(code)
+}
+
+func (t *timeoutWriter) Flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timedOut {
+		return
+	}
+	if f, ok := t.w.(http.Flusher); ok {
+		t.writeHeader(http.StatusOK)
+		f.Flush()
+	}
+}
+
+func (t *timeoutWriter) writeHeader(code int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+
+	dst := t.w.Header()
+	for k, v := range t.header {
+		dst[k] = v
+	}
+	t.w.WriteHeader(code)
+}
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/limits"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       time.Duration
+		handler     func(w http.ResponseWriter, r *http.Request)
+		code        int
+		body        string
+		log         string
+	}{
+		{
+			"request timeout not set",
+			0,
+			func(w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Deadline()
+				assert.False(t, ok)
+				_, _ = w.Write([]byte("test"))
+			},
+			200,
+			`test`,
+			``,
+		},
+		{
+			"request completed within timeout",
+			time.Minute,
+			func(w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Deadline()
+				assert.True(t, ok)
+				w.Header().Set("X-Test", "test")
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("test"))
+			},
+			201,
+			`test`,
+			``,
+		},
+		{
+			"request exceeding timeout",
+			10 * time.Millisecond,
+			func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(50 * time.Millisecond)
+				w.Header().Set("X-Test", "test")
+				_, _ = w.Write([]byte("test"))
+			},
+			504,
+			`{"result":null,"error":"Request exceeded the timeout of 10ms"}`,
+			`Request exceeded the timeout of 10ms: /`,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			var output bytes.Buffer
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+			logger := test.DummyLogger(&output).Sugar()
+
+			expected := &gabi.Config{Logger: logger, LimitsEnv: &limits.Env{RequestTimeout: tc.given}}
+			RequestTimeout(expected)(http.HandlerFunc(tc.handler)).ServeHTTP(w, r)
+
+			actual := w.Result()
+			defer func() { _ = actual.Body.Close() }()
+
+			var body bytes.Buffer
+			_, _ = body.ReadFrom(actual.Body)
+
+			assert.Equal(t, tc.code, actual.StatusCode)
+			assert.Contains(t, body.String(), tc.body)
+			assert.Contains(t, output.String(), tc.log)
+			if tc.code == http.StatusGatewayTimeout {
+				assert.Empty(t, actual.Header.Get("X-Test"))
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutStreaming(t *testing.T) {
+	t.Parallel()
+
+	logger := test.DummyLogger(&bytes.Buffer{}).Sugar()
+
+	cfg := &gabi.Config{Logger: logger, LimitsEnv: &limits.Env{RequestTimeout: 10 * time.Millisecond}}
+
+	written := make(chan error, 1)
+	handler := RequestTimeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("test"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		// The stream is only aborted once the timeout response is handled.
+		time.Sleep(10 * time.Millisecond)
+		_, err := w.Write([]byte("test"))
+		written <- err
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(w, r)
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test", w.Body.String())
+	assert.ErrorIs(t, <-written, http.ErrHandlerTimeout)
+}
+
+func TestRequestTimeoutPanic(t *testing.T) {
+	t.Parallel()
+
+	logger := test.DummyLogger(&bytes.Buffer{}).Sugar()
+
+	cfg := &gabi.Config{Logger: logger, LimitsEnv: &limits.Env{RequestTimeout: time.Minute}}
+
+	handler := RequestTimeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("test")
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+
+	assert.PanicsWithValue(t, "test", func() {
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func TestRequestTimeoutCancellation(t *testing.T) {
+	t.Parallel()
+
+	logger := test.DummyLogger(&bytes.Buffer{}).Sugar()
+
+	cfg := &gabi.Config{Logger: logger, LimitsEnv: &limits.Env{RequestTimeout: 10 * time.Millisecond}}
+
+	cancelled := make(chan error, 1)
+	handler := RequestTimeout(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		cancelled <- r.Context().Err()
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", &bytes.Buffer{})
+	handler.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.ErrorIs(t, <-cancelled, context.DeadlineExceeded)
+}
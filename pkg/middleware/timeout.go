@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	gabi "github.com/app-sre/gabi/pkg"
+)
+
+// QueryTimeout returns the timeout applied to the query, taking into account
+// the timeout requested using the "timeout" query parameter, together with a
+// notice for the client when the requested timeout had to be capped.
+func QueryTimeout(cfg *gabi.Config, r *http.Request) (time.Duration, string, error) {
+	if cfg.DBEnv == nil {
+		return 0, "", nil
+	}
+
+	var requested time.Duration
+	if s := r.URL.Query().Get("timeout"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return 0, "", fmt.Errorf("unable to parse query timeout: %s", s)
+		}
+		requested = d
+	}
+
+	timeout, capped := cfg.DBEnv.EffectiveTimeout(requested)
+	if capped {
+		return timeout, fmt.Sprintf("The requested query timeout exceeds the maximum allowed, using %s instead", timeout), nil
+	}
+	return timeout, "", nil
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/env/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       *db.Env
+		query       string
+		expected    time.Duration
+		notice      string
+		error       bool
+	}{
+		{
+			"no timeouts set",
+			&db.Env{},
+			"",
+			0,
+			``,
+			false,
+		},
+		{
+			"default timeout",
+			&db.Env{QueryTimeout: 30 * time.Second},
+			"",
+			30 * time.Second,
+			``,
+			false,
+		},
+		{
+			"requested timeout within maximum",
+			&db.Env{QueryTimeout: 30 * time.Second, MaxQueryTimeout: 5 * time.Minute},
+			"timeout=2m",
+			2 * time.Minute,
+			``,
+			false,
+		},
+		{
+			"requested timeout capped at maximum",
+			&db.Env{QueryTimeout: 30 * time.Second, MaxQueryTimeout: 5 * time.Minute},
+			"timeout=1h",
+			5 * time.Minute,
+			`The requested query timeout exceeds the maximum allowed, using 5m0s instead`,
+			false,
+		},
+		{
+			"invalid requested timeout",
+			&db.Env{QueryTimeout: 30 * time.Second},
+			"timeout=test",
+			0,
+			``,
+			true,
+		},
+		{
+			"negative requested timeout",
+			&db.Env{QueryTimeout: 30 * time.Second},
+			"timeout=-1s",
+			0,
+			``,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/?"+tc.query, http.NoBody)
+
+			actual, notice, err := QueryTimeout(&gabi.Config{DBEnv: tc.given}, r)
+
+			if tc.error {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.notice, notice)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/telemetry"
+)
+
+func Tracing(cfg *gabi.Config) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			ctx, span := telemetry.Tracer().Start(ctx, fmt.Sprintf("%s %s", r.Method, r.URL.Path),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPTargetKey.String(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			if id, ok := ctx.Value(ContextKeyRequestID).(string); ok {
+				span.SetAttributes(telemetry.RequestIDKey.String(id))
+			}
+
+			m := httpsnoop.CaptureMetrics(h, w, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(m.Code))
+			if m.Code >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(m.Code))
+			}
+		})
+	}
+}
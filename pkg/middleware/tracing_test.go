@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/app-sre/gabi/internal/test"
+	gabi "github.com/app-sre/gabi/pkg"
+	"github.com/app-sre/gabi/pkg/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer func() { _ = provider.Shutdown(context.Background()) }()
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	cases := []struct {
+		description string
+		given       http.HandlerFunc
+		parent      string
+		code        int
+		status      codes.Code
+	}{
+		{
+			"successful request without trace context",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			}),
+			``,
+			200,
+			codes.Unset,
+		},
+		{
+			"successful request with trace context",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// No-op.
+			}),
+			`00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`,
+			200,
+			codes.Unset,
+		},
+		{
+			"failed request with trace context",
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "test", http.StatusInternalServerError)
+			}),
+			`00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01`,
+			500,
+			codes.Error,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/query", &bytes.Buffer{})
+			if tc.parent != "" {
+				r.Header.Set("Traceparent", tc.parent)
+			}
+
+			logger := test.DummyLogger(io.Discard).Sugar()
+
+			expected := &gabi.Config{Logger: logger}
+			chain := RequestID(expected)(Tracing(expected)(tc.given))
+			chain.ServeHTTP(w, r)
+
+			var span sdktrace.ReadOnlySpan
+			for _, s := range recorder.Ended() {
+				if s.Name() == "POST /query" {
+					span = s
+				}
+			}
+			require.NotNil(t, span)
+
+			if tc.parent != "" {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+			}
+
+			assert.Contains(t, span.Attributes(), telemetry.RequestIDKey.String(w.Result().Header.Get(requestIDHeader)))
+			assert.Contains(t, span.Attributes(), attribute.Int("http.status_code", tc.code))
+			assert.Equal(t, tc.status, span.Status().Code)
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Versions of the envelope of query responses. The first one is the original
+// format, with column names as the first row of the results.
+const (
+	APIVersion1 = 1
+	APIVersion2 = 2
+
+	defaultAPIVersion = APIVersion1
+)
+
+// APIVersion returns the version of the response envelope requested using the
+// "api_version" query parameter, or else using the "version" parameter of the
+// JSON media type in the Accept header, so that clients can pin the format.
+func APIVersion(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("api_version")
+	if s == "" {
+		s = acceptVersion(r)
+	}
+	if s == "" {
+		return defaultAPIVersion, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "v"))
+	if err != nil || version < APIVersion1 || version > APIVersion2 {
+		return 0, fmt.Errorf("unsupported API version: %s", s)
+	}
+	return version, nil
+}
+
+func acceptVersion(r *http.Request) string {
+	for _, value := range r.Header.Values("Accept") {
+		for _, s := range strings.Split(value, ",") {
+			media, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+			if err != nil || media != "application/json" {
+				continue
+			}
+			if version, ok := params["version"]; ok {
+				return version
+			}
+		}
+	}
+	return ""
+}
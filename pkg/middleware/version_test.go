@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIVersion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		url         string
+		accept      string
+		expected    int
+		error       bool
+	}{
+		{
+			"no version requested",
+			"/",
+			"",
+			APIVersion1,
+			false,
+		},
+		{
+			"version requested using query parameter",
+			"/?api_version=2",
+			"",
+			APIVersion2,
+			false,
+		},
+		{
+			"version with prefix requested using query parameter",
+			"/?api_version=v1",
+			"",
+			APIVersion1,
+			false,
+		},
+		{
+			"version requested using Accept header",
+			"/",
+			"text/plain, application/json; version=2",
+			APIVersion2,
+			false,
+		},
+		{
+			"JSON media type without version",
+			"/",
+			"application/json",
+			APIVersion1,
+			false,
+		},
+		{
+			"version of other media type",
+			"/",
+			"application/x-ndjson; version=2",
+			APIVersion1,
+			false,
+		},
+		{
+			"query parameter taking precedence over Accept header",
+			"/?api_version=1",
+			"application/json; version=2",
+			APIVersion1,
+			false,
+		},
+		{
+			"unsupported version",
+			"/?api_version=3",
+			"",
+			0,
+			true,
+		},
+		{
+			"invalid version requested using Accept header",
+			"/",
+			"application/json; version=test",
+			0,
+			true,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.description, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, tc.url, nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+
+			actual, err := APIVersion(r)
+
+			if tc.error {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "unsupported API version")
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
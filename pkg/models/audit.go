@@ -0,0 +1,17 @@
+package models
+
+// AuditPreviewRequest holds the query, together with the context it would be
+// submitted in, whose audit event is previewed. The user and client IP default
+// to those of the request, and the event describes the outcome of the query
+// once executed, or its rejection.
+type AuditPreviewRequest struct {
+	Query     string            `json:"query"`
+	Args      []interface{}     `json:"args,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	User      string            `json:"user,omitempty"`
+	ClientIP  string            `json:"client_ip,omitempty"`
+	Executed  bool              `json:"executed,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Rejection string            `json:"rejection,omitempty"`
+	Database  string            `json:"database,omitempty"`
+}
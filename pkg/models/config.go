@@ -0,0 +1,53 @@
+package models
+
+type ConfigResponse struct {
+	Database   DatabaseConfig `json:"database"`
+	Audit      AuditConfig    `json:"audit"`
+	Users      UsersConfig    `json:"users"`
+	Policy     PolicyConfig   `json:"policy"`
+	Limits     LimitsConfig   `json:"limits"`
+	Production bool           `json:"production"`
+}
+
+type DatabaseConfig struct {
+	Driver     string `json:"driver"`
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Name       string `json:"name"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	AllowWrite bool   `json:"allow_write"`
+
+	Databases []string `json:"databases,omitempty"`
+}
+
+type AuditConfig struct {
+	Backend     string            `json:"backend"`
+	Endpoint    string            `json:"endpoint"`
+	Endpoints   []string          `json:"endpoints,omitempty"`
+	Token       string            `json:"token"`
+	Index       string            `json:"index"`
+	IndexMap    map[string]string `json:"index_map,omitempty"`
+	HealthCheck bool              `json:"health_check"`
+}
+
+type UsersConfig struct {
+	Expiration  string   `json:"expiration"`
+	Expired     bool     `json:"expired"`
+	WarningDays int      `json:"warning_days"`
+	GraceDays   int      `json:"grace_days,omitempty"`
+	Users       []string `json:"users"`
+}
+
+type PolicyConfig struct {
+	Allow       []string `json:"allow"`
+	Deny        []string `json:"deny"`
+	Mask        []string `json:"mask,omitempty"`
+	Statements  []string `json:"statements,omitempty"`
+	LargeTables []string `json:"large_tables,omitempty"`
+}
+
+type LimitsConfig struct {
+	RatePerMinute int `json:"rate_per_minute"`
+	RateBurst     int `json:"rate_burst"`
+}
@@ -0,0 +1,8 @@
+package models
+
+import "time"
+
+type ExpirationResponse struct {
+	Error      string    `json:"error"`
+	Expiration time.Time `json:"expiration"`
+}
@@ -1,10 +1,51 @@
 package models
 
 type QueryRequest struct {
-	Query string `json:"query"`
+	Query    string            `json:"query"`
+	Args     []interface{}     `json:"args,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Database string            `json:"database,omitempty"`
 }
 
 type QueryResponse struct {
-	Result [][]string `json:"result"`
-	Error  string     `json:"error"`
+	Result  [][]interface{}  `json:"result"`
+	Error   string           `json:"error"`
+	Warning string           `json:"warning,omitempty"`
+	Columns []Column         `json:"columns,omitempty"`
+	Partial *PartialResult   `json:"partial,omitempty"`
+	Status  *StatementStatus `json:"status,omitempty"`
+
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// QueryResponseV2 is the second version of the response envelope, in which
+// the columns, with their types, are kept apart from the rows of the results.
+type QueryResponseV2 struct {
+	Rows    [][]interface{}  `json:"rows"`
+	Columns []Column         `json:"columns"`
+	Error   string           `json:"error,omitempty"`
+	Warning string           `json:"warning,omitempty"`
+	Partial *PartialResult   `json:"partial,omitempty"`
+	Status  *StatementStatus `json:"status,omitempty"`
+
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// StatementStatus is returned in place of column names for statements, such
+// as SET or CALL, that do not return any results.
+type StatementStatus struct {
+	Message string `json:"message"`
+}
+
+// PartialResult describes the row, counted from zero not including the column
+// names, at which reading the results failed.
+type PartialResult struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type Column struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable *bool  `json:"nullable,omitempty"`
 }
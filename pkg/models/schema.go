@@ -0,0 +1,11 @@
+package models
+
+type SchemaResponse struct {
+	Tables []Table `json:"tables"`
+}
+
+type Table struct {
+	Schema  string   `json:"schema"`
+	Name    string   `json:"name"`
+	Columns []Column `json:"columns"`
+}
@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/app-sre/gabi/pkg/env/tracing"
+	"github.com/app-sre/gabi/pkg/version"
+)
+
+const (
+	tracerName  = "github.com/app-sre/gabi"
+	serviceName = "gabi"
+
+	userHashLength = 16
+)
+
+const (
+	RequestIDKey = attribute.Key("gabi.request_id")
+	UserHashKey  = attribute.Key("gabi.user_hash")
+	QueryHashKey = attribute.Key("gabi.query_hash")
+)
+
+// Setup configures the global tracer provider to export spans using OTLP when
+// tracing is enabled. Incoming W3C trace context is always propagated.
+func Setup(ctx context.Context, env *tracing.Env, namespace string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	if !env.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(version.Version()),
+			semconv.K8SNamespaceNameKey.String(namespace),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// SetUser attaches the user to the current span, hashed so that the identity
+// is not disclosed to the tracing backend.
+func SetUser(ctx context.Context, user string) {
+	sum := sha256.Sum256([]byte(user))
+	trace.SpanFromContext(ctx).SetAttributes(UserHashKey.String(hex.EncodeToString(sum[:])[:userHashLength]))
+}
@@ -1,7 +1,50 @@
 package version
 
-var version = "0.1.0"
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const unknown = "unknown"
+
+// Set at build time using the -ldflags "-X" option.
+var (
+	version = "0.1.0"
+	commit  = ""
+	date    = ""
+)
 
 func Version() string {
 	return version
 }
+
+// Commit returns the Git commit the binary was built from, falling back to the
+// version control information embedded by the Go toolchain.
+func Commit() string {
+	if commit != "" {
+		return commit
+	}
+	return buildSetting("vcs.revision")
+}
+
+func BuildDate() string {
+	if date != "" {
+		return date
+	}
+	return buildSetting("vcs.time")
+}
+
+func GoVersion() string {
+	return runtime.Version()
+}
+
+func buildSetting(key string) string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == key && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+	return unknown
+}
@@ -141,6 +141,8 @@ func setEnvironment(configFile, dbHost, dbPort, dbWrite, splunkToken, splunkEndp
 	os.Setenv("NAMESPACE", "test")
 	os.Setenv("POD_NAME", "test")
 
+	os.Setenv("PROXY_TRUSTED_CIDRS", "127.0.0.1,::1")
+
 	if configFile != "" {
 		os.Setenv("CONFIG_FILE_PATH", configFile)
 	}
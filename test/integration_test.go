@@ -252,7 +252,7 @@ func TestQueryWithExpiredInstance(t *testing.T) {
 	}
 
 	assert.Contains(t, output.String(), `expired: true`)
-	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
 	assert.Contains(t, string(body), `The service instance has expired`)
 }
 